@@ -2,16 +2,28 @@ package XMLconfig
 
 import (
 	"encoding/xml"
-	"fmt"
 	"io/ioutil"
-	"log"
 	"strings"
+
+	"github.com/STEJLS/AudioServer/i18n"
 )
 
 // Config - это основная структура для парсинга xml файла
 type Config struct {
-	HTTP Http     `xml:"http"`
-	Db   DataBase `xml:"DataBase"`
+	HTTP            Http            `xml:"http"`
+	Db              DataBase        `xml:"DataBase"`
+	ReplayGain      ReplayGain      `xml:"ReplayGain"`
+	Fingerprint     Fingerprint     `xml:"Fingerprint"`
+	Storage         Storage         `xml:"Storage"`
+	Upload          Upload          `xml:"Upload"`
+	PlaylistArchive PlaylistArchive `xml:"PlaylistArchive"`
+	Scrubber        Scrubber        `xml:"Scrubber"`
+	Trash           Trash           `xml:"Trash"`
+	Charts          Charts          `xml:"Charts"`
+	Plays           Plays           `xml:"Plays"`
+	Pagination      Pagination      `xml:"Pagination"`
+	Errors          Errors          `xml:"Errors"`
+	Log             Log             `xml:"Log"`
 }
 
 // Http - это структура для парсинга
@@ -26,9 +38,107 @@ type Http struct {
 // информации об базеданных из xml файла
 type DataBase struct {
 	XMLName xml.Name `xml:"DataBase"`
+	Driver  string   `xml:"driver,attr"` // mongodb (по умолчанию) или sqlite
 	Host    string   `xml:"host"`
 	Name    string   `xml:"name"`
 	Port    int      `xml:"port"`
+	URI     string   `xml:"uri"`  // строка подключения, например mongodb+srv://..., если задана, то host и port не используются
+	Path    string   `xml:"path"` // файл базы данных для драйвера sqlite
+}
+
+// ReplayGain - это структура для парсинга
+// настроек фонового расчета ReplayGain из xml файла
+type ReplayGain struct {
+	XMLName   xml.Name `xml:"ReplayGain"`
+	Interval  int      `xml:"interval,attr"`  // период запуска в минутах, 0 - значение по умолчанию
+	WriteTags bool     `xml:"writeTags,attr"` // записывать ли результаты в тэги файлов
+}
+
+// Fingerprint - это структура для парсинга
+// настроек поиска дубликатов по акустическим отпечаткам из xml файла
+type Fingerprint struct {
+	XMLName   xml.Name `xml:"Fingerprint"`
+	Threshold float64  `xml:"threshold,attr"` // минимальное сходство дубликатов от 0 до 1, 0 - значение по умолчанию
+}
+
+// Storage - это структура для парсинга
+// настроек хранилища файлов песен из xml файла
+type Storage struct {
+	XMLName   xml.Name `xml:"Storage"`
+	Driver    string   `xml:"driver,attr"`    // local (по умолчанию) или s3
+	Directory string   `xml:"directory,attr"` // каталог для драйвера local
+	Endpoint  string   `xml:"endpoint,attr"`  // адрес S3-совместимого сервера, например http://localhost:9000
+	Bucket    string   `xml:"bucket,attr"`
+	Region    string   `xml:"region,attr"`
+	AccessKey string   `xml:"accessKey,attr"`
+	SecretKey string   `xml:"secretKey,attr"`
+}
+
+// Upload - это структура для парсинга
+// ограничений на загружаемые файлы из xml файла
+type Upload struct {
+	XMLName xml.Name `xml:"Upload"`
+	MaxSize int64    `xml:"maxSize,attr"` // максимальный размер файла в мегабайтах, 0 - значение по умолчанию
+}
+
+// PlaylistArchive - это структура для парсинга
+// настроек архивов плейлистов из xml файла
+type PlaylistArchive struct {
+	XMLName      xml.Name `xml:"PlaylistArchive"`
+	NameTemplate string   `xml:"nameTemplate,attr"` // шаблон имен файлов песен, например "{index} - {artist} - {title}.{ext}"
+}
+
+// Scrubber - это структура для парсинга
+// настроек фоновой проверки хранилища из xml файла
+type Scrubber struct {
+	XMLName  xml.Name `xml:"Scrubber"`
+	Interval int      `xml:"interval,attr"` // период запуска в минутах, 0 - значение по умолчанию
+	Mode     string   `xml:"mode,attr"`     // dryrun (по умолчанию), quarantine или repair
+}
+
+// Trash - это структура для парсинга
+// настроек корзины из xml файла
+type Trash struct {
+	XMLName   xml.Name `xml:"Trash"`
+	Retention int      `xml:"retention,attr"` // сколько дней удаленные записи можно восстановить, 0 - значение по умолчанию
+	Interval  int      `xml:"interval,attr"`  // период очистки корзины в минутах, 0 - значение по умолчанию
+}
+
+// Charts - это структура для парсинга
+// настроек расчета чартов из xml файла
+type Charts struct {
+	XMLName  xml.Name `xml:"Charts"`
+	Interval int      `xml:"interval,attr"` // период расчета в минутах, 0 - значение по умолчанию
+}
+
+// Plays - это структура для парсинга
+// настроек хранения событий прослушиваний из xml файла
+type Plays struct {
+	XMLName   xml.Name `xml:"Plays"`
+	Retention int      `xml:"retention,attr"` // сколько дней хранятся события прослушиваний, 0 - значение по умолчанию
+	Interval  int      `xml:"interval,attr"`  // период удаления устаревших событий в минутах, 0 - значение по умолчанию
+}
+
+// Pagination - это структура для парсинга
+// настроек постраничной отдачи списков из xml файла
+type Pagination struct {
+	XMLName     xml.Name `xml:"Pagination"`
+	DefaultSize int      `xml:"defaultSize,attr"` // размер страницы, если клиент его не указал, 0 - значение по умолчанию
+	MaxSize     int      `xml:"maxSize,attr"`     // максимальный размер страницы, 0 - значение по умолчанию
+}
+
+// Errors - это структура для парсинга
+// настроек ответов с ошибками из xml файла
+type Errors struct {
+	XMLName xml.Name `xml:"Errors"`
+	Format  string   `xml:"format,attr"` // формат ошибок API первой версии: json или text (простой текст для старых клиентов), пустой - json
+}
+
+// Log - это структура для парсинга
+// настроек логов из xml файла
+type Log struct {
+	XMLName xml.Name `xml:"Log"`
+	Lang    string   `xml:"lang,attr"` // язык логов: ru или en, пустой - ru
 }
 
 // Get - это функция парсит xml конфиг, находящийся в файле "source"
@@ -36,39 +146,128 @@ type DataBase struct {
 func Get(source string) Config {
 	data, err := ioutil.ReadFile(source)
 	if err != nil {
-		log.Fatalln(fmt.Sprintf("Фатал. При открытии xml файла(%v) для парсинга: ", source) + err.Error())
+		i18n.Fatal("config.open_error", source, err)
 	}
 
 	var config Config
 	err = xml.Unmarshal(data, &config)
 	if err != nil {
-		log.Fatalln(fmt.Sprintf("Фатал. При анмаршалинге xml файла(%q): ", source) + err.Error())
+		i18n.Fatal("config.unmarshal_error", source, err)
 	}
 
-	log.Printf("Инфо. Файл %q успешно расперсен.", source)
+	// Язык логов задается до остальных сообщений, чтобы они выводились уже на нем
+	if config.Log.Lang != "" {
+		if !i18n.Supported(config.Log.Lang) {
+			i18n.Fatal("config.unknown_log_lang", config.Log.Lang)
+		}
+		i18n.SetLogLanguage(config.Log.Lang)
+	}
 
-	err = validating(config)
-	if err != nil {
-		log.Fatalln(err)
+	i18n.Info("config.parsed", source)
+
+	message := validating(config)
+	if message != nil {
+		i18n.Fatal(message.Key, message.Args...)
 	}
 
 	return config
 }
 
 // Validating - это функция которая проверяет введенную информацию из конфига
-func validating(config Config) error {
+func validating(config Config) *i18n.Message {
 	if config.HTTP.Port < 1024 || config.HTTP.Port >= 65535 {
-		return fmt.Errorf("Фатал. Не валидный номер http порта(от 1024 до 65535), а вы ввели %v", config.HTTP.Port)
+		return i18n.Errorf("config.invalid_http_port", config.HTTP.Port)
+	}
+
+	switch config.Db.Driver {
+	case "", "mongodb":
+		if strings.ContainsAny(config.Db.Name, "/\\.\"*<>:|?$,'") {
+			return i18n.Errorf("config.invalid_db_name", config.Db.Name)
+		}
+
+		if config.Db.URI == "" && (config.Db.Port < 1024 || config.Db.Port >= 65535) {
+			return i18n.Errorf("config.invalid_db_port", config.Db.Port)
+		}
+	case "sqlite":
+		if config.Db.Path == "" {
+			return i18n.Errorf("config.sqlite_path_required")
+		}
+	default:
+		return i18n.Errorf("config.unknown_db_driver", config.Db.Driver)
+	}
+
+	if config.ReplayGain.Interval < 0 {
+		return i18n.Errorf("config.invalid_replaygain_interval", config.ReplayGain.Interval)
+	}
+
+	if config.Fingerprint.Threshold < 0 || config.Fingerprint.Threshold > 1 {
+		return i18n.Errorf("config.invalid_fingerprint_threshold", config.Fingerprint.Threshold)
+	}
+
+	switch config.Storage.Driver {
+	case "", "local":
+	case "s3":
+		if config.Storage.Endpoint == "" || config.Storage.Bucket == "" {
+			return i18n.Errorf("config.s3_required")
+		}
+	default:
+		return i18n.Errorf("config.unknown_storage_driver", config.Storage.Driver)
+	}
+
+	if config.Upload.MaxSize < 0 || config.Upload.MaxSize > 1<<20 {
+		return i18n.Errorf("config.invalid_max_upload", 1<<20, config.Upload.MaxSize)
+	}
+
+	if config.PlaylistArchive.NameTemplate != "" && !strings.Contains(config.PlaylistArchive.NameTemplate, "{index}") {
+		return i18n.Errorf("config.invalid_name_template", config.PlaylistArchive.NameTemplate)
+	}
+
+	if config.Scrubber.Interval < 0 {
+		return i18n.Errorf("config.invalid_scrub_interval", config.Scrubber.Interval)
+	}
+
+	switch config.Scrubber.Mode {
+	case "", "dryrun", "quarantine", "repair":
+	default:
+		return i18n.Errorf("config.unknown_scrub_mode", config.Scrubber.Mode)
+	}
+
+	if config.Trash.Retention < 0 {
+		return i18n.Errorf("config.invalid_trash_retention", config.Trash.Retention)
+	}
+
+	if config.Trash.Interval < 0 {
+		return i18n.Errorf("config.invalid_trash_interval", config.Trash.Interval)
+	}
+
+	if config.Charts.Interval < 0 {
+		return i18n.Errorf("config.invalid_charts_interval", config.Charts.Interval)
+	}
+
+	if config.Plays.Retention < 0 {
+		return i18n.Errorf("config.invalid_play_retention", config.Plays.Retention)
+	}
+
+	if config.Plays.Interval < 0 {
+		return i18n.Errorf("config.invalid_play_interval", config.Plays.Interval)
+	}
+
+	if config.Pagination.DefaultSize < 0 {
+		return i18n.Errorf("config.invalid_default_page_size", config.Pagination.DefaultSize)
+	}
+
+	if config.Pagination.MaxSize < 0 {
+		return i18n.Errorf("config.invalid_max_page_size", config.Pagination.MaxSize)
 	}
 
-	if strings.ContainsAny(config.Db.Name, "/\\.\"*<>:|?$,'") {
-		return fmt.Errorf("Фатал. Не валидное имя базы данных(не должно быть символов /, \\, ., \", *, <, >, :, |, ?, $), введено: %q", config.Db.Name)
+	if config.Pagination.MaxSize != 0 && config.Pagination.DefaultSize > config.Pagination.MaxSize {
+		return i18n.Errorf("config.default_page_size_too_large", config.Pagination.DefaultSize, config.Pagination.MaxSize)
 	}
 
-	if config.Db.Port < 1024 || config.Db.Port >= 65535 {
-		return fmt.Errorf("Фатал. Не валидный номер http порта(от 1024 до 65535), а вы ввели %v", config.HTTP.Port)
+	if config.Errors.Format != "" && config.Errors.Format != "json" && config.Errors.Format != "text" {
+		return i18n.Errorf("config.invalid_errors_format", config.Errors.Format)
 	}
 
-	log.Printf("Инфо. Конфиг успешно прошел проверку.")
+	i18n.Info("config.valid")
 	return nil
 }
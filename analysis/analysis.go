@@ -0,0 +1,137 @@
+// Package analysis - анализ декодированного аудио: громкость по EBU R128,
+// истинный пик, ReplayGain, форма волны и акустический отпечаток.
+package analysis
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// Source - источник PCM отсчетов, например декодер mp3.
+// ReadFrame возвращает очередную порцию отсчетов по каналам в диапазоне [-1, 1]
+// и io.EOF в конце потока.
+type Source interface {
+	SampleRate() int
+	Channels() int
+	ReadFrame() ([][]float64, error)
+}
+
+// Loudness - результаты анализа громкости трека
+type Loudness struct {
+	Integrated float64 // интегральная громкость в LUFS
+	TruePeak   float64 // истинный пик в dBTP
+	TrackGain  float64 // ReplayGain трека в дБ
+	TrackPeak  float64 // пиковое значение отсчетов (1.0 - полная шкала)
+
+	blocks []float64 // мощности блоков 400 мс, нужны для расчета громкости альбома
+}
+
+// Album - результаты анализа громкости альбома
+type Album struct {
+	Integrated float64 // интегральная громкость всех треков альбома в LUFS
+	AlbumGain  float64 // ReplayGain альбома в дБ
+	AlbumPeak  float64 // максимальное пиковое значение среди треков альбома
+}
+
+var (
+	errBadSource          = errors.New("Некорректные параметры источника аудио")
+	errBadSamplesPerPixel = errors.New("Некорректный размер окна формы волны")
+)
+
+// Result - результаты полного анализа трека
+type Result struct {
+	Loudness    *Loudness
+	Waveform    *Waveform
+	Fingerprint Fingerprint
+}
+
+// AnalyzeLoudness - декодирует источник до конца и вычисляет параметры громкости
+func AnalyzeLoudness(src Source) (*Loudness, error) {
+	result, err := analyze(src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Loudness, nil
+}
+
+// Analyze - декодирует источник до конца, вычисляет параметры громкости,
+// пики формы волны по окнам из samplesPerPixel отсчетов и акустический отпечаток
+func Analyze(src Source, samplesPerPixel int) (*Result, error) {
+	if samplesPerPixel <= 0 {
+		return nil, errBadSamplesPerPixel
+	}
+
+	return analyze(src, samplesPerPixel)
+}
+
+// analyze - общий цикл декодирования. Если samplesPerPixel равен нулю, то вычисляется
+// только громкость, иначе еще форма волны и отпечаток.
+func analyze(src Source, samplesPerPixel int) (*Result, error) {
+	if src.SampleRate() <= 0 || src.Channels() <= 0 {
+		return nil, errBadSource
+	}
+
+	meter := newLoudnessMeter(src.SampleRate(), src.Channels())
+	peak := newTruePeakMeter(src.SampleRate(), src.Channels())
+	var waveform *waveformBuilder
+	var fingerprint *fingerprinter
+	if samplesPerPixel > 0 {
+		waveform = newWaveformBuilder(src.SampleRate(), samplesPerPixel)
+		fingerprint = newFingerprinter(src.SampleRate())
+	}
+
+	for {
+		frame, err := src.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		meter.process(frame)
+		peak.process(frame)
+		if waveform != nil {
+			waveform.process(frame)
+			fingerprint.process(frame)
+		}
+	}
+
+	result := &Result{
+		Loudness: &Loudness{
+			Integrated: meter.integrated(),
+			TruePeak:   toDecibels(peak.truePeak),
+			TrackPeak:  peak.samplePeak,
+			blocks:     meter.blocks,
+		},
+	}
+	result.Loudness.TrackGain = replayGain(result.Loudness.Integrated)
+
+	if waveform != nil {
+		result.Waveform = waveform.result()
+		result.Fingerprint = fingerprint.result()
+	}
+
+	return result, nil
+}
+
+// AnalyzeAlbum - вычисляет громкость альбома по результатам анализа его треков.
+// Альбом считается одним непрерывным треком: стробирование выполняется
+// по блокам всех треков сразу, поэтому длинные треки влияют на результат сильнее коротких.
+func AnalyzeAlbum(tracks []*Loudness) *Album {
+	var blocks []float64
+	var peak float64
+	for _, track := range tracks {
+		blocks = append(blocks, track.blocks...)
+		peak = math.Max(peak, track.TrackPeak)
+	}
+
+	integrated := gatedLoudness(blocks)
+	return &Album{
+		Integrated: integrated,
+		AlbumGain:  replayGain(integrated),
+		AlbumPeak:  peak,
+	}
+}
@@ -0,0 +1,37 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// fft - быстрое преобразование Фурье (итеративный алгоритм Кули-Тьюки по основанию 2).
+// Длина data должна быть степенью двойки, преобразование выполняется на месте.
+func fft(data []complex128) {
+	n := len(data)
+
+	// Перестановка с обращением порядка бит
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := data[start+k], w*data[start+k+size/2]
+				data[start+k] = even + odd
+				data[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package analysis
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Параметры акустического отпечатка. Схема повторяет Chromaprint: хромаграмма
+// моно сигнала 11025 Гц, сглаженная по времени, превращается набором из 16
+// классификаторов в 32-битные суботпечатки, по одному на кадр.
+const (
+	fingerprintSampleRate  = 11025
+	fingerprintFrameSize   = 4096
+	fingerprintHop         = fingerprintFrameSize / 3
+	fingerprintMaxDuration = 120 // секунд аудио, по которым строится отпечаток
+	chromaBands            = 12
+	chromaMinFreq          = 28.0
+	chromaMaxFreq          = 3520.0
+	chromaBaseFreq         = 440.0 / 16 // частота ноты ля субконтроктавы
+	chromaNormEpsilon      = 0.01
+
+	fingerprintMaxOffset  = 80 // максимальный сдвиг (в кадрах) при сравнении отпечатков
+	fingerprintMinOverlap = 40 // минимальное перекрытие (в кадрах) для сравнения
+)
+
+// Fingerprint - акустический отпечаток: последовательность 32-битных суботпечатков
+type Fingerprint []uint32
+
+// chromaFilterCoefficients - сглаживание хромаграммы по времени
+var chromaFilterCoefficients = [...]float64{0.25, 0.75, 1.0, 0.75, 0.25}
+
+// grayCode - код Грея для значений квантователя, соседние уровни отличаются одним битом
+var grayCode = [4]uint32{0, 1, 3, 2}
+
+// classifier - прямоугольный фильтр по области хромаграммы и пороги квантования его значения
+type classifier struct {
+	kind       int        // тип фильтра 0-5 (разбиение области на части)
+	band       int        // первая полоса хромаграммы
+	height     int        // количество полос
+	width      int        // количество кадров
+	thresholds [3]float64 // пороги квантования на 4 уровня
+}
+
+// fingerprintClassifiers - классификаторы Chromaprint (алгоритм 2)
+var fingerprintClassifiers = [16]classifier{
+	{0, 4, 3, 15, [3]float64{1.98215, 2.35817, 2.63523}},
+	{4, 4, 6, 15, [3]float64{-1.03809, -0.651211, -0.282167}},
+	{1, 0, 4, 16, [3]float64{-0.298702, 0.119262, 0.558497}},
+	{3, 8, 2, 12, [3]float64{-0.105439, 0.0153946, 0.135898}},
+	{3, 4, 4, 8, [3]float64{-0.142891, 0.0258736, 0.200632}},
+	{4, 0, 3, 5, [3]float64{-0.826319, -0.590612, -0.368214}},
+	{1, 2, 2, 9, [3]float64{-0.557409, -0.233035, 0.0534525}},
+	{2, 7, 3, 4, [3]float64{-0.0646826, 0.00620476, 0.0784847}},
+	{2, 6, 2, 16, [3]float64{-0.192387, -0.029699, 0.215855}},
+	{2, 1, 3, 2, [3]float64{-0.0397818, -0.00568076, 0.0292026}},
+	{5, 10, 1, 15, [3]float64{-0.53823, -0.369934, -0.190235}},
+	{3, 6, 2, 10, [3]float64{-0.124877, 0.0296483, 0.139239}},
+	{2, 1, 1, 14, [3]float64{-0.101475, 0.0225617, 0.231971}},
+	{3, 5, 6, 4, [3]float64{-0.0799915, -0.00729616, 0.063262}},
+	{1, 9, 2, 12, [3]float64{-0.272556, 0.019424, 0.302559}},
+	{3, 4, 2, 14, [3]float64{-0.164292, -0.0321188, 0.0846339}},
+}
+
+// fingerprinter - строит акустический отпечаток по потоку отсчетов
+type fingerprinter struct {
+	resampler *resampler
+	remaining int         // сколько еще отсчетов 11025 Гц нужно обработать
+	buffer    []float64   // отсчеты, еще не вошедшие в полный кадр
+	window    []float64   // окно Хэмминга
+	notes     []int       // номер полосы хромаграммы для каждого бина спектра (-1 - бин не используется)
+	raw       [][]float64 // последние кадры хромаграммы до сглаживания
+	image     [][]float64 // сглаженная и нормированная хромаграмма
+}
+
+// newFingerprinter - конструктор для типа fingerprinter
+func newFingerprinter(sampleRate int) *fingerprinter {
+	f := &fingerprinter{
+		resampler: newResampler(sampleRate, fingerprintSampleRate),
+		remaining: fingerprintMaxDuration * fingerprintSampleRate,
+		window:    make([]float64, fingerprintFrameSize),
+		notes:     make([]int, fingerprintFrameSize/2),
+	}
+
+	for i := range f.window {
+		f.window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(fingerprintFrameSize-1))
+	}
+
+	for i := range f.notes {
+		freq := float64(i) * fingerprintSampleRate / fingerprintFrameSize
+		if freq < chromaMinFreq || freq >= chromaMaxFreq {
+			f.notes[i] = -1
+			continue
+		}
+		octave := math.Log2(freq / chromaBaseFreq)
+		f.notes[i] = int(chromaBands * (octave - math.Floor(octave)))
+	}
+
+	return f
+}
+
+// process - обрабатывает порцию отсчетов по каналам (каналы смешиваются в моно)
+func (f *fingerprinter) process(frame [][]float64) {
+	if len(frame) == 0 || f.remaining <= 0 {
+		return
+	}
+
+	mono := make([]float64, len(frame[0]))
+	for _, channel := range frame {
+		for i, s := range channel {
+			mono[i] += s / float64(len(frame))
+		}
+	}
+
+	samples := f.resampler.process(mono)
+	if len(samples) > f.remaining {
+		samples = samples[:f.remaining]
+	}
+	f.remaining -= len(samples)
+	f.buffer = append(f.buffer, samples...)
+
+	for len(f.buffer) >= fingerprintFrameSize {
+		f.processFrame(f.buffer[:fingerprintFrameSize])
+		f.buffer = f.buffer[fingerprintHop:]
+	}
+}
+
+// processFrame - вычисляет хромаграмму одного кадра и добавляет ее в изображение
+func (f *fingerprinter) processFrame(samples []float64) {
+	spectrum := make([]complex128, fingerprintFrameSize)
+	for i, s := range samples {
+		spectrum[i] = complex(s*f.window[i], 0)
+	}
+	fft(spectrum)
+
+	chroma := make([]float64, chromaBands)
+	for i, note := range f.notes {
+		if note >= 0 {
+			re, im := real(spectrum[i]), imag(spectrum[i])
+			chroma[note] += re*re + im*im
+		}
+	}
+
+	f.raw = append(f.raw, chroma)
+	if len(f.raw) < len(chromaFilterCoefficients) {
+		return
+	}
+
+	smoothed := make([]float64, chromaBands)
+	for i, c := range chromaFilterCoefficients {
+		for band := range smoothed {
+			smoothed[band] += c * f.raw[i][band]
+		}
+	}
+	f.raw = f.raw[1:]
+
+	var norm float64
+	for _, v := range smoothed {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	for band := range smoothed {
+		if norm < chromaNormEpsilon {
+			smoothed[band] = 0
+		} else {
+			smoothed[band] /= norm
+		}
+	}
+
+	f.image = append(f.image, smoothed)
+}
+
+// result - применяет классификаторы к хромаграмме и возвращает отпечаток
+func (f *fingerprinter) result() Fingerprint {
+	integral := newIntegralImage(f.image)
+
+	maxWidth := 0
+	for _, c := range fingerprintClassifiers {
+		if c.width > maxWidth {
+			maxWidth = c.width
+		}
+	}
+
+	var fingerprint Fingerprint
+	for x := 0; x+maxWidth <= len(f.image); x++ {
+		var value uint32
+		for _, c := range fingerprintClassifiers {
+			value = value<<2 | grayCode[c.quantize(c.apply(integral, x))]
+		}
+		fingerprint = append(fingerprint, value)
+	}
+
+	return fingerprint
+}
+
+// integralImage - таблица сумм для быстрого расчета суммы по прямоугольной области
+type integralImage struct {
+	sums [][]float64 // sums[x][y] - сумма по кадрам [0, x) и полосам [0, y)
+}
+
+// newIntegralImage - конструктор для типа integralImage
+func newIntegralImage(image [][]float64) *integralImage {
+	sums := make([][]float64, len(image)+1)
+	sums[0] = make([]float64, chromaBands+1)
+	for x, row := range image {
+		sums[x+1] = make([]float64, chromaBands+1)
+		for y, v := range row {
+			sums[x+1][y+1] = v + sums[x][y+1] + sums[x+1][y] - sums[x][y]
+		}
+	}
+
+	return &integralImage{sums: sums}
+}
+
+// area - сумма по кадрам [x1, x2) и полосам [y1, y2)
+func (img *integralImage) area(x1, y1, x2, y2 int) float64 {
+	return img.sums[x2][y2] - img.sums[x1][y2] - img.sums[x2][y1] + img.sums[x1][y1]
+}
+
+// apply - значение фильтра для области, начинающейся с кадра x
+func (c *classifier) apply(img *integralImage, x int) float64 {
+	y, w, h := c.band, c.width, c.height
+	var a, b float64
+	switch c.kind {
+	case 0:
+		a = img.area(x, y, x+w, y+h)
+	case 1:
+		a = img.area(x, y+h/2, x+w, y+h)
+		b = img.area(x, y, x+w, y+h/2)
+	case 2:
+		a = img.area(x+w/2, y, x+w, y+h)
+		b = img.area(x, y, x+w/2, y+h)
+	case 3:
+		a = img.area(x, y+h/2, x+w/2, y+h) + img.area(x+w/2, y, x+w, y+h/2)
+		b = img.area(x, y, x+w/2, y+h/2) + img.area(x+w/2, y+h/2, x+w, y+h)
+	case 4:
+		a = img.area(x, y, x+w, y+h/3) + img.area(x, y+2*h/3, x+w, y+h)
+		b = img.area(x, y+h/3, x+w, y+2*h/3)
+	case 5:
+		a = img.area(x, y, x+w/3, y+h) + img.area(x+2*w/3, y, x+w, y+h)
+		b = img.area(x+w/3, y, x+2*w/3, y+h)
+	}
+
+	return math.Log(1+a) - math.Log(1+b)
+}
+
+// quantize - переводит значение фильтра в один из 4 уровней
+func (c *classifier) quantize(value float64) int {
+	switch {
+	case value < c.thresholds[0]:
+		return 0
+	case value < c.thresholds[1]:
+		return 1
+	case value < c.thresholds[2]:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Similarity - сходство отпечатков от 0 до 1: доля совпадающих бит при наилучшем
+// взаимном сдвиге (не больше 10 секунд). Если перекрытие слишком короткое, возвращает 0.
+func (fp Fingerprint) Similarity(other Fingerprint) float64 {
+	best := 0.0
+	for offset := -fingerprintMaxOffset; offset <= fingerprintMaxOffset; offset++ {
+		// Сравниваются fp[i] и other[i+offset]
+		start, end := 0, len(fp)
+		if offset < 0 {
+			start = -offset
+		}
+		if len(other)-offset < end {
+			end = len(other) - offset
+		}
+		if end-start < fingerprintMinOverlap {
+			continue
+		}
+
+		mismatches := 0
+		for i := start; i < end; i++ {
+			mismatches += bits.OnesCount32(fp[i] ^ other[i+offset])
+		}
+
+		similarity := 1 - float64(mismatches)/float64(32*(end-start))
+		if similarity > best {
+			best = similarity
+		}
+	}
+
+	return best
+}
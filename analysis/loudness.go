@@ -0,0 +1,205 @@
+package analysis
+
+import "math"
+
+const (
+	absoluteGate      = -70.0 // абсолютный порог стробирования в LUFS
+	relativeGate      = -10.0 // относительный порог стробирования в LU
+	replayGainTarget  = -18.0 // опорная громкость ReplayGain 2.0 в LUFS
+	maxReplayGain     = 51.0  // ограничение значения ReplayGain в дБ
+	blockSubdivisions = 4     // блок 400 мс состоит из 4 подблоков по 100 мс (перекрытие 75%)
+)
+
+// biquad - биквадратный IIR фильтр (прямая форма I)
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// filter - пропускает один отсчет через фильтр
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting - возвращает два фильтра K-взвешивания из ITU-R BS.1770 (полочный фильтр
+// и фильтр верхних частот RLB), пересчитанные для заданной частоты дискретизации
+func kWeighting(sampleRate int) (biquad, biquad) {
+	fs := float64(sampleRate)
+
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return shelf, highPass
+}
+
+// channelWeight - весовой коэффициент канала. Для 5.1 (L, R, C, LFE, Ls, Rs)
+// канал LFE не учитывается, а тыловые каналы усиливаются на 1.5 дБ.
+func channelWeight(channel, channels int) float64 {
+	if channels == 6 {
+		switch channel {
+		case 3:
+			return 0
+		case 4, 5:
+			return 1.41
+		}
+	}
+	return 1
+}
+
+// loudnessMeter - измеритель громкости по EBU R128 (ITU-R BS.1770)
+type loudnessMeter struct {
+	shelf, highPass []biquad
+	weights         []float64
+	subblockSize    int       // количество отсчетов в подблоке 100 мс
+	subblockFill    int       // сколько отсчетов уже накоплено в текущем подблоке
+	current         float64   // взвешенная сумма квадратов текущего подблока
+	subblocks       []float64 // суммы последних подблоков
+	blocks          []float64 // средние мощности блоков 400 мс
+}
+
+// newLoudnessMeter - конструктор для типа loudnessMeter
+func newLoudnessMeter(sampleRate, channels int) *loudnessMeter {
+	meter := &loudnessMeter{
+		shelf:        make([]biquad, channels),
+		highPass:     make([]biquad, channels),
+		weights:      make([]float64, channels),
+		subblockSize: sampleRate / 10,
+	}
+
+	for ch := 0; ch < channels; ch++ {
+		meter.shelf[ch], meter.highPass[ch] = kWeighting(sampleRate)
+		meter.weights[ch] = channelWeight(ch, channels)
+	}
+
+	return meter
+}
+
+// process - обрабатывает порцию отсчетов по каналам
+func (m *loudnessMeter) process(frame [][]float64) {
+	if len(frame) == 0 {
+		return
+	}
+
+	for i := range frame[0] {
+		for ch := range m.shelf {
+			if ch >= len(frame) {
+				break
+			}
+			y := m.highPass[ch].filter(m.shelf[ch].filter(frame[ch][i]))
+			m.current += m.weights[ch] * y * y
+		}
+
+		m.subblockFill++
+		if m.subblockFill == m.subblockSize {
+			m.finishSubblock()
+		}
+	}
+}
+
+// finishSubblock - закрывает подблок 100 мс и, если накоплено 4 подблока, добавляет блок 400 мс
+func (m *loudnessMeter) finishSubblock() {
+	m.subblocks = append(m.subblocks, m.current)
+	m.current, m.subblockFill = 0, 0
+
+	if len(m.subblocks) < blockSubdivisions {
+		return
+	}
+
+	var sum float64
+	for _, s := range m.subblocks {
+		sum += s
+	}
+	m.blocks = append(m.blocks, sum/float64(blockSubdivisions*m.subblockSize))
+	m.subblocks = m.subblocks[1:]
+}
+
+// integrated - вычисляет интегральную громкость с абсолютным и относительным стробированием.
+// Для тишины и слишком коротких треков возвращает значение абсолютного порога.
+func (m *loudnessMeter) integrated() float64 {
+	return gatedLoudness(m.blocks)
+}
+
+// gatedLoudness - интегральная громкость по набору мощностей блоков 400 мс
+func gatedLoudness(blocks []float64) float64 {
+	threshold := loudnessToPower(absoluteGate)
+	var sum float64
+	var count int
+	for _, b := range blocks {
+		if b > threshold {
+			sum += b
+			count++
+		}
+	}
+	if count == 0 {
+		return absoluteGate
+	}
+
+	threshold = loudnessToPower(powerToLoudness(sum/float64(count)) + relativeGate)
+	sum, count = 0, 0
+	for _, b := range blocks {
+		if b > threshold {
+			sum += b
+			count++
+		}
+	}
+	if count == 0 {
+		return absoluteGate
+	}
+
+	return powerToLoudness(sum / float64(count))
+}
+
+// powerToLoudness - переводит среднюю взвешенную мощность в LUFS
+func powerToLoudness(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+// loudnessToPower - переводит LUFS в среднюю взвешенную мощность
+func loudnessToPower(loudness float64) float64 {
+	return math.Pow(10, (loudness+0.691)/10)
+}
+
+// replayGain - усиление ReplayGain 2.0, приводящее громкость к -18 LUFS.
+// Для тишины и слишком коротких треков (нет блоков выше абсолютного порога)
+// громкость не определена, поэтому усиление равно нулю, а не +51 дБ.
+func replayGain(loudness float64) float64 {
+	if loudness <= absoluteGate {
+		return 0
+	}
+
+	gain := replayGainTarget - loudness
+	return math.Max(-maxReplayGain, math.Min(maxReplayGain, gain))
+}
+
+// toDecibels - переводит линейную амплитуду в децибелы относительно полной шкалы.
+// Для нулевой амплитуды возвращает -200 дБ, чтобы значение можно было сериализовать.
+func toDecibels(amplitude float64) float64 {
+	if amplitude <= 1e-10 {
+		return -200
+	}
+	return 20 * math.Log10(amplitude)
+}
@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+// sineSource - источник с синусом заданной амплитуды и длительности
+type sineSource struct {
+	rate      int
+	amplitude float64
+	left      int
+}
+
+func (s *sineSource) SampleRate() int { return s.rate }
+func (s *sineSource) Channels() int   { return 1 }
+
+func (s *sineSource) ReadFrame() ([][]float64, error) {
+	if s.left == 0 {
+		return nil, io.EOF
+	}
+	n := 1152
+	if n > s.left {
+		n = s.left
+	}
+	frame := make([]float64, n)
+	for i := range frame {
+		frame[i] = s.amplitude * math.Sin(2*math.Pi*1000*float64(s.left-i)/float64(s.rate))
+	}
+	s.left -= n
+	return [][]float64{frame}, nil
+}
+
+func TestSilenceHasZeroGain(t *testing.T) {
+	for _, src := range []*sineSource{
+		{rate: 44100, amplitude: 0, left: 44100 * 5}, // тишина
+		{rate: 44100, amplitude: 0.5, left: 4410},    // короче одного блока 400 мс
+	} {
+		loudness, err := AnalyzeLoudness(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loudness.TrackGain != 0 {
+			t.Errorf("amplitude %v: TrackGain = %.2f, want 0", src.amplitude, loudness.TrackGain)
+		}
+		if album := AnalyzeAlbum([]*Loudness{loudness}); album.AlbumGain != 0 {
+			t.Errorf("amplitude %v: AlbumGain = %.2f, want 0", src.amplitude, album.AlbumGain)
+		}
+	}
+}
+
+func TestSineGain(t *testing.T) {
+	loudness, err := AnalyzeLoudness(&sineSource{rate: 48000, amplitude: 0.5, left: 48000 * 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// синус 1 кГц с амплитудой 0.5 на одном канале - около -9 LUFS
+	if math.Abs(loudness.Integrated+9.0) > 0.5 {
+		t.Errorf("Integrated = %.2f LUFS, want about -9", loudness.Integrated)
+	}
+	if math.Abs(loudness.TrackGain-(replayGainTarget-loudness.Integrated)) > 1e-9 {
+		t.Errorf("TrackGain = %.2f, want %.2f", loudness.TrackGain, replayGainTarget-loudness.Integrated)
+	}
+}
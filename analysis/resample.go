@@ -0,0 +1,93 @@
+package analysis
+
+import "math"
+
+const (
+	resamplerZeroCrossings = 8   // количество нулей sinc по каждую сторону от центра фильтра
+	resamplerPhases        = 256 // количество фаз в таблице коэффициентов
+)
+
+// resampler - потоковый передискретизатор одного канала с фильтром sinc и окном Блэкмана.
+// Дробное положение выходного отсчета округляется до одной из resamplerPhases фаз.
+type resampler struct {
+	step      float64     // шаг выходных отсчетов в единицах входных
+	halfWidth int         // половина длины фильтра во входных отсчетах
+	table     [][]float64 // коэффициенты фильтра по фазам
+	history   []float64   // входные отсчеты, еще нужные для расчета
+	pos       float64     // положение следующего выходного отсчета относительно history[0]
+}
+
+// newResampler - конструктор для типа resampler
+func newResampler(inRate, outRate int) *resampler {
+	step := float64(inRate) / float64(outRate)
+	cutoff := math.Min(1, 1/step) // частота среза относительно частоты Найквиста входа
+	halfWidth := int(math.Ceil(resamplerZeroCrossings / cutoff))
+
+	table := make([][]float64, resamplerPhases)
+	for phase := range table {
+		table[phase] = make([]float64, 2*halfWidth)
+		frac := float64(phase) / resamplerPhases
+		for tap := range table[phase] {
+			// Расстояние от выходного отсчета до входного отсчета tap
+			t := float64(tap-halfWidth+1) - frac
+			table[phase][tap] = cutoff * sinc(cutoff*t) * blackman(t/float64(halfWidth))
+		}
+	}
+
+	return &resampler{
+		step:      step,
+		halfWidth: halfWidth,
+		table:     table,
+		history:   make([]float64, halfWidth), // нулевая история перед началом сигнала
+		pos:       float64(halfWidth - 1),
+	}
+}
+
+// process - принимает входные отсчеты и возвращает готовые выходные
+func (r *resampler) process(in []float64) []float64 {
+	r.history = append(r.history, in...)
+
+	var out []float64
+	for {
+		base := int(r.pos)
+		if base+r.halfWidth >= len(r.history) {
+			break
+		}
+
+		phase := int((r.pos - float64(base)) * resamplerPhases)
+		taps := r.table[phase]
+		window := r.history[base-r.halfWidth+1 : base+r.halfWidth+1]
+
+		var sum float64
+		for i, c := range taps {
+			sum += c * window[i]
+		}
+		out = append(out, sum)
+		r.pos += r.step
+	}
+
+	// Отбрасываются отсчеты, которые больше не попадут в окно фильтра
+	drop := int(r.pos) - r.halfWidth + 1
+	if drop > 0 {
+		r.history = append(r.history[:0], r.history[drop:]...)
+		r.pos -= float64(drop)
+	}
+
+	return out
+}
+
+// sinc - нормированная функция sin(πx)/(πx)
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman - окно Блэкмана на отрезке [-1, 1]
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
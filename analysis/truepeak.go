@@ -0,0 +1,111 @@
+package analysis
+
+import "math"
+
+const truePeakTapsPerPhase = 12 // длина каждой фазы интерполирующего фильтра
+
+// truePeakMeter - измеритель истинного пика (ITU-R BS.1770, приложение 2):
+// сигнал передискретизируется полифазным FIR фильтром и ищется максимум модуля
+type truePeakMeter struct {
+	phases     [][]float64 // коэффициенты фильтра по фазам
+	history    [][]float64 // последние отсчеты по каналам
+	positions  []int       // положение самого нового отсчета в history по каналам
+	truePeak   float64     // максимум модуля передискретизированного сигнала
+	samplePeak float64     // максимум модуля исходных отсчетов
+}
+
+// newTruePeakMeter - конструктор для типа truePeakMeter. Коэффициент передискретизации
+// выбирается так, чтобы итоговая частота была не меньше 176.4 кГц.
+func newTruePeakMeter(sampleRate, channels int) *truePeakMeter {
+	factor := 4
+	switch {
+	case sampleRate >= 192000:
+		factor = 1
+	case sampleRate >= 96000:
+		factor = 2
+	}
+
+	meter := &truePeakMeter{
+		phases:    interpolationFilter(factor),
+		history:   make([][]float64, channels),
+		positions: make([]int, channels),
+	}
+	for ch := range meter.history {
+		meter.history[ch] = make([]float64, 2*truePeakTapsPerPhase)
+	}
+
+	return meter
+}
+
+// interpolationFilter - строит фильтр нижних частот (sinc с окном Блэкмана)
+// для интерполяции с заданным коэффициентом и раскладывает его по фазам
+func interpolationFilter(factor int) [][]float64 {
+	if factor == 1 {
+		return [][]float64{{1}}
+	}
+
+	length := factor * truePeakTapsPerPhase
+	center := float64(length-1) / 2
+
+	phases := make([][]float64, factor)
+	for p := range phases {
+		phases[p] = make([]float64, truePeakTapsPerPhase)
+	}
+
+	for n := 0; n < length; n++ {
+		x := (float64(n) - center) / float64(factor)
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(n)/float64(length-1)) +
+			0.08*math.Cos(4*math.Pi*float64(n)/float64(length-1))
+		phases[n%factor][n/factor] = sinc(x) * w
+	}
+
+	// каждая фаза должна пропускать постоянную составляющую без изменений
+	for _, phase := range phases {
+		var sum float64
+		for _, c := range phase {
+			sum += c
+		}
+		for i := range phase {
+			phase[i] /= sum
+		}
+	}
+
+	return phases
+}
+
+// process - обрабатывает порцию отсчетов по каналам
+func (m *truePeakMeter) process(frame [][]float64) {
+	for ch, samples := range frame {
+		if ch >= len(m.history) {
+			break
+		}
+
+		// history - кольцевой буфер двойной длины: отсчет пишется дважды,
+		// поэтому окно history[pos:pos+taps] всегда непрерывно (новый отсчет первым)
+		history, pos := m.history[ch], m.positions[ch]
+		for _, x := range samples {
+			if a := math.Abs(x); a > m.samplePeak {
+				m.samplePeak = a
+			}
+
+			pos = (pos + truePeakTapsPerPhase - 1) % truePeakTapsPerPhase
+			history[pos], history[pos+truePeakTapsPerPhase] = x, x
+			window := history[pos : pos+truePeakTapsPerPhase]
+
+			for _, phase := range m.phases {
+				var y float64
+				for i, c := range phase {
+					y += c * window[i]
+				}
+				if a := math.Abs(y); a > m.truePeak {
+					m.truePeak = a
+				}
+			}
+		}
+		m.positions[ch] = pos
+	}
+
+	if m.samplePeak > m.truePeak {
+		m.truePeak = m.samplePeak
+	}
+}
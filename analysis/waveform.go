@@ -0,0 +1,112 @@
+package analysis
+
+import "math"
+
+// Waveform - пики формы волны: для каждого окна из SamplesPerPixel отсчетов
+// хранятся минимум и максимум (по всем каналам) в виде 8-битных чисел со знаком.
+// Формат совпадает с 8-битными данными утилиты audiowaveform.
+type Waveform struct {
+	SampleRate      int    // частота дискретизации исходного аудио
+	SamplesPerPixel int    // количество отсчетов в одном окне
+	Data            []int8 // пары min, max по окнам
+}
+
+// Length - возвращает количество окон
+func (w *Waveform) Length() int {
+	return len(w.Data) / 2
+}
+
+// Resample - уменьшает количество окон до points (не меньше), объединяя соседние окна.
+// Если окон уже не больше points, то возвращается сама форма волны.
+func (w *Waveform) Resample(points int) *Waveform {
+	length := w.Length()
+	if points <= 0 || length <= points {
+		return w
+	}
+
+	factor := (length + points - 1) / points
+	result := &Waveform{
+		SampleRate:      w.SampleRate,
+		SamplesPerPixel: w.SamplesPerPixel * factor,
+		Data:            make([]int8, 0, 2*((length+factor-1)/factor)),
+	}
+
+	for start := 0; start < length; start += factor {
+		end := start + factor
+		if end > length {
+			end = length
+		}
+
+		min, max := int8(math.MaxInt8), int8(math.MinInt8)
+		for i := start; i < end; i++ {
+			if w.Data[2*i] < min {
+				min = w.Data[2*i]
+			}
+			if w.Data[2*i+1] > max {
+				max = w.Data[2*i+1]
+			}
+		}
+		result.Data = append(result.Data, min, max)
+	}
+
+	return result
+}
+
+// waveformBuilder - накапливает пики формы волны по окнам фиксированной длины
+type waveformBuilder struct {
+	waveform *Waveform
+	fill     int     // сколько отсчетов накоплено в текущем окне
+	min, max float64 // экстремумы текущего окна
+}
+
+// newWaveformBuilder - конструктор для типа waveformBuilder
+func newWaveformBuilder(sampleRate, samplesPerPixel int) *waveformBuilder {
+	return &waveformBuilder{
+		waveform: &Waveform{SampleRate: sampleRate, SamplesPerPixel: samplesPerPixel},
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+// process - обрабатывает порцию отсчетов по каналам
+func (b *waveformBuilder) process(frame [][]float64) {
+	if len(frame) == 0 {
+		return
+	}
+
+	for i := range frame[0] {
+		for ch := range frame {
+			s := frame[ch][i]
+			b.min = math.Min(b.min, s)
+			b.max = math.Max(b.max, s)
+		}
+
+		b.fill++
+		if b.fill == b.waveform.SamplesPerPixel {
+			b.finishWindow()
+		}
+	}
+}
+
+// finishWindow - закрывает текущее окно и добавляет его пики
+func (b *waveformBuilder) finishWindow() {
+	if b.fill == 0 {
+		return
+	}
+
+	b.waveform.Data = append(b.waveform.Data, toInt8(b.min), toInt8(b.max))
+	b.fill = 0
+	b.min, b.max = math.Inf(1), math.Inf(-1)
+}
+
+// result - возвращает форму волны, включая неполное последнее окно
+func (b *waveformBuilder) result() *Waveform {
+	b.finishWindow()
+	return b.waveform
+}
+
+// toInt8 - переводит отсчет из диапазона [-1, 1] в 8-битное число со знаком
+func toInt8(s float64) int8 {
+	v := math.Floor(s * 128)
+	return int8(math.Max(math.MinInt8, math.Min(math.MaxInt8, v)))
+}
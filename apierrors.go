@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errorCode - стабильный код ошибки, по которому клиент выбирает реакцию. Коды не меняются
+// между версиями сервера, а сообщение для пользователя берется из каталога i18n по ключу
+// "error.<код>" на языке клиента и может меняться.
+type errorCode string
+
+// Каталог кодов ошибок
+const (
+	codeInternal            errorCode = "internal_error"
+	codeMissingParameter    errorCode = "missing_parameter"
+	codeMissingFields       errorCode = "missing_fields"
+	codeInvalidParameter    errorCode = "invalid_parameter"
+	codeInvalidID           errorCode = "invalid_id"
+	codeInvalidIDs          errorCode = "invalid_ids"
+	codeInvalidJSON         errorCode = "invalid_json"
+	codeMethodNotAllowed    errorCode = "method_not_allowed"
+	codeUnsupportedMedia    errorCode = "unsupported_media_type"
+	codeResourceNotFound    errorCode = "resource_not_found"
+	codeSongNotFound        errorCode = "song_not_found"
+	codeSongsNotFound       errorCode = "songs_not_found"
+	codeSongFileNotFound    errorCode = "song_file_not_found"
+	codeSongNotInTrash      errorCode = "song_not_in_trash"
+	codePlaylistNotFound    errorCode = "playlist_not_found"
+	codePlaylistNotInTrash  errorCode = "playlist_not_in_trash"
+	codeWaveformNotFound    errorCode = "waveform_not_found"
+	codeNoUploadFile        errorCode = "no_upload_file"
+	codeFileTooLarge        errorCode = "file_too_large"
+	codeUnsupportedFormat   errorCode = "unsupported_format"
+	codeDuplicateSong       errorCode = "duplicate_song"
+	codeQueueFull           errorCode = "queue_full"
+	codeScrubReportNotReady errorCode = "scrub_report_not_ready"
+	codeChartsNotReady      errorCode = "charts_not_ready"
+)
+
+// errorDetails - подробности ошибки, например имя некорректного параметра
+type errorDetails map[string]interface{}
+
+// errorReason - стабильный код причины в details.reason, уточняющий код ошибки.
+// Как и коды ошибок, не переводится и не меняется между версиями сервера.
+type errorReason string
+
+// Каталог причин ошибок
+const (
+	reasonDaysRequireDownloads errorReason = "days_require_sort_downloads"    // days задан не для sort=downloads
+	reasonDaysWithFilters      errorReason = "days_incompatible_with_filters" // days задан вместе с фильтрами или order=asc
+	reasonCursorMismatch       errorReason = "cursor_mismatch"                // курсор поврежден или получен для другой сортировки
+)
+
+// errorJSON - ошибка для отдачи пользователю
+type errorJSON struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   errorDetails `json:"details,omitempty"`
+	RequestID string       `json:"requestId"`
+}
+
+// Форматы ответов с ошибками
+const (
+	errorFormatJSON = "json" // errorJSON
+	errorFormatText = "text" // только сообщение простым текстом, как до появления кодов ошибок
+)
+
+// requestIDHeader - заголовок с ID запроса. Клиент может передать свой ID, иначе он создается сервером.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey - ключ ID запроса в контексте запроса
+type requestIDKey struct{}
+
+// withRequestID - присваивает каждому запросу ID, который возвращается в заголовке
+// requestIDHeader и в ответах с ошибками, чтобы по нему можно было найти запрос в логах
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 64 || strings.ContainsAny(id, " \t\r\n") {
+			id = primitive.NewObjectID().Hex()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID - ID запроса, присвоенный withRequestID
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// errorFormat - формат ответа с ошибкой. Клиент выбирает его параметром errorFormat в адресе
+// или заголовком X-Error-Format. Иначе API второй версии всегда отвечает в JSON, а первой -
+// в формате errorsFormat из конфига: старые клиенты ожидают сообщение простым текстом.
+// Параметр читается только из адреса: разбор формы прочитал бы тело загружаемого файла.
+func errorFormat(r *http.Request) string {
+	format := r.URL.Query().Get("errorFormat")
+	if format == "" {
+		format = r.Header.Get("X-Error-Format")
+	}
+
+	switch {
+	case format == errorFormatJSON || format == errorFormatText:
+		return format
+	case strings.HasPrefix(r.URL.Path, apiV2Prefix):
+		return errorFormatJSON
+	}
+
+	return errorsFormat
+}
+
+// writeError - отвечает клиенту ошибкой с кодом ответа status
+func writeError(w http.ResponseWriter, r *http.Request, status int, code errorCode, details errorDetails) {
+	message := i18n.T(i18n.FromRequest(r), "error."+string(code))
+	if errorFormat(r) == errorFormatText {
+		if id, ok := details["id"]; ok {
+			message += fmt.Sprintf(", id: %v", id)
+		}
+		http.Error(w, message, status)
+		return
+	}
+
+	data, err := json.Marshal(&errorJSON{
+		Code:      string(code),
+		Message:   message,
+		Details:   details,
+		RequestID: requestID(r),
+	})
+	if err != nil {
+		i18n.Error("error.marshal_error", err)
+		http.Error(w, i18n.T(i18n.FromRequest(r), "error."+string(codeInternal)), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(data)
+}
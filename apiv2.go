@@ -0,0 +1,425 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// apiV2Prefix - префикс путей REST API второй версии
+const apiV2Prefix = "/api/v2/"
+
+// apiIDSegment - сегмент пути маршрута, на месте которого стоит ID ресурса
+const apiIDSegment = "{id}"
+
+// apiHandler - обработчик метода ресурса API, id - ID ресурса из пути (пустой для коллекций)
+type apiHandler func(w http.ResponseWriter, r *http.Request, id primitive.ObjectID)
+
+// apiRoute - ресурс API: сегменты пути после apiV2Prefix и обработчики методов
+type apiRoute struct {
+	path    []string
+	methods map[string]apiHandler
+}
+
+// apiRouter - маршрутизатор REST API второй версии. В отличие от запросов первой версии
+// ресурс задается путем, а действие - методом. Ошибки возвращаются с кодами HTTP:
+// 404 - ресурса нет, 405 - метод не поддерживается ресурсом, 409 - конфликт с другой записью,
+// 413 - файл слишком большой, 415 - неподдерживаемый тип тела запроса или формат файла.
+// Ошибки общих функций песен и плейлистов отдаются с теми же кодами и в первой версии.
+type apiRouter struct {
+	routes []apiRoute
+}
+
+// songPatch - изменяемые поля песни в теле запроса PATCH, отсутствующие поля не изменяются
+type songPatch struct {
+	Title       *string `json:"Title"`
+	Artist      *string `json:"Artist"`
+	Genre       *string `json:"Genre"`
+	Album       *string `json:"Album"`
+	AlbumArtist *string `json:"AlbumArtist"`
+}
+
+// playlistBody - плейлист в теле запросов POST и PATCH, в PATCH отсутствующие поля не изменяются
+type playlistBody struct {
+	Name *string   `json:"Name"`
+	IDs  *[]string `json:"IDs"`
+}
+
+// newAPIv2 - маршрутизатор REST API второй версии поверх тех же обработчиков, что и первая версия
+func (h *handlers) newAPIv2() *apiRouter {
+	return &apiRouter{routes: []apiRoute{
+		{[]string{"songs"}, map[string]apiHandler{
+			"GET":  h.listSongsV2,
+			"POST": h.createSongV2,
+		}},
+		{[]string{"songs", apiIDSegment}, map[string]apiHandler{
+			"GET":    h.getSongV2,
+			"PATCH":  h.patchSongV2,
+			"DELETE": h.deleteSongV2,
+		}},
+		{[]string{"songs", apiIDSegment, "stream"}, map[string]apiHandler{
+			"GET": h.streamSongV2,
+		}},
+		{[]string{"playlists"}, map[string]apiHandler{
+			"GET":  h.listPlaylistsV2,
+			"POST": h.createPlaylistV2,
+		}},
+		{[]string{"playlists", apiIDSegment}, map[string]apiHandler{
+			"GET":    h.getPlaylistV2,
+			"PATCH":  h.patchPlaylistV2,
+			"DELETE": h.deletePlaylistV2,
+		}},
+		{[]string{"playlists", apiIDSegment, "zip"}, map[string]apiHandler{
+			"GET": h.playlistZipV2,
+		}},
+	}}
+}
+
+// match - проверяет, что путь запроса соответствует маршруту, и извлекает из него ID ресурса.
+// Путь с некорректным ID не соответствует маршруту: такого ресурса нет.
+func (route *apiRoute) match(segments []string) (primitive.ObjectID, bool) {
+	var id primitive.ObjectID
+	if len(segments) != len(route.path) {
+		return id, false
+	}
+
+	for i, segment := range route.path {
+		if segment != apiIDSegment {
+			if segments[i] != segment {
+				return id, false
+			}
+			continue
+		}
+
+		var err error
+		id, err = primitive.ObjectIDFromHex(segments[i])
+		if err != nil {
+			return id, false
+		}
+	}
+
+	return id, true
+}
+
+// allow - методы маршрута для заголовка Allow
+func (route *apiRoute) allow() string {
+	methods := []string{"OPTIONS"}
+	for method := range route.methods {
+		methods = append(methods, method)
+		if method == "GET" {
+			methods = append(methods, "HEAD")
+		}
+	}
+	sort.Strings(methods)
+
+	return strings.Join(methods, ", ")
+}
+
+func (router *apiRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiV2Prefix), "/"), "/")
+	for i := range router.routes {
+		route := &router.routes[i]
+		id, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Allow", route.allow())
+			w.Header().Set("Access-Control-Allow-Methods", route.allow())
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		handler, ok := route.methods[r.Method]
+		if !ok && r.Method == "HEAD" {
+			handler, ok = route.methods["GET"]
+		}
+		if !ok {
+			i18n.Info("api.method_not_allowed", r.Method, r.URL.Path)
+			w.Header().Set("Allow", route.allow())
+			writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, errorDetails{"allow": route.allow()})
+			return
+		}
+
+		handler(w, r, id)
+		return
+	}
+
+	i18n.Info("api.resource_not_found", r.URL.Path)
+	writeError(w, r, http.StatusNotFound, codeResourceNotFound, nil)
+}
+
+// hasContentType - проверяет тип тела запроса, в случае ошибки отвечает клиенту кодом 415
+func hasContentType(w http.ResponseWriter, r *http.Request, expected string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != expected {
+		i18n.Info("api.unsupported_media_type", r.Header.Get("Content-Type"), expected)
+		writeError(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMedia, errorDetails{"expected": expected})
+		return false
+	}
+
+	return true
+}
+
+// decodeJSONBody - читает JSON из тела запроса в body. Неизвестные поля считаются ошибкой,
+// чтобы опечатка в имени поля PATCH не приводила к молчаливому игнорированию изменения.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	if !hasContentType(w, r, "application/json") {
+		return false
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(body)
+	if err != nil {
+		i18n.Info("api.invalid_json", err)
+		writeError(w, r, http.StatusBadRequest, codeInvalidJSON, nil)
+		return false
+	}
+
+	return true
+}
+
+// listSongsV2 - GET /songs, страница песен, по умолчанию сначала новые. Параметры - см. parseSongQuery.
+func (h *handlers) listSongsV2(w http.ResponseWriter, r *http.Request, _ primitive.ObjectID) {
+	query, ok := parseSongQuery(w, r, songSortFields, sortUploadDate, 0)
+	if !ok {
+		return
+	}
+
+	page, err := listSongs(r.Context(), h.songs, query)
+	if err != nil {
+		i18n.Error("songs_list.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	serveContent(page, w, r)
+}
+
+// createSongV2 - POST /songs, загрузка песни из multipart формы с файлом в поле formFileName
+func (h *handlers) createSongV2(w http.ResponseWriter, r *http.Request, _ primitive.ObjectID) {
+	i18n.Info("add_song.start")
+	if !hasContentType(w, r, "multipart/form-data") {
+		return
+	}
+
+	song, err := h.uploadSong(w, r)
+	if err != nil {
+		writeSongError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", apiV2Prefix+"songs/"+song.ID.Hex())
+	serveContentWithStatus(song, http.StatusCreated, w, r)
+
+	i18n.Info("add_song.done")
+}
+
+// getSongV2 - GET /songs/{id}, метаданные песни
+func (h *handlers) getSongV2(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	song, ok := h.findSong(w, r, id)
+	if !ok {
+		return
+	}
+
+	serveContent(song, w, r)
+}
+
+// streamSongV2 - GET /songs/{id}/stream, файл песни. С параметром download=true
+// доставленный целиком файл засчитывается как загрузка.
+func (h *handlers) streamSongV2(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	song, ok := h.findSong(w, r, id)
+	if !ok {
+		return
+	}
+
+	h.streamSong(song, r.FormValue("download") == "true", w, r)
+}
+
+// patchSongV2 - PATCH /songs/{id}, изменение метаданных песни в БД (тэги файла не изменяются)
+func (h *handlers) patchSongV2(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	var patch songPatch
+	if !decodeJSONBody(w, r, &patch) {
+		return
+	}
+
+	song, ok := h.findSong(w, r, id)
+	if !ok {
+		return
+	}
+
+	if patch.Title != nil {
+		song.Title = *patch.Title
+	}
+	if patch.Artist != nil {
+		song.Artist = *patch.Artist
+	}
+	if patch.Genre != nil {
+		song.Genre = *patch.Genre
+	}
+	if patch.Album != nil {
+		song.Album = *patch.Album
+	}
+	if patch.AlbumArtist != nil {
+		song.AlbumArtist = *patch.AlbumArtist
+	}
+
+	err := h.songs.UpdateMetadata(r.Context(), song)
+	switch err {
+	case nil:
+		serveContent(song, w, r)
+	case errNotFound:
+		i18n.Info("patch_song.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codeSongNotFound, nil)
+	default:
+		i18n.Error("patch_song.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+	}
+}
+
+// deleteSongV2 - DELETE /songs/{id}, перемещение песни в корзину
+func (h *handlers) deleteSongV2(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	err := trashSong(r.Context(), h.songs, h.playlists, id)
+	if err == errNotFound {
+		i18n.Info("delete_song.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codeSongNotFound, nil)
+		return
+	}
+	if err != nil {
+		i18n.Error("delete_song.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listPlaylistsV2 - GET /playlists, страница плейлистов, по умолчанию сначала новые.
+// Параметры - см. parsePlaylistQuery.
+func (h *handlers) listPlaylistsV2(w http.ResponseWriter, r *http.Request, _ primitive.ObjectID) {
+	query, ok := parsePlaylistQuery(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := listPlaylists(r.Context(), h.playlists, query)
+	if err != nil {
+		i18n.Error("playlists.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	serveContent(page, w, r)
+}
+
+// createPlaylistV2 - POST /playlists, создание плейлиста из JSON с полями Name и IDs
+func (h *handlers) createPlaylistV2(w http.ResponseWriter, r *http.Request, _ primitive.ObjectID) {
+	var body playlistBody
+	if !decodeJSONBody(w, r, &body) {
+		return
+	}
+
+	if body.Name == nil || *body.Name == "" || body.IDs == nil {
+		i18n.Info("add_playlist.empty_fields")
+		writeError(w, r, http.StatusBadRequest, codeMissingFields, nil)
+		return
+	}
+
+	playList, err := h.createPlaylist(r.Context(), *body.Name, *body.IDs)
+	if err != nil {
+		writeSongError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", apiV2Prefix+"playlists/"+playList.ID.Hex())
+	serveContentWithStatus(playList, http.StatusCreated, w, r)
+}
+
+// getPlaylistV2 - GET /playlists/{id}, плейлист
+func (h *handlers) getPlaylistV2(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	playList, ok := h.findPlaylist(w, r, id)
+	if !ok {
+		return
+	}
+
+	serveContent(playList, w, r)
+}
+
+// patchPlaylistV2 - PATCH /playlists/{id}, переименование плейлиста или замена списка его песен
+func (h *handlers) patchPlaylistV2(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	var body playlistBody
+	if !decodeJSONBody(w, r, &body) {
+		return
+	}
+
+	if body.Name != nil && *body.Name == "" {
+		writeError(w, r, http.StatusBadRequest, codeMissingFields, nil)
+		return
+	}
+	if body.IDs != nil && len(makeSliceSliceObjectIDs(*body.IDs)) != len(*body.IDs) {
+		writeSongError(w, r, errInvalidIDs)
+		return
+	}
+
+	playList, ok := h.findPlaylist(w, r, id)
+	if !ok {
+		return
+	}
+
+	if body.Name != nil {
+		playList.Name = *body.Name
+	}
+	if body.IDs != nil {
+		playList.IDs = *body.IDs
+	}
+
+	err := h.playlists.Update(r.Context(), playList)
+	if err == errNotFound {
+		writeError(w, r, http.StatusNotFound, codePlaylistNotFound, nil)
+		return
+	}
+	if err != nil {
+		i18n.Error("patch_playlist.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	serveContent(playList, w, r)
+}
+
+// deletePlaylistV2 - DELETE /playlists/{id}, перемещение плейлиста в корзину
+func (h *handlers) deletePlaylistV2(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	err := h.playlists.Trash(r.Context(), id)
+	if err == errNotFound {
+		i18n.Info("delete_playlist.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codePlaylistNotFound, nil)
+		return
+	}
+	if err != nil {
+		i18n.Error("delete_playlist.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// playlistZipV2 - GET /playlists/{id}/zip, песни плейлиста в zip архиве
+func (h *handlers) playlistZipV2(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	playList, ok := h.findPlaylist(w, r, id)
+	if !ok {
+		return
+	}
+
+	h.servePlaylistInZIP(playList, w, r)
+}
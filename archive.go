@@ -0,0 +1,162 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// zipEntry - файл, который будет записан в архив
+type zipEntry struct {
+	name     string             // имя файла в архиве
+	size     int64              // размер в байтах
+	modified time.Time          // время изменения
+	song     primitive.ObjectID // ID песни, пустой для служебных файлов архива
+	open     func() (io.ReadCloser, error)
+}
+
+// deliveredSongs - ID песен из файлов архива без повторов
+func deliveredSongs(entries []zipEntry) []primitive.ObjectID {
+	seen := make(map[primitive.ObjectID]bool)
+	var ids []primitive.ObjectID
+	for i := range entries {
+		id := entries[i].song
+		if id.IsZero() || seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// countWriter - подсчитывает записанные байты, сами данные отбрасываются
+type countWriter struct {
+	count int64
+}
+
+// Write - увеличивает счетчик на длину p
+func (cw *countWriter) Write(p []byte) (int, error) {
+	cw.count += int64(len(p))
+	return len(p), nil
+}
+
+// contextReader - прекращает чтение, когда контекст отменен (например, клиент отключился)
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read - читает данные, если контекст еще не отменен
+func (cr *contextReader) Read(p []byte) (int, error) {
+	err := cr.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	return cr.reader.Read(p)
+}
+
+// zipHeader - заголовок файла архива. Аудио уже сжато, поэтому файлы хранятся без сжатия.
+func zipHeader(entry *zipEntry) *zip.FileHeader {
+	return &zip.FileHeader{
+		Name:     entry.name,
+		Method:   zip.Store,
+		Modified: entry.modified,
+	}
+}
+
+// zipSize - вычисляет точный размер архива из файлов entries. Размер служебных записей
+// не зависит от содержимого файлов, поэтому архив строится без данных в счетчик байт,
+// а к результату прибавляются размеры файлов. Если архиву нужен формат ZIP64
+// (файлы или архив больше 4 ГБ), то размер не вычисляется и возвращается -1.
+func zipSize(entries []zipEntry) int64 {
+	counter := new(countWriter)
+	zipWriter := zip.NewWriter(counter)
+
+	var dataSize int64
+	for i := range entries {
+		_, err := zipWriter.CreateHeader(zipHeader(&entries[i]))
+		if err != nil {
+			return -1
+		}
+		dataSize += entries[i].size
+	}
+
+	err := zipWriter.Close()
+	if err != nil {
+		return -1
+	}
+
+	size := counter.count + dataSize
+	if size >= math.MaxUint32 {
+		return -1
+	}
+
+	return size
+}
+
+// writeZip - потоково записывает архив из файлов entries в writer. Запись прекращается,
+// если контекст отменен. Возвращает ошибку, если архив записан не полностью.
+func writeZip(ctx context.Context, writer io.Writer, entries []zipEntry) error {
+	zipWriter := zip.NewWriter(writer)
+	for i := range entries {
+		err := writeZipEntry(ctx, zipWriter, &entries[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+// writeZipEntry - записывает в архив один файл
+func writeZipEntry(ctx context.Context, zipWriter *zip.Writer, entry *zipEntry) error {
+	file, err := entry.open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fileWriter, err := zipWriter.CreateHeader(zipHeader(entry))
+	if err != nil {
+		return err
+	}
+
+	// Размер должен совпасть с заявленным, иначе не совпадет Content-Length
+	n, err := io.Copy(fileWriter, io.LimitReader(&contextReader{ctx: ctx, reader: file}, entry.size))
+	if err == nil && n != entry.size {
+		err = fmt.Errorf("Файл %q короче заявленного размера: %v из %v байт", entry.name, n, entry.size)
+	}
+
+	return err
+}
+
+// uniqueNames - делает имена файлов архива уникальными, добавляя к повторам номер:
+// "song.mp3", "song (2).mp3". Имена сравниваются без учета регистра.
+func uniqueNames(names []string) []string {
+	used := make(map[string]bool)
+	result := make([]string, len(names))
+	for i, name := range names {
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		unique := name
+		for n := 2; used[strings.ToLower(unique)]; n++ {
+			unique = fmt.Sprintf("%v (%v)%v", base, n, ext)
+		}
+
+		used[strings.ToLower(unique)] = true
+		result[i] = unique
+	}
+
+	return result
+}
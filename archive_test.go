@@ -0,0 +1,193 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// downloadZIP - скачивает архив с сервера и проверяет, что Content-Length совпадает
+// с количеством полученных байт, а имена файлов архива не повторяются.
+// Возвращает содержимое файлов архива по именам.
+func downloadZIP(t *testing.T, target string) map[string][]byte {
+	t.Helper()
+	resp, err := http.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("GET %v: %v %q", target, resp.Status, body)
+	}
+	if resp.ContentLength != int64(len(body)) {
+		t.Errorf("Content-Length = %v, received %v bytes", resp.ContentLength, len(body))
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		if _, ok := files[strings.ToLower(file.Name)]; ok {
+			t.Errorf("duplicate file %q in archive", file.Name)
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("%v: %v", file.Name, err)
+		}
+		files[strings.ToLower(file.Name)] = data
+	}
+
+	return files
+}
+
+// storedFile - содержимое файла песни в хранилище
+func storedFile(t *testing.T, h *handlers, song *SongInfo) []byte {
+	t.Helper()
+	reader, err := h.files.Get(songFileName(song))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestGetSongsInZip(t *testing.T) {
+	h := newTestHandlers(t, newMemoryRepositories())
+	server := httptest.NewServer(withRequestID(h.newMux()))
+	defer server.Close()
+
+	// У песен одинаковые имена файлов, в архиве они должны различаться
+	first := addTestSong(t, h, 1, "Same")
+	second := addTestSong(t, h, 2, "Same")
+	ids := `["` + first.ID.Hex() + `","` + second.ID.Hex() + `"]`
+
+	files := downloadZIP(t, server.URL+"/getSongsInZip?ids="+url.QueryEscape(ids))
+	if len(files) != 2 || !bytes.Equal(files["same.wav"], storedFile(t, h, first)) ||
+		!bytes.Equal(files["same (2).wav"], storedFile(t, h, second)) {
+		t.Errorf("archive files = %v", len(files))
+	}
+
+	stored, _ := h.songs.FindByID(context.Background(), first.ID)
+	if stored.CountOfDownload != 1 {
+		t.Errorf("CountOfDownload = %v, want 1", stored.CountOfDownload)
+	}
+}
+
+func TestGetPlaylistInZip(t *testing.T) {
+	h := newTestHandlers(t, newMemoryRepositories())
+	server := httptest.NewServer(withRequestID(h.newMux()))
+	defer server.Close()
+
+	playlistNameTemplate = "{title}.{ext}"
+	song := addTestSong(t, h, 1, "Song")
+	playList := &PlayList{ID: primitive.NewObjectID(), Name: "Mix", IDs: []string{song.ID.Hex(), song.ID.Hex()}}
+	if err := h.playlists.Insert(context.Background(), playList); err != nil {
+		t.Fatal(err)
+	}
+
+	files := downloadZIP(t, server.URL+"/getPlaylistInZip?id="+playList.ID.Hex())
+	for _, name := range []string{"mix/song.wav", "mix/song (2).wav", "mix/mix.m3u8", "mix/playlist.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("no %q in archive", name)
+		}
+	}
+	if len(files) != 4 || !bytes.Equal(files["mix/song (2).wav"], storedFile(t, h, song)) {
+		t.Errorf("archive has %v files", len(files))
+	}
+}
+
+// endlessReader - бесконечный поток нулей, считает прочитанные байты
+type endlessReader struct {
+	read *int64
+}
+
+func (er endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	atomic.AddInt64(er.read, int64(len(p)))
+	return len(p), nil
+}
+
+// Когда клиент отключается, запись архива прекращается и загрузки не считаются
+func TestServeZIPClientDisconnect(t *testing.T) {
+	h := newTestHandlers(t, newMemoryRepositories())
+	song := addTestSong(t, h, 1, "Song")
+
+	var read int64
+	const size = 1 << 30
+	entries := []zipEntry{{
+		name: "huge.wav",
+		size: size,
+		song: song.ID,
+		open: func() (io.ReadCloser, error) { return ioutil.NopCloser(endlessReader{read: &read}), nil },
+	}}
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.serveZIP(entries, "huge.zip", w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadFull(resp.Body, make([]byte, 1<<16)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("archive is still written after the client disconnected")
+	}
+	if n := atomic.LoadInt64(&read); n >= size {
+		t.Errorf("read %v bytes of %v", n, size)
+	}
+
+	stored, _ := h.songs.FindByID(context.Background(), song.ID)
+	if stored.CountOfDownload != 0 {
+		t.Errorf("CountOfDownload = %v after disconnect, want 0", stored.CountOfDownload)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/storage"
+)
+
+// blobName - имя файла в хранилище по хэшу содержимого: ab/cd/abcd...
+func blobName(hash string) string {
+	return hash[:2] + "/" + hash[2:4] + "/" + hash
+}
+
+// songFileName - имя файла песни в хранилище. Песни, добавленные до перехода
+// на адресацию по содержимому и не прошедшие миграцию, хранятся под своим ID.
+func songFileName(song *SongInfo) string {
+	if song.Blob == "" {
+		return song.ID.Hex()
+	}
+
+	return blobName(song.Blob)
+}
+
+// hashContent - SHA-256 содержимого в шестнадцатеричном виде и его размер
+func hashContent(reader io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// blobStore - файлы песен в хранилище вместе с записями о песнях, блобах и журналом изменений.
+// Через него файлы меняют и обработчики запросов, и фоновые задачи, поэтому в сервере
+// он один: блокировки хэшей действуют только между пользователями одного blobStore.
+type blobStore struct {
+	repositories
+	files storage.Storage
+	locks *hashLocks
+}
+
+// newBlobStore - конструктор для типа blobStore
+func newBlobStore(files storage.Storage, repos repositories) *blobStore {
+	return &blobStore{repositories: repos, files: files, locks: &hashLocks{locks: make(map[string]*hashLock)}}
+}
+
+// hashLocks - блокировки блобов по хэшу. Счетчик ссылок блоба и его файл меняются
+// под одной блокировкой, иначе загрузка могла бы сослаться на файл, который еще
+// не сохранен (или уже не сохранится), а освобождение блоба - удалить файл, который
+// только что сохранила загрузка того же содержимого.
+type hashLocks struct {
+	mutex sync.Mutex
+	locks map[string]*hashLock
+}
+
+// hashLock - блокировка одного хэша
+type hashLock struct {
+	sync.Mutex
+	users int // сколько горутин держат блокировку или ждут ее
+}
+
+// lock - захватывает блокировку хэша и возвращает функцию ее освобождения
+func (locks *hashLocks) lock(hash string) func() {
+	locks.mutex.Lock()
+	lock, ok := locks.locks[hash]
+	if !ok {
+		lock = &hashLock{}
+		locks.locks[hash] = lock
+	}
+	lock.users++
+	locks.mutex.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		locks.mutex.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(locks.locks, hash)
+		}
+		locks.mutex.Unlock()
+	}
+}
+
+// acquireBlob - добавляет ссылку на блоб и, если блоба еще не было, сохраняет его файл
+// функцией put. Если сохранить файл не удалось, то ссылка на блоб убирается.
+// Возвращает true, если файл был сохранен.
+func (store *blobStore) acquireBlob(ctx context.Context, hash string, size int64, put func() error) (bool, error) {
+	unlock := store.locks.lock(hash)
+	defer unlock()
+
+	created, err := store.blobs.Acquire(ctx, hash, size)
+	if err != nil {
+		i18n.Error("blob.acquire_error", err)
+		return false, err
+	}
+	if !created {
+		return false, nil
+	}
+
+	err = put()
+	if err != nil {
+		store.releaseLockedBlob(hash)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// storeUploadedBlob - добавляет ссылку на блоб загруженного файла и сохраняет файл
+// в хранилище, если такого содержимого там еще нет. Иначе временный файл не нужен
+// и удаляется вызовом Discard.
+// Если сохранить файл не удалось, то ссылка на блоб убирается.
+func (store *blobStore) storeUploadedBlob(ctx context.Context, fd *uploadedFile) error {
+	_, err := store.acquireBlob(ctx, fd.hash, fd.size, func() error {
+		return fd.Commit(blobName(fd.hash))
+	})
+
+	return err
+}
+
+// releaseBlob - убирает ссылку на блоб. Когда ссылок не остается,
+// запись о блобе и его файл удаляются. Ссылка убирается и при откате после отмены запроса,
+// поэтому запросы к БД не зависят от контекста вызывающего.
+func (store *blobStore) releaseBlob(hash string) error {
+	unlock := store.locks.lock(hash)
+	defer unlock()
+
+	return store.releaseLockedBlob(hash)
+}
+
+// releaseLockedBlob - releaseBlob для вызывающего, который уже держит блокировку хэша
+func (store *blobStore) releaseLockedBlob(hash string) error {
+	removed, err := store.blobs.Release(context.Background(), hash)
+	if err != nil {
+		i18n.Error("blob.release_error", err)
+		return err
+	}
+	if !removed {
+		return nil
+	}
+
+	err = store.files.Delete(blobName(hash))
+	if err != nil && err != storage.ErrNotExist {
+		i18n.Error("blob.delete_error", err)
+		return err
+	}
+
+	return nil
+}
+
+// releaseSongFile - освобождает файл песни: убирает ссылку на блоб или, если песня
+// еще не переведена на блобы, удаляет файл под ее ID
+func (store *blobStore) releaseSongFile(song *SongInfo) error {
+	if song.Blob != "" {
+		return store.releaseBlob(song.Blob)
+	}
+
+	err := store.files.Delete(song.ID.Hex())
+	if err != nil && err != storage.ErrNotExist {
+		i18n.Error("storage.delete_error", err)
+		return err
+	}
+
+	return nil
+}
+
+// migrateStorage - переводит файлы песен, хранящиеся под ID песен, на адресацию по содержимому.
+// Одинаковые файлы после миграции хранятся в одном экземпляре.
+func (store *blobStore) migrateStorage() {
+	ctx := context.Background()
+
+	songs, err := store.songs.WithoutBlob(ctx)
+	if err != nil {
+		i18n.Fatal("migrate.find_error", err)
+	}
+	i18n.Info("migrate.start", len(songs))
+
+	migrated := 0
+	for i := range songs {
+		err = store.migrateSongFile(ctx, &songs[i])
+		if err != nil {
+			i18n.Error("migrate.song_error", songs[i].ID.Hex(), err)
+			continue
+		}
+		migrated++
+	}
+
+	i18n.Info("migrate.done", migrated, len(songs))
+}
+
+// migrateSongFile - переносит файл одной песни в блоб
+func (store *blobStore) migrateSongFile(ctx context.Context, song *SongInfo) error {
+	oldName := song.ID.Hex()
+	file, err := store.files.Get(oldName)
+	if err != nil {
+		return err
+	}
+	hash, size, err := hashContent(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	created, err := store.acquireBlob(ctx, hash, size, func() error {
+		return storage.Move(store.files, oldName, blobName(hash))
+	})
+	if err != nil {
+		return err
+	}
+
+	err = store.songs.SetBlob(ctx, song.ID, hash)
+	if err != nil {
+		if created {
+			store.restoreSongFile(hash, oldName, size)
+		} else {
+			store.releaseBlob(hash)
+		}
+		return err
+	}
+
+	if !created {
+		// Такой же файл уже перенесен для другой песни
+		err = store.files.Delete(oldName)
+		if err != nil {
+			i18n.Error("migrate.duplicate_delete_error", err)
+		}
+	}
+
+	return nil
+}
+
+// restoreSongFile - откатывает перенос файла песни в блоб, если песню не удалось на него перевести:
+// убирает ссылку на блоб и возвращает файл под прежнее имя. Если на блоб успела сослаться
+// другая песня, то файл не переносится, а копируется.
+func (store *blobStore) restoreSongFile(hash, oldName string, size int64) {
+	unlock := store.locks.lock(hash)
+	defer unlock()
+
+	removed, err := store.blobs.Release(context.Background(), hash)
+	if err == nil && removed {
+		err = storage.Move(store.files, blobName(hash), oldName)
+	} else if err == nil {
+		var file io.ReadCloser
+		file, err = store.files.Get(blobName(hash))
+		if err == nil {
+			err = store.files.Put(oldName, file, size)
+			file.Close()
+		}
+	}
+	if err != nil {
+		i18n.Error("migrate.rollback_error", oldName, err)
+	}
+}
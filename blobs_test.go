@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestBlobStore - хранилище блобов с записями в памяти и файлами во временном каталоге
+func newTestBlobStore(t *testing.T) *blobStore {
+	t.Helper()
+	files, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newBlobStore(files, newMemoryRepositories())
+}
+
+// slowStorage - хранилище с задержкой записи и удаления, как у сетевого хранилища.
+// Задержка расширяет окно между изменением счетчика ссылок и операцией с файлом.
+type slowStorage struct {
+	storage.Storage
+}
+
+func (s slowStorage) Put(name string, r io.Reader, size int64) error {
+	time.Sleep(time.Millisecond)
+	return s.Storage.Put(name, r, size)
+}
+
+func (s slowStorage) Delete(name string) error {
+	time.Sleep(time.Millisecond)
+	return s.Storage.Delete(name)
+}
+
+// Параллельные загрузки и освобождения одного содержимого: пока на блоб есть ссылка, его файл существует
+func TestConcurrentUploadRelease(t *testing.T) {
+	ctx := context.Background()
+	store := newTestBlobStore(t)
+	store.files = slowStorage{store.files}
+	data := []byte("same content")
+	hash, size, _ := hashContent(bytes.NewReader(data))
+
+	const workers, rounds = 8, 50
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				upload, err := storage.NewUpload(store.files)
+				if err == nil {
+					_, err = upload.Write(data)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				err = store.storeUploadedBlob(ctx, &uploadedFile{Upload: upload, hash: hash, size: size})
+				upload.Discard()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err = store.files.Stat(blobName(hash)); err != nil {
+					errs <- fmt.Errorf("referenced blob file: %v", err)
+					return
+				}
+
+				err = store.releaseBlob(hash)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	blobs, err := store.blobs.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("blobs after release: %+v", blobs)
+	}
+	if _, err = store.files.Stat(blobName(hash)); err != storage.ErrNotExist {
+		t.Errorf("file after release: %v, want ErrNotExist", err)
+	}
+}
+
+// Загрузка того же содержимого ждет, пока первая сохранит файл, и сохраняет его сама, если первая не смогла
+func TestAcquireBlobAfterFailedPut(t *testing.T) {
+	ctx := context.Background()
+	store := newTestBlobStore(t)
+	hash := strings.Repeat("ab", 32)
+
+	started, fail := make(chan struct{}), make(chan struct{})
+	first := make(chan error)
+	go func() {
+		_, err := store.acquireBlob(ctx, hash, 4, func() error {
+			close(started)
+			<-fail
+			return errors.New("put failed")
+		})
+		first <- err
+	}()
+	<-started
+
+	second := make(chan bool)
+	go func() {
+		created, err := store.acquireBlob(ctx, hash, 4, func() error {
+			return store.files.Put(blobName(hash), strings.NewReader("data"), 4)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		second <- created
+	}()
+	close(fail)
+
+	if err := <-first; err == nil {
+		t.Error("first acquire succeeded")
+	}
+	if !<-second {
+		t.Error("second acquire did not store the file")
+	}
+	if _, err := store.files.Stat(blobName(hash)); err != nil {
+		t.Errorf("blob file: %v", err)
+	}
+}
+
+// failingSetBlob - хранилище песен, в котором не удается перевести песню на блоб
+type failingSetBlob struct {
+	SongRepository
+}
+
+func (repo failingSetBlob) SetBlob(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return errors.New("set blob failed")
+}
+
+func TestMigrateSongFileRollback(t *testing.T) {
+	ctx := context.Background()
+	store := newTestBlobStore(t)
+	store.songs = failingSetBlob{store.songs}
+
+	song := &SongInfo{ID: primitive.NewObjectID()}
+	data := strings.NewReader("song data")
+	if err := store.files.Put(song.ID.Hex(), data, data.Size()); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, _ := hashContent(strings.NewReader("song data"))
+
+	err := store.migrateSongFile(ctx, song)
+	if err == nil {
+		t.Fatal("migrateSongFile succeeded")
+	}
+
+	file, err := store.files.Get(song.ID.Hex())
+	if err != nil {
+		t.Fatalf("song file after rollback: %v", err)
+	}
+	content, _ := ioutil.ReadAll(file)
+	file.Close()
+	if string(content) != "song data" {
+		t.Errorf("song file after rollback = %q", content)
+	}
+	if _, err = store.files.Stat(blobName(hash)); err != storage.ErrNotExist {
+		t.Errorf("blob file after rollback: %v, want ErrNotExist", err)
+	}
+	blobs, _ := store.blobs.All(ctx)
+	if len(blobs) != 0 {
+		t.Errorf("blobs after rollback: %+v", blobs)
+	}
+}
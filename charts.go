@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Окна чартов
+const (
+	chartWindowDay      = "24h"
+	chartWindowWeek     = "7d"
+	chartWindowMonth    = "30d"
+	chartWindowTrending = "trending" // загрузки за неделю, вес загрузки убывает с ее возрастом
+)
+
+// Группировки чартов
+const (
+	chartBySong   = "song"
+	chartByArtist = "artist"
+	chartByGenre  = "genre"
+)
+
+// chartWindows - длительность окон чартов
+var chartWindows = map[string]time.Duration{
+	chartWindowDay:      24 * time.Hour,
+	chartWindowWeek:     7 * 24 * time.Hour,
+	chartWindowMonth:    30 * 24 * time.Hour,
+	chartWindowTrending: 7 * 24 * time.Hour,
+}
+
+// chartsJob - периодический расчет чартов по событиям загрузок. Загрузки песен
+// считаются заранее для каждого окна, а группировка по исполнителям и жанрам
+// и отбор по жанру выполняются при запросе по уже посчитанным песням.
+type chartsJob struct {
+	songs          SongRepository
+	downloadEvents DownloadEventRepository
+	interval       time.Duration
+
+	mutex    sync.Mutex
+	windows  map[string][]chartSong // песни окон последнего расчета, по убыванию очков
+	computed time.Time              // время последнего расчета
+}
+
+// chartSong - песня в окне чарта
+type chartSong struct {
+	song      *SongInfo
+	downloads int64   // загрузок за окно
+	score     float64 // загрузки, для trending - с убывающим весом
+}
+
+// chartEntry - строка чарта
+type chartEntry struct {
+	Position  int       `json:"Position"`
+	Name      string    `json:"Name"` // название песни, исполнитель или жанр
+	Song      *SongInfo `json:"Song,omitempty"`
+	Downloads int64     `json:"Downloads"`
+	Score     float64   `json:"Score"`
+}
+
+// chartJSON - чарт для отдачи пользователю
+type chartJSON struct {
+	Window   string       `json:"Window"`
+	By       string       `json:"By"`
+	Genre    string       `json:"Genre,omitempty"`
+	Computed time.Time    `json:"Computed"`
+	Entries  []chartEntry `json:"Entries"`
+}
+
+// newChartsJob - конструктор для типа chartsJob, interval - период расчета в минутах
+func newChartsJob(songs SongRepository, downloadEvents DownloadEventRepository, interval int) *chartsJob {
+	if interval == 0 {
+		interval = defaultChartsInterval
+	}
+
+	return &chartsJob{
+		songs:          songs,
+		downloadEvents: downloadEvents,
+		interval:       time.Duration(interval) * time.Minute,
+	}
+}
+
+// run - запускает бесконечный цикл расчета чартов, вызывается в отдельной горутине
+func (job *chartsJob) run() {
+	i18n.Info("charts_job.started", job.interval)
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		job.compute()
+		<-ticker.C
+	}
+}
+
+// longestChartWindow - самое длинное окно, события старше него больше не нужны
+func longestChartWindow() time.Duration {
+	var longest time.Duration
+	for _, window := range chartWindows {
+		if window > longest {
+			longest = window
+		}
+	}
+
+	return longest
+}
+
+// compute - пересчитывает загрузки песен во всех окнах и удаляет устаревшие события
+func (job *chartsJob) compute() {
+	ctx := context.Background()
+	now := time.Now()
+	oldest := now.Add(-longestChartWindow())
+
+	removed, err := job.downloadEvents.DeleteBefore(ctx, oldest)
+	if err != nil {
+		i18n.Error("charts_job.purge_error", err)
+	} else if removed != 0 {
+		i18n.Info("charts_job.purged", removed)
+	}
+
+	downloads := make(map[string]map[primitive.ObjectID]int64, len(chartWindows))
+	scores := make(map[string]map[primitive.ObjectID]float64, len(chartWindows))
+	for name := range chartWindows {
+		downloads[name] = make(map[primitive.ObjectID]int64)
+		scores[name] = make(map[primitive.ObjectID]float64)
+	}
+
+	err = job.downloadEvents.EachSince(ctx, oldest, func(event *DownloadEvent) {
+		age := now.Sub(event.Time)
+		for name, window := range chartWindows {
+			if age > window {
+				continue
+			}
+
+			score := 1.0
+			if name == chartWindowTrending {
+				score = math.Exp2(-float64(age) / float64(trendingHalfLife))
+			}
+			downloads[name][event.Song]++
+			scores[name][event.Song] += score
+		}
+	})
+	if err != nil {
+		i18n.Error("charts_job.events_error", err)
+		return
+	}
+
+	seen := make(map[primitive.ObjectID]bool)
+	var ids []primitive.ObjectID
+	for name := range chartWindows {
+		for id := range downloads[name] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	// Песни из корзины и удаленные песни в чарты не попадают
+	songs, err := job.songs.FindByIDs(ctx, ids)
+	if err != nil {
+		i18n.Error("charts_job.songs_error", err)
+		return
+	}
+	byID := make(map[primitive.ObjectID]*SongInfo, len(songs))
+	for i := range songs {
+		byID[songs[i].ID] = &songs[i]
+	}
+
+	windows := make(map[string][]chartSong, len(chartWindows))
+	for name := range chartWindows {
+		var chart []chartSong
+		for id, count := range downloads[name] {
+			if song, ok := byID[id]; ok {
+				chart = append(chart, chartSong{song: song, downloads: count, score: scores[name][id]})
+			}
+		}
+
+		sort.Slice(chart, func(i, j int) bool {
+			if chart[i].score != chart[j].score {
+				return chart[i].score > chart[j].score
+			}
+			return chart[i].song.ID.Hex() < chart[j].song.ID.Hex()
+		})
+		windows[name] = chart
+	}
+
+	job.mutex.Lock()
+	job.windows = windows
+	job.computed = now
+	job.mutex.Unlock()
+
+	i18n.Info("charts_job.done", len(byID))
+}
+
+// chart - чарт окна window, сгруппированный по by, из песен жанра genre (без учета регистра,
+// пустой - все жанры), не больше count строк, count = 0 - без ограничения.
+// Возвращает nil, если чарты еще не рассчитаны.
+func (job *chartsJob) chart(window, by, genre string, count int) *chartJSON {
+	job.mutex.Lock()
+	songs, computed := job.windows[window], job.computed
+	job.mutex.Unlock()
+
+	if computed.IsZero() {
+		return nil
+	}
+
+	var entries []chartEntry
+	groups := make(map[string]int)
+	for i := range songs {
+		song := songs[i].song
+		if genre != "" && !strings.EqualFold(song.Genre, genre) {
+			continue
+		}
+
+		if by == chartBySong {
+			entries = append(entries, chartEntry{
+				Name:      song.Title,
+				Song:      song,
+				Downloads: songs[i].downloads,
+				Score:     songs[i].score,
+			})
+			continue
+		}
+
+		name := song.Artist
+		if by == chartByGenre {
+			name = song.Genre
+		}
+
+		index, ok := groups[name]
+		if !ok {
+			index = len(entries)
+			groups[name] = index
+			entries = append(entries, chartEntry{Name: name})
+		}
+		entries[index].Downloads += songs[i].downloads
+		entries[index].Score += songs[i].score
+	}
+
+	// Песни уже отсортированы, а суммы групп нужно упорядочить заново.
+	// При равных очках порядок задает имя, чтобы он не зависел от порядка песен.
+	if by != chartBySong {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Score != entries[j].Score {
+				return entries[i].Score > entries[j].Score
+			}
+			return entries[i].Name < entries[j].Name
+		})
+	}
+
+	if count > 0 && len(entries) > count {
+		entries = entries[:count]
+	}
+	for i := range entries {
+		entries[i].Position = i + 1
+	}
+
+	return &chartJSON{Window: window, By: by, Genre: genre, Computed: computed, Entries: entries}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// addChartSong - добавляет запись о песне и ее загрузки, ages - возраст каждой загрузки
+func addChartSong(t *testing.T, repos repositories, title, artist, genre string, ages ...time.Duration) *SongInfo {
+	t.Helper()
+	ctx := context.Background()
+	song := &SongInfo{ID: primitive.NewObjectID(), FileName: title + ".mp3", Title: title, Artist: artist, Genre: genre}
+	if err := repos.songs.Insert(ctx, song); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	events := make([]DownloadEvent, len(ages))
+	for i, age := range ages {
+		events[i] = DownloadEvent{ID: primitive.NewObjectID(), Song: song.ID, Time: now.Add(-age)}
+	}
+	if err := repos.downloadEvents.Insert(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+
+	return song
+}
+
+// chartNames - имена строк чарта через запятую
+func chartNames(chart *chartJSON) string {
+	names := make([]string, len(chart.Entries))
+	for i, entry := range chart.Entries {
+		names[i] = entry.Name
+	}
+
+	return strings.Join(names, ",")
+}
+
+// sameAges - count загрузок одного возраста
+func sameAges(age time.Duration, count int) []time.Duration {
+	ages := make([]time.Duration, count)
+	for i := range ages {
+		ages[i] = age
+	}
+
+	return ages
+}
+
+func TestChartsWindows(t *testing.T) {
+	repos := newMemoryRepositories()
+	job := newChartsJob(repos.songs, repos.downloadEvents, 0)
+	addChartSong(t, repos, "Recent", "A", "Rock", append([]time.Duration{time.Hour}, sameAges(3*24*time.Hour, 3)...)...)
+	addChartSong(t, repos, "Older", "B", "Rock", sameAges(10*24*time.Hour, 5)...)
+	addChartSong(t, repos, "Expired", "C", "Rock", sameAges(40*24*time.Hour, 9)...)
+
+	job.compute()
+	for _, test := range []struct {
+		window, names string
+		downloads     []int64
+	}{
+		{chartWindowDay, "Recent", []int64{1}},
+		{chartWindowWeek, "Recent", []int64{4}},
+		{chartWindowMonth, "Older,Recent", []int64{5, 4}},
+	} {
+		chart := job.chart(test.window, chartBySong, "", 0)
+		if chartNames(chart) != test.names || len(chart.Entries) != len(test.downloads) {
+			t.Errorf("%v: entries = %+v, want %v", test.window, chart.Entries, test.names)
+			continue
+		}
+		for i, entry := range chart.Entries {
+			if entry.Position != i+1 || entry.Downloads != test.downloads[i] || entry.Score != float64(test.downloads[i]) {
+				t.Errorf("%v: entry %+v, want %v downloads", test.window, entry, test.downloads[i])
+			}
+		}
+	}
+
+	// События старше самого длинного окна удаляются при расчете
+	var events int
+	repos.downloadEvents.EachSince(context.Background(), time.Time{}, func(*DownloadEvent) { events++ })
+	if events != 9 {
+		t.Errorf("%d download events after compute, want 9", events)
+	}
+}
+
+func TestChartsTrending(t *testing.T) {
+	repos := newMemoryRepositories()
+	job := newChartsJob(repos.songs, repos.downloadEvents, 0)
+	addChartSong(t, repos, "Fresh", "A", "Pop", time.Minute)
+	addChartSong(t, repos, "Fading", "B", "Pop", sameAges(3*trendingHalfLife, 4)...)
+
+	job.compute()
+	trending := job.chart(chartWindowTrending, chartBySong, "", 0)
+	if chartNames(trending) != "Fresh,Fading" {
+		t.Fatalf("trending = %v, want Fresh,Fading", chartNames(trending))
+	}
+	// Через три периода полураспада четыре загрузки весят как половина одной
+	for i, want := range []float64{1, 0.5} {
+		if entry := trending.Entries[i]; math.Abs(entry.Score-want) > 0.01 {
+			t.Errorf("%v score = %v, want %v", entry.Name, entry.Score, want)
+		}
+	}
+	if trending.Entries[1].Downloads != 4 {
+		t.Errorf("Fading downloads = %v, want 4", trending.Entries[1].Downloads)
+	}
+
+	if week := job.chart(chartWindowWeek, chartBySong, "", 0); chartNames(week) != "Fading,Fresh" {
+		t.Errorf("7d = %v, want Fading,Fresh", chartNames(week))
+	}
+}
+
+func TestChartsGroups(t *testing.T) {
+	repos := newMemoryRepositories()
+	job := newChartsJob(repos.songs, repos.downloadEvents, 0)
+	addChartSong(t, repos, "S1", "X", "Rock", sameAges(time.Hour, 3)...)
+	addChartSong(t, repos, "S2", "Y", "Pop", sameAges(time.Hour, 4)...)
+	addChartSong(t, repos, "S3", "X", "Pop", sameAges(time.Hour, 2)...)
+	addChartSong(t, repos, "S4", "Z", "Jazz", sameAges(time.Hour, 5)...)
+
+	job.compute()
+	for _, test := range []struct {
+		by, genre string
+		count     int
+		names     string
+	}{
+		{chartBySong, "", 0, "S4,S2,S1,S3"},
+		// X и Z набрали поровну, при равенстве порядок задает имя
+		{chartByArtist, "", 0, "X,Z,Y"},
+		{chartByGenre, "", 0, "Pop,Jazz,Rock"},
+		{chartBySong, "pOP", 0, "S2,S3"},
+		{chartByArtist, "POP", 0, "Y,X"},
+		{chartBySong, "", 2, "S4,S2"},
+	} {
+		chart := job.chart(chartWindowDay, test.by, test.genre, test.count)
+		if chartNames(chart) != test.names {
+			t.Errorf("by %v, genre %q, count %v = %v, want %v", test.by, test.genre, test.count, chartNames(chart), test.names)
+		}
+	}
+
+	artists := job.chart(chartWindowDay, chartByArtist, "", 0)
+	for i, want := range []int64{5, 5, 4} {
+		if entry := artists.Entries[i]; entry.Downloads != want || entry.Position != i+1 || entry.Song != nil {
+			t.Errorf("artist entry %+v, want %v downloads at %v", entry, want, i+1)
+		}
+	}
+}
+
+func TestChartsExcludeTrashed(t *testing.T) {
+	ctx := context.Background()
+	repos := newMemoryRepositories()
+	job := newChartsJob(repos.songs, repos.downloadEvents, 0)
+	trashed := addChartSong(t, repos, "Trashed", "A", "Rock", sameAges(time.Hour, 3)...)
+	addChartSong(t, repos, "Kept", "B", "Rock", time.Hour)
+	if err := repos.songs.Trash(ctx, trashed.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	job.compute()
+	for window := range chartWindows {
+		if chart := job.chart(window, chartBySong, "", 0); chartNames(chart) != "Kept" {
+			t.Errorf("%v = %v, want Kept", window, chartNames(chart))
+		}
+	}
+}
+
+func TestGetCharts(t *testing.T) {
+	h := newTestHandlers(t, newMemoryRepositories())
+	get := func(query string) *httptest.ResponseRecorder {
+		return serve(h.getCharts, httptest.NewRequest(http.MethodGet, "/getCharts?"+query, nil))
+	}
+
+	w := get("")
+	if w.Code != http.StatusNotFound || decodeError(t, w).Code != string(codeChartsNotReady) {
+		t.Fatalf("charts before compute: %v %v", w.Code, w.Body.String())
+	}
+
+	addChartSong(t, h.repositories, "Song", "Artist", "Rock", time.Hour)
+	h.charts.compute()
+
+	for query, parameter := range map[string]string{
+		"window=1y":                           "window",
+		"window=7D":                           "window",
+		"by=album":                            "by",
+		"count=-1":                            "count",
+		"limit=many":                          "limit",
+		"by=":                                 "",
+		"window=trending&by=genre&genre=ROCK": "",
+	} {
+		w = get(query)
+		if parameter == "" {
+			if w.Code != http.StatusOK {
+				t.Errorf("%q: %v %v", query, w.Code, w.Body.String())
+			}
+			continue
+		}
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: %v %v", query, w.Code, w.Body.String())
+			continue
+		}
+		result := decodeError(t, w)
+		if result.Code != string(codeInvalidParameter) || result.Details["parameter"] != parameter {
+			t.Errorf("%q: error %+v, want invalid %v", query, result, parameter)
+		}
+	}
+
+	w = get("")
+	var chart chartJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &chart); err != nil {
+		t.Fatalf("getCharts %q: %v", w.Body.String(), err)
+	}
+	if chart.Window != chartWindowWeek || chart.By != chartBySong || chartNames(&chart) != "Song" || chart.Computed.IsZero() {
+		t.Errorf("default chart = %+v", chart)
+	}
+}
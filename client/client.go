@@ -0,0 +1,468 @@
+// Package client - клиент HTTP API AudioServer. Скрывает особенности запросов первой версии
+// (например, список ID в виде JSON строки в поле формы) и использует вторую версию API там,
+// где она есть. Описание API отдается сервером по адресу /openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SongInfo - информация о песне
+type SongInfo struct {
+	ID              string     `json:"id"`
+	FileName        string     `json:"FileName"`
+	Title           string     `json:"Title"`
+	Artist          string     `json:"Artist"`
+	Genre           string     `json:"Genre"`
+	Album           string     `json:"Album"`
+	AlbumArtist     string     `json:"AlbumArtist"`
+	Bitrate         int        `json:"Bitrate"`  // килобит в секунду
+	Duration        int        `json:"Duration"` // продолжительность в секундах
+	CountOfDownload int64      `json:"CountOfDownload"`
+	CountOfPlays    int64      `json:"CountOfPlays"`
+	Size            int        `json:"Size"` // размер в байтах
+	UploadDate      time.Time  `json:"UploadDate"`
+	Loudness        float64    `json:"Loudness"`  // интегральная громкость по EBU R128 в LUFS
+	TruePeak        float64    `json:"TruePeak"`  // истинный пиковый уровень в dBTP
+	TrackGain       float64    `json:"TrackGain"` // ReplayGain трека в дБ
+	TrackPeak       float64    `json:"TrackPeak"`
+	IsAnalyzed      bool       `json:"IsAnalyzed"`
+	AlbumGain       float64    `json:"AlbumGain"` // ReplayGain альбома (или плейлиста) в дБ
+	AlbumPeak       float64    `json:"AlbumPeak"`
+	IsAlbumAnalyzed bool       `json:"IsAlbumAnalyzed"`
+	PayloadHash     string     `json:"PayloadHash"`
+	DuplicateOf     string     `json:"DuplicateOf,omitempty"` // ID песни с такими же аудиоданными
+	Blob            string     `json:"Blob,omitempty"`
+	DeletedAt       *time.Time `json:"DeletedAt,omitempty"`
+}
+
+// PlayList - плейлист
+type PlayList struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"Name"`
+	IDs       []string   `json:"IDs"` // ID песен в порядке воспроизведения
+	DeletedAt *time.Time `json:"DeletedAt,omitempty"`
+}
+
+// SongPage - страница списка песен
+type SongPage struct {
+	Items      []SongInfo `json:"items"`
+	NextCursor string     `json:"nextCursor"` // курсор следующей страницы, пустой на последней странице
+}
+
+// PlaylistPage - страница списка плейлистов
+type PlaylistPage struct {
+	Items      []PlayList `json:"items"`
+	NextCursor string     `json:"nextCursor"` // курсор следующей страницы, пустой на последней странице
+}
+
+// SongPatch - изменяемые метаданные песни, nil поля не изменяются
+type SongPatch struct {
+	Title       *string `json:"Title,omitempty"`
+	Artist      *string `json:"Artist,omitempty"`
+	Genre       *string `json:"Genre,omitempty"`
+	Album       *string `json:"Album,omitempty"`
+	AlbumArtist *string `json:"AlbumArtist,omitempty"`
+}
+
+// PlaylistPatch - изменения плейлиста, nil поля не изменяются
+type PlaylistPatch struct {
+	Name *string   `json:"Name,omitempty"`
+	IDs  *[]string `json:"IDs,omitempty"`
+}
+
+// SongListOptions - параметры выборки страницы песен, нулевые значения не передаются
+type SongListOptions struct {
+	Sort         string // uploadDate, downloads, plays, title, artist, bitrate или duration
+	Order        string // asc или desc, по умолчанию зависит от поля сортировки
+	Limit        int    // размер страницы
+	Cursor       string // NextCursor предыдущей страницы
+	Genre        string
+	Artist       string
+	MinBitrate   int
+	MaxBitrate   int
+	MinDuration  int
+	MaxDuration  int
+	UploadedFrom time.Time
+	UploadedTo   time.Time
+}
+
+// PlaylistListOptions - параметры выборки страницы плейлистов, нулевые значения не передаются
+type PlaylistListOptions struct {
+	Sort   string // created или name
+	Order  string // asc или desc
+	Limit  int
+	Cursor string
+}
+
+// Error - ошибка, которой ответил сервер
+type Error struct {
+	StatusCode int                    `json:"-"`
+	Code       string                 `json:"code"`    // стабильный код ошибки, например song_not_found
+	Message    string                 `json:"message"` // сообщение для пользователя на языке Client.Lang
+	Details    map[string]interface{} `json:"details"`
+	RequestID  string                 `json:"requestId"` // ID запроса в логах сервера
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("audioserver: %v %v: %v", err.StatusCode, err.Code, err.Message)
+}
+
+// Client - клиент API одного сервера
+type Client struct {
+	BaseURL    string       // адрес сервера, например http://localhost:8080
+	HTTPClient *http.Client // по умолчанию http.DefaultClient
+	Lang       string       // язык сообщений об ошибках: ru или en, пустой - по умолчанию сервера
+}
+
+// New - конструктор для типа Client
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// UploadSong - загружает песню. Если такая песня уже есть, то возвращает *Error
+// с кодом duplicate_song, ID существующей песни - в Details["id"].
+func (c *Client) UploadSong(ctx context.Context, fileName string, file io.Reader) (*SongInfo, error) {
+	// Тело пишется в канал, чтобы не держать файл в памяти целиком
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile("file", fileName)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	// Если сервер ответил, не дочитав тело, то запись в канал прерывается
+	defer reader.Close()
+
+	var song SongInfo
+	err := c.doJSON(ctx, "POST", "/api/v2/songs", nil, form.FormDataContentType(), reader, &song)
+	if err != nil {
+		return nil, err
+	}
+
+	return &song, nil
+}
+
+// Song - метаданные песни
+func (c *Client) Song(ctx context.Context, id string) (*SongInfo, error) {
+	var song SongInfo
+	err := c.doJSON(ctx, "GET", "/api/v2/songs/"+url.PathEscape(id), nil, "", nil, &song)
+	if err != nil {
+		return nil, err
+	}
+
+	return &song, nil
+}
+
+// Songs - метаданные песен по списку ID, порядок не определен. Песни из корзины пропускаются.
+func (c *Client) Songs(ctx context.Context, ids []string) ([]SongInfo, error) {
+	params, err := idsValues(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var songs []SongInfo
+	err = c.doForm(ctx, "/getMetadataOfSongsbyIDs", params, &songs)
+	return songs, err
+}
+
+// ListSongs - страница песен, по умолчанию сначала новые
+func (c *Client) ListSongs(ctx context.Context, opts *SongListOptions) (*SongPage, error) {
+	var page SongPage
+	err := c.doJSON(ctx, "GET", "/api/v2/songs", opts.values(), "", nil, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// SearchSongs - песни, у которых исполнитель, название или жанр содержит хотя бы одно из слов search
+func (c *Client) SearchSongs(ctx context.Context, search string) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := c.doForm(ctx, "/searchSongs", url.Values{"searchString": {search}}, &songs)
+	return songs, err
+}
+
+// UpdateSong - изменяет метаданные песни в БД сервера (тэги файла не изменяются)
+func (c *Client) UpdateSong(ctx context.Context, id string, patch *SongPatch) (*SongInfo, error) {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var song SongInfo
+	err = c.doJSON(ctx, "PATCH", "/api/v2/songs/"+url.PathEscape(id), nil, "application/json", bytes.NewReader(body), &song)
+	if err != nil {
+		return nil, err
+	}
+
+	return &song, nil
+}
+
+// DeleteSong - перемещает песню в корзину
+func (c *Client) DeleteSong(ctx context.Context, id string) error {
+	return c.doJSON(ctx, "DELETE", "/api/v2/songs/"+url.PathEscape(id), nil, "", nil, nil)
+}
+
+// DownloadSong - файл песни. Если download = true, то файл, прочитанный до конца,
+// засчитывается как загрузка. Тело нужно закрыть.
+func (c *Client) DownloadSong(ctx context.Context, id string, download bool) (io.ReadCloser, error) {
+	params := url.Values{}
+	if download {
+		params.Set("download", "true")
+	}
+
+	return c.doStream(ctx, "/api/v2/songs/"+url.PathEscape(id)+"/stream", params)
+}
+
+// SongsZip - zip архив с песнями. Тело нужно закрыть.
+func (c *Client) SongsZip(ctx context.Context, ids []string) (io.ReadCloser, error) {
+	params, err := idsValues(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.doStream(ctx, "/getSongsInZip", params)
+}
+
+// CreatePlaylist - создает плейлист из песен ids
+func (c *Client) CreatePlaylist(ctx context.Context, name string, ids []string) (*PlayList, error) {
+	if ids == nil {
+		ids = []string{}
+	}
+	body, err := json.Marshal(&PlaylistPatch{Name: &name, IDs: &ids})
+	if err != nil {
+		return nil, err
+	}
+
+	var playList PlayList
+	err = c.doJSON(ctx, "POST", "/api/v2/playlists", nil, "application/json", bytes.NewReader(body), &playList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &playList, nil
+}
+
+// Playlist - плейлист
+func (c *Client) Playlist(ctx context.Context, id string) (*PlayList, error) {
+	var playList PlayList
+	err := c.doJSON(ctx, "GET", "/api/v2/playlists/"+url.PathEscape(id), nil, "", nil, &playList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &playList, nil
+}
+
+// ListPlaylists - страница плейлистов, по умолчанию сначала новые
+func (c *Client) ListPlaylists(ctx context.Context, opts *PlaylistListOptions) (*PlaylistPage, error) {
+	var page PlaylistPage
+	err := c.doJSON(ctx, "GET", "/api/v2/playlists", opts.values(), "", nil, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// SearchPlaylists - плейлисты, название которых содержит хотя бы одно из слов search
+func (c *Client) SearchPlaylists(ctx context.Context, search string) ([]PlayList, error) {
+	var playLists []PlayList
+	err := c.doForm(ctx, "/searchPlaylists", url.Values{"searchString": {search}}, &playLists)
+	return playLists, err
+}
+
+// UpdatePlaylist - переименовывает плейлист или заменяет список его песен
+func (c *Client) UpdatePlaylist(ctx context.Context, id string, patch *PlaylistPatch) (*PlayList, error) {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var playList PlayList
+	err = c.doJSON(ctx, "PATCH", "/api/v2/playlists/"+url.PathEscape(id), nil, "application/json", bytes.NewReader(body), &playList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &playList, nil
+}
+
+// DeletePlaylist - перемещает плейлист в корзину
+func (c *Client) DeletePlaylist(ctx context.Context, id string) error {
+	return c.doJSON(ctx, "DELETE", "/api/v2/playlists/"+url.PathEscape(id), nil, "", nil, nil)
+}
+
+// PlaylistZip - zip архив плейлиста с песнями и списком m3u8. Тело нужно закрыть.
+func (c *Client) PlaylistZip(ctx context.Context, id string) (io.ReadCloser, error) {
+	return c.doStream(ctx, "/api/v2/playlists/"+url.PathEscape(id)+"/zip", nil)
+}
+
+// values - параметры запроса страницы песен
+func (opts *SongListOptions) values() url.Values {
+	params := url.Values{}
+	if opts == nil {
+		return params
+	}
+
+	setString(params, "sort", opts.Sort)
+	setString(params, "order", opts.Order)
+	setInt(params, "limit", opts.Limit)
+	setString(params, "cursor", opts.Cursor)
+	setString(params, "genre", opts.Genre)
+	setString(params, "artist", opts.Artist)
+	setInt(params, "minBitrate", opts.MinBitrate)
+	setInt(params, "maxBitrate", opts.MaxBitrate)
+	setInt(params, "minDuration", opts.MinDuration)
+	setInt(params, "maxDuration", opts.MaxDuration)
+	if !opts.UploadedFrom.IsZero() {
+		params.Set("uploadedFrom", opts.UploadedFrom.Format(time.RFC3339))
+	}
+	if !opts.UploadedTo.IsZero() {
+		params.Set("uploadedTo", opts.UploadedTo.Format(time.RFC3339))
+	}
+
+	return params
+}
+
+// values - параметры запроса страницы плейлистов
+func (opts *PlaylistListOptions) values() url.Values {
+	params := url.Values{}
+	if opts == nil {
+		return params
+	}
+
+	setString(params, "sort", opts.Sort)
+	setString(params, "order", opts.Order)
+	setInt(params, "limit", opts.Limit)
+	setString(params, "cursor", opts.Cursor)
+
+	return params
+}
+
+// setString - добавляет непустой параметр
+func setString(params url.Values, name, value string) {
+	if value != "" {
+		params.Set(name, value)
+	}
+}
+
+// setInt - добавляет ненулевой параметр
+func setInt(params url.Values, name string, value int) {
+	if value != 0 {
+		params.Set(name, strconv.Itoa(value))
+	}
+}
+
+// idsValues - список ID в том виде, в котором его принимают запросы первой версии: JSON строкой в поле ids
+func idsValues(ids []string) (url.Values, error) {
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return url.Values{"ids": {string(data)}}, nil
+}
+
+// do - выполняет запрос. Ответ с кодом не 2xx возвращается как *Error.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	// Запросы первой версии по умолчанию могут отвечать ошибками простым текстом
+	query := url.Values{"errorFormat": {"json"}}
+	for name, values := range params {
+		query[name] = values
+	}
+	address := c.BaseURL + path + "?" + query.Encode()
+
+	request, err := http.NewRequest(method, address, body)
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if c.Lang != "" {
+		request.Header.Set("Accept-Language", c.Lang)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		return nil, responseError(response)
+	}
+
+	return response, nil
+}
+
+// doJSON - выполняет запрос и читает JSON ответа в result (если result не nil)
+func (c *Client) doJSON(ctx context.Context, method, path string, params url.Values, contentType string, body io.Reader, result interface{}) error {
+	response, err := c.do(ctx, method, path, params, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// doForm - выполняет запрос первой версии с параметрами в форме и читает JSON ответа в result
+func (c *Client) doForm(ctx context.Context, path string, params url.Values, result interface{}) error {
+	return c.doJSON(ctx, "POST", path, nil, "application/x-www-form-urlencoded", strings.NewReader(params.Encode()), result)
+}
+
+// doStream - выполняет GET запрос и возвращает тело ответа
+func (c *Client) doStream(ctx context.Context, path string, params url.Values) (io.ReadCloser, error) {
+	response, err := c.do(ctx, "GET", path, params, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+// responseError - ошибка из ответа сервера. Если тело не JSON (например, ответ прокси),
+// то оно становится сообщением ошибки.
+func responseError(response *http.Response) error {
+	data, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+
+	apiError := &Error{StatusCode: response.StatusCode}
+	if json.Unmarshal(data, apiError) != nil || apiError.Code == "" {
+		apiError.Code = ""
+		apiError.Message = strings.TrimSpace(string(data))
+	}
+
+	return apiError
+}
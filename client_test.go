@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/STEJLS/AudioServer/client"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestServer - сервер со всеми маршрутами первой и второй версии API и клиент к нему
+func newTestServer(t *testing.T, repos repositories) (*httptest.Server, *client.Client) {
+	t.Helper()
+	h := newTestHandlers(t, repos)
+	server := httptest.NewServer(withRequestID(h.newMux()))
+	t.Cleanup(server.Close)
+
+	return server, client.New(server.URL)
+}
+
+// uploadTestSong - загружает песню через клиент
+func uploadTestSong(t *testing.T, c *client.Client, seed int64, title string) *client.SongInfo {
+	t.Helper()
+	song, err := c.UploadSong(context.Background(), title+".wav", bytes.NewReader(testWAV(seed, title, "Tester")))
+	if err != nil {
+		t.Fatalf("UploadSong(%v): %v", title, err)
+	}
+
+	return song
+}
+
+// apiError - проверяет, что err - ошибка сервера с заданными статусом и кодом
+func apiError(t *testing.T, err error, status int, code errorCode) *client.Error {
+	t.Helper()
+	apiErr, ok := err.(*client.Error)
+	if !ok {
+		t.Fatalf("error = %v, want *client.Error", err)
+	}
+	if apiErr.StatusCode != status || apiErr.Code != string(code) {
+		t.Fatalf("error = %v %v, want %v %v", apiErr.StatusCode, apiErr.Code, status, code)
+	}
+	if apiErr.RequestID == "" || apiErr.Message == "" {
+		t.Errorf("error without request ID or message: %+v", apiErr)
+	}
+
+	return apiErr
+}
+
+func TestClientUploadSong(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		_, c := newTestServer(t, repos)
+		ctx := context.Background()
+
+		song := uploadTestSong(t, c, 1, "First")
+		if song.ID == "" || song.Title != "First" || song.Artist != "Tester" {
+			t.Fatalf("uploaded %+v", song)
+		}
+
+		got, err := c.Song(ctx, song.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != song.ID || got.Title != "First" {
+			t.Errorf("Song = %+v", got)
+		}
+
+		_, err = c.UploadSong(ctx, "again.wav", bytes.NewReader(testWAV(1, "First", "Tester")))
+		apiErr := apiError(t, err, http.StatusConflict, codeDuplicateSong)
+		if apiErr.Details["id"] != song.ID {
+			t.Errorf("duplicate details = %v, want id %v", apiErr.Details, song.ID)
+		}
+	})
+}
+
+func TestClientListSongs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		_, c := newTestServer(t, repos)
+		ctx := context.Background()
+
+		uploaded := map[string]bool{}
+		for i, title := range []string{"One", "Two", "Three"} {
+			uploaded[uploadTestSong(t, c, int64(i+1), title).ID] = true
+		}
+
+		seen := map[string]bool{}
+		opts := &client.SongListOptions{Sort: "title", Limit: 2}
+		var titles []string
+		for pages := 0; ; pages++ {
+			if pages == len(uploaded) {
+				t.Fatal("pagination does not end")
+			}
+			page, err := c.ListSongs(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Items) > opts.Limit {
+				t.Errorf("page of %d songs, limit %d", len(page.Items), opts.Limit)
+			}
+			for _, song := range page.Items {
+				if seen[song.ID] || !uploaded[song.ID] {
+					t.Errorf("unexpected song %v on page %d", song.ID, pages)
+				}
+				seen[song.ID] = true
+				titles = append(titles, song.Title)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		if strings.Join(titles, ",") != "One,Three,Two" {
+			t.Errorf("titles = %v", titles)
+		}
+
+		_, err := c.ListSongs(ctx, &client.SongListOptions{Sort: "artist", Cursor: opts.Cursor})
+		apiErr := apiError(t, err, http.StatusBadRequest, codeInvalidParameter)
+		if apiErr.Details["reason"] != string(reasonCursorMismatch) {
+			t.Errorf("cursor details = %v", apiErr.Details)
+		}
+	})
+}
+
+// TestClientSearchSongs - поиск без учета регистра одинаков во всех хранилищах, в том числе
+// для слов короче трех символов, которые в SQLite ищутся мимо полнотекстового индекса
+func TestClientSearchSongs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		_, c := newTestServer(t, repos)
+		for i, title := range []string{"Ом", "Оркестр", "Jazz"} {
+			uploadTestSong(t, c, int64(i+1), title)
+		}
+
+		for search, want := range map[string]string{
+			"ом":      "Ом",
+			"ОРКЕСТР": "Оркестр",
+			"рк":      "Оркестр",
+			"JA":      "Jazz",
+			"azz":     "Jazz",
+		} {
+			songs, err := c.SearchSongs(context.Background(), search)
+			if err != nil {
+				t.Fatalf("SearchSongs(%q): %v", search, err)
+			}
+			if len(songs) != 1 || songs[0].Title != want {
+				t.Errorf("SearchSongs(%q) = %+v, want %v", search, songs, want)
+			}
+		}
+	})
+}
+
+func TestClientUpdateSong(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		_, c := newTestServer(t, repos)
+		ctx := context.Background()
+		song := uploadTestSong(t, c, 1, "Before")
+
+		title, genre := "After", "Jazz"
+		updated, err := c.UpdateSong(ctx, song.ID, &client.SongPatch{Title: &title, Genre: &genre})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Title != title || updated.Genre != genre || updated.Artist != "Tester" {
+			t.Errorf("UpdateSong = %+v", updated)
+		}
+
+		got, err := c.Song(ctx, song.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != title {
+			t.Errorf("Song after update = %+v", got)
+		}
+
+		_, err = c.UpdateSong(ctx, primitive.NewObjectID().Hex(), &client.SongPatch{Title: &title})
+		apiError(t, err, http.StatusNotFound, codeSongNotFound)
+	})
+}
+
+func TestClientDeleteRestoreSong(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		server, c := newTestServer(t, repos)
+		ctx := context.Background()
+		song := uploadTestSong(t, c, 1, "Trashed")
+
+		err := c.DeleteSong(ctx, song.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Song(ctx, song.ID)
+		apiError(t, err, http.StatusNotFound, codeSongNotFound)
+		err = c.DeleteSong(ctx, song.ID)
+		apiError(t, err, http.StatusNotFound, codeSongNotFound)
+
+		// В клиенте нет восстановления из корзины, оно есть только в первой версии API
+		response, err := http.PostForm(server.URL+"/restoreSong", url.Values{"id": {song.ID}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("restoreSong status %v", response.StatusCode)
+		}
+
+		got, err := c.Song(ctx, song.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.DeletedAt != nil {
+			t.Errorf("restored song is still in the trash: %+v", got)
+		}
+	})
+}
+
+func TestClientErrorEnvelope(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		_, c := newTestServer(t, repos)
+		ctx := context.Background()
+
+		c.Lang = "en"
+		_, err := c.Song(ctx, primitive.NewObjectID().Hex())
+		apiErr := apiError(t, err, http.StatusNotFound, codeSongNotFound)
+		c.Lang = "ru"
+		_, err = c.Song(ctx, primitive.NewObjectID().Hex())
+		if ru := apiError(t, err, http.StatusNotFound, codeSongNotFound); ru.Message == apiErr.Message {
+			t.Errorf("message is not localized: %q", ru.Message)
+		}
+
+		// Ошибки первой версии приходят в том же формате
+		_, err = c.Songs(ctx, []string{"not an id"})
+		apiError(t, err, http.StatusBadRequest, codeInvalidIDs)
+	})
+}
+
+// Каждый метод каждого пути из описания API должен обрабатываться сервером
+func TestOpenAPIRoutesServed(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		server, _ := newTestServer(t, repos)
+
+		var document struct {
+			Paths map[string]map[string]json.RawMessage `json:"paths"`
+		}
+		err := json.Unmarshal([]byte(openAPIDocument), &document)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		methods := []string{"get", "post", "put", "patch", "delete"}
+		for path, operations := range document.Paths {
+			for _, method := range methods {
+				if _, ok := operations[method]; !ok {
+					continue
+				}
+				target := server.URL + strings.Replace(path, "{id}", primitive.NewObjectID().Hex(), 1) + "?errorFormat=json"
+				request, err := http.NewRequest(strings.ToUpper(method), target, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				response, err := http.DefaultClient.Do(request)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var result errorJSON
+				json.NewDecoder(response.Body).Decode(&result)
+				response.Body.Close()
+
+				// Неизвестный ServeMux путь - 404 без кода ошибки, неизвестный путь второй версии - resource_not_found
+				notFound := response.StatusCode == http.StatusNotFound && (result.Code == "" || result.Code == string(codeResourceNotFound))
+				if notFound || response.StatusCode == http.StatusMethodNotAllowed {
+					t.Errorf("%v %v is not served: %v %v", method, path, response.StatusCode, result.Code)
+				}
+			}
+		}
+	})
+}
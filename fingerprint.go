@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/STEJLS/AudioServer/analysis"
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newFingerprint - конструктор для типа Fingerprint, суботпечатки хранятся как байты
+func newFingerprint(id primitive.ObjectID, duration int, fingerprint analysis.Fingerprint) *Fingerprint {
+	data := make([]byte, 4*len(fingerprint))
+	for i, v := range fingerprint {
+		binary.LittleEndian.PutUint32(data[4*i:], v)
+	}
+
+	return &Fingerprint{
+		ID:       id,
+		Duration: duration,
+		Data:     data,
+	}
+}
+
+// values - переводит хранимый отпечаток обратно в суботпечатки
+func (fingerprint *Fingerprint) values() analysis.Fingerprint {
+	values := make(analysis.Fingerprint, len(fingerprint.Data)/4)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint32(fingerprint.Data[4*i:])
+	}
+
+	return values
+}
+
+// findDuplicateByFingerprint - ищет в БД песню, акустически совпадающую с переданной.
+// Кандидаты отбираются по продолжительности, затем сравниваются отпечатки.
+// Возвращает ID самой похожей песни со сходством не ниже порога или пустой ID.
+func findDuplicateByFingerprint(ctx context.Context, fingerprints FingerprintRepository, fingerprint *Fingerprint) (primitive.ObjectID, error) {
+	values := fingerprint.values()
+
+	var duplicateID primitive.ObjectID
+	best := fingerprintThreshold
+	err := fingerprints.EachByDuration(ctx,
+		fingerprint.Duration-fingerprintDurationTolerance,
+		fingerprint.Duration+fingerprintDurationTolerance,
+		func(candidate *Fingerprint) {
+			similarity := values.Similarity(candidate.values())
+			if similarity >= best {
+				best = similarity
+				duplicateID = candidate.ID
+			}
+		})
+	if err != nil {
+		i18n.Error("fingerprint.db_error", err)
+		return primitive.NilObjectID, err
+	}
+
+	if !duplicateID.IsZero() {
+		i18n.Info("fingerprint.duplicate", duplicateID.Hex(), best)
+	}
+
+	return duplicateID, nil
+}
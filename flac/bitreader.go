@@ -0,0 +1,74 @@
+package flac
+
+import (
+	"bufio"
+	"math/bits"
+)
+
+// bitReader - читает из потока последовательности бит (старший бит первым)
+type bitReader struct {
+	reader *bufio.Reader
+	cache  uint64 // последние прочитанные байты
+	n      uint   // количество непрочитанных бит в cache
+}
+
+// newBitReader - конструктор для типа bitReader
+func newBitReader(reader *bufio.Reader) *bitReader {
+	return &bitReader{reader: reader}
+}
+
+// readBits - читает n (не больше 56) бит и возвращает их как беззнаковое число
+func (br *bitReader) readBits(n uint) (uint64, error) {
+	for br.n < n {
+		b, err := br.reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		br.cache = br.cache<<8 | uint64(b)
+		br.n += 8
+	}
+
+	br.n -= n
+	return br.cache >> br.n & (1<<n - 1), nil
+}
+
+// readSigned - читает n бит как число в дополнительном коде
+func (br *bitReader) readSigned(n uint) (int64, error) {
+	v, err := br.readBits(n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	return int64(v<<(64-n)) >> (64 - n), nil
+}
+
+// readUnary - читает число в унарном коде (количество нулей до первой единицы)
+func (br *bitReader) readUnary() (uint64, error) {
+	var count uint64
+	for {
+		if br.n == 0 {
+			b, err := br.reader.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			br.cache, br.n = uint64(b), 8
+		}
+
+		rest := br.cache & (1<<br.n - 1)
+		if rest == 0 {
+			count += uint64(br.n)
+			br.n = 0
+			continue
+		}
+
+		zeros := uint(bits.LeadingZeros64(rest)) - (64 - br.n)
+		count += uint64(zeros)
+		br.n -= zeros + 1
+		return count, nil
+	}
+}
+
+// alignToByte - пропускает биты до границы байта
+func (br *bitReader) alignToByte() {
+	br.n -= br.n % 8
+}
@@ -0,0 +1,87 @@
+package flac
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// pictureTypeFrontCover - тип изображения "передняя обложка" в блоке PICTURE
+const pictureTypeFrontCover = 3
+
+// Cover - возвращает обложку из блоков метаданных PICTURE и ее MIME тип.
+// Предпочитается передняя обложка, иначе берется первое изображение.
+// Если изображений нет, то возвращается nil.
+func Cover(rs io.ReadSeeker) ([]byte, string) {
+	err := findFlacMarker(rs)
+	if err != nil {
+		return nil, ""
+	}
+
+	var cover []byte
+	var mimeType string
+	header := new(metaHeader)
+	for !header.IsLast {
+		err = header.Parse(rs)
+		if err != nil {
+			break
+		}
+
+		if header.Type != 6 { // PICTURE
+			_, err = rs.Seek(int64(header.Length), os.SEEK_CUR)
+			if err != nil {
+				break
+			}
+			continue
+		}
+
+		data := make([]byte, header.Length)
+		_, err = io.ReadFull(rs, data)
+		if err != nil {
+			break
+		}
+
+		pictureType, picture, pictureMIME := parsePicture(data)
+		if picture == nil {
+			continue
+		}
+		if pictureType == pictureTypeFrontCover {
+			return picture, pictureMIME
+		}
+		if cover == nil {
+			cover, mimeType = picture, pictureMIME
+		}
+	}
+
+	return cover, mimeType
+}
+
+// parsePicture - разбирает блок PICTURE: тип, MIME тип, описание, размеры изображения, данные
+func parsePicture(data []byte) (uint32, []byte, string) {
+	pointer := 0
+	next := func(n int) []byte {
+		if n < 0 || pointer+n > len(data) {
+			return nil
+		}
+		pointer += n
+		return data[pointer-n : pointer]
+	}
+	readUint32 := func() int {
+		field := next(4)
+		if field == nil {
+			return -1
+		}
+		return int(binary.BigEndian.Uint32(field))
+	}
+
+	pictureType := readUint32()
+	mimeType := next(readUint32())
+	description := next(readUint32())
+	dimensions := next(16) // ширина, высота, глубина цвета, размер палитры
+	picture := next(readUint32())
+	if pictureType < 0 || mimeType == nil || description == nil || dimensions == nil || len(picture) == 0 {
+		return 0, nil, ""
+	}
+
+	return uint32(pictureType), picture, string(mimeType)
+}
@@ -0,0 +1,482 @@
+package flac
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+)
+
+// Decoder - декодер аудиоданных FLAC в PCM
+type Decoder struct {
+	reader *bufio.Reader
+	br     *bitReader
+	info   streamInfo
+}
+
+var (
+	errNoStreamInfo = errors.New("Блок STREAMINFO не найден")
+	errFrameHeader  = errors.New("Некорректный заголовок фрейма flac")
+	errSubframe     = errors.New("Некорректный подфрейм flac")
+)
+
+// Размеры блоков и частоты дискретизации, закодированные в заголовке фрейма
+var (
+	blockSizes  = [16]int{0, 192, 576, 1152, 2304, 4608, 0, 0, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768}
+	sampleRates = [12]int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+	sampleSizes = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+)
+
+// Коэффициенты фиксированных предсказателей порядков 0-4
+var fixedCoefficients = [5][]int64{
+	{},
+	{1},
+	{2, -1},
+	{3, -3, 1},
+	{4, -6, 4, -1},
+}
+
+// NewDecoder - конструктор для типа Decoder. Читает блоки метаданных
+// и устанавливает указатель на первый фрейм с аудиоданными.
+func NewDecoder(rs io.ReadSeeker) (*Decoder, error) {
+	err := findFlacMarker(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := new(Decoder)
+	header := new(metaHeader)
+	found := false
+	for {
+		err = header.Parse(rs)
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Type == 0 { // STREAMINFO
+			err = decoder.info.Parse(rs)
+			if err != nil {
+				return nil, err
+			}
+			_, err = rs.Seek(int64(header.Length-streamInfoSize), os.SEEK_CUR)
+			found = true
+		} else {
+			_, err = rs.Seek(int64(header.Length), os.SEEK_CUR)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.IsLast {
+			break
+		}
+	}
+
+	if !found || decoder.info.SampleRate == 0 {
+		return nil, errNoStreamInfo
+	}
+
+	decoder.reader = bufio.NewReader(rs)
+	decoder.br = newBitReader(decoder.reader)
+
+	return decoder, nil
+}
+
+// SampleRate - возвращает частоту дискретизации в герцах
+func (d *Decoder) SampleRate() int {
+	return int(d.info.SampleRate)
+}
+
+// Channels - возвращает количество каналов
+func (d *Decoder) Channels() int {
+	return d.info.Channels
+}
+
+// ReadFrame - декодирует следующий фрейм и возвращает его отсчеты по каналам
+// в диапазоне [-1, 1]. В конце потока возвращает io.EOF.
+func (d *Decoder) ReadFrame() ([][]float64, error) {
+	var header *frameHeader
+	for header == nil {
+		err := d.sync()
+		if err != nil {
+			return nil, err
+		}
+
+		// Ложный код синхронизации пропускается вместе с прочитанным заголовком
+		header, err = d.readFrameHeader()
+		if err != nil && err != errFrameHeader {
+			return nil, err
+		}
+	}
+
+	var err error
+
+	samples := make([][]int64, header.channels)
+	for ch := range samples {
+		bps := header.bitsPerSample
+		if (header.assignment == 8 || header.assignment == 10) && ch == 1 ||
+			header.assignment == 9 && ch == 0 {
+			bps++ // канал разности хранится с одним дополнительным битом
+		}
+
+		samples[ch], err = d.readSubframe(header.blockSize, uint(bps))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d.br.alignToByte()
+	_, err = d.br.readBits(16) // CRC-16 фрейма
+	if err != nil {
+		return nil, err
+	}
+
+	decorrelate(header.assignment, samples)
+
+	scale := 1 / float64(int64(1)<<uint(header.bitsPerSample-1))
+	out := make([][]float64, len(samples))
+	for ch := range samples {
+		out[ch] = make([]float64, len(samples[ch]))
+		for i, s := range samples[ch] {
+			out[ch][i] = float64(s) * scale
+		}
+	}
+
+	return out, nil
+}
+
+// endOfStream - обрыв потока посреди фрейма считается концом потока,
+// остальные ошибки чтения (например, хранилища) передаются вызывающему
+func endOfStream(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
+
+// frameHeader - параметры фрейма аудиоданных
+type frameHeader struct {
+	blockSize     int // количество отсчетов на канал
+	sampleRate    int
+	channels      int
+	assignment    int // способ кодирования каналов (8-10 - стерео с разностным каналом)
+	bitsPerSample int
+}
+
+// sync - находит код синхронизации следующего фрейма, пропуская мусор
+func (d *Decoder) sync() error {
+	for {
+		data, err := d.reader.Peek(2)
+		if err != nil {
+			return endOfStream(err)
+		}
+
+		if data[0] == 0xFF && data[1]&0xFE == 0xF8 {
+			return nil
+		}
+
+		d.reader.Discard(1)
+	}
+}
+
+// readFrameHeader - читает заголовок фрейма
+func (d *Decoder) readFrameHeader() (*frameHeader, error) {
+	data := make([]byte, 4)
+	_, err := io.ReadFull(d.reader, data)
+	if err != nil {
+		return nil, endOfStream(err)
+	}
+
+	header := &frameHeader{
+		blockSize:     blockSizes[data[2]>>4],
+		assignment:    int(data[3] >> 4),
+		bitsPerSample: sampleSizes[data[3]>>1&7],
+	}
+
+	switch {
+	case header.assignment < 8:
+		header.channels = header.assignment + 1
+	case header.assignment <= 10:
+		header.channels = 2
+	default:
+		return nil, errFrameHeader
+	}
+
+	// Номер фрейма или отсчета в кодировке UTF-8, значение не используется
+	first, err := d.reader.ReadByte()
+	if err != nil {
+		return nil, endOfStream(err)
+	}
+	for mask := byte(0x80); first&mask != 0 && mask > 1; mask >>= 1 {
+		if mask != 0x80 {
+			_, err = d.reader.ReadByte()
+			if err != nil {
+				return nil, endOfStream(err)
+			}
+		}
+	}
+
+	switch data[2] >> 4 {
+	case 6:
+		v, err := d.br.readBits(8)
+		if err != nil {
+			return nil, endOfStream(err)
+		}
+		header.blockSize = int(v) + 1
+	case 7:
+		v, err := d.br.readBits(16)
+		if err != nil {
+			return nil, endOfStream(err)
+		}
+		header.blockSize = int(v) + 1
+	}
+
+	rateCode := data[2] & 15
+	switch {
+	case rateCode == 0:
+		header.sampleRate = int(d.info.SampleRate)
+	case rateCode < 12:
+		header.sampleRate = sampleRates[rateCode]
+	case rateCode == 12:
+		v, err := d.br.readBits(8)
+		if err != nil {
+			return nil, endOfStream(err)
+		}
+		header.sampleRate = int(v) * 1000
+	case rateCode == 13 || rateCode == 14:
+		v, err := d.br.readBits(16)
+		if err != nil {
+			return nil, endOfStream(err)
+		}
+		header.sampleRate = int(v)
+		if rateCode == 14 {
+			header.sampleRate *= 10
+		}
+	default:
+		return nil, errFrameHeader
+	}
+
+	_, err = d.br.readBits(8) // CRC-8 заголовка
+	if err != nil {
+		return nil, endOfStream(err)
+	}
+
+	if data[3]>>1&7 == 0 {
+		header.bitsPerSample = d.info.BitsPerSample
+	}
+
+	if header.blockSize == 0 || header.bitsPerSample == 0 || header.channels != d.info.Channels {
+		return nil, errFrameHeader
+	}
+
+	return header, nil
+}
+
+// readSubframe - декодирует подфрейм одного канала
+func (d *Decoder) readSubframe(blockSize int, bps uint) ([]int64, error) {
+	br := d.br
+	header, err := br.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	if header&0x80 != 0 {
+		return nil, errSubframe
+	}
+
+	// Отсчеты могут храниться без младших нулевых бит (wasted bits)
+	var wasted uint
+	if header&1 == 1 {
+		k, err := br.readUnary()
+		if err != nil {
+			return nil, err
+		}
+		wasted = uint(k) + 1
+		bps -= wasted
+	}
+
+	samples := make([]int64, blockSize)
+	kind := int(header >> 1 & 0x3F)
+	switch {
+	case kind == 0: // CONSTANT
+		v, err := br.readSigned(bps)
+		if err != nil {
+			return nil, err
+		}
+		for i := range samples {
+			samples[i] = v
+		}
+	case kind == 1: // VERBATIM
+		for i := range samples {
+			samples[i], err = br.readSigned(bps)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case kind >= 8 && kind <= 12: // FIXED
+		order := kind & 7
+		err = d.readWarmup(samples[:order], bps)
+		if err == nil {
+			err = d.readResidual(samples, order)
+		}
+		if err != nil {
+			return nil, err
+		}
+		predict(samples, fixedCoefficients[order], 0)
+	case kind >= 32: // LPC
+		order := kind&31 + 1
+		err = d.readWarmup(samples[:order], bps)
+		if err != nil {
+			return nil, err
+		}
+
+		precision, err := br.readBits(4)
+		if err != nil {
+			return nil, err
+		}
+		if precision == 15 {
+			return nil, errSubframe
+		}
+		shift, err := br.readSigned(5)
+		if err != nil {
+			return nil, err
+		}
+		if shift < 0 {
+			return nil, errSubframe
+		}
+
+		coefficients := make([]int64, order)
+		for i := range coefficients {
+			coefficients[i], err = br.readSigned(uint(precision) + 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = d.readResidual(samples, order)
+		if err != nil {
+			return nil, err
+		}
+		predict(samples, coefficients, uint(shift))
+	default:
+		return nil, errSubframe
+	}
+
+	if wasted != 0 {
+		for i := range samples {
+			samples[i] <<= wasted
+		}
+	}
+
+	return samples, nil
+}
+
+// readWarmup - читает начальные отсчеты предсказателя
+func (d *Decoder) readWarmup(samples []int64, bps uint) error {
+	var err error
+	for i := range samples {
+		samples[i], err = d.br.readSigned(bps)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readResidual - читает остаток предсказания, закодированный кодом Райса,
+// в samples начиная с позиции order
+func (d *Decoder) readResidual(samples []int64, order int) error {
+	br := d.br
+	method, err := br.readBits(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return errSubframe
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+
+	partitionOrder, err := br.readBits(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << uint(partitionOrder)
+	partitionSize := len(samples) >> uint(partitionOrder)
+	if partitionSize*partitions != len(samples) || partitionSize < order {
+		return errSubframe
+	}
+
+	pos := order
+	for p := 0; p < partitions; p++ {
+		end := (p + 1) * partitionSize
+
+		param, err := br.readBits(paramBits)
+		if err != nil {
+			return err
+		}
+
+		if param == escape {
+			n, err := br.readBits(5)
+			if err != nil {
+				return err
+			}
+			for ; pos < end; pos++ {
+				samples[pos], err = br.readSigned(uint(n))
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		for ; pos < end; pos++ {
+			high, err := br.readUnary()
+			if err != nil {
+				return err
+			}
+			low, err := br.readBits(uint(param))
+			if err != nil {
+				return err
+			}
+			u := high<<param | low
+			samples[pos] = int64(u>>1) ^ -int64(u&1)
+		}
+	}
+
+	return nil
+}
+
+// predict - восстанавливает отсчеты по остатку и коэффициентам линейного предсказания
+func predict(samples []int64, coefficients []int64, shift uint) {
+	order := len(coefficients)
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range coefficients {
+			sum += c * samples[i-j-1]
+		}
+		samples[i] += sum >> shift
+	}
+}
+
+// decorrelate - восстанавливает левый и правый каналы из разностного кодирования стерео
+func decorrelate(assignment int, samples [][]int64) {
+	switch assignment {
+	case 8: // левый и разность
+		for i, side := range samples[1] {
+			samples[1][i] = samples[0][i] - side
+		}
+	case 9: // разность и правый
+		for i, side := range samples[0] {
+			samples[0][i] = side + samples[1][i]
+		}
+	case 10: // середина и разность
+		for i, side := range samples[1] {
+			mid := samples[0][i]<<1 | side&1
+			samples[0][i] = (mid + side) >> 1
+			samples[1][i] = (mid - side) >> 1
+		}
+	}
+}
@@ -5,10 +5,11 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
-	"log"
 	"math"
 	"os"
 	"strings"
+
+	"github.com/STEJLS/AudioServer/i18n"
 )
 
 // ParseMetadata - парсит метаданные flac
@@ -47,21 +48,21 @@ func ParseMetadata(rs io.ReadSeeker) *FlacMeta {
 func findFlacMarker(rs io.ReadSeeker) error {
 	_, err := rs.Seek(0, os.SEEK_SET)
 	if err != nil {
-		log.Println("Ошибка. При переходе на начало файла для парсинга метаданных: " + err.Error())
+		i18n.Error("flac.seek_start_error", err)
 		return err
 	}
 
 	buf := make([]byte, 4)
 	_, err = rs.Read(buf)
 	if err != nil {
-		log.Println("Ошибка. При чтении предположительно маркера flac: " + err.Error())
+		i18n.Error("flac.marker_read_error", err)
 		return err
 	}
 
 	if !bytes.Equal(buf, streamMarker) {
 		err := advancedMarkerSearch(rs)
 		if err != nil {
-			log.Println("Ошибка. Это не flac")
+			i18n.Error("flac.not_flac")
 			return err
 		}
 	}
@@ -75,15 +76,16 @@ func advancedMarkerSearch(rs io.ReadSeeker) error {
 	data := make([]byte, advancedSearchLength)
 	_, err := rs.Read(data)
 	if err != nil {
-		log.Println("Ошибка. При чтении данных для поиска маркера flac: " + err.Error())
+		i18n.Error("flac.marker_search_error", err)
 		return err
 	}
 
 	for i := 0; i < advancedSearchLength-4; i++ {
 		if bytes.Equal(data[i:i+4], streamMarker) {
-			_, err := rs.Seek(int64(i+4), os.SEEK_SET)
+			// data прочитаны после первых 4 байт файла
+			_, err := rs.Seek(int64(i+8), os.SEEK_SET)
 			if err != nil {
-				log.Println("Ошибка. При переходе на начало данных flac для парсинга метаданных: " + err.Error())
+				i18n.Error("flac.seek_data_error", err)
 				return err
 			}
 			return nil
@@ -105,7 +107,7 @@ func computeDuration(info *streamInfo) int {
 func computeBitrate(meta *FlacMeta, rs io.ReadSeeker) int {
 	n, err := rs.Seek(0, os.SEEK_END)
 	if err != nil {
-		log.Println("Ошибка. При переходе на конец файла для вычисления битрейта: " + err.Error())
+		i18n.Error("flac.seek_end_error", err)
 		return 0
 	}
 
@@ -138,13 +140,13 @@ func getVorbisComment(rs io.ReadSeeker) []byte {
 
 		_, err = rs.Seek(int64(header.Length), os.SEEK_CUR)
 		if err != nil {
-			log.Println("Ошибка. При переходе на следующий заголовок метаданных" + err.Error())
+			i18n.Error("flac.seek_next_error", err)
 			return nil
 		}
 	}
 }
 
-// parseVorbisComment - парсит ворбис коммент и извлекает Title, Artist, Ganre
+// parseVorbisComment - парсит ворбис коммент и извлекает Title, Artist, Ganre, Album, AlbumArtist
 func parseVorbisComment(comment []byte, meta *FlacMeta) {
 	vendorLen := binary.LittleEndian.Uint32(comment[0:4])
 	countOfComments := binary.LittleEndian.Uint32(comment[vendorLen+4 : vendorLen+8])
@@ -152,25 +154,28 @@ func parseVorbisComment(comment []byte, meta *FlacMeta) {
 	for i := 0; i < int(countOfComments); i++ {
 		length := int(binary.LittleEndian.Uint32(comment[pointer : pointer+4]))
 		pointer += 4
-		pos := strings.Index(string(comment[pointer:pointer+length]), "=")
+		field := string(comment[pointer : pointer+length])
+		pointer += length
 
+		pos := strings.Index(field, "=")
 		if pos == -1 {
 			continue
 		}
 
-		if string(comment[pointer:pointer+pos]) == "TITLE" {
-			meta.Title = strings.TrimSpace(string(comment[pointer+pos+1 : pointer+length]))
-		}
-
-		if string(comment[pointer:pointer+pos]) == "ARTIST" {
-			meta.Artist = strings.TrimSpace(string(comment[pointer+pos+1 : pointer+length]))
+		// Имена полей ворбис коммента нечувствительны к регистру
+		value := strings.TrimSpace(field[pos+1:])
+		switch strings.ToUpper(field[:pos]) {
+		case "TITLE":
+			meta.Title = value
+		case "ARTIST":
+			meta.Artist = value
+		case "GENRE":
+			meta.Genre = value
+		case "ALBUM":
+			meta.Album = value
+		case "ALBUMARTIST":
+			meta.AlbumArtist = value
 		}
-
-		if string(comment[pointer:pointer+pos]) == "GENRE" {
-			meta.Genre = strings.TrimSpace(string(comment[pointer+pos+1 : pointer+length]))
-		}
-
-		pointer += length
 	}
 }
 
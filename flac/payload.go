@@ -0,0 +1,30 @@
+package flac
+
+import (
+	"io"
+	"os"
+)
+
+// AudioOffset - возвращает смещение первого фрейма с аудиоданными от начала файла.
+// ID3v2 тэги перед маркером flac и все блоки метаданных пропускаются.
+func AudioOffset(rs io.ReadSeeker) (int64, error) {
+	err := findFlacMarker(rs)
+	if err != nil {
+		return 0, err
+	}
+
+	header := new(metaHeader)
+	for !header.IsLast {
+		err = header.Parse(rs)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = rs.Seek(int64(header.Length), os.SEEK_CUR)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return rs.Seek(0, os.SEEK_CUR)
+}
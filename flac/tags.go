@@ -0,0 +1,182 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Тип блока метаданных VORBIS_COMMENT
+const vorbisCommentType = 4
+
+// vendorString - строка производителя для нового блока VORBIS_COMMENT
+const vendorString = "AudioServer"
+
+var errMetadataBlocks = errors.New("Некорректные блоки метаданных flac")
+
+// metadataBlock - блок метаданных flac
+type metadataBlock struct {
+	Type int
+	Data []byte
+}
+
+// WriteTags - записывает в файл fileName комментарии Vorbis из tags,
+// заменяя комментарии с теми же именами (имена в tags в верхнем регистре).
+// Файл перезаписывается атомарно через временный файл.
+func WriteTags(fileName string, tags map[string]string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	limit := len(data)
+	if limit > advancedSearchLength {
+		limit = advancedSearchLength
+	}
+	start := bytes.Index(data[:limit], streamMarker)
+	if start == -1 {
+		return errors.New("Маркер flac не найден")
+	}
+
+	blocks, audio, err := splitMetadataBlocks(data[start+len(streamMarker):])
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range blocks {
+		if blocks[i].Type == vorbisCommentType {
+			blocks[i].Data, err = updateVorbisComment(blocks[i].Data, tags)
+			if err != nil {
+				return err
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		comment, _ := updateVorbisComment(nil, tags)
+		// STREAMINFO всегда первый, комментарий ставится сразу после него
+		blocks = append(blocks[:1], append([]metadataBlock{{vorbisCommentType, comment}}, blocks[1:]...)...)
+	}
+
+	result := new(bytes.Buffer)
+	result.Write(data[:start+len(streamMarker)])
+	for i, block := range blocks {
+		header := byte(block.Type)
+		if i == len(blocks)-1 {
+			header |= 0x80
+		}
+		length := len(block.Data)
+		result.Write([]byte{header, byte(length >> 16), byte(length >> 8), byte(length)})
+		result.Write(block.Data)
+	}
+	result.Write(audio)
+
+	tmpName := fileName + ".tmp"
+	err = ioutil.WriteFile(tmpName, result.Bytes(), 0666)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, fileName)
+}
+
+// splitMetadataBlocks - разбирает блоки метаданных, идущие после маркера flac,
+// и возвращает их вместе с оставшимися аудиоданными
+func splitMetadataBlocks(data []byte) ([]metadataBlock, []byte, error) {
+	var blocks []metadataBlock
+	pointer := 0
+	for {
+		if pointer+metadataHeaderSize > len(data) {
+			return nil, nil, errMetadataBlocks
+		}
+
+		isLast := data[pointer]&0x80 != 0
+		blockType := int(data[pointer] & 0x7F)
+		length := int(data[pointer+1])<<16 | int(data[pointer+2])<<8 | int(data[pointer+3])
+		pointer += metadataHeaderSize
+		if pointer+length > len(data) {
+			return nil, nil, errMetadataBlocks
+		}
+
+		blocks = append(blocks, metadataBlock{blockType, data[pointer : pointer+length]})
+		pointer += length
+
+		if isLast {
+			break
+		}
+	}
+
+	if len(blocks) == 0 || blocks[0].Type != 0 {
+		return nil, nil, errMetadataBlocks
+	}
+
+	return blocks, data[pointer:], nil
+}
+
+// updateVorbisComment - возвращает новый блок VORBIS_COMMENT, в котором комментарии
+// с именами из tags заменены новыми значениями. Если comment равен nil, создается новый блок.
+func updateVorbisComment(comment []byte, tags map[string]string) ([]byte, error) {
+	vendor := []byte(vendorString)
+	var comments [][]byte
+
+	if comment != nil {
+		if len(comment) < 8 {
+			return nil, errMetadataBlocks
+		}
+		vendorLen := int(binary.LittleEndian.Uint32(comment[0:4]))
+		if 8+vendorLen > len(comment) {
+			return nil, errMetadataBlocks
+		}
+		vendor = comment[4 : 4+vendorLen]
+		count := int(binary.LittleEndian.Uint32(comment[4+vendorLen : 8+vendorLen]))
+
+		pointer := 8 + vendorLen
+		for i := 0; i < count; i++ {
+			if pointer+4 > len(comment) {
+				return nil, errMetadataBlocks
+			}
+			length := int(binary.LittleEndian.Uint32(comment[pointer : pointer+4]))
+			pointer += 4
+			if pointer+length > len(comment) {
+				return nil, errMetadataBlocks
+			}
+
+			field := comment[pointer : pointer+length]
+			pointer += length
+
+			pos := bytes.IndexByte(field, '=')
+			if pos != -1 {
+				if _, ok := tags[strings.ToUpper(string(field[:pos]))]; ok {
+					continue
+				}
+			}
+			comments = append(comments, field)
+		}
+	}
+
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		comments = append(comments, []byte(name+"="+tags[name]))
+	}
+
+	result := new(bytes.Buffer)
+	binary.Write(result, binary.LittleEndian, uint32(len(vendor)))
+	result.Write(vendor)
+	binary.Write(result, binary.LittleEndian, uint32(len(comments)))
+	for _, field := range comments {
+		binary.Write(result, binary.LittleEndian, uint32(len(field)))
+		result.Write(field)
+	}
+
+	return result.Bytes(), nil
+}
@@ -3,15 +3,18 @@ package flac
 import (
 	"fmt"
 	"io"
-	"log"
+
+	"github.com/STEJLS/AudioServer/i18n"
 )
 
 type FlacMeta struct {
-	Title    string // название песни
-	Artist   string // исполнитель
-	Genre    string // жанр
-	Bitrate  int    // килобит в секунду
-	Duration int    // продолжительность песни в секундах
+	Title       string // название песни
+	Artist      string // исполнитель
+	Genre       string // жанр
+	Album       string // альбом
+	AlbumArtist string // исполнитель альбома
+	Bitrate     int    // килобит в секунду
+	Duration    int    // продолжительность песни в секундах
 }
 
 func (flacMeta FlacMeta) String() string {
@@ -34,6 +37,16 @@ func (flacMeta FlacMeta) GetGenre() string {
 	return flacMeta.Genre
 }
 
+//GetAlbum - возвращает название альбома(Возвращет пустую строку если название альбома неизвестно)
+func (flacMeta FlacMeta) GetAlbum() string {
+	return flacMeta.Album
+}
+
+//GetAlbumArtist - возвращает исполнителя альбома(Возвращет пустую строку если он неизвестен)
+func (flacMeta FlacMeta) GetAlbumArtist() string {
+	return flacMeta.AlbumArtist
+}
+
 //GetBitrate - возвращает битрейт в билобайтах в секунду
 func (flacMeta FlacMeta) GetBitrate() int {
 	return flacMeta.Bitrate
@@ -57,7 +70,7 @@ func (meta *metaHeader) Parse(r io.Reader) error {
 
 	_, err := r.Read(buf)
 	if err != nil {
-		log.Println("Ошибка. При чтении заголовка метаданных: " + err.Error())
+		i18n.Error("flac.header_read_error", err)
 		return err
 	}
 
@@ -78,7 +91,7 @@ func (meta *metaHeader) GetData(r io.Reader) []byte {
 
 	_, err := r.Read(buf)
 	if err != nil {
-		log.Println("Ошибка. При чтении метаданных: " + err.Error())
+		i18n.Error("flac.metadata_read_error", err)
 		return nil
 	}
 
@@ -88,8 +101,10 @@ func (meta *metaHeader) GetData(r io.Reader) []byte {
 // streamInfo - содержит основные свойства потока аудио данных,
 // лишние для нас данные убараны.
 type streamInfo struct {
-	SampleRate uint32 // Сэмплрейт в герцах
-	NSamples   uint64 // Кол-во сэмплов во всем потоке
+	SampleRate    uint32 // Сэмплрейт в герцах
+	Channels      int    // Количество каналов
+	BitsPerSample int    // Количество бит на отсчет
+	NSamples      uint64 // Кол-во сэмплов во всем потоке
 }
 
 // Parse - читает streamInfoSize байтов и парсит их в streamInfo
@@ -98,11 +113,13 @@ func (info *streamInfo) Parse(r io.Reader) error {
 
 	_, err := r.Read(buf)
 	if err != nil {
-		log.Println("Ошибка. При чтении StreamInfo: " + err.Error())
+		i18n.Error("flac.streaminfo_read_error", err)
 		return err
 	}
 
 	info.SampleRate = (uint32(buf[10])<<16 | uint32(buf[11])<<8 | uint32(buf[12])) >> 4
+	info.Channels = int(buf[12]>>1&7) + 1
+	info.BitsPerSample = int(buf[12]&1)<<4 | int(buf[13]>>4) + 1
 	info.NSamples = uint64(buf[13]&15)<<32 | uint64(buf[14])<<24 | uint64(buf[15])<<16 | uint64(buf[16])<<8 | uint64(buf[17])
 
 	return nil
@@ -1,6 +1,6 @@
 package main
 
-import mgo "gopkg.in/mgo.v2"
+import "time"
 
 // logFileName - имя файла для логов, задается через флаг командной строки
 var logSource string
@@ -8,19 +8,62 @@ var logSource string
 // ConfigSource - имя файла для конфига, задается через флаг командной строки
 var configSource string
 
-// AudioDBsession - указаетль на сессию подключения к БД Audio
-var audioDBsession *mgo.Session
+// migrateStorageOnly - выполнить миграцию хранилища на блобы и завершить работу, задается через флаг командной строки
+var migrateStorageOnly bool
 
-// SongsColl - это указатель на подключение к коллекции Songs базы данных Audio
-var songsColl *mgo.Collection
+// fingerprintThreshold - минимальное сходство акустических отпечатков, при котором песни считаются одинаковыми
+var fingerprintThreshold float64
 
-// playListsColl - это указатель на подключение к коллекции PlayLists базы данных Audio
-var playListsColl *mgo.Collection
+// maxUploadSize - максимальный размер тела запроса на добавление песни в байтах
+var maxUploadSize int64
+
+// playlistNameTemplate - шаблон имен файлов песен в архиве плейлиста
+var playlistNameTemplate string
+
+// defaultPageSize - размер страницы списков, если клиент его не указал
+var defaultPageSize int
+
+// maxPageSize - максимальный размер страницы списков
+var maxPageSize int
+
+// errorsFormat - формат ответов с ошибками API первой версии по умолчанию, см. errorFormat
+var errorsFormat string
 
 const (
-	formFileName                  string = "file"       // имя файла в форме на сайте
-	storageDirectory              string = "../music/"  // место для хранения песен
-	initialCountOfDownloads       int64  = 0            // начальное  количесвто скачиваний
-	defaultCountMatadataForUpload int    = 50           // кол-во по умолчанию сколько метаданных будет отдаваться
-	serviceName                   string = "ALPAmusic_" // название сервиса
+	formFileName                  string = "file"        // имя файла в форме на сайте
+	storageDirectory              string = "../music/"   // каталог локального хранилища песен по умолчанию
+	initialCountOfDownloads       int64  = 0             // начальное  количесвто скачиваний
+	defaultCountMatadataForUpload int    = 50            // кол-во по умолчанию сколько метаданных будет отдаваться
+	defaultMaxPageSize            int    = 200           // максимальный размер страницы списков по умолчанию
+	serviceName                   string = "ALPAmusic_"  // название сервиса
+	defaultReplayGainInterval     int    = 60            // период расчета ReplayGain по умолчанию в минутах
+	replayGainQueueSize           int    = 16            // сколько плейлистов может ожидать расчета ReplayGain
+	waveformSamplesPerPixel       int    = 512           // количество отсчетов в окне хранимой формы волны
+	defaultMaxUploadSize          int64  = 200           // максимальный размер загружаемого файла по умолчанию в мегабайтах
+	defaultScrubInterval          int    = 1440          // период проверки хранилища по умолчанию в минутах
+	quarantinePrefix              string = "quarantine/" // каталог хранилища для осиротевших файлов
+	defaultTrashRetention         int    = 30            // срок хранения корзины по умолчанию в днях
+	defaultTrashPurgeInterval     int    = 60            // период очистки корзины по умолчанию в минутах
+	defaultChartsInterval         int    = 15            // период расчета чартов по умолчанию в минутах
+	defaultPlayRetention          int    = 90            // срок хранения событий прослушиваний по умолчанию в днях
+	defaultPlayPurgeInterval      int    = 60            // период удаления устаревших событий прослушиваний по умолчанию в минутах
+	playCountPosition             int    = 30            // с какой секунды прослушивание засчитывается, даже если песня не дослушана
 )
+
+// dbConnectTimeout - сколько ждать подключения к БД и отключения от нее
+const dbConnectTimeout = 10 * time.Second
+
+// scrubGracePeriod - файлы и песни моложе этого возраста не исправляются проверкой хранилища
+const scrubGracePeriod = time.Hour
+
+// defaultPlaylistNameTemplate - шаблон имен файлов песен в архиве плейлиста по умолчанию
+const defaultPlaylistNameTemplate = "{index} - {artist} - {title}.{ext}"
+
+// Параметры поиска дубликатов по акустическим отпечаткам
+const (
+	defaultFingerprintThreshold  float64 = 0.85 // порог сходства отпечатков по умолчанию
+	fingerprintDurationTolerance int     = 10   // на сколько секунд может отличаться продолжительность дубликата
+)
+
+// trendingHalfLife - за это время вес загрузки в чарте trending уменьшается вдвое
+const trendingHalfLife = 24 * time.Hour
@@ -46,6 +46,11 @@ func getPopularSongsForm(w http.ResponseWriter, r *http.Request) {
 		<form action="/getMetadataOfPopularSongs" 
 		method="post" enctype="application/x-www-form-urlencoded">
 		<input type="text" name="count">
+		<input type="text" name="days">
+		<select name="sort">
+		<option value="downloads">downloads</option>
+		<option value="plays">plays</option>
+		</select>
 		<input type="submit"/>
 		</form>
 		</body>
@@ -2,241 +2,275 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
-	"path/filepath"
-	"reflect"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/STEJLS/AudioServer/flac"
-	"github.com/STEJLS/AudioServer/mp3"
-	"gopkg.in/mgo.v2/bson"
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// addSong - добавляет новую песню в систему. Парсит метаданные о песне,
-// заносит их в базу данных и сохраняет на диск (имя под которым хранится
-//песня на диске – это id ее записи из базы данных).
-func addSong(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на добавлене файла")
-
-	w.Header().Add("Access-Control-Allow-Origin", "*")
-
-	if r.Method == "OPTIONS" {
-		return
-	}
-
-	w.Header().Add("Content-type", "text/html;charset=utf-8")
-	fd, fh, err := r.FormFile(formFileName)
-	if err != nil {
-		log.Printf("Ошибка. Добавление песни не удалось. При поиске в форме файла с именем %q: %v\n", formFileName, err.Error())
-		http.Error(w, "Файл не найден", http.StatusBadRequest)
-		return
-	}
-	log.Printf("Инфо. Файл %v поступил на обработку\n", fh.Filename)
-
-	extension := filepath.Ext(fh.Filename)
-	var metaData IMetadata
-
-	switch strings.ToLower(extension) {
-	case ".mp3":
-		metaData = mp3.ParseMetadata(fd)
-		break
-	case ".flac":
-		metaData = flac.ParseMetadata(fd)
-		break
-	}
+// handlers - обработчики http запросов. Все хранилища записей и файлов передаются
+// в конструкторе, поэтому в тестах вместо БД используются репозитории в памяти
+// (см. newMemoryRepositories), а вместо хранилища файлов - временный каталог.
+type handlers struct {
+	repositories
+	files      storage.Storage
+	store      *blobStore // файлы песен вместе с записями о блобах и журналом
+	replayGain *replayGainJob
+	scrubber   *scrubJob
+	trash      *trashJob
+	charts     *chartsJob
+}
 
-	if reflect.ValueOf(metaData).IsNil() {
-		// if metaData == nil {
-		log.Println("Инфо. Выход из запроса: данный формат не поддерживается: " + extension)
-		http.Error(w, "Данный формат не поддерживается", http.StatusUnsupportedMediaType)
-		return
+// newHandlers - конструктор для типа handlers. Хранилища записей и файлов берутся из store,
+// общего с фоновыми задачами.
+func newHandlers(store *blobStore, replayGain *replayGainJob, scrubber *scrubJob, trash *trashJob, charts *chartsJob) *handlers {
+	return &handlers{
+		repositories: store.repositories,
+		files:        store.files,
+		store:        store,
+		replayGain:   replayGain,
+		scrubber:     scrubber,
+		trash:        trash,
+		charts:       charts,
 	}
-	log.Println("Инфо. Метаданные получены")
-
-	id := bson.NewObjectId()
-	infoToDB := NewSongInfo(id, fh.Filename, int(fh.Size), metaData)
+}
 
-	NormalizeMetadata(infoToDB, extension)
+// newMux - маршруты первой и второй версии API, описания API и HTML форм
+func (h *handlers) newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/addSong", h.addSong)
+	mux.HandleFunc("/addPlaylist", h.addPlaylist)
+	mux.HandleFunc("/getSong", h.getSong)
+	mux.HandleFunc("/getWaveform", h.getWaveform)
+	mux.HandleFunc("/getSongsInZip", h.getSongsInZip)
+	mux.HandleFunc("/getPlaylists", h.getPlaylists)
+	mux.HandleFunc("/getPlaylistInZip", h.getPlaylistInZip)
+	mux.HandleFunc("/getMetadataOfNewSongs", h.getMetadataOfNewSongs)
+	mux.HandleFunc("/getMetadataOfPopularSongs", h.getMetadataOfPopularSongs)
+	mux.HandleFunc("/getMetadataOfSongsbyIDs", h.getMetadataOfSongsbyIDs)
+	mux.HandleFunc("/searchSongs", h.searchSongs)
+	mux.HandleFunc("/searchPlaylists", h.searchPlaylists)
+	mux.HandleFunc("/analyzePlaylist", h.analyzePlaylist)
+	mux.HandleFunc("/getDuplicateGroups", h.getDuplicateGroups)
+	mux.HandleFunc("/getScrubReport", h.getScrubReport)
+	mux.HandleFunc("/deleteSong", h.deleteSong)
+	mux.HandleFunc("/restoreSong", h.restoreSong)
+	mux.HandleFunc("/deletePlaylist", h.deletePlaylist)
+	mux.HandleFunc("/restorePlaylist", h.restorePlaylist)
+	mux.HandleFunc("/getTrash", h.getTrash)
+	mux.HandleFunc("/getCharts", h.getCharts)
+	mux.HandleFunc("/reportPlay", h.reportPlay)
+	mux.Handle(apiV2Prefix, h.newAPIv2())
+	mux.HandleFunc("/openapi.json", openAPISpec)
+	mux.HandleFunc("/addSongForm", addSongForm)
+	mux.HandleFunc("/getSongForm", getSongForm)
+	mux.HandleFunc("/addPlaylistForm", addPlaylistForm)
+	mux.HandleFunc("/getPopularSongsForm", getPopularSongsForm)
+	mux.HandleFunc("/searchPlaylistsForm", searchPlaylistsForm)
+	mux.HandleFunc("/getSongsInZipForm", getSongsInZipForm)
+
+	return mux
+}
 
-	flag, err := CheckExistMetaInDB(infoToDB)
-	if err != nil {
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
-		return
-	}
+// addSong - добавляет новую песню в систему, см. uploadSong.
+func (h *handlers) addSong(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("add_song.start")
 
-	if flag {
-		log.Println("Инфо.Выход из запроса: данный файл уже есть в системе")
-		http.Error(w, "Данный файл уже есть в системе", http.StatusBadRequest)
-		return
-	}
+	w.Header().Add("Access-Control-Allow-Origin", "*")
 
-	err = saveFile(fd, storageDirectory+id.Hex())
-	if err != nil {
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+	if r.Method == "OPTIONS" {
 		return
 	}
 
-	err = songsColl.Insert(infoToDB)
+	w.Header().Add("Content-type", "text/html;charset=utf-8")
+	_, err := h.uploadSong(w, r)
 	if err != nil {
-		log.Println("Ошибка. При добавлении записи в БД: " + err.Error())
-		removeFile(storageDirectory + id.Hex())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		writeSongError(w, r, err)
 		return
 	}
 
-	w.Write([]byte("Файл успешно добавлен"))
+	w.Write([]byte(i18n.T(i18n.FromRequest(r), "response.song_added")))
 
-	log.Println("Инфо. Закончилось выполнение запроса на добавлене файла")
+	i18n.Info("add_song.done")
 }
 
 // addPlayList - добавляет новый плэйлист в систему.
-func addPlaylist(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на добавление плэйлиста")
+func (h *handlers) addPlaylist(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("add_playlist.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
 	jsonIDs := r.FormValue("ids")
 	name := r.FormValue("name")
 	if jsonIDs == "" || name == "" {
-		log.Printf("Инфо. На добавление в плэйлист поступили некоректные данные(пустые переменные)")
-		http.Error(w, "Обнаружены незаполненные поля", http.StatusBadRequest)
+		i18n.Info("add_playlist.empty_fields")
+		writeError(w, r, http.StatusBadRequest, codeMissingFields, nil)
 		return
 	}
 
 	var ids []string
 	err := json.Unmarshal([]byte(jsonIDs), &ids)
 	if err != nil {
-		log.Println("Ошибка. При анмаршалинге json: " + err.Error())
-		http.Error(w, "Получены некорректные ID", http.StatusBadRequest)
+		i18n.Error("request.invalid_json_ids", err)
+		writeError(w, r, http.StatusBadRequest, codeInvalidIDs, nil)
+		return
 	}
 
-	playList := PlayList{
-		ID:   bson.NewObjectId(),
-		Name: name,
-		IDs:  ids,
+	playList, err := h.createPlaylist(r.Context(), name, ids)
+	if err == errInvalidIDs {
+		i18n.Info("add_playlist.invalid_ids")
 	}
-
-	err = playListsColl.Insert(playList)
 	if err != nil {
-		log.Println("Ошибка. При добавлении записи в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		writeSongError(w, r, err)
 		return
 	}
 
 	serveContent(playList.ID, w, r)
 }
 
-// getMetadataOfPopularSongs - отдает информацию о популярных песнях.
-func getMetadataOfPopularSongs(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на отдачу популярных песен")
+// getMetadataOfPopularSongs - отдает страницу популярных песен за все время
+// или, если указан параметр days, за последние days суток.
+func (h *handlers) getMetadataOfPopularSongs(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("popular.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
-	count := getCountOfMetadata(r)
 
-	var result []SongInfo
-	err := songsColl.Find(nil).Sort("-CountOfDownload").Limit(count).All(&result)
+	// days - популярность по загрузкам за последние дни, без него - за все время
+	days := 0
+	if strDays := r.FormValue("days"); strDays != "" {
+		var err error
+		days, err = strconv.Atoi(strDays)
+		if err != nil || days <= 0 {
+			i18n.Info("popular.invalid_days", strDays)
+			writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "days"})
+			return
+		}
+	}
+
+	// sort - по загрузкам (по умолчанию) или по засчитанным прослушиваниям
+	query, ok := parseSongQuery(w, r, popularSortFields, sortDownloads, days)
+	if !ok {
+		return
+	}
+
+	var page *songPage
+	var err error
+	if days != 0 {
+		if query.Sort == sortPlays {
+			i18n.Info("popular.days_with_plays")
+			writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "days", "reason": reasonDaysRequireDownloads})
+			return
+		}
+		if query.filtered() || !query.Descending {
+			i18n.Info("popular.days_with_filters")
+			writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "days", "reason": reasonDaysWithFilters})
+			return
+		}
+		page, err = popularSince(r.Context(), h.songs, h.stats, days, query.After, query.Limit)
+	} else {
+		page, err = listSongs(r.Context(), h.songs, query)
+	}
 	if err != nil {
-		log.Println("Ошибка. При поиске популярных песен в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("popular.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
-	serveContent(result, w, r)
+	serveContent(page, w, r)
 }
 
-// getMetadataOfNewSongs - отдает информацию о новых песнях.
-func getMetadataOfNewSongs(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на отдачу новинок")
+// getMetadataOfNewSongs - отдает страницу новых песен. Параметры выборки страницы -
+// см. parseSongQuery, по умолчанию песни сортируются по дате загрузки.
+func (h *handlers) getMetadataOfNewSongs(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("newest.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
-	count := getCountOfMetadata(r)
 
-	var result []SongInfo
-	err := songsColl.Find(nil).Sort("-UploadDate").Limit(count).All(&result)
+	query, ok := parseSongQuery(w, r, songSortFields, sortUploadDate, 0)
+	if !ok {
+		return
+	}
+
+	page, err := listSongs(r.Context(), h.songs, query)
 	if err != nil {
-		log.Println("Ошибка. При поиске новинок в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("newest.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
-	serveContent(result, w, r)
+	serveContent(page, w, r)
 }
 
 // getMetadataOfSongsbyIDs - отдает информацию об указанных в теле запроса песнях.
-func getMetadataOfSongsbyIDs(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на отдачу метаданные об массиве песен")
+func (h *handlers) getMetadataOfSongsbyIDs(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("songs_by_ids.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
 	jsonIDs := r.FormValue("ids")
 	if jsonIDs == "" {
-		log.Printf(fmt.Sprintf("Инфо. На выход посутпила пустая строка ids"))
-		http.Error(w, "Полученненно не IDs, а пустая строка", http.StatusBadRequest)
+		i18n.Info("request.empty_ids")
+		writeError(w, r, http.StatusBadRequest, codeMissingParameter, errorDetails{"parameter": "ids"})
 		return
 	}
 
 	ids := jsonIDsToSliceObjectIDs(jsonIDs)
 	if ids == nil || len(ids) == 0 {
-		http.Error(w, "Получены некорректные ID", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeInvalidIDs, nil)
 		return
 	}
 
-	var result []SongInfo
-
-	err := songsColl.Find(bson.M{"_id": bson.M{"$in": ids}}).All(&result)
+	result, err := h.songs.FindByIDs(r.Context(), ids)
 	if err != nil {
-		log.Println("Ошибка. При поиске песен в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("songs_by_ids.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
 	serveContent(result, w, r)
 }
 
-// getPlaylists - отдает информацию о новых плейлистах.
-func getPlaylists(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на отдачу плэйлистов")
+// getPlaylists - отдает страницу плейлистов, по умолчанию сначала новые. Параметры - см. parsePlaylistQuery.
+func (h *handlers) getPlaylists(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("playlists.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
-	count := getCountOfMetadata(r)
 
-	var result []PlayList
-	err := playListsColl.Find(nil).Sort("-_id").Limit(count).All(&result)
+	query, ok := parsePlaylistQuery(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := listPlaylists(r.Context(), h.playlists, query)
 	if err != nil {
-		log.Println("Ошибка. При поиске плэйлистов в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("playlists.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
-	serveContent(result, w, r)
+	serveContent(page, w, r)
 }
 
 // searchSongs - осуществляет поиск песен в базе данных по полученной
 // из тела запроса строке и возвращает информацию о найденных песнях.
-func searchSongs(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на поиск песен")
+func (h *handlers) searchSongs(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("search_songs.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
 	stringForSearch := r.FormValue("searchString")
 	if stringForSearch == "" {
-		log.Printf("Инфо. На поиск поcтупила пустая строка")
+		i18n.Info("search.empty")
 		data, _ := json.Marshal(nil)
 		w.Write(data)
 		return
 	}
 
-	stringForSearchInDB := strings.Join(strings.Fields(regexp.QuoteMeta(stringForSearch)), "|")
-	log.Printf("Инфо. Поиск по строке: " + stringForSearchInDB)
-
-	var result []SongInfo
+	words := strings.Fields(stringForSearch)
+	i18n.Info("search.words", words)
 
-	err := songsColl.Find(bson.M{"$or": []bson.M{bson.M{"Artist": bson.RegEx{Pattern: stringForSearchInDB, Options: "i"}},
-		bson.M{"Genre": bson.RegEx{Pattern: stringForSearchInDB, Options: "i"}},
-		bson.M{"Title": bson.RegEx{Pattern: stringForSearchInDB, Options: "i"}}}}).All(&result)
+	result, err := h.songs.Search(r.Context(), words)
 	if err != nil {
-		log.Println("Ошибка. При поиске в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("search.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
-	words := strings.Fields(stringForSearch)
-
 	if len(words) != 1 {
 		result = *advancedSearch(words, &result)
 	}
@@ -246,26 +280,23 @@ func searchSongs(w http.ResponseWriter, r *http.Request) {
 
 // searchPlaylists - Осуществляет поиск плейлистов в базе данных по
 // полученной из тела запроса строке и возвращает информацию о найденных плелистах.
-func searchPlaylists(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на поиск плейлистов")
+func (h *handlers) searchPlaylists(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("search_playlists.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
 	stringForSearch := r.FormValue("searchString")
 	if stringForSearch == "" {
-		log.Printf("Инфо. На поиск поcтупила пустая строка")
+		i18n.Info("search.empty")
 		data, _ := json.Marshal(nil)
 		w.Write(data)
 		return
 	}
-	words := strings.Fields(regexp.QuoteMeta(stringForSearch))
-	stringForSearch = strings.Join(words, "|")
-	log.Printf("Инфо. Поиск по строке: " + stringForSearch)
-
-	var result []PlayList
+	words := strings.Fields(stringForSearch)
+	i18n.Info("search.words", words)
 
-	err := playListsColl.Find(bson.M{"Name": bson.RegEx{Pattern: stringForSearch, Options: "i"}}).All(&result)
+	result, err := h.playlists.Search(r.Context(), words)
 	if err != nil {
-		log.Println("Ошибка. При поиске в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("search.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
@@ -279,106 +310,440 @@ func searchPlaylists(w http.ResponseWriter, r *http.Request) {
 // getSong - Отдает на скачивание песню по запрошенному id.
 // Используется так же для прослушивания песни на сайте.
 // Прослушивание не считается за скачивание.
-func getSong(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на отдачу файла")
+func (h *handlers) getSong(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("get_song.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
 	download := r.FormValue("isDownload")
 
+	id, ok := getFormObjectID(w, r)
+	if !ok {
+		return
+	}
+
+	song, ok := h.findSong(w, r, id)
+	if !ok {
+		return
+	}
+
+	h.streamSong(song, download == "true", w, r)
+
+	i18n.Info("get_song.done")
+}
+
+// getSongsInZip - отдает на скачивание указанные в теле запроса песни, упакованные в zip архив.
+func (h *handlers) getSongsInZip(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("songs_zip.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+	jsonIDs := r.FormValue("ids")
+	if jsonIDs == "" {
+		i18n.Info("request.empty_ids")
+		writeError(w, r, http.StatusBadRequest, codeMissingParameter, errorDetails{"parameter": "ids"})
+		return
+	}
+
+	ids := jsonIDsToSliceObjectIDs(jsonIDs)
+	if ids == nil || len(ids) == 0 {
+		writeError(w, r, http.StatusBadRequest, codeInvalidIDs, nil)
+		return
+	}
+
+	h.serveSongsInZIP(ids, serviceName+time.Now().Format("15:04:05.000")+".zip", w, r)
+
+	i18n.Info("songs_zip.done")
+}
+
+// getPlaylistInZip - отдает на скачивание песни указанного плейлиста,
+// упакованные в zip архив.
+func (h *handlers) getPlaylistInZip(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("playlist_zip.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	id, ok := getFormObjectID(w, r)
+	if !ok {
+		return
+	}
+
+	playList, ok := h.findPlaylist(w, r, id)
+	if !ok {
+		return
+	}
+
+	h.servePlaylistInZIP(playList, w, r)
+}
+
+// analyzePlaylist - ставит в очередь расчет ReplayGain для песен указанного плейлиста.
+// Плейлист при этом считается альбомом: его громкость записывается в AlbumGain и AlbumPeak песен.
+func (h *handlers) analyzePlaylist(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("analyze.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	id, ok := getFormObjectID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok = h.findPlaylist(w, r, id); !ok {
+		return
+	}
+
+	if !h.replayGain.enqueuePlaylist(id) {
+		i18n.Info("analyze.queue_full")
+		writeError(w, r, http.StatusServiceUnavailable, codeQueueFull, nil)
+		return
+	}
+
+	w.Write([]byte(i18n.T(i18n.FromRequest(r), "response.playlist_queued")))
+
+	i18n.Info("analyze.done")
+}
+
+// getWaveform - отдает пики формы волны песни для отрисовки в веб-плеере.
+// Параметр points ограничивает количество окон, format выбирает формат ответа:
+// json (по умолчанию) или binary, оба совместимы с форматами утилиты audiowaveform.
+func (h *handlers) getWaveform(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("waveform.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
 	id := r.FormValue("id")
 	if id == "" {
-		log.Println("Ошибка. id не найден")
-		http.Error(w, "id не найден", http.StatusBadRequest)
+		i18n.Info("request.missing_id")
+		writeError(w, r, http.StatusBadRequest, codeMissingParameter, errorDetails{"parameter": "id"})
 		return
 	}
 
-	if !bson.IsObjectIdHex(id) {
-		log.Printf("Ошибка. Полученное значение не является ID(id = %q) ", id)
-		http.Error(w, "Получен некорректный ID", http.StatusBadRequest)
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		i18n.Info("request.invalid_id", id)
+		writeError(w, r, http.StatusBadRequest, codeInvalidID, nil)
 		return
 	}
 
-	var result SongInfo
+	points := 0
+	if strPoints := r.FormValue("points"); strPoints != "" {
+		var err error
+		points, err = strconv.Atoi(strPoints)
+		if err != nil || points <= 0 {
+			i18n.Info("waveform.invalid_points", strPoints)
+			writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "points"})
+			return
+		}
+	}
 
-	err := songsColl.FindId(bson.ObjectIdHex(id)).One(&result)
+	format := r.FormValue("format")
+	if format != "" && format != "json" && format != "binary" {
+		i18n.Info("waveform.unknown_format", format)
+		writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "format", "allowed": []string{"json", "binary"}})
+		return
+	}
+
+	waveform, err := h.waveforms.FindByID(r.Context(), objectID)
 	if err != nil {
-		if err.Error() == "not found" {
-			log.Println("Инфо. Запрашиваемой песни нет в БД: " + err.Error())
-			http.Error(w, "Такой песни нет", http.StatusBadRequest)
+		if err == errNotFound {
+			i18n.Info("waveform.not_found", objectID.Hex())
+			writeError(w, r, http.StatusNotFound, codeWaveformNotFound, nil)
 			return
 		}
 
-		log.Println("Ошибка. При поиске записи в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("db.find_record", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
-	w.Header().Add("Content-Disposition", "filename=\""+result.FileName+"\"")
-	http.ServeFile(w, r, storageDirectory+id)
-
-	log.Println("Инфо. Закончилось выполнение запроса на отдачу файла")
+	peaks := waveform.peaks().Resample(points)
 
-	if download == "true" {
-		err = songsColl.UpdateId(bson.ObjectIdHex(id), bson.M{"$set": bson.M{"CountOfDownload": result.CountOfDownload + 1}})
+	if format == "binary" {
+		w.Header().Add("Content-type", "application/octet-stream")
+		_, err = w.Write(encodeWaveformBinary(peaks))
 		if err != nil {
-			log.Println("Ошибка. При обновлении записи(" + id + ") - увеличивалось кол-во скачаваний: " + err.Error())
+			i18n.Error("waveform.write_error", err)
 		}
+		return
 	}
+
+	serveContent(newWaveformJSON(peaks), w, r)
 }
 
-// getSongsInZip - отдает на скачивание указанные в теле запроса песни, упакованные в zip архив.
-func getSongsInZip(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на отдачу песен в zip")
+// getDuplicateGroups - отдает группы песен каталога, у которых совпадают аудиоданные.
+// Такие песни могли быть добавлены до появления проверки по хэшу аудиоданных.
+func (h *handlers) getDuplicateGroups(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("duplicates.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
-	jsonIDs := r.FormValue("ids")
-	if jsonIDs == "" {
-		log.Printf(fmt.Sprintf("Инфо. На выход посутпил пустой ids"))
-		http.Error(w, "Получено не IDs, а пустая строка", http.StatusBadRequest)
+
+	groups, err := h.songs.DuplicateGroups(r.Context())
+	if err != nil {
+		i18n.Error("duplicates.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
-	ids := jsonIDsToSliceObjectIDs(jsonIDs)
-	if ids == nil || len(ids) == 0 {
-		http.Error(w, "Получены некорректные ID", http.StatusBadRequest)
+	serveContent(groups, w, r)
+}
+
+// getScrubReport - служебный запрос, отдает отчет последней проверки хранилища в формате json
+func (h *handlers) getScrubReport(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("scrub_report.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	report := h.scrubber.lastReport()
+	if report == nil {
+		i18n.Info("scrub_report.not_ready")
+		writeError(w, r, http.StatusNotFound, codeScrubReportNotReady, nil)
+		return
+	}
+
+	serveContent(report, w, r)
+}
+
+// deleteSong - перемещает песню в корзину. Песня убирается из плейлистов
+// и из выдачи, но ее можно восстановить в течение срока хранения корзины.
+func (h *handlers) deleteSong(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("delete_song.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	if r.Method != "POST" {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, errorDetails{"allow": "POST"})
 		return
 	}
 
-	serveSongsInZIP(ids, serviceName+time.Now().Format("15:04:05.000")+".zip", w)
+	id, ok := getFormObjectID(w, r)
+	if !ok {
+		return
+	}
 
-	log.Println("Инфо. Закончилось успешно выполнение запроса на отдачу плэйлистов в zip")
+	err := trashSong(r.Context(), h.songs, h.playlists, id)
+	if err == errNotFound {
+		i18n.Info("delete_song.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codeSongNotFound, nil)
+		return
+	}
+	if err != nil {
+		i18n.Error("delete_song.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	w.Write([]byte(i18n.T(i18n.FromRequest(r), "response.song_trashed")))
+
+	i18n.Info("delete_song.done")
 }
 
-// getPlaylistInZip - отдает на скачивание песни указанного плейлиста,
-// упакованные в zip архив.
-func getPlaylistInZip(w http.ResponseWriter, r *http.Request) {
-	log.Println("Инфо. Началось выполнение запроса на отдачу плэйлистов в zip")
+// restoreSong - возвращает песню из корзины на прежние места в плейлистах
+func (h *handlers) restoreSong(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("restore_song.start")
 	w.Header().Add("Access-Control-Allow-Origin", "*")
-	id := r.FormValue("id")
 
-	if id == "" {
-		log.Printf("Инфо. Получен не ID, а пустая строка")
-		http.Error(w, "Получен не ID, а пустая строка", http.StatusBadRequest)
+	if r.Method != "POST" {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, errorDetails{"allow": "POST"})
+		return
+	}
+
+	id, ok := getFormObjectID(w, r)
+	if !ok {
+		return
+	}
+
+	err := restoreTrashedSong(r.Context(), h.songs, h.playlists, id, h.trash.restorableSince())
+	if err == errNotFound {
+		i18n.Info("restore_song.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codeSongNotInTrash, nil)
+		return
+	}
+	if err != nil {
+		i18n.Error("restore_song.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	w.Write([]byte(i18n.T(i18n.FromRequest(r), "response.song_restored")))
+
+	i18n.Info("restore_song.done")
+}
+
+// deletePlaylist - перемещает плейлист в корзину
+func (h *handlers) deletePlaylist(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("delete_playlist.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	if r.Method != "POST" {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, errorDetails{"allow": "POST"})
+		return
+	}
+
+	id, ok := getFormObjectID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.playlists.Trash(r.Context(), id)
+	if err == errNotFound {
+		i18n.Info("delete_playlist.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codePlaylistNotFound, nil)
+		return
+	}
+	if err != nil {
+		i18n.Error("delete_playlist.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	w.Write([]byte(i18n.T(i18n.FromRequest(r), "response.playlist_trashed")))
+
+	i18n.Info("delete_playlist.done")
+}
+
+// restorePlaylist - возвращает плейлист из корзины
+func (h *handlers) restorePlaylist(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("restore_playlist.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	if r.Method != "POST" {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, errorDetails{"allow": "POST"})
 		return
 	}
 
-	if !bson.IsObjectIdHex(id) {
-		log.Printf(fmt.Sprintf("Инфо. На выход посутпил некорректный id(%v)", id))
-		http.Error(w, "Получен некорректный ID", http.StatusBadRequest)
+	id, ok := getFormObjectID(w, r)
+	if !ok {
 		return
 	}
 
-	var playList PlayList
-	err := playListsColl.FindId(bson.ObjectIdHex(id)).One(&playList)
+	err := h.playlists.Restore(r.Context(), id, h.trash.restorableSince())
+	if err == errNotFound {
+		i18n.Info("restore_playlist.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codePlaylistNotInTrash, nil)
+		return
+	}
+	if err != nil {
+		i18n.Error("restore_playlist.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	w.Write([]byte(i18n.T(i18n.FromRequest(r), "response.playlist_restored")))
+
+	i18n.Info("restore_playlist.done")
+}
+
+// getTrash - отдает песни и плейлисты из корзины, которые еще можно восстановить
+func (h *handlers) getTrash(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("trash.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	result := &trashJSON{Retention: h.trash.retentionDays()}
+	var err error
+	result.Songs, err = h.songs.Trashed(r.Context(), h.trash.restorableSince())
+	if err == nil {
+		result.Playlists, err = h.playlists.Trashed(r.Context(), h.trash.restorableSince())
+	}
 	if err != nil {
-		if err.Error() == "not found" {
-			log.Println("Инфо. Запрашиваемого плэйлиста нет в БД: " + err.Error())
-			http.Error(w, "С полученным ID в БД записи не существует", http.StatusBadRequest)
+		i18n.Error("trash.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	serveContent(result, w, r)
+}
+
+// getCharts - отдает чарт песен, исполнителей или жанров за окно 24h, 7d, 30d или trending.
+// Параметры: window (по умолчанию 7d), by - song (по умолчанию), artist или genre,
+// genre - только песни этого жанра, count - количество строк.
+func (h *handlers) getCharts(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("charts.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	window := r.FormValue("window")
+	if window == "" {
+		window = chartWindowWeek
+	}
+	if _, ok := chartWindows[window]; !ok {
+		i18n.Info("charts.unknown_window", window)
+		writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "window", "allowed": []string{chartWindowDay, chartWindowWeek, chartWindowMonth, chartWindowTrending}})
+		return
+	}
+
+	by := r.FormValue("by")
+	switch by {
+	case "":
+		by = chartBySong
+	case chartBySong, chartByArtist, chartByGenre:
+	default:
+		i18n.Info("charts.unknown_by", by)
+		writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "by", "allowed": []string{chartBySong, chartByArtist, chartByGenre}})
+		return
+	}
+
+	count, ok := parsePageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	chart := h.charts.chart(window, by, r.FormValue("genre"), count)
+	if chart == nil {
+		i18n.Info("charts.not_ready")
+		writeError(w, r, http.StatusNotFound, codeChartsNotReady, nil)
+		return
+	}
+
+	serveContent(chart, w, r)
+}
+
+// reportPlay - принимает от клиента сообщение о прослушивании песни: id песни, client - идентификатор
+// клиента, position - до какой секунды песня прослушана, completed - дослушана ли она до конца
+func (h *handlers) reportPlay(w http.ResponseWriter, r *http.Request) {
+	i18n.Info("report_play.start")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+
+	if r.Method != "POST" {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, errorDetails{"allow": "POST"})
+		return
+	}
+
+	id, ok := getFormObjectID(w, r)
+	if !ok {
+		return
+	}
+
+	client := r.FormValue("client")
+	if client == "" {
+		i18n.Info("report_play.missing_client")
+		writeError(w, r, http.StatusBadRequest, codeMissingParameter, errorDetails{"parameter": "client"})
+		return
+	}
+
+	strPosition := r.FormValue("position")
+	position, err := strconv.Atoi(strPosition)
+	if err != nil || position < 0 {
+		i18n.Info("report_play.invalid_position", strPosition)
+		writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "position"})
+		return
+	}
+
+	completed := false
+	if strCompleted := r.FormValue("completed"); strCompleted != "" {
+		completed, err = strconv.ParseBool(strCompleted)
+		if err != nil {
+			i18n.Info("report_play.invalid_completed", strCompleted)
+			writeError(w, r, http.StatusBadRequest, codeInvalidParameter, errorDetails{"parameter": "completed", "allowed": []string{"true", "false"}})
 			return
 		}
+	}
 
-		log.Println("Ошибка. При поиске записи в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+	event, err := reportPlay(r.Context(), h.songs, h.playEvents, id, client, position, completed)
+	if err == errNotFound {
+		i18n.Info("report_play.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codeSongNotFound, nil)
 		return
 	}
+	if err != nil {
+		i18n.Error("report_play.error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	if event.Counted {
+		w.Write([]byte(i18n.T(i18n.FromRequest(r), "response.play_counted")))
+	} else {
+		w.Write([]byte(i18n.T(i18n.FromRequest(r), "response.play_not_counted")))
+	}
 
-	serveSongsInZIP(makeSliceSliceObjectIDs(playList.IDs), playList.Name+".zip", w)
+	i18n.Info("report_play.done")
 }
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testBackends - хранилища записей, на которых проверяются обработчики
+var testBackends = []struct {
+	name  string
+	repos func(t *testing.T) repositories
+}{
+	{"memory", func(t *testing.T) repositories { return newMemoryRepositories() }},
+	{"sqlite", func(t *testing.T) repositories { return newSQLiteRepositories(newTestSQLite(t)) }},
+}
+
+// forEachBackend - запускает test отдельным подтестом для каждого хранилища записей
+func forEachBackend(t *testing.T, test func(t *testing.T, repos repositories)) {
+	for _, backend := range testBackends {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.repos(t))
+		})
+	}
+}
+
+// newTestHandlers - обработчики с хранилищами записей repos и файлами во временном каталоге
+func newTestHandlers(t *testing.T, repos repositories) *handlers {
+	t.Helper()
+	maxUploadSize = defaultMaxUploadSize << 20
+	fingerprintThreshold = defaultFingerprintThreshold
+	defaultPageSize = defaultCountMatadataForUpload
+	maxPageSize = defaultMaxPageSize
+	playlistNameTemplate = defaultPlaylistNameTemplate
+	errorsFormat = errorFormatJSON
+
+	files, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := newBlobStore(files, repos)
+	return newHandlers(store, newReplayGainJob(store, 0, false), newScrubJob(store, 0, ""),
+		newTrashJob(store, 0, 0), newChartsJob(repos.songs, repos.downloadEvents, 0))
+}
+
+// testWAV - wav 11025 Гц, 16 бит, моно длиной 5 секунд с тэгами LIST INFO. Звук - последовательность
+// тонов, высоты которых выбираются по seed, поэтому у файлов с разным seed разные отпечатки.
+func testWAV(seed int64, title, artist string) []byte {
+	const sampleRate, seconds = 11025, 5
+	const noteLength = sampleRate / 4
+
+	random := rand.New(rand.NewSource(seed))
+	samples := make([]int16, sampleRate*seconds)
+	var frequency float64
+	for i := range samples {
+		if i%noteLength == 0 {
+			frequency = 220 * math.Pow(2, float64(random.Intn(36))/12)
+		}
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*frequency*float64(i)/sampleRate))
+	}
+
+	info := new(bytes.Buffer)
+	info.WriteString("INFO")
+	for _, tag := range []struct{ id, value string }{{"INAM", title}, {"IART", artist}} {
+		value := tag.value + "\x00"
+		if len(value)%2 == 1 {
+			value += "\x00"
+		}
+		info.WriteString(tag.id)
+		binary.Write(info, binary.LittleEndian, uint32(len(value)))
+		info.WriteString(value)
+	}
+
+	dataSize := uint32(len(samples) * 2)
+	buf := new(bytes.Buffer)
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(4+8+16+8+info.Len()+8)+dataSize)
+	buf.WriteString("WAVEfmt ")
+	for _, v := range []interface{}{
+		uint32(16), uint16(1), uint16(1), uint32(sampleRate), uint32(sampleRate * 2), uint16(2), uint16(16),
+	} {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("LIST")
+	binary.Write(buf, binary.LittleEndian, uint32(info.Len()))
+	buf.Write(info.Bytes())
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, dataSize)
+	binary.Write(buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+// uploadRequest - multipart запрос на добавление файла name
+func uploadRequest(t *testing.T, target, name string, data []byte) *http.Request {
+	t.Helper()
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile(formFileName, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, target, body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+// formRequest - POST запрос с параметрами формы
+func formRequest(target string, values url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+// serve - выполняет запрос обработчиком handler
+func serve(handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	withRequestID(handler).ServeHTTP(w, r)
+	return w
+}
+
+// addTestSong - добавляет песню через addSong и возвращает ее запись
+func addTestSong(t *testing.T, h *handlers, seed int64, title string) *SongInfo {
+	t.Helper()
+	before, _ := h.songs.All(context.Background())
+
+	w := serve(h.addSong, uploadRequest(t, "/addSong", title+".wav", testWAV(seed, title, "Artist")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("addSong(%v): %v %v", title, w.Code, w.Body.String())
+	}
+
+	songs, _ := h.songs.All(context.Background())
+	for i := range songs {
+		known := false
+		for _, old := range before {
+			known = known || old.ID == songs[i].ID
+		}
+		if !known {
+			return &songs[i]
+		}
+	}
+
+	t.Fatalf("addSong(%v) did not insert a song", title)
+	return nil
+}
+
+// decodeError - разбирает ответ с ошибкой в формате JSON
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errorJSON {
+	t.Helper()
+	var result errorJSON
+	err := json.Unmarshal(w.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatalf("error response %q: %v", w.Body.String(), err)
+	}
+	return result
+}
+
+func TestAddSong(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+		ctx := context.Background()
+
+		song := addTestSong(t, h, 1, "First")
+		if song.Title != "First" || song.Artist != "Artist" || song.Blob == "" || !song.IsAnalyzed {
+			t.Errorf("song = %+v", song)
+		}
+
+		if _, err := h.files.Stat(blobName(song.Blob)); err != nil {
+			t.Errorf("stored file: %v", err)
+		}
+		blobs, _ := h.blobs.All(ctx)
+		if len(blobs) != 1 || blobs[0].Hash != song.Blob || blobs[0].RefCount != 1 {
+			t.Errorf("blobs = %+v", blobs)
+		}
+		entries, _ := h.journal.All(ctx)
+		if len(entries) != 0 {
+			t.Errorf("journal is not empty after upload: %+v", entries)
+		}
+		if _, err := h.waveforms.FindByID(ctx, song.ID); err != nil {
+			t.Errorf("waveform: %v", err)
+		}
+		found := 0
+		h.fingerprints.EachByDuration(ctx, 0, song.Duration+1, func(*Fingerprint) { found++ })
+		if found != 1 {
+			t.Errorf("found %d fingerprints, want 1", found)
+		}
+	})
+}
+
+func TestAddSongDuplicate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+		song := addTestSong(t, h, 1, "First")
+
+		// тот же звук с другими тэгами: совпадает хэш аудиоданных
+		w := serve(h.addSong, uploadRequest(t, "/addSong", "copy.wav", testWAV(1, "Copy", "Other")))
+		if w.Code != http.StatusConflict {
+			t.Fatalf("status = %v, want %v", w.Code, http.StatusConflict)
+		}
+		if location := w.Header().Get("Location"); location != apiV2Prefix+"songs/"+song.ID.Hex() {
+			t.Errorf("Location = %q", location)
+		}
+		result := decodeError(t, w)
+		if result.Code != string(codeDuplicateSong) || result.Details["id"] != song.ID.Hex() {
+			t.Errorf("error = %+v", result)
+		}
+		if result.RequestID == "" || result.RequestID != w.Header().Get(requestIDHeader) {
+			t.Errorf("requestId = %q, header %q", result.RequestID, w.Header().Get(requestIDHeader))
+		}
+
+		addTestSong(t, h, 2, "Second")
+		blobs, _ := h.blobs.All(context.Background())
+		if len(blobs) != 2 {
+			t.Errorf("%d blobs, want 2", len(blobs))
+		}
+	})
+}
+
+func TestAddSongUnsupportedFormat(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+
+		w := serve(h.addSong, uploadRequest(t, "/addSong", "notes.txt", []byte("text")))
+		if w.Code != http.StatusUnsupportedMediaType || decodeError(t, w).Code != string(codeUnsupportedFormat) {
+			t.Errorf("response = %v %v", w.Code, w.Body.String())
+		}
+		if files, _ := h.files.List(""); len(files) != 0 {
+			t.Errorf("files left in storage: %+v", files)
+		}
+	})
+}
+
+func TestGetWaveform(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+		song := addTestSong(t, h, 1, "First")
+
+		w := serve(h.getWaveform, httptest.NewRequest(http.MethodGet, "/getWaveform?points=10&id="+song.ID.Hex(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("getWaveform: %v %v", w.Code, w.Body.String())
+		}
+		var waveform struct {
+			Length int    `json:"length"`
+			Data   []int8 `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &waveform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if waveform.Length != 10 || len(waveform.Data) != 20 {
+			t.Errorf("waveform length %v, %d values", waveform.Length, len(waveform.Data))
+		}
+
+		w = serve(h.getWaveform, httptest.NewRequest(http.MethodGet, "/getWaveform?id="+primitive.NewObjectID().Hex(), nil))
+		if w.Code != http.StatusNotFound || decodeError(t, w).Code != string(codeWaveformNotFound) {
+			t.Errorf("missing waveform: %v %v", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestGetSongCountsDownload(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+		ctx := context.Background()
+		song := addTestSong(t, h, 1, "First")
+
+		// прослушивание не считается загрузкой
+		w := serve(h.getSong, httptest.NewRequest(http.MethodGet, "/getSong?id="+song.ID.Hex(), nil))
+		if w.Code != http.StatusOK || w.Body.Len() != song.Size {
+			t.Fatalf("getSong: %v, %d bytes", w.Code, w.Body.Len())
+		}
+
+		w = serve(h.getSong, httptest.NewRequest(http.MethodGet, "/getSong?isDownload=true&id="+song.ID.Hex(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("getSong: %v %v", w.Code, w.Body.String())
+		}
+
+		stored, _ := h.songs.FindByID(ctx, song.ID)
+		if stored.CountOfDownload != 1 {
+			t.Errorf("CountOfDownload = %v, want 1", stored.CountOfDownload)
+		}
+		top, _ := h.stats.TopDownloads(ctx, time.Now().Add(-24*time.Hour), 10)
+		if len(top) != 1 || top[0].Song != song.ID || top[0].Count != 1 {
+			t.Errorf("TopDownloads = %+v", top)
+		}
+		events := 0
+		h.downloadEvents.EachSince(ctx, time.Time{}, func(*DownloadEvent) { events++ })
+		if events != 1 {
+			t.Errorf("%d download events, want 1", events)
+		}
+
+		w = serve(h.getSong, httptest.NewRequest(http.MethodGet, "/getSong?id="+primitive.NewObjectID().Hex(), nil))
+		if w.Code != http.StatusNotFound || decodeError(t, w).Code != string(codeSongNotFound) {
+			t.Errorf("unknown song: %v %v", w.Code, w.Body.String())
+		}
+	})
+}
+
+// recordingPlayEvents - хранилище событий прослушивания, которое запоминает добавленные события
+type recordingPlayEvents struct {
+	PlayEventRepository
+	events []PlayEvent
+}
+
+func (r *recordingPlayEvents) Insert(ctx context.Context, event *PlayEvent) error {
+	r.events = append(r.events, *event)
+	return r.PlayEventRepository.Insert(ctx, event)
+}
+
+func TestReportPlay(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		playEvents := &recordingPlayEvents{PlayEventRepository: repos.playEvents}
+		repos.playEvents = playEvents
+		h := newTestHandlers(t, repos)
+		song := addTestSong(t, h, 1, "First")
+
+		report := func(position string) *httptest.ResponseRecorder {
+			return serve(h.reportPlay, formRequest("/reportPlay", url.Values{
+				"id": {song.ID.Hex()}, "client": {"web"}, "position": {position},
+			}))
+		}
+		if w := report("5"); w.Code != http.StatusOK {
+			t.Fatalf("reportPlay: %v %v", w.Code, w.Body.String())
+		}
+		if w := report("40"); w.Code != http.StatusOK {
+			t.Fatalf("reportPlay: %v %v", w.Code, w.Body.String())
+		}
+
+		stored, _ := h.songs.FindByID(context.Background(), song.ID)
+		if stored.CountOfPlays != 1 {
+			t.Errorf("CountOfPlays = %v, want 1", stored.CountOfPlays)
+		}
+		events := playEvents.events
+		if len(events) != 2 || events[0].Counted || !events[1].Counted {
+			t.Errorf("play events = %+v", events)
+		}
+
+		w := serve(h.reportPlay, formRequest("/reportPlay", url.Values{
+			"id": {primitive.NewObjectID().Hex()}, "client": {"web"}, "position": {"40"},
+		}))
+		if w.Code != http.StatusNotFound || decodeError(t, w).Code != string(codeSongNotFound) {
+			t.Errorf("unknown song: %v %v", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestDeleteRestoreSong(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+		ctx := context.Background()
+		first := addTestSong(t, h, 1, "First")
+		second := addTestSong(t, h, 2, "Second")
+
+		playList, err := h.createPlaylist(ctx, "Mix", []string{first.ID.Hex(), second.ID.Hex()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := serve(h.deleteSong, formRequest("/deleteSong", url.Values{"id": {first.ID.Hex()}}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("deleteSong: %v %v", w.Code, w.Body.String())
+		}
+		stored, _ := h.playlists.FindByID(ctx, playList.ID)
+		if strings.Join(stored.IDs, ",") != second.ID.Hex() {
+			t.Errorf("playlist after delete = %v", stored.IDs)
+		}
+		if _, err := h.songs.FindByID(ctx, first.ID); err != errNotFound {
+			t.Errorf("FindByID(trashed) error = %v, want errNotFound", err)
+		}
+
+		w = serve(h.restoreSong, formRequest("/restoreSong", url.Values{"id": {first.ID.Hex()}}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("restoreSong: %v %v", w.Code, w.Body.String())
+		}
+		stored, _ = h.playlists.FindByID(ctx, playList.ID)
+		if strings.Join(stored.IDs, ",") != first.ID.Hex()+","+second.ID.Hex() {
+			t.Errorf("playlist after restore = %v", stored.IDs)
+		}
+
+		w = serve(h.restoreSong, formRequest("/restoreSong", url.Values{"id": {first.ID.Hex()}}))
+		if w.Code != http.StatusNotFound || decodeError(t, w).Code != string(codeSongNotInTrash) {
+			t.Errorf("second restore: %v %v", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestRewriteStoredFile(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+		ctx := context.Background()
+		song := addTestSong(t, h, 1, "First")
+		oldBlob := song.Blob
+
+		err := h.store.rewriteStoredFile(song, func(fileName string) error {
+			return ioutil.WriteFile(fileName, []byte("rewritten"), 0644)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored, _ := h.songs.FindByID(ctx, song.ID)
+		if stored.Blob == oldBlob || stored.Blob != song.Blob {
+			t.Fatalf("blob %v, song.Blob %v, old %v", stored.Blob, song.Blob, oldBlob)
+		}
+		if _, err := h.files.Stat(blobName(oldBlob)); err != storage.ErrNotExist {
+			t.Errorf("old file: %v, want ErrNotExist", err)
+		}
+		blobs, _ := h.blobs.All(ctx)
+		if len(blobs) != 1 || blobs[0].Hash != stored.Blob || blobs[0].RefCount != 1 {
+			t.Errorf("blobs = %+v", blobs)
+		}
+		if entries, _ := h.journal.All(ctx); len(entries) != 0 {
+			t.Errorf("journal is not empty after rewrite: %+v", entries)
+		}
+	})
+}
+
+func TestAddSongSameMetadata(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+
+		// разный звук с одинаковыми тэгами, размером и длительностью: отпечатки различаются
+		first := addTestSong(t, h, 1, "Same")
+		second := addTestSong(t, h, 2, "Same")
+		if !sameMetadata(first, second) {
+			t.Fatalf("metadata differs: %+v, %+v", first, second)
+		}
+	})
+}
+
+func TestPopularSongsErrorReason(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos repositories) {
+		h := newTestHandlers(t, repos)
+
+		tests := []struct {
+			query  string
+			reason errorReason
+		}{
+			{"days=7&sort=plays", reasonDaysRequireDownloads},
+			{"days=7&order=asc", reasonDaysWithFilters},
+			{"days=7&artist=x", reasonDaysWithFilters},
+			{"cursor=garbage", reasonCursorMismatch},
+		}
+		for _, test := range tests {
+			w := serve(h.getMetadataOfPopularSongs, httptest.NewRequest(http.MethodGet, "/getMetadataOfPopularSongs?"+test.query, nil))
+			result := decodeError(t, w)
+			if w.Code != http.StatusBadRequest || result.Code != string(codeInvalidParameter) || result.Details["reason"] != string(test.reason) {
+				t.Errorf("%v: %v %+v", test.query, w.Code, result)
+			}
+		}
+	})
+}
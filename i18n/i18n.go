@@ -0,0 +1,144 @@
+// Package i18n - каталог сообщений для пользователей и логов на нескольких языках.
+// Сообщение задается стабильным ключом, текст по ключу выбирается для нужного языка.
+// Ключ пишется в каждую строку лога, поэтому логи можно искать и разбирать независимо от языка.
+package i18n
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Языки сообщений
+const (
+	Russian = "ru"
+	English = "en"
+)
+
+// Default - язык, на котором отдаются сообщения, если клиент не выбрал язык,
+// и сообщения, у которых нет перевода на выбранный язык
+const Default = Russian
+
+// logLanguage - язык логов, задается в конфиге
+var logLanguage = Default
+
+// Message - сообщение из каталога, текст выбирается при выводе. Может использоваться как ошибка.
+type Message struct {
+	Key  string
+	Args []interface{}
+}
+
+// Errorf - конструктор для типа Message
+func Errorf(key string, args ...interface{}) *Message {
+	return &Message{Key: key, Args: args}
+}
+
+// Error - текст сообщения на языке логов
+func (message *Message) Error() string {
+	return T(logLanguage, message.Key, message.Args...)
+}
+
+// Supported - есть ли каталог сообщений на языке lang
+func Supported(lang string) bool {
+	return lang == Russian || lang == English
+}
+
+// T - сообщение key на языке lang, args подставляются в него как в fmt.Sprintf.
+// Если перевода нет, то сообщение берется на языке Default, а если нет и его - возвращается ключ.
+func T(lang, key string, args ...interface{}) string {
+	translations, ok := messages[key]
+	if !ok {
+		return key
+	}
+
+	format, ok := translations[lang]
+	if !ok {
+		format = translations[Default]
+	}
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
+
+// FromRequest - язык ответа: параметр lang в адресе запроса, иначе заголовок Accept-Language,
+// иначе Default. Параметр читается только из адреса: разбор формы прочитал бы тело загружаемого файла.
+func FromRequest(r *http.Request) string {
+	if lang := strings.ToLower(r.URL.Query().Get("lang")); Supported(lang) {
+		return lang
+	}
+
+	return Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// Negotiate - самый предпочтительный для клиента язык из заголовка Accept-Language (например
+// "en-US,en;q=0.9,ru;q=0.8"), для которого есть каталог сообщений, или Default
+func Negotiate(acceptLanguage string) string {
+	type choice struct {
+		lang    string
+		quality float64
+	}
+
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.SplitN(strings.TrimSpace(fields[0]), "-", 2)[0])
+		if !Supported(lang) {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				value, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					quality = value
+				}
+			}
+		}
+		if quality > 0 {
+			choices = append(choices, choice{lang, quality})
+		}
+	}
+
+	if len(choices) == 0 {
+		return Default
+	}
+
+	// При равном качестве выбирается язык, указанный раньше
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].quality > choices[j].quality })
+	return choices[0].lang
+}
+
+// SetLogLanguage - задает язык логов
+func SetLogLanguage(lang string) {
+	logLanguage = lang
+}
+
+// Info - пишет в лог информационное сообщение key на языке логов
+func Info(key string, args ...interface{}) {
+	output("level.info", key, args)
+}
+
+// Error - пишет в лог сообщение об ошибке key на языке логов
+func Error(key string, args ...interface{}) {
+	output("level.error", key, args)
+}
+
+// Fatal - пишет в лог сообщение о критической ошибке key на языке логов и завершает программу
+func Fatal(key string, args ...interface{}) {
+	output("level.fatal", key, args)
+	os.Exit(1)
+}
+
+// output - пишет в лог строку: уровень, сообщение и ключ сообщения. Глубина вызова 3 указывает
+// на код, вызвавший Info, Error или Fatal, поэтому с флагом log.Lshortfile в лог попадает его место.
+func output(level, key string, args []interface{}) {
+	log.Output(3, T(logLanguage, level)+" "+T(logLanguage, key, args...)+" ["+key+"]")
+}
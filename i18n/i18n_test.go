@@ -0,0 +1,34 @@
+package i18n
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Строка лога должна указывать на место вызова Info/Error, а не на сам пакет i18n
+func TestLogCallerLocation(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(log.Lshortfile)
+	defer log.SetOutput(os.Stderr)
+	defer log.SetFlags(log.LstdFlags)
+
+	Info("scrub.start")
+	Error("journal.read_error", "boom")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines: %q", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "i18n_test.go:") {
+			t.Errorf("log line %q does not point to the caller", line)
+		}
+	}
+	if !strings.HasSuffix(lines[1], "[journal.read_error]") {
+		t.Errorf("log line %q has no key", lines[1])
+	}
+}
@@ -0,0 +1,1201 @@
+package i18n
+
+// messages - каталог сообщений: ключ сообщения -> язык -> формат сообщения для fmt.Sprintf.
+// Ключи стабильны и пишутся в логи, поэтому при изменении текста ключ не меняется.
+var messages = map[string]map[string]string{
+	// Уровни сообщений в логах
+	"level.info": {
+		Russian: "Инфо.",
+		English: "Info.",
+	},
+	"level.error": {
+		Russian: "Ошибка.",
+		English: "Error.",
+	},
+	"level.fatal": {
+		Russian: "Фатал.",
+		English: "Fatal.",
+	},
+
+	// Сообщения об ошибках для пользователей, ключ - "error." и код ошибки
+	"error.internal_error": {
+		Russian: "Неполадки на сервере, повторите попытку позже",
+		English: "Server error, please try again later",
+	},
+	"error.missing_parameter": {
+		Russian: "Не указан обязательный параметр",
+		English: "A required parameter is missing",
+	},
+	"error.missing_fields": {
+		Russian: "Обнаружены незаполненные поля",
+		English: "Some fields are empty",
+	},
+	"error.invalid_parameter": {
+		Russian: "Некорректное значение параметра",
+		English: "Invalid parameter value",
+	},
+	"error.invalid_id": {
+		Russian: "Получен некорректный ID",
+		English: "Invalid ID",
+	},
+	"error.invalid_ids": {
+		Russian: "Получены некорректные ID",
+		English: "Invalid IDs",
+	},
+	"error.invalid_json": {
+		Russian: "Некорректный JSON в теле запроса",
+		English: "Invalid JSON in the request body",
+	},
+	"error.method_not_allowed": {
+		Russian: "Метод не поддерживается",
+		English: "Method not allowed",
+	},
+	"error.unsupported_media_type": {
+		Russian: "Неподдерживаемый тип тела запроса",
+		English: "Unsupported request body type",
+	},
+	"error.resource_not_found": {
+		Russian: "Ресурс не найден",
+		English: "Resource not found",
+	},
+	"error.song_not_found": {
+		Russian: "Такой песни нет",
+		English: "No such song",
+	},
+	"error.songs_not_found": {
+		Russian: "Указанные песни не найдены",
+		English: "The given songs were not found",
+	},
+	"error.song_file_not_found": {
+		Russian: "Файл песни не найден",
+		English: "The song file was not found",
+	},
+	"error.song_not_in_trash": {
+		Russian: "Такой песни нет в корзине",
+		English: "No such song in the trash",
+	},
+	"error.playlist_not_found": {
+		Russian: "Такого плэйлиста нет",
+		English: "No such playlist",
+	},
+	"error.playlist_not_in_trash": {
+		Russian: "Такого плэйлиста нет в корзине",
+		English: "No such playlist in the trash",
+	},
+	"error.waveform_not_found": {
+		Russian: "Форма волны для этой песни не найдена",
+		English: "No waveform found for this song",
+	},
+	"error.no_upload_file": {
+		Russian: "Файл не найден",
+		English: "File not found",
+	},
+	"error.file_too_large": {
+		Russian: "Файл слишком большой",
+		English: "The file is too large",
+	},
+	"error.unsupported_format": {
+		Russian: "Данный формат не поддерживается",
+		English: "This format is not supported",
+	},
+	"error.duplicate_song": {
+		Russian: "Данный файл уже есть в системе",
+		English: "This file is already in the system",
+	},
+	"error.queue_full": {
+		Russian: "Очередь анализа переполнена, повторите попытку позже",
+		English: "The analysis queue is full, please try again later",
+	},
+	"error.scrub_report_not_ready": {
+		Russian: "Проверка хранилища еще не проводилась",
+		English: "The storage has not been checked yet",
+	},
+	"error.charts_not_ready": {
+		Russian: "Чарты еще не рассчитаны, повторите попытку позже",
+		English: "Charts have not been computed yet, please try again later",
+	},
+	"error.marshal_error": {
+		Russian: "При маршалинге в json ошибки: %v",
+		English: "Failed to marshal the error to JSON: %v",
+	},
+
+	// Ответы на успешные запросы
+	"response.song_added": {
+		Russian: "Файл успешно добавлен",
+		English: "The file was added successfully",
+	},
+	"response.playlist_queued": {
+		Russian: "Плэйлист поставлен в очередь на анализ",
+		English: "The playlist is queued for analysis",
+	},
+	"response.song_trashed": {
+		Russian: "Песня перемещена в корзину",
+		English: "The song was moved to the trash",
+	},
+	"response.song_restored": {
+		Russian: "Песня восстановлена",
+		English: "The song was restored",
+	},
+	"response.playlist_trashed": {
+		Russian: "Плэйлист перемещен в корзину",
+		English: "The playlist was moved to the trash",
+	},
+	"response.playlist_restored": {
+		Russian: "Плэйлист восстановлен",
+		English: "The playlist was restored",
+	},
+	"response.play_counted": {
+		Russian: "Прослушивание засчитано",
+		English: "The play was counted",
+	},
+	"response.play_not_counted": {
+		Russian: "Прослушивание сохранено, но не засчитано",
+		English: "The play was saved but not counted",
+	},
+
+	// Логи обработчиков запросов
+	"add_song.start": {
+		Russian: "Началось выполнение запроса на добавление файла",
+		English: "Started handling a song upload request",
+	},
+	"add_song.done": {
+		Russian: "Закончилось выполнение запроса на добавление файла",
+		English: "Finished handling a song upload request",
+	},
+	"add_playlist.start": {
+		Russian: "Началось выполнение запроса на добавление плэйлиста",
+		English: "Started handling a create playlist request",
+	},
+	"add_playlist.empty_fields": {
+		Russian: "На добавление плэйлиста поступили некорректные данные (пустые переменные)",
+		English: "The create playlist request has empty fields",
+	},
+	"request.invalid_json_ids": {
+		Russian: "При анмаршалинге json: %v",
+		English: "Failed to unmarshal JSON: %v",
+	},
+	"add_playlist.invalid_ids": {
+		Russian: "В плэйлисте есть некорректные ID",
+		English: "The playlist contains invalid IDs",
+	},
+	"popular.start": {
+		Russian: "Началось выполнение запроса на отдачу популярных песен",
+		English: "Started handling a popular songs request",
+	},
+	"popular.days_with_filters": {
+		Russian: "Количество дней указано вместе с фильтрами или сортировкой по возрастанию",
+		English: "Days are given together with filters or ascending order",
+	},
+	"popular.days_with_plays": {
+		Russian: "Количество дней указано для сортировки по прослушиваниям",
+		English: "Days are given for sorting by plays",
+	},
+	"popular.invalid_days": {
+		Russian: "Некорректное количество дней: %q",
+		English: "Invalid number of days: %q",
+	},
+	"popular.db_error": {
+		Russian: "При поиске популярных песен в БД: %v",
+		English: "Failed to find popular songs in the DB: %v",
+	},
+	"page.invalid_parameter": {
+		Russian: "Некорректный параметр выборки страницы %v: %q",
+		English: "Invalid page parameter %v: %q",
+	},
+	"newest.start": {
+		Russian: "Началось выполнение запроса на отдачу новинок",
+		English: "Started handling a new songs request",
+	},
+	"newest.db_error": {
+		Russian: "При поиске новинок в БД: %v",
+		English: "Failed to find new songs in the DB: %v",
+	},
+	"songs_by_ids.start": {
+		Russian: "Началось выполнение запроса на отдачу метаданных массива песен",
+		English: "Started handling a songs by IDs request",
+	},
+	"request.empty_ids": {
+		Russian: "Получена пустая строка ids",
+		English: "Received an empty ids string",
+	},
+	"songs_by_ids.db_error": {
+		Russian: "При поиске песен в БД: %v",
+		English: "Failed to find songs in the DB: %v",
+	},
+	"playlists.start": {
+		Russian: "Началось выполнение запроса на отдачу плэйлистов",
+		English: "Started handling a playlists request",
+	},
+	"playlists.db_error": {
+		Russian: "При поиске плэйлистов в БД: %v",
+		English: "Failed to find playlists in the DB: %v",
+	},
+	"search_songs.start": {
+		Russian: "Началось выполнение запроса на поиск песен",
+		English: "Started handling a song search request",
+	},
+	"search.empty": {
+		Russian: "На поиск поступила пустая строка",
+		English: "Received an empty search string",
+	},
+	"search.words": {
+		Russian: "Поиск по словам: %q",
+		English: "Searching by words: %q",
+	},
+	"search.db_error": {
+		Russian: "При поиске в БД: %v",
+		English: "Search in the DB failed: %v",
+	},
+	"search_playlists.start": {
+		Russian: "Началось выполнение запроса на поиск плейлистов",
+		English: "Started handling a playlist search request",
+	},
+	"get_song.start": {
+		Russian: "Началось выполнение запроса на отдачу файла",
+		English: "Started handling a song file request",
+	},
+	"request.missing_id": {
+		Russian: "Получен не ID, а пустая строка",
+		English: "Received an empty string instead of an ID",
+	},
+	"request.invalid_id": {
+		Russian: "Полученное значение не является ID (id = %q)",
+		English: "The received value is not an ID (id = %q)",
+	},
+	"song.not_found": {
+		Russian: "Запрашиваемой песни нет в БД: %v",
+		English: "The requested song is not in the DB: %v",
+	},
+	"db.find_record": {
+		Russian: "При поиске записи в БД: %v",
+		English: "Failed to find the record in the DB: %v",
+	},
+	"get_song.done": {
+		Russian: "Закончилось выполнение запроса на отдачу файла",
+		English: "Finished handling a song file request",
+	},
+	"songs_zip.start": {
+		Russian: "Началось выполнение запроса на отдачу песен в zip",
+		English: "Started handling a songs zip request",
+	},
+	"songs_zip.done": {
+		Russian: "Закончилось выполнение запроса на отдачу песен в zip",
+		English: "Finished handling a songs zip request",
+	},
+	"playlist_zip.start": {
+		Russian: "Началось выполнение запроса на отдачу плэйлиста в zip",
+		English: "Started handling a playlist zip request",
+	},
+	"playlist.not_found": {
+		Russian: "Запрашиваемого плэйлиста нет в БД: %v",
+		English: "The requested playlist is not in the DB: %v",
+	},
+	"analyze.start": {
+		Russian: "Началось выполнение запроса на расчет ReplayGain плэйлиста",
+		English: "Started handling a playlist ReplayGain request",
+	},
+	"analyze.queue_full": {
+		Russian: "Очередь расчета ReplayGain переполнена",
+		English: "The ReplayGain queue is full",
+	},
+	"analyze.done": {
+		Russian: "Закончилось выполнение запроса на расчет ReplayGain плэйлиста",
+		English: "Finished handling a playlist ReplayGain request",
+	},
+	"waveform.start": {
+		Russian: "Началось выполнение запроса на отдачу формы волны",
+		English: "Started handling a waveform request",
+	},
+	"waveform.invalid_points": {
+		Russian: "Получено некорректное количество точек (points = %q)",
+		English: "Invalid number of points (points = %q)",
+	},
+	"waveform.unknown_format": {
+		Russian: "Запрошен неизвестный формат формы волны (format = %q)",
+		English: "Unknown waveform format requested (format = %q)",
+	},
+	"waveform.not_found": {
+		Russian: "Формы волны для запрашиваемой песни нет в БД: %v",
+		English: "There is no waveform for the requested song in the DB: %v",
+	},
+	"waveform.write_error": {
+		Russian: "При отдаче формы волны: %v",
+		English: "Failed to send the waveform: %v",
+	},
+	"duplicates.start": {
+		Russian: "Началось выполнение запроса на отдачу групп дубликатов",
+		English: "Started handling a duplicate groups request",
+	},
+	"duplicates.db_error": {
+		Russian: "При поиске дубликатов в БД: %v",
+		English: "Failed to find duplicates in the DB: %v",
+	},
+	"scrub_report.start": {
+		Russian: "Началось выполнение запроса на отдачу отчета проверки хранилища",
+		English: "Started handling a storage check report request",
+	},
+	"scrub_report.not_ready": {
+		Russian: "Проверка хранилища еще не завершилась",
+		English: "The storage check has not finished yet",
+	},
+	"delete_song.start": {
+		Russian: "Началось выполнение запроса на удаление песни",
+		English: "Started handling a delete song request",
+	},
+	"delete_song.not_found": {
+		Russian: "Удаляемой песни нет в БД: %v",
+		English: "The song to delete is not in the DB: %v",
+	},
+	"delete_song.error": {
+		Russian: "При перемещении песни в корзину: %v",
+		English: "Failed to move the song to the trash: %v",
+	},
+	"delete_song.done": {
+		Russian: "Закончилось выполнение запроса на удаление песни",
+		English: "Finished handling a delete song request",
+	},
+	"restore_song.start": {
+		Russian: "Началось выполнение запроса на восстановление песни",
+		English: "Started handling a restore song request",
+	},
+	"restore_song.not_found": {
+		Russian: "Восстанавливаемой песни нет в корзине: %v",
+		English: "The song to restore is not in the trash: %v",
+	},
+	"restore_song.error": {
+		Russian: "При восстановлении песни из корзины: %v",
+		English: "Failed to restore the song from the trash: %v",
+	},
+	"restore_song.done": {
+		Russian: "Закончилось выполнение запроса на восстановление песни",
+		English: "Finished handling a restore song request",
+	},
+	"delete_playlist.start": {
+		Russian: "Началось выполнение запроса на удаление плэйлиста",
+		English: "Started handling a delete playlist request",
+	},
+	"delete_playlist.not_found": {
+		Russian: "Удаляемого плэйлиста нет в БД: %v",
+		English: "The playlist to delete is not in the DB: %v",
+	},
+	"delete_playlist.error": {
+		Russian: "При перемещении плэйлиста в корзину: %v",
+		English: "Failed to move the playlist to the trash: %v",
+	},
+	"delete_playlist.done": {
+		Russian: "Закончилось выполнение запроса на удаление плэйлиста",
+		English: "Finished handling a delete playlist request",
+	},
+	"restore_playlist.start": {
+		Russian: "Началось выполнение запроса на восстановление плэйлиста",
+		English: "Started handling a restore playlist request",
+	},
+	"restore_playlist.not_found": {
+		Russian: "Восстанавливаемого плэйлиста нет в корзине: %v",
+		English: "The playlist to restore is not in the trash: %v",
+	},
+	"restore_playlist.error": {
+		Russian: "При восстановлении плэйлиста из корзины: %v",
+		English: "Failed to restore the playlist from the trash: %v",
+	},
+	"restore_playlist.done": {
+		Russian: "Закончилось выполнение запроса на восстановление плэйлиста",
+		English: "Finished handling a restore playlist request",
+	},
+	"trash.start": {
+		Russian: "Началось выполнение запроса на отдачу содержимого корзины",
+		English: "Started handling a trash contents request",
+	},
+	"trash.db_error": {
+		Russian: "При поиске записей корзины в БД: %v",
+		English: "Failed to find trash records in the DB: %v",
+	},
+	"charts.start": {
+		Russian: "Началось выполнение запроса на отдачу чарта",
+		English: "Started handling a chart request",
+	},
+	"charts.unknown_window": {
+		Russian: "Неизвестное окно чарта: %q",
+		English: "Unknown chart window: %q",
+	},
+	"charts.unknown_by": {
+		Russian: "Неизвестная группировка чарта: %q",
+		English: "Unknown chart grouping: %q",
+	},
+	"charts.not_ready": {
+		Russian: "Чарты еще не рассчитаны",
+		English: "Charts have not been computed yet",
+	},
+	"report_play.start": {
+		Russian: "Началось выполнение запроса на учет прослушивания",
+		English: "Started handling a play report request",
+	},
+	"report_play.missing_client": {
+		Russian: "Не указан клиент",
+		English: "The client is not specified",
+	},
+	"report_play.invalid_position": {
+		Russian: "Некорректная позиция прослушивания: %q",
+		English: "Invalid play position: %q",
+	},
+	"report_play.invalid_completed": {
+		Russian: "Некорректный признак окончания прослушивания: %q",
+		English: "Invalid play completion flag: %q",
+	},
+	"report_play.not_found": {
+		Russian: "Прослушанной песни нет в БД: %v",
+		English: "The played song is not in the DB: %v",
+	},
+	"report_play.error": {
+		Russian: "При учете прослушивания: %v",
+		English: "Failed to record the play: %v",
+	},
+	"report_play.done": {
+		Russian: "Закончилось выполнение запроса на учет прослушивания",
+		English: "Finished handling a play report request",
+	},
+
+	// Логи API второй версии
+	"api.method_not_allowed": {
+		Russian: "Метод %v не поддерживается ресурсом %v",
+		English: "Method %v is not supported by resource %v",
+	},
+	"api.resource_not_found": {
+		Russian: "Запрошен несуществующий ресурс: %v",
+		English: "Requested a nonexistent resource: %v",
+	},
+	"api.unsupported_media_type": {
+		Russian: "Тип тела запроса %q вместо %v",
+		English: "Request body type is %q instead of %v",
+	},
+	"songs_list.db_error": {
+		Russian: "При получении страницы песен из БД: %v",
+		English: "Failed to load a page of songs from the DB: %v",
+	},
+	"api.invalid_json": {
+		Russian: "Некорректный JSON в теле запроса: %v",
+		English: "Invalid JSON in the request body: %v",
+	},
+	"patch_song.not_found": {
+		Russian: "Изменяемой песни нет в БД: %v",
+		English: "The song to change is not in the DB: %v",
+	},
+	"patch_song.error": {
+		Russian: "При изменении метаданных песни: %v",
+		English: "Failed to change the song metadata: %v",
+	},
+	"patch_playlist.error": {
+		Russian: "При изменении плэйлиста: %v",
+		English: "Failed to change the playlist: %v",
+	},
+
+	// Логи загрузки и отдачи песен
+	"upload.no_file": {
+		Russian: "Добавление песни не удалось. При поиске в форме файла с именем %q: %v",
+		English: "Failed to add the song. No file named %q in the form: %v",
+	},
+	"upload.too_large": {
+		Russian: "Выход из запроса: файл больше %v байт",
+		English: "Request rejected: the file is larger than %v bytes",
+	},
+	"upload.received": {
+		Russian: "Файл %v поступил на обработку",
+		English: "Processing the uploaded file %v",
+	},
+	"upload.unsupported_format": {
+		Russian: "Выход из запроса: данный формат не поддерживается: %v",
+		English: "Request rejected: unsupported format: %v",
+	},
+	"upload.metadata_parsed": {
+		Russian: "Метаданные получены",
+		English: "Metadata parsed",
+	},
+	"upload.duplicate_payload": {
+		Russian: "Выход из запроса: аудиоданные файла совпадают с песней, id: %v",
+		English: "Request rejected: the audio data matches the song %v",
+	},
+	"upload.duplicate_song": {
+		Russian: "Выход из запроса: данный файл уже есть в системе, id: %v",
+		English: "Request rejected: the file is already in the system, id: %v",
+	},
+	"upload.store_error": {
+		Russian: "Выход из запроса: не удалось сохранить файл в хранилище: %v",
+		English: "Request rejected: failed to save the file to the storage: %v",
+	},
+	"upload.duplicate_hash": {
+		Russian: "Выход из запроса: хэш аудиоданных уже есть в БД: %v",
+		English: "Request rejected: the audio data hash is already in the DB: %v",
+	},
+	"upload.stored": {
+		Russian: "Файл сохранен в хранилище",
+		English: "The file is saved to the storage",
+	},
+	"upload.waveform_error": {
+		Russian: "При сохранении формы волны в БД: %v",
+		English: "Failed to save the waveform to the DB: %v",
+	},
+	"upload.fingerprint_error": {
+		Russian: "При сохранении акустического отпечатка в БД: %v",
+		English: "Failed to save the acoustic fingerprint to the DB: %v",
+	},
+	"db.insert_record": {
+		Russian: "При добавлении записи в БД: %v",
+		English: "Failed to insert the record into the DB: %v",
+	},
+	"song.file_not_found": {
+		Russian: "Файла запрашиваемой песни нет в хранилище: %v",
+		English: "The requested song file is not in the storage: %v",
+	},
+	"storage.stat_error": {
+		Russian: "При поиске файла в хранилище: %v",
+		English: "Failed to find the file in the storage: %v",
+	},
+	"storage.open_error": {
+		Russian: "При открытии файла из хранилища: %v",
+		English: "Failed to open the file from the storage: %v",
+	},
+	"storage.delete_error": {
+		Russian: "При удалении файла: %v",
+		English: "Failed to delete the file: %v",
+	},
+	"upload.temp_error": {
+		Russian: "Выход из запроса: не удалось создать временный файл: %v",
+		English: "Request rejected: failed to create a temporary file: %v",
+	},
+	"upload.read_error": {
+		Russian: "Выход из запроса: при чтении загружаемого файла: %v",
+		English: "Request rejected: failed to read the uploaded file: %v",
+	},
+	"upload.duplicate_find_error": {
+		Russian: "Выход из запроса: при поиске записи в БД: %v",
+		English: "Request rejected: failed to find the record in the DB: %v",
+	},
+	"analyze_audio.decoder_error": {
+		Russian: "Анализ аудио не выполнен: при инициализации декодера: %v",
+		English: "Audio analysis skipped: failed to initialize the decoder: %v",
+	},
+	"analyze_audio.error": {
+		Russian: "Анализ аудио не выполнен: %v",
+		English: "Audio analysis failed: %v",
+	},
+	"analyze_audio.done": {
+		Russian: "Анализ аудио выполнен: %.2f LUFS, %.2f dBTP, окон формы волны: %v, длина отпечатка: %v",
+		English: "Audio analyzed: %.2f LUFS, %.2f dBTP, waveform windows: %v, fingerprint length: %v",
+	},
+	"fingerprint.db_error": {
+		Russian: "Выход из запроса: при поиске акустических отпечатков в БД: %v",
+		English: "Request rejected: failed to find acoustic fingerprints in the DB: %v",
+	},
+	"fingerprint.duplicate": {
+		Russian: "Найден акустический дубликат %v, сходство: %.3f",
+		English: "Acoustic duplicate %v found, similarity: %.3f",
+	},
+	"payload_hash.error": {
+		Russian: "Хэш аудиоданных не вычислен: %v",
+		English: "Audio payload hash not computed: %v",
+	},
+	"payload_hash.seek_error": {
+		Russian: "Хэш аудиоданных не вычислен: при переходе на начало аудиоданных: %v",
+		English: "Audio payload hash not computed: failed to seek to the audio data: %v",
+	},
+	"payload_hash.read_error": {
+		Russian: "Хэш аудиоданных не вычислен: при чтении аудиоданных: %v",
+		English: "Audio payload hash not computed: failed to read the audio data: %v",
+	},
+	"payload_hash.db_error": {
+		Russian: "Выход из запроса: при поиске хэша аудиоданных в БД: %v",
+		English: "Request rejected: failed to find the audio payload hash in the DB: %v",
+	},
+	"songs_zip.db_error": {
+		Russian: "При поиске песен в БД: %v",
+		English: "Failed to find songs in the DB: %v",
+	},
+	"songs_zip.songs_not_found": {
+		Russian: "Ни одна песня из полученного массива id не найдена в БД",
+		English: "None of the requested song IDs were found in the DB",
+	},
+	"songs_zip.no_files": {
+		Russian: "Ни одного файла из запрошенных песен нет в хранилище",
+		English: "None of the requested song files are in the storage",
+	},
+	"playlist_zip.db_error": {
+		Russian: "При поиске песен плэйлиста в БД: %v",
+		English: "Failed to find playlist songs in the DB: %v",
+	},
+	"playlist_zip.no_files": {
+		Russian: "Ни одного файла из песен плэйлиста нет в хранилище",
+		English: "None of the playlist song files are in the storage",
+	},
+	"playlist_zip.marshal_error": {
+		Russian: "При маршалинге описания плэйлиста: %v",
+		English: "Failed to marshal the playlist description: %v",
+	},
+	"playlist_zip.song_not_found": {
+		Russian: "Песни %v из плэйлиста нет в БД",
+		English: "Playlist song %v is not in the DB",
+	},
+	"zip.stat_error": {
+		Russian: "При чтении файла из хранилища id = %v ошибка: %v",
+		English: "Failed to read the file from the storage, id = %v: %v",
+	},
+	"zip.incomplete": {
+		Russian: "Архив отправлен не полностью: %v",
+		English: "The archive was sent incompletely: %v",
+	},
+	"zip.sent": {
+		Russian: "Песни в формате zip успешно отправлены",
+		English: "Songs sent as a zip archive",
+	},
+	"downloads.increment_error": {
+		Russian: "При инкременте поля CountOfDownload: %v",
+		English: "Failed to increment CountOfDownload: %v",
+	},
+	"downloads.stats_error": {
+		Russian: "При обновлении посуточной статистики загрузок: %v",
+		English: "Failed to update daily download statistics: %v",
+	},
+	"downloads.events_error": {
+		Russian: "При сохранении событий загрузок: %v",
+		English: "Failed to save download events: %v",
+	},
+	"downloads.counted": {
+		Russian: "Успешно увеличено кол-во загрузок для скачиваемых песен",
+		English: "Download counts of the downloaded songs increased",
+	},
+	"response.marshal_error": {
+		Russian: "При маршалинге в json результата: %v",
+		English: "Failed to marshal the result to JSON: %v",
+	},
+	"response.write_error": {
+		Russian: "При отдаче метаинформации: %v",
+		English: "Failed to send the metadata: %v",
+	},
+	"response.sent": {
+		Russian: "Отдача метаданных успешно закончена",
+		English: "Metadata sent",
+	},
+	"db.update_error": {
+		Russian: "При обновлении записи в БД: %v",
+		English: "Failed to update the record in the DB: %v",
+	},
+
+	// Логи журнала изменений, блобов и миграции хранилища
+	"journal.insert_error": {
+		Russian: "При добавлении записи в журнал изменений: %v",
+		English: "Failed to add an entry to the change journal: %v",
+	},
+	"journal.unfinished": {
+		Russian: "Изменение %v не завершено, оно будет восстановлено при следующем запуске: %v",
+		English: "Change %v is not finished, it will be recovered at the next start: %v",
+	},
+	"journal.delete_error": {
+		Russian: "При удалении записи из журнала изменений: %v",
+		English: "Failed to delete an entry from the change journal: %v",
+	},
+	"journal.read_error": {
+		Russian: "При чтении журнала изменений: %v",
+		English: "Failed to read the change journal: %v",
+	},
+	"journal.recovery_start": {
+		Russian: "Восстановление незавершенных изменений, записей в журнале: %v",
+		English: "Recovering unfinished changes, journal entries: %v",
+	},
+	"journal.recover_error": {
+		Russian: "При восстановлении изменения %v песни %v: %v",
+		English: "Failed to recover change %v of song %v: %v",
+	},
+	"journal.recovery_done": {
+		Russian: "Восстановление закончено, восстановлено изменений: %v из %v",
+		English: "Recovery finished, changes recovered: %v of %v",
+	},
+	"journal.unknown_operation": {
+		Russian: "Неизвестная операция в журнале изменений: %q",
+		English: "Unknown operation in the change journal: %q",
+	},
+	"blob.acquire_error": {
+		Russian: "При добавлении ссылки на блоб в БД: %v",
+		English: "Failed to add a blob reference to the DB: %v",
+	},
+	"blob.release_error": {
+		Russian: "При удалении ссылки на блоб в БД: %v",
+		English: "Failed to remove a blob reference from the DB: %v",
+	},
+	"blob.delete_error": {
+		Russian: "При удалении блоба из хранилища: %v",
+		English: "Failed to delete the blob from the storage: %v",
+	},
+	"migrate.find_error": {
+		Russian: "При поиске песен для миграции хранилища: %v",
+		English: "Failed to find songs for the storage migration: %v",
+	},
+	"migrate.start": {
+		Russian: "Началась миграция хранилища, песен: %v",
+		English: "Storage migration started, songs: %v",
+	},
+	"migrate.song_error": {
+		Russian: "При миграции файла песни %v: %v",
+		English: "Failed to migrate the file of song %v: %v",
+	},
+	"migrate.rollback_error": {
+		Russian: "При возврате файла %v после неудачной миграции: %v",
+		English: "Failed to restore file %v after a failed migration: %v",
+	},
+	"migrate.done": {
+		Russian: "Миграция хранилища закончена, перенесено файлов: %v из %v",
+		English: "Storage migration finished, files moved: %v of %v",
+	},
+	"migrate.duplicate_delete_error": {
+		Russian: "При удалении дубликата файла из хранилища: %v",
+		English: "Failed to delete a duplicate file from the storage: %v",
+	},
+
+	// Логи фоновых задач
+	"scrub.started": {
+		Russian: "Фоновая проверка хранилища запущена, период: %v, режим: %v",
+		English: "Background storage scrub started, interval: %v, mode: %v",
+	},
+	"scrub.start": {
+		Russian: "Началась проверка хранилища",
+		English: "Storage scrub started",
+	},
+	"scrub.list_error": {
+		Russian: "При получении списка файлов хранилища: %v",
+		English: "Failed to list the storage files: %v",
+	},
+	"scrub.songs_error": {
+		Russian: "При поиске песен для проверки хранилища: %v",
+		English: "Failed to find songs for the storage scrub: %v",
+	},
+	"scrub.blobs_error": {
+		Russian: "При поиске блобов для проверки хранилища: %v",
+		English: "Failed to find blobs for the storage scrub: %v",
+	},
+	"scrub.done": {
+		Russian: "Закончилась проверка хранилища: нет файлов: %v, осиротевших файлов: %v, не совпал размер: %v, не совпал счетчик ссылок: %v",
+		English: "Storage scrub finished: missing files: %v, orphan files: %v, size mismatches: %v, reference count mismatches: %v",
+	},
+	"charts_job.started": {
+		Russian: "Фоновый расчет чартов запущен, период: %v",
+		English: "Background charts computation started, interval: %v",
+	},
+	"charts_job.purge_error": {
+		Russian: "При удалении устаревших событий загрузок: %v",
+		English: "Failed to delete outdated download events: %v",
+	},
+	"charts_job.purged": {
+		Russian: "Удалено устаревших событий загрузок: %v",
+		English: "Outdated download events deleted: %v",
+	},
+	"charts_job.events_error": {
+		Russian: "При чтении событий загрузок для чартов: %v",
+		English: "Failed to read download events for the charts: %v",
+	},
+	"charts_job.songs_error": {
+		Russian: "При поиске песен для чартов: %v",
+		English: "Failed to find songs for the charts: %v",
+	},
+	"charts_job.done": {
+		Russian: "Чарты рассчитаны, песен в чартах: %v",
+		English: "Charts computed, songs in the charts: %v",
+	},
+	"plays_job.started": {
+		Russian: "Фоновое удаление событий прослушиваний запущено, период: %v, срок хранения: %v",
+		English: "Background play events purge started, interval: %v, retention: %v",
+	},
+	"plays_job.purge_error": {
+		Russian: "При удалении устаревших событий прослушиваний: %v",
+		English: "Failed to delete outdated play events: %v",
+	},
+	"plays_job.purged": {
+		Russian: "Удалено устаревших событий прослушиваний: %v",
+		English: "Outdated play events deleted: %v",
+	},
+	"replaygain.started": {
+		Russian: "Фоновый расчет ReplayGain запущен, период: %v",
+		English: "Background ReplayGain analysis started, interval: %v",
+	},
+	"replaygain.find_error": {
+		Russian: "При поиске песен для расчета ReplayGain: %v",
+		English: "Failed to find songs for ReplayGain analysis: %v",
+	},
+	"replaygain.start": {
+		Russian: "Начался расчет ReplayGain, песен без ReplayGain альбома: %v",
+		English: "ReplayGain analysis started, songs without album ReplayGain: %v",
+	},
+	"replaygain.album_error": {
+		Russian: "При поиске песен альбома в БД: %v",
+		English: "Failed to find album songs in the DB: %v",
+	},
+	"replaygain.done": {
+		Russian: "Закончился расчет ReplayGain",
+		English: "ReplayGain analysis finished",
+	},
+	"replaygain.playlist_error": {
+		Russian: "При поиске плэйлиста для расчета ReplayGain: %v",
+		English: "Failed to find the playlist for ReplayGain analysis: %v",
+	},
+	"replaygain.playlist_songs_error": {
+		Russian: "При поиске песен плэйлиста в БД: %v",
+		English: "Failed to find playlist songs in the DB: %v",
+	},
+	"replaygain.playlist_done": {
+		Russian: "Закончился расчет ReplayGain плэйлиста %v",
+		English: "ReplayGain analysis of playlist %v finished",
+	},
+	"replaygain.analyze_error": {
+		Russian: "При анализе громкости песни %v: %v",
+		English: "Failed to analyze the loudness of song %v: %v",
+	},
+	"replaygain.save_error": {
+		Russian: "При сохранении ReplayGain в БД: %v",
+		English: "Failed to save ReplayGain to the DB: %v",
+	},
+	"replaygain.tags_unsupported": {
+		Russian: "Запись ReplayGain в тэги файла %q не поддерживается",
+		English: "Writing ReplayGain tags to file %q is not supported",
+	},
+	"replaygain.tags_error": {
+		Russian: "При записи ReplayGain в тэги песни %v: %v",
+		English: "Failed to write ReplayGain tags of song %v: %v",
+	},
+	"payload_hash.find_error": {
+		Russian: "При поиске песен без хэша аудиоданных: %v",
+		English: "Failed to find songs without an audio payload hash: %v",
+	},
+	"payload_hash.start": {
+		Russian: "Начался расчет хэшей аудиоданных, песен без хэша: %v",
+		English: "Audio payload hashing started, songs without a hash: %v",
+	},
+	"payload_hash.save_error": {
+		Russian: "При сохранении хэша аудиоданных в БД: %v",
+		English: "Failed to save the audio payload hash to the DB: %v",
+	},
+	"payload_hash.duplicate": {
+		Russian: "Песня %v - точная копия песни %v",
+		English: "Song %v is an exact copy of song %v",
+	},
+	"payload_hash.done": {
+		Russian: "Закончился расчет хэшей аудиоданных",
+		English: "Audio payload hashing finished",
+	},
+	"trash_job.started": {
+		Russian: "Фоновая очистка корзины запущена, период: %v, срок хранения: %v",
+		English: "Background trash purge started, interval: %v, retention: %v",
+	},
+	"trash_job.find_error": {
+		Russian: "При поиске песен для очистки корзины: %v",
+		English: "Failed to find songs to purge from the trash: %v",
+	},
+	"trash_job.song_error": {
+		Russian: "При удалении песни %v из корзины: %v",
+		English: "Failed to purge song %v from the trash: %v",
+	},
+	"trash_job.playlists_error": {
+		Russian: "При удалении плэйлистов из корзины: %v",
+		English: "Failed to purge playlists from the trash: %v",
+	},
+	"trash_job.done": {
+		Russian: "Корзина очищена, удалено песен: %v, плэйлистов: %v",
+		English: "Trash purged, songs deleted: %v, playlists: %v",
+	},
+
+	// Логи подключения к базам данных
+	"sqlite.open_error": {
+		Russian: "При открытии базы данных SQLite: %v",
+		English: "Failed to open the SQLite database: %v",
+	},
+	"sqlite.migrate_error": {
+		Russian: "При миграции базы данных SQLite: %v",
+		English: "Failed to migrate the SQLite database: %v",
+	},
+	"sqlite.migration_applied": {
+		Russian: "Применена миграция базы данных SQLite, версия: %v",
+		English: "SQLite database migration applied, version: %v",
+	},
+	"sqlite.opened": {
+		Russian: "База данных SQLite %q открыта",
+		English: "SQLite database %q opened",
+	},
+	"sqlite.close_error": {
+		Russian: "При закрытии базы данных SQLite: %v",
+		English: "Failed to close the SQLite database: %v",
+	},
+	"mongo.connect_error": {
+		Russian: "При подключении к серверу БД: %v",
+		English: "Failed to connect to the DB server: %v",
+	},
+	"mongo.migrate_error": {
+		Russian: "При миграции базы данных: %v",
+		English: "Failed to migrate the database: %v",
+	},
+	"mongo.migration_applied": {
+		Russian: "Применена миграция базы данных, версия: %v (%v)",
+		English: "Database migration applied, version: %v (%v)",
+	},
+	"mongo.connected": {
+		Russian: "Подключение к базе данных установлено",
+		English: "Connected to the database",
+	},
+	"mongo.disconnect_error": {
+		Russian: "При отключении от БД: %v",
+		English: "Failed to disconnect from the DB: %v",
+	},
+
+	// Логи запуска сервера
+	"log.open_error": {
+		Russian: "Файл логов (%q) не открылся: %v",
+		English: "Failed to open the log file (%q): %v",
+	},
+	"storage.init_error": {
+		Russian: "При инициализации хранилища файлов: %v",
+		English: "Failed to initialize the file storage: %v",
+	},
+	"storage.initialized": {
+		Russian: "Хранилище файлов (%v) инициализировано",
+		English: "File storage (%v) initialized",
+	},
+	"server.error": {
+		Russian: "HTTP сервер остановлен: %v",
+		English: "HTTP server stopped: %v",
+	},
+
+	// Логи разбора аудиофайлов
+	"mp3.id3v1_decode_error": {
+		Russian: "При перекодировании тэга ID3v1: %v",
+		English: "Failed to decode an ID3v1 tag: %v",
+	},
+	"mp3.id3v1_short_tag": {
+		Russian: "Считано %v, а размер тэга ID3v1 %v",
+		English: "Read %v bytes, but the ID3v1 tag size is %v",
+	},
+	"mp3.id3v1_read_error": {
+		Russian: "Чтение ID3v1 тэга: %v",
+		English: "Failed to read the ID3v1 tag: %v",
+	},
+	"mp3.id3v1_not_found": {
+		Russian: "Это не ID3v1: найдено %q, а должно быть TAG",
+		English: "Not an ID3v1 tag: found %q instead of TAG",
+	},
+	"mp3.id3v1_year_error": {
+		Russian: "При чтении года из тэга ID3v1: %v",
+		English: "Failed to read the year from the ID3v1 tag: %v",
+	},
+	"mp3.id3v2_read_error": {
+		Russian: "При чтении заголовка ID3v2: %v",
+		English: "Failed to read the ID3v2 header: %v",
+	},
+	"mp3.id3v2_short_header": {
+		Russian: "Считано %v, а размер заголовка ID3v2 %v",
+		English: "Read %v bytes, but the ID3v2 header size is %v",
+	},
+	"mp3.id3v2_seek_end_error": {
+		Russian: "При попытке переместиться на конец текущего заголовка ID3v2: %v",
+		English: "Failed to seek to the end of the current ID3v2 header: %v",
+	},
+	"mp3.id3v2_seek_next_error": {
+		Russian: "При попытке переместиться на следующий заголовок ID3v2: %v",
+		English: "Failed to seek to the next ID3v2 header: %v",
+	},
+	"mp3.id3v2_search_error": {
+		Russian: "При поиске следующего заголовка ID3v2: %v",
+		English: "Failed to search for the next ID3v2 header: %v",
+	},
+	"mp3.id3v2_next_short_header": {
+		Russian: "При поиске следующего тэга ID3v2 считано %v, а размер заголовка ID3v2 %v",
+		English: "Searching for the next ID3v2 tag read %v bytes, but the ID3v2 header size is %v",
+	},
+	"mp3.text_frame_read_error": {
+		Russian: "При чтении текстового фрейма ID3v2: %v",
+		English: "Failed to read an ID3v2 text frame: %v",
+	},
+	"mp3.text_frame_decode_error": {
+		Russian: "При перекодировании текстового фрейма ID3v2: %v",
+		English: "Failed to decode an ID3v2 text frame: %v",
+	},
+	"mp3.frames_error": {
+		Russian: "При чтении фреймов MP3: %v",
+		English: "Failed to read MP3 frames: %v",
+	},
+	"mp3.seek_tag_end_error": {
+		Russian: "При попытке переместиться на конец тэга ID3v2: %v",
+		English: "Failed to seek to the end of the ID3v2 tag: %v",
+	},
+	"mp3.seek_frame_error": {
+		Russian: "При попытке переместиться на начало заголовка фрейма MP3: %v",
+		English: "Failed to seek to the MP3 frame header: %v",
+	},
+	"mp3.next_frame_error": {
+		Russian: "При переходе на следующий фрейм MP3: %v",
+		English: "Failed to seek to the next MP3 frame: %v",
+	},
+	"mp3.next_header_error": {
+		Russian: "При чтении следующего заголовка фрейма MP3: %v",
+		English: "Failed to read the next MP3 frame header: %v",
+	},
+	"mp3.short_frame_header": {
+		Russian: "Кол-во считанных байт меньше, чем размер заголовка фрейма MP3",
+		English: "Read fewer bytes than the MP3 frame header size",
+	},
+	"mp3.frame_search_error": {
+		Russian: "При поиске заголовка фрейма MP3: %v",
+		English: "Failed to search for the MP3 frame header: %v",
+	},
+	"flac.seek_start_error": {
+		Russian: "При переходе на начало файла для парсинга метаданных: %v",
+		English: "Failed to seek to the start of the file to parse metadata: %v",
+	},
+	"flac.marker_read_error": {
+		Russian: "При чтении предположительно маркера flac: %v",
+		English: "Failed to read the presumed flac marker: %v",
+	},
+	"flac.not_flac": {
+		Russian: "Это не flac",
+		English: "Not a flac file",
+	},
+	"flac.marker_search_error": {
+		Russian: "При чтении данных для поиска маркера flac: %v",
+		English: "Failed to read data to search for the flac marker: %v",
+	},
+	"flac.seek_data_error": {
+		Russian: "При переходе на начало данных flac для парсинга метаданных: %v",
+		English: "Failed to seek to the flac data to parse metadata: %v",
+	},
+	"flac.seek_end_error": {
+		Russian: "При переходе на конец файла для вычисления битрейта: %v",
+		English: "Failed to seek to the end of the file to compute the bitrate: %v",
+	},
+	"flac.seek_next_error": {
+		Russian: "При переходе на следующий заголовок метаданных: %v",
+		English: "Failed to seek to the next metadata header: %v",
+	},
+	"flac.header_read_error": {
+		Russian: "При чтении заголовка метаданных: %v",
+		English: "Failed to read a metadata header: %v",
+	},
+	"flac.metadata_read_error": {
+		Russian: "При чтении метаданных: %v",
+		English: "Failed to read metadata: %v",
+	},
+	"flac.streaminfo_read_error": {
+		Russian: "При чтении StreamInfo: %v",
+		English: "Failed to read StreamInfo: %v",
+	},
+	"wav.parse_error": {
+		Russian: "При парсинге метаданных wav: %v",
+		English: "Failed to parse wav metadata: %v",
+	},
+	"wav.list_read_error": {
+		Russian: "При чтении блока LIST: %v",
+		English: "Failed to read the LIST chunk: %v",
+	},
+
+	// Логи чтения конфига
+	"config.open_error": {
+		Russian: "При открытии xml файла(%v) для парсинга: %v",
+		English: "Failed to open the XML file (%v) for parsing: %v",
+	},
+	"config.unmarshal_error": {
+		Russian: "При анмаршалинге xml файла(%q): %v",
+		English: "Failed to unmarshal the XML file (%q): %v",
+	},
+	"config.unknown_log_lang": {
+		Russian: "Не известный язык логов(ru или en), а вы ввели %q",
+		English: "Unknown log language (ru or en), got %q",
+	},
+	"config.parsed": {
+		Russian: "Файл %q успешно распарсен.",
+		English: "The file %q was parsed successfully.",
+	},
+	"config.valid": {
+		Russian: "Конфиг успешно прошел проверку.",
+		English: "The config passed validation.",
+	},
+	"config.invalid_http_port": {
+		Russian: "Не валидный номер http порта(от 1024 до 65535), а вы ввели %v",
+		English: "Invalid HTTP port (1024 to 65535), got %v",
+	},
+	"config.invalid_db_name": {
+		Russian: "Не валидное имя базы данных(не должно быть символов /, \\, ., \", *, <, >, :, |, ?, $), введено: %q",
+		English: "Invalid database name (must not contain /, \\, ., \", *, <, >, :, |, ?, $), got %q",
+	},
+	"config.invalid_db_port": {
+		Russian: "Не валидный номер порта базы данных(от 1024 до 65535), а вы ввели %v",
+		English: "Invalid database port (1024 to 65535), got %v",
+	},
+	"config.sqlite_path_required": {
+		Russian: "Для базы данных sqlite нужно указать path",
+		English: "The sqlite database requires a path",
+	},
+	"config.unknown_db_driver": {
+		Russian: "Не известный драйвер базы данных(mongodb или sqlite), а вы ввели %q",
+		English: "Unknown database driver (mongodb or sqlite), got %q",
+	},
+	"config.invalid_replaygain_interval": {
+		Russian: "Не валидный период расчета ReplayGain(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid ReplayGain interval (must not be negative), got %v",
+	},
+	"config.invalid_fingerprint_threshold": {
+		Russian: "Не валидный порог сходства отпечатков(от 0 до 1), а вы ввели %v",
+		English: "Invalid fingerprint similarity threshold (0 to 1), got %v",
+	},
+	"config.s3_required": {
+		Russian: "Для хранилища s3 нужно указать endpoint и bucket",
+		English: "The s3 storage requires an endpoint and a bucket",
+	},
+	"config.unknown_storage_driver": {
+		Russian: "Не известный драйвер хранилища(local или s3), а вы ввели %q",
+		English: "Unknown storage driver (local or s3), got %q",
+	},
+	"config.invalid_max_upload": {
+		Russian: "Не валидный максимальный размер загрузки(от 0 до %v мегабайт), а вы ввели %v",
+		English: "Invalid maximum upload size (0 to %v megabytes), got %v",
+	},
+	"config.invalid_name_template": {
+		Russian: "Шаблон имен файлов плейлиста должен содержать {index}, а вы ввели %q",
+		English: "The playlist file name template must contain {index}, got %q",
+	},
+	"config.invalid_scrub_interval": {
+		Russian: "Не валидный период проверки хранилища(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid storage check interval (must not be negative), got %v",
+	},
+	"config.unknown_scrub_mode": {
+		Russian: "Не известный режим проверки хранилища(dryrun, quarantine или repair), а вы ввели %q",
+		English: "Unknown storage check mode (dryrun, quarantine or repair), got %q",
+	},
+	"config.invalid_trash_retention": {
+		Russian: "Не валидный срок хранения корзины(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid trash retention (must not be negative), got %v",
+	},
+	"config.invalid_trash_interval": {
+		Russian: "Не валидный период очистки корзины(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid trash purge interval (must not be negative), got %v",
+	},
+	"config.invalid_charts_interval": {
+		Russian: "Не валидный период расчета чартов(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid charts interval (must not be negative), got %v",
+	},
+	"config.invalid_play_retention": {
+		Russian: "Не валидный срок хранения событий прослушиваний(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid play events retention (must not be negative), got %v",
+	},
+	"config.invalid_play_interval": {
+		Russian: "Не валидный период удаления событий прослушиваний(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid play events purge interval (must not be negative), got %v",
+	},
+	"config.invalid_default_page_size": {
+		Russian: "Не валидный размер страницы по умолчанию(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid default page size (must not be negative), got %v",
+	},
+	"config.invalid_max_page_size": {
+		Russian: "Не валидный максимальный размер страницы(не может быть отрицательным), а вы ввели %v",
+		English: "Invalid maximum page size (must not be negative), got %v",
+	},
+	"config.default_page_size_too_large": {
+		Russian: "Размер страницы по умолчанию(%v) больше максимального(%v)",
+		English: "The default page size (%v) is larger than the maximum (%v)",
+	},
+	"config.invalid_errors_format": {
+		Russian: "Не валидный формат ошибок(допустимы json и text), а вы ввели %v",
+		English: "Invalid errors format (json or text), got %v",
+	},
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Операции журнала изменений песен и их файлов
+const (
+	journalUpload  = "upload"  // добавление песни: запись журнала, файл, запись о песне
+	journalDelete  = "delete"  // окончательное удаление песни и освобождение ее файла
+	journalRewrite = "rewrite" // замена файла песни, например при записи тэгов
+)
+
+// beginJournal - добавляет в журнал запись о начатом изменении песни и ее файлов.
+// Запись удаляется функцией finishJournal, а если сервер остановится раньше, то изменение
+// будет завершено или откачено при следующем запуске в recoverJournal.
+// Журнал пишется и после отмены запроса, поэтому запросы к БД не зависят от контекста вызывающего.
+func (store *blobStore) beginJournal(operation string, songID primitive.ObjectID, blob, oldBlob string) (primitive.ObjectID, error) {
+	entry := JournalEntry{
+		ID:        primitive.NewObjectID(),
+		Operation: operation,
+		Song:      songID,
+		Blob:      blob,
+		OldBlob:   oldBlob,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err := store.journal.Insert(context.Background(), &entry)
+	if err != nil {
+		i18n.Error("journal.insert_error", err)
+		return primitive.NilObjectID, err
+	}
+
+	return entry.ID, nil
+}
+
+// finishJournal - удаляет запись журнала, если изменение (или его откат) завершено без ошибок.
+// Иначе запись остается, и хранилище и БД согласуются при следующем запуске сервера.
+func (store *blobStore) finishJournal(id primitive.ObjectID, err error) {
+	if err != nil {
+		i18n.Error("journal.unfinished", id.Hex(), err)
+		return
+	}
+
+	err = store.journal.Delete(context.Background(), id)
+	if err != nil {
+		i18n.Error("journal.delete_error", err)
+	}
+}
+
+// removeSongRecords - удаляет из БД песню и связанные с ней записи, убирает ее из плейлистов
+// и освобождает ее файл. Удаление записывается в журнал: если оно прервется, то будет
+// завершено при следующем запуске сервера.
+func (store *blobStore) removeSongRecords(song *SongInfo) error {
+	journalID, err := store.beginJournal(journalDelete, song.ID, "", song.Blob)
+	if err != nil {
+		return err
+	}
+
+	err = store.songs.Delete(context.Background(), song.ID)
+	if err != nil {
+		store.finishJournal(journalID, err)
+		return err
+	}
+
+	err = store.deleteSongRecords(context.Background(), song.ID)
+	if err == nil {
+		err = store.releaseSongFile(song)
+	}
+	store.finishJournal(journalID, err)
+
+	return err
+}
+
+// deleteSongRecords - удаляет форму волны, отпечаток и статистику загрузок песни и убирает ее из плейлистов
+func (store *blobStore) deleteSongRecords(ctx context.Context, id primitive.ObjectID) error {
+	err := store.waveforms.Delete(ctx, id)
+	if err != nil && err != errNotFound {
+		return err
+	}
+
+	err = store.fingerprints.Delete(ctx, id)
+	if err != nil && err != errNotFound {
+		return err
+	}
+
+	err = store.stats.DeleteSong(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = store.playEvents.DeleteSong(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return store.playlists.RemoveSong(ctx, id)
+}
+
+// recoverJournal - завершает или откатывает изменения, оставшиеся в журнале после остановки сервера.
+// Вызывается при запуске, пока нет запросов и фоновых задач, которые могли бы менять те же блобы.
+func (store *blobStore) recoverJournal() {
+	ctx := context.Background()
+
+	entries, err := store.journal.All(ctx)
+	if err != nil {
+		i18n.Fatal("journal.read_error", err)
+	}
+	if len(entries) == 0 {
+		return
+	}
+	i18n.Info("journal.recovery_start", len(entries))
+
+	recovered := 0
+	for i := range entries {
+		err = store.recoverEntry(ctx, &entries[i])
+		if err != nil {
+			i18n.Error("journal.recover_error", entries[i].Operation, entries[i].Song.Hex(), err)
+			continue
+		}
+
+		err = store.journal.Delete(ctx, entries[i].ID)
+		if err != nil {
+			i18n.Error("journal.delete_error", err)
+			continue
+		}
+		recovered++
+	}
+
+	i18n.Info("journal.recovery_done", recovered, len(entries))
+}
+
+// recoverEntry - доводит до конца или откатывает одно изменение из журнала.
+// Удаление песни всегда доводится до конца. Для загрузки и замены файла по записям
+// о песнях пересчитываются ссылки на блобы: если песня успела сослаться на новый блоб,
+// то изменение завершено, иначе новый блоб без ссылок удаляется.
+func (store *blobStore) recoverEntry(ctx context.Context, entry *JournalEntry) error {
+	switch entry.Operation {
+	case journalUpload:
+		return store.reconcileBlob(ctx, entry.Blob)
+	case journalRewrite:
+		err := store.reconcileBlob(ctx, entry.Blob)
+		if err != nil {
+			return err
+		}
+
+		return store.reconcileSongFile(ctx, entry.Song, entry.OldBlob)
+	case journalDelete:
+		err := store.songs.Delete(ctx, entry.Song)
+		if err != nil && err != errNotFound {
+			return err
+		}
+
+		err = store.deleteSongRecords(ctx, entry.Song)
+		if err != nil {
+			return err
+		}
+
+		return store.reconcileSongFile(ctx, entry.Song, entry.OldBlob)
+	}
+
+	i18n.Error("journal.unknown_operation", entry.Operation)
+	return nil
+}
+
+// reconcileSongFile - пересчитывает ссылки на блоб, а для файла, хранящегося под ID песни
+// (blob пустой), удаляет его, если песня (в том числе в корзине) больше на него не ссылается
+func (store *blobStore) reconcileSongFile(ctx context.Context, songID primitive.ObjectID, blob string) error {
+	if blob != "" {
+		return store.reconcileBlob(ctx, blob)
+	}
+
+	song, err := store.songs.FindByIDIncludingTrash(ctx, songID)
+	if err == nil && song.Blob == "" {
+		return nil
+	}
+	if err != nil && err != errNotFound {
+		return err
+	}
+
+	err = store.files.Delete(songID.Hex())
+	if err != nil && err != storage.ErrNotExist {
+		return err
+	}
+
+	return nil
+}
+
+// reconcileBlob - записывает в блоб количество песен, которые на него ссылаются.
+// Блоб без ссылок удаляется вместе с файлом.
+func (store *blobStore) reconcileBlob(ctx context.Context, hash string) error {
+	count, err := store.songs.CountByBlob(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	if count != 0 {
+		var size int64
+		info, err := store.files.Stat(blobName(hash))
+		if err == nil {
+			size = info.Size
+		}
+
+		return store.blobs.SetRefCount(ctx, hash, count, size)
+	}
+
+	err = store.blobs.Delete(ctx, hash)
+	if err != nil && err != errNotFound {
+		return err
+	}
+
+	err = store.files.Delete(blobName(hash))
+	if err != nil && err != storage.ErrNotExist {
+		return err
+	}
+
+	return nil
+}
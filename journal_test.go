@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReconcileSongFile(t *testing.T) {
+	ctx := context.Background()
+	store := newTestBlobStore(t)
+	repos, files := store.repositories, store.files
+
+	active := &SongInfo{ID: primitive.NewObjectID()}
+	trashed := &SongInfo{ID: primitive.NewObjectID()}
+	deleted := &SongInfo{ID: primitive.NewObjectID()}
+	for _, song := range []*SongInfo{active, trashed, deleted} {
+		data := strings.NewReader("data")
+		if err := files.Put(song.ID.Hex(), data, data.Size()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repos.songs.Insert(ctx, active)
+	repos.songs.Insert(ctx, trashed)
+	repos.songs.Trash(ctx, trashed.ID, nil)
+
+	for _, song := range []*SongInfo{active, trashed, deleted} {
+		err := store.reconcileSongFile(ctx, song.ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, song := range []*SongInfo{active, trashed} {
+		if _, err := files.Stat(song.ID.Hex()); err != nil {
+			t.Errorf("file of song %v: %v", song.ID.Hex(), err)
+		}
+	}
+	if _, err := files.Stat(deleted.ID.Hex()); err != storage.ErrNotExist {
+		t.Errorf("file of deleted song: %v, want ErrNotExist", err)
+	}
+}
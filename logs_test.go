@@ -0,0 +1,92 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/STEJLS/AudioServer/i18n"
+)
+
+// Логи пишутся только через каталог i18n: прямые вызовы log.Print*, log.Fatal* и log.Panic*
+// вне пакета i18n запрещены, а ключи сообщений в вызовах i18n.Info/Error/Fatal должны быть в каталоге
+func TestLogsThroughCatalogue(t *testing.T) {
+	fset := token.NewFileSet()
+	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != "." && (path == "i18n" || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		imports := make(map[string]string)
+		for _, spec := range file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			name := filepath.Base(importPath)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			imports[name] = importPath
+		}
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			selector, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			pkg, ok := selector.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+
+			switch imports[pkg.Name] {
+			case "log":
+				for _, prefix := range []string{"Print", "Fatal", "Panic"} {
+					if strings.HasPrefix(selector.Sel.Name, prefix) {
+						t.Errorf("%v: log.%v instead of i18n", fset.Position(call.Pos()), selector.Sel.Name)
+					}
+				}
+			case "github.com/STEJLS/AudioServer/i18n":
+				switch selector.Sel.Name {
+				case "Info", "Error", "Fatal":
+				default:
+					return true
+				}
+				literal, ok := call.Args[0].(*ast.BasicLit)
+				if !ok {
+					return true
+				}
+				key, _ := strconv.Unquote(literal.Value)
+				if i18n.T(i18n.Default, key) == key {
+					t.Errorf("%v: key %q is not in the catalogue", fset.Position(call.Pos()), key)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
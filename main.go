@@ -2,10 +2,10 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/STEJLS/AudioServer/XMLconfig"
+	"github.com/STEJLS/AudioServer/i18n"
 )
 
 func main() {
@@ -15,33 +15,76 @@ func main() {
 
 	config := XMLconfig.Get(configSource)
 
-	connectToDB(config.Db.Host, config.Db.Port, config.Db.Name)
-	defer audioDBsession.Close()
+	repos, closeDB := connectToDB(config.Db)
+	defer closeDB()
+
+	store := newBlobStore(initStorage(config.Storage), repos)
+	store.recoverJournal()
+
+	if migrateStorageOnly {
+		store.migrateStorage()
+		return
+	}
+
+	fingerprintThreshold = config.Fingerprint.Threshold
+	if fingerprintThreshold == 0 {
+		fingerprintThreshold = defaultFingerprintThreshold
+	}
+
+	maxUploadSize = config.Upload.MaxSize
+	if maxUploadSize == 0 {
+		maxUploadSize = defaultMaxUploadSize
+	}
+	maxUploadSize <<= 20
+
+	playlistNameTemplate = config.PlaylistArchive.NameTemplate
+	if playlistNameTemplate == "" {
+		playlistNameTemplate = defaultPlaylistNameTemplate
+	}
+
+	defaultPageSize = config.Pagination.DefaultSize
+	if defaultPageSize == 0 {
+		defaultPageSize = defaultCountMatadataForUpload
+	}
+
+	maxPageSize = config.Pagination.MaxSize
+	if maxPageSize == 0 {
+		maxPageSize = defaultMaxPageSize
+	}
+	if defaultPageSize > maxPageSize {
+		defaultPageSize = maxPageSize
+	}
+
+	errorsFormat = config.Errors.Format
+	if errorsFormat == "" {
+		errorsFormat = errorFormatJSON
+	}
+
+	replayGain := newReplayGainJob(store, config.ReplayGain.Interval, config.ReplayGain.WriteTags)
+	go replayGain.run()
+	go hashCatalogue(repos.songs, store.files)
+
+	scrubber := newScrubJob(store, config.Scrubber.Interval, config.Scrubber.Mode)
+	go scrubber.run()
+
+	trash := newTrashJob(store, config.Trash.Interval, config.Trash.Retention)
+	go trash.run()
+
+	charts := newChartsJob(repos.songs, repos.downloadEvents, config.Charts.Interval)
+	go charts.run()
+
+	plays := newPlaysJob(repos.playEvents, config.Plays.Interval, config.Plays.Retention)
+	go plays.run()
+
+	h := newHandlers(store, replayGain, scrubber, trash, charts)
 
 	server := http.Server{
-		Addr: fmt.Sprintf("%v:%v", config.HTTP.Host, config.HTTP.Port),
-	}
-
-	http.HandleFunc("/addSong", addSong)
-	http.HandleFunc("/addPlaylist", addPlaylist)
-	http.HandleFunc("/getSong", getSong)
-	http.HandleFunc("/getSongsInZip", getSongsInZip)
-	http.HandleFunc("/getPlaylists", getPlaylists)
-	http.HandleFunc("/getPlaylistInZip", getPlaylistInZip)
-	http.HandleFunc("/getMetadataOfNewSongs", getMetadataOfNewSongs)
-	http.HandleFunc("/getMetadataOfPopularSongs", getMetadataOfPopularSongs)
-	http.HandleFunc("/getMetadataOfSongsbyIDs", getMetadataOfSongsbyIDs)
-	http.HandleFunc("/searchSongs", searchSongs)
-	http.HandleFunc("/searchPlaylists", searchPlaylists)
-	http.HandleFunc("/addSongForm", addSongForm)
-	http.HandleFunc("/getSongForm", getSongForm)
-	http.HandleFunc("/addPlaylistForm", addPlaylistForm)
-	http.HandleFunc("/getPopularSongsForm", getPopularSongsForm)
-	http.HandleFunc("/searchPlaylistsForm", searchPlaylistsForm)
-	http.HandleFunc("/getSongsInZipForm", getSongsInZipForm)
+		Addr:    fmt.Sprintf("%v:%v", config.HTTP.Host, config.HTTP.Port),
+		Handler: withRequestID(h.newMux()),
+	}
 
 	err := server.ListenAndServe()
 	if err != nil {
-		log.Println(err.Error())
+		i18n.Error("server.error", err)
 	}
 }
@@ -0,0 +1,976 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memorySongRepository - реализация SongRepository в памяти, например для тестов
+type memorySongRepository struct {
+	mutex sync.RWMutex
+	songs map[primitive.ObjectID]SongInfo
+}
+
+// memoryPlaylistRepository - реализация PlaylistRepository в памяти, например для тестов
+type memoryPlaylistRepository struct {
+	mutex     sync.RWMutex
+	playLists map[primitive.ObjectID]PlayList
+}
+
+// newMemorySongRepository - конструктор для типа memorySongRepository
+func newMemorySongRepository() *memorySongRepository {
+	return &memorySongRepository{songs: make(map[primitive.ObjectID]SongInfo)}
+}
+
+// newMemoryPlaylistRepository - конструктор для типа memoryPlaylistRepository
+func newMemoryPlaylistRepository() *memoryPlaylistRepository {
+	return &memoryPlaylistRepository{playLists: make(map[primitive.ObjectID]PlayList)}
+}
+
+// containsAny - содержит ли строка хотя бы одно из слов без учета регистра
+func containsAny(s string, words []string) bool {
+	s = strings.ToLower(s)
+	for _, word := range words {
+		if strings.Contains(s, strings.ToLower(word)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// limit - обрезает результат до count записей, count = 0 - без ограничения
+func limit(count, length int) int {
+	if count <= 0 || count > length {
+		return length
+	}
+
+	return count
+}
+
+// copySong - копия песни, не разделяющая с оригиналом срезы и указатели
+func copySong(song SongInfo) SongInfo {
+	if song.DeletedAt != nil {
+		deletedAt := *song.DeletedAt
+		song.DeletedAt = &deletedAt
+	}
+	if song.DuplicateOf != nil {
+		duplicateOf := *song.DuplicateOf
+		song.DuplicateOf = &duplicateOf
+	}
+	song.TrashedFrom = append([]PlaylistPosition(nil), song.TrashedFrom...)
+
+	return song
+}
+
+// copyPlaylist - копия плейлиста, не разделяющая с оригиналом срезы и указатели
+func copyPlaylist(playList PlayList) PlayList {
+	if playList.DeletedAt != nil {
+		deletedAt := *playList.DeletedAt
+		playList.DeletedAt = &deletedAt
+	}
+	playList.IDs = append([]string(nil), playList.IDs...)
+
+	return playList
+}
+
+// filter - копии песен вне корзины, для которых match возвращает true
+func (repo *memorySongRepository) filter(match func(song *SongInfo) bool) []SongInfo {
+	var songs []SongInfo
+	for _, song := range repo.songs {
+		if song.DeletedAt == nil && match(&song) {
+			songs = append(songs, copySong(song))
+		}
+	}
+
+	return songs
+}
+
+// collect - копии песен (в том числе в корзине), для которых match возвращает true
+func (repo *memorySongRepository) collect(match func(song *SongInfo) bool) []SongInfo {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var songs []SongInfo
+	for _, song := range repo.songs {
+		if match(&song) {
+			songs = append(songs, copySong(song))
+		}
+	}
+
+	return songs
+}
+
+// change - изменяет песню (в том числе в корзине) функцией edit
+func (repo *memorySongRepository) change(id primitive.ObjectID, edit func(song *SongInfo)) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	song, ok := repo.songs[id]
+	if !ok {
+		return errNotFound
+	}
+
+	edit(&song)
+	repo.songs[id] = song
+	return nil
+}
+
+// findID - ID первой песни (в том числе в корзине), для которой match возвращает true
+func (repo *memorySongRepository) findID(match func(song *SongInfo) bool) primitive.ObjectID {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for id, song := range repo.songs {
+		if match(&song) {
+			return id
+		}
+	}
+
+	return primitive.NilObjectID
+}
+
+// sameMetadata - совпадают ли метаданные, по которым ищутся дубликаты
+func sameMetadata(a, b *SongInfo) bool {
+	return a.Title == b.Title &&
+		a.Artist == b.Artist &&
+		a.Genre == b.Genre &&
+		a.Bitrate == b.Bitrate &&
+		a.Duration == b.Duration &&
+		a.Size == b.Size
+}
+
+func (repo *memorySongRepository) Insert(ctx context.Context, song *SongInfo) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.songs[song.ID]; ok {
+		return errDuplicate
+	}
+	for _, stored := range repo.songs {
+		if song.PayloadHash != "" && stored.PayloadHash == song.PayloadHash {
+			return errDuplicate
+		}
+	}
+
+	repo.songs[song.ID] = copySong(*song)
+	return nil
+}
+
+func (repo *memorySongRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*SongInfo, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	song, ok := repo.songs[id]
+	if !ok || song.DeletedAt != nil {
+		return nil, errNotFound
+	}
+
+	song = copySong(song)
+	return &song, nil
+}
+
+func (repo *memorySongRepository) FindByIDIncludingTrash(ctx context.Context, id primitive.ObjectID) (*SongInfo, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	song, ok := repo.songs[id]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	song = copySong(song)
+	return &song, nil
+}
+
+func (repo *memorySongRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]SongInfo, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	wanted := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	return repo.filter(func(song *SongInfo) bool { return wanted[song.ID] }), nil
+}
+
+func (repo *memorySongRepository) FindDuplicate(ctx context.Context, song *SongInfo) (primitive.ObjectID, error) {
+	return repo.findID(func(stored *SongInfo) bool { return sameMetadata(stored, song) }), nil
+}
+
+func (repo *memorySongRepository) FindByPayloadHash(ctx context.Context, hash string) (primitive.ObjectID, error) {
+	return repo.findID(func(stored *SongInfo) bool { return stored.PayloadHash == hash }), nil
+}
+
+func (repo *memorySongRepository) List(ctx context.Context, query *SongQuery) ([]SongInfo, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return query.page(repo.filter(func(*SongInfo) bool { return true })), nil
+}
+
+func (repo *memorySongRepository) Search(ctx context.Context, words []string) ([]SongInfo, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return repo.filter(func(song *SongInfo) bool {
+		return containsAny(song.Artist, words) || containsAny(song.Genre, words) || containsAny(song.Title, words)
+	}), nil
+}
+
+func (repo *memorySongRepository) Update(ctx context.Context, song *SongInfo) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.songs[song.ID]; !ok {
+		return errNotFound
+	}
+
+	repo.songs[song.ID] = copySong(*song)
+	return nil
+}
+
+func (repo *memorySongRepository) IncrementDownloads(ctx context.Context, ids []primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, id := range ids {
+		if song, ok := repo.songs[id]; ok {
+			song.CountOfDownload++
+			repo.songs[id] = song
+		}
+	}
+
+	return nil
+}
+
+func (repo *memorySongRepository) IncrementPlays(ctx context.Context, id primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	song, ok := repo.songs[id]
+	if !ok {
+		return errNotFound
+	}
+
+	song.CountOfPlays++
+	repo.songs[id] = song
+	return nil
+}
+
+func (repo *memorySongRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.songs[id]; !ok {
+		return errNotFound
+	}
+
+	delete(repo.songs, id)
+	return nil
+}
+
+func (repo *memorySongRepository) Trash(ctx context.Context, id primitive.ObjectID, positions []PlaylistPosition) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	song, ok := repo.songs[id]
+	if !ok || song.DeletedAt != nil {
+		return errNotFound
+	}
+
+	now := time.Now()
+	song.DeletedAt = &now
+	song.TrashedFrom = append([]PlaylistPosition(nil), positions...)
+	repo.songs[id] = song
+	return nil
+}
+
+func (repo *memorySongRepository) Restore(ctx context.Context, id primitive.ObjectID, after time.Time) (*SongInfo, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	song, ok := repo.songs[id]
+	if !ok || song.DeletedAt == nil || !song.DeletedAt.After(after) {
+		return nil, errNotFound
+	}
+
+	restored := song
+	restored.DeletedAt = nil
+	restored.TrashedFrom = nil
+	repo.songs[id] = restored
+
+	return &song, nil
+}
+
+func (repo *memorySongRepository) Trashed(ctx context.Context, after time.Time) ([]SongInfo, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var songs []SongInfo
+	for _, song := range repo.songs {
+		if song.DeletedAt != nil && song.DeletedAt.After(after) {
+			songs = append(songs, copySong(song))
+		}
+	}
+
+	sort.Slice(songs, func(i, j int) bool { return songs[i].DeletedAt.After(*songs[j].DeletedAt) })
+	return songs, nil
+}
+
+func (repo *memorySongRepository) Expired(ctx context.Context, before time.Time) ([]SongInfo, error) {
+	return repo.collect(func(song *SongInfo) bool {
+		return song.DeletedAt != nil && !song.DeletedAt.After(before)
+	}), nil
+}
+
+func (repo *memorySongRepository) All(ctx context.Context) ([]SongInfo, error) {
+	return repo.collect(func(*SongInfo) bool { return true }), nil
+}
+
+func (repo *memorySongRepository) WithoutBlob(ctx context.Context) ([]SongInfo, error) {
+	return repo.collect(func(song *SongInfo) bool { return song.Blob == "" }), nil
+}
+
+func (repo *memorySongRepository) SetBlob(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return repo.change(id, func(song *SongInfo) { song.Blob = hash })
+}
+
+func (repo *memorySongRepository) CountByBlob(ctx context.Context, hash string) (int, error) {
+	return len(repo.collect(func(song *SongInfo) bool { return song.Blob == hash })), nil
+}
+
+func (repo *memorySongRepository) WithoutPayloadHash(ctx context.Context) ([]SongInfo, error) {
+	return repo.collect(func(song *SongInfo) bool {
+		return song.PayloadHash == "" && song.DuplicateOf == nil
+	}), nil
+}
+
+func (repo *memorySongRepository) SetPayloadHash(ctx context.Context, id primitive.ObjectID, hash string) error {
+	if found := repo.findID(func(song *SongInfo) bool { return song.PayloadHash == hash }); !found.IsZero() && found != id {
+		return errDuplicate
+	}
+
+	return repo.change(id, func(song *SongInfo) { song.PayloadHash = hash })
+}
+
+func (repo *memorySongRepository) SetDuplicateOf(ctx context.Context, id primitive.ObjectID, original primitive.ObjectID) error {
+	return repo.change(id, func(song *SongInfo) { song.DuplicateOf = &original })
+}
+
+func (repo *memorySongRepository) DuplicateGroups(ctx context.Context) ([]duplicateGroup, error) {
+	duplicates := repo.collect(func(song *SongInfo) bool { return song.DuplicateOf != nil })
+
+	byOriginal := make(map[primitive.ObjectID][]primitive.ObjectID)
+	for _, song := range duplicates {
+		byOriginal[*song.DuplicateOf] = append(byOriginal[*song.DuplicateOf], song.ID)
+	}
+
+	var groups []duplicateGroup
+	for original, ids := range byOriginal {
+		groups = append(groups, duplicateGroup{Original: original, Duplicates: ids})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Original.Hex() < groups[j].Original.Hex() })
+	return groups, nil
+}
+
+func (repo *memorySongRepository) NotAlbumAnalyzed(ctx context.Context) ([]SongInfo, error) {
+	return repo.collect(func(song *SongInfo) bool { return !song.IsAlbumAnalyzed }), nil
+}
+
+func (repo *memorySongRepository) FindByAlbum(ctx context.Context, album, albumArtist string) ([]SongInfo, error) {
+	return repo.collect(func(song *SongInfo) bool {
+		return song.Album == album && (song.AlbumArtist == albumArtist ||
+			song.AlbumArtist == "" && song.Artist == albumArtist)
+	}), nil
+}
+
+func (repo *memorySongRepository) UpdateLoudness(ctx context.Context, updated *SongInfo) error {
+	return repo.change(updated.ID, func(song *SongInfo) {
+		song.Loudness = updated.Loudness
+		song.TruePeak = updated.TruePeak
+		song.TrackGain = updated.TrackGain
+		song.TrackPeak = updated.TrackPeak
+		song.IsAnalyzed = updated.IsAnalyzed
+		song.AlbumGain = updated.AlbumGain
+		song.AlbumPeak = updated.AlbumPeak
+		song.IsAlbumAnalyzed = updated.IsAlbumAnalyzed
+	})
+}
+
+func (repo *memorySongRepository) UpdateMetadata(ctx context.Context, updated *SongInfo) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	song, ok := repo.songs[updated.ID]
+	if !ok || song.DeletedAt != nil {
+		return errNotFound
+	}
+
+	song.Title = updated.Title
+	song.Artist = updated.Artist
+	song.Genre = updated.Genre
+	song.Album = updated.Album
+	song.AlbumArtist = updated.AlbumArtist
+	repo.songs[song.ID] = song
+	return nil
+}
+
+// filter - копии плейлистов вне корзины, для которых match возвращает true
+func (repo *memoryPlaylistRepository) filter(match func(playList *PlayList) bool) []PlayList {
+	var playLists []PlayList
+	for _, playList := range repo.playLists {
+		if playList.DeletedAt == nil && match(&playList) {
+			playLists = append(playLists, copyPlaylist(playList))
+		}
+	}
+
+	return playLists
+}
+
+func (repo *memoryPlaylistRepository) Insert(ctx context.Context, playList *PlayList) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.playLists[playList.ID]; ok {
+		return errDuplicate
+	}
+
+	repo.playLists[playList.ID] = copyPlaylist(*playList)
+	return nil
+}
+
+func (repo *memoryPlaylistRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*PlayList, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	playList, ok := repo.playLists[id]
+	if !ok || playList.DeletedAt != nil {
+		return nil, errNotFound
+	}
+
+	playList = copyPlaylist(playList)
+	return &playList, nil
+}
+
+func (repo *memoryPlaylistRepository) FindBySong(ctx context.Context, songID primitive.ObjectID) ([]PlayList, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var playLists []PlayList
+	for _, playList := range repo.playLists {
+		for _, id := range playList.IDs {
+			if id == songID.Hex() {
+				playLists = append(playLists, copyPlaylist(playList))
+				break
+			}
+		}
+	}
+
+	return playLists, nil
+}
+
+func (repo *memoryPlaylistRepository) List(ctx context.Context, query *PlaylistQuery) ([]PlayList, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return query.page(repo.filter(func(*PlayList) bool { return true })), nil
+}
+
+func (repo *memoryPlaylistRepository) Search(ctx context.Context, words []string) ([]PlayList, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return repo.filter(func(playList *PlayList) bool { return containsAny(playList.Name, words) }), nil
+}
+
+func (repo *memoryPlaylistRepository) Update(ctx context.Context, playList *PlayList) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.playLists[playList.ID]; !ok {
+		return errNotFound
+	}
+
+	repo.playLists[playList.ID] = copyPlaylist(*playList)
+	return nil
+}
+
+func (repo *memoryPlaylistRepository) AddSong(ctx context.Context, id primitive.ObjectID, songID primitive.ObjectID, index int) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	playList, ok := repo.playLists[id]
+	if !ok {
+		return errNotFound
+	}
+
+	if index > len(playList.IDs) {
+		index = len(playList.IDs)
+	}
+	ids := append([]string(nil), playList.IDs[:index]...)
+	ids = append(ids, songID.Hex())
+	playList.IDs = append(ids, playList.IDs[index:]...)
+	repo.playLists[id] = playList
+	return nil
+}
+
+func (repo *memoryPlaylistRepository) RemoveSong(ctx context.Context, songID primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for id, playList := range repo.playLists {
+		ids := make([]string, 0, len(playList.IDs))
+		for _, stored := range playList.IDs {
+			if stored != songID.Hex() {
+				ids = append(ids, stored)
+			}
+		}
+		playList.IDs = ids
+		repo.playLists[id] = playList
+	}
+
+	return nil
+}
+
+func (repo *memoryPlaylistRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.playLists[id]; !ok {
+		return errNotFound
+	}
+
+	delete(repo.playLists, id)
+	return nil
+}
+
+func (repo *memoryPlaylistRepository) Trash(ctx context.Context, id primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	playList, ok := repo.playLists[id]
+	if !ok || playList.DeletedAt != nil {
+		return errNotFound
+	}
+
+	now := time.Now()
+	playList.DeletedAt = &now
+	repo.playLists[id] = playList
+	return nil
+}
+
+func (repo *memoryPlaylistRepository) Restore(ctx context.Context, id primitive.ObjectID, after time.Time) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	playList, ok := repo.playLists[id]
+	if !ok || playList.DeletedAt == nil || !playList.DeletedAt.After(after) {
+		return errNotFound
+	}
+
+	playList.DeletedAt = nil
+	repo.playLists[id] = playList
+	return nil
+}
+
+func (repo *memoryPlaylistRepository) Trashed(ctx context.Context, after time.Time) ([]PlayList, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	var playLists []PlayList
+	for _, playList := range repo.playLists {
+		if playList.DeletedAt != nil && playList.DeletedAt.After(after) {
+			playLists = append(playLists, copyPlaylist(playList))
+		}
+	}
+
+	sort.Slice(playLists, func(i, j int) bool { return playLists[i].DeletedAt.After(*playLists[j].DeletedAt) })
+	return playLists, nil
+}
+
+func (repo *memoryPlaylistRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	removed := 0
+	for id, playList := range repo.playLists {
+		if playList.DeletedAt != nil && !playList.DeletedAt.After(before) {
+			delete(repo.playLists, id)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// memoryWaveformRepository - реализация WaveformRepository в памяти
+type memoryWaveformRepository struct {
+	mutex     sync.RWMutex
+	waveforms map[primitive.ObjectID]Waveform
+}
+
+// memoryFingerprintRepository - реализация FingerprintRepository в памяти
+type memoryFingerprintRepository struct {
+	mutex        sync.RWMutex
+	fingerprints map[primitive.ObjectID]Fingerprint
+}
+
+// memoryBlobRepository - реализация BlobRepository в памяти
+type memoryBlobRepository struct {
+	mutex sync.Mutex
+	blobs map[string]Blob
+}
+
+// memoryJournalRepository - реализация JournalRepository в памяти
+type memoryJournalRepository struct {
+	mutex   sync.Mutex
+	entries []JournalEntry
+}
+
+// memoryStatsRepository - реализация StatsRepository в памяти
+type memoryStatsRepository struct {
+	mutex  sync.Mutex
+	counts map[time.Time]map[primitive.ObjectID]int64 // счетчики загрузок по суткам
+}
+
+// memoryDownloadEventRepository - реализация DownloadEventRepository в памяти
+type memoryDownloadEventRepository struct {
+	mutex  sync.RWMutex
+	events []DownloadEvent
+}
+
+// memoryPlayEventRepository - реализация PlayEventRepository в памяти
+type memoryPlayEventRepository struct {
+	mutex  sync.Mutex
+	events []PlayEvent
+}
+
+// newMemoryRepositories - хранилища записей в памяти, например для тестов обработчиков
+func newMemoryRepositories() repositories {
+	return repositories{
+		songs:          newMemorySongRepository(),
+		playlists:      newMemoryPlaylistRepository(),
+		waveforms:      &memoryWaveformRepository{waveforms: make(map[primitive.ObjectID]Waveform)},
+		fingerprints:   &memoryFingerprintRepository{fingerprints: make(map[primitive.ObjectID]Fingerprint)},
+		blobs:          &memoryBlobRepository{blobs: make(map[string]Blob)},
+		journal:        &memoryJournalRepository{},
+		stats:          &memoryStatsRepository{counts: make(map[time.Time]map[primitive.ObjectID]int64)},
+		downloadEvents: &memoryDownloadEventRepository{},
+		playEvents:     &memoryPlayEventRepository{},
+	}
+}
+
+func (repo *memoryWaveformRepository) Insert(ctx context.Context, waveform *Waveform) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.waveforms[waveform.ID]; ok {
+		return errDuplicate
+	}
+
+	stored := *waveform
+	stored.Data = append([]byte(nil), waveform.Data...)
+	repo.waveforms[waveform.ID] = stored
+	return nil
+}
+
+func (repo *memoryWaveformRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*Waveform, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	waveform, ok := repo.waveforms[id]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	waveform.Data = append([]byte(nil), waveform.Data...)
+	return &waveform, nil
+}
+
+func (repo *memoryWaveformRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.waveforms[id]; !ok {
+		return errNotFound
+	}
+
+	delete(repo.waveforms, id)
+	return nil
+}
+
+func (repo *memoryFingerprintRepository) Insert(ctx context.Context, fingerprint *Fingerprint) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.fingerprints[fingerprint.ID]; ok {
+		return errDuplicate
+	}
+
+	stored := *fingerprint
+	stored.Data = append([]byte(nil), fingerprint.Data...)
+	repo.fingerprints[fingerprint.ID] = stored
+	return nil
+}
+
+func (repo *memoryFingerprintRepository) EachByDuration(ctx context.Context, min, max int, fn func(fingerprint *Fingerprint)) error {
+	repo.mutex.RLock()
+	var candidates []Fingerprint
+	for _, fingerprint := range repo.fingerprints {
+		if fingerprint.Duration >= min && fingerprint.Duration <= max {
+			candidates = append(candidates, fingerprint)
+		}
+	}
+	repo.mutex.RUnlock()
+
+	for i := range candidates {
+		fn(&candidates[i])
+	}
+
+	return nil
+}
+
+func (repo *memoryFingerprintRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.fingerprints[id]; !ok {
+		return errNotFound
+	}
+
+	delete(repo.fingerprints, id)
+	return nil
+}
+
+func (repo *memoryBlobRepository) Acquire(ctx context.Context, hash string, size int64) (bool, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	blob, ok := repo.blobs[hash]
+	if !ok {
+		blob = Blob{Hash: hash, Size: size}
+	}
+
+	blob.RefCount++
+	repo.blobs[hash] = blob
+	return !ok, nil
+}
+
+func (repo *memoryBlobRepository) Release(ctx context.Context, hash string) (bool, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	blob, ok := repo.blobs[hash]
+	if !ok {
+		return false, errNotFound
+	}
+
+	blob.RefCount--
+	if blob.RefCount > 0 {
+		repo.blobs[hash] = blob
+		return false, nil
+	}
+
+	delete(repo.blobs, hash)
+	return true, nil
+}
+
+func (repo *memoryBlobRepository) All(ctx context.Context) ([]Blob, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	var blobs []Blob
+	for _, blob := range repo.blobs {
+		blobs = append(blobs, blob)
+	}
+
+	return blobs, nil
+}
+
+func (repo *memoryBlobRepository) SetRefCount(ctx context.Context, hash string, count int, size int64) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	blob, ok := repo.blobs[hash]
+	if !ok {
+		blob = Blob{Hash: hash, Size: size}
+	}
+
+	blob.RefCount = count
+	repo.blobs[hash] = blob
+	return nil
+}
+
+func (repo *memoryBlobRepository) Delete(ctx context.Context, hash string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.blobs[hash]; !ok {
+		return errNotFound
+	}
+
+	delete(repo.blobs, hash)
+	return nil
+}
+
+func (repo *memoryJournalRepository) Insert(ctx context.Context, entry *JournalEntry) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.entries = append(repo.entries, *entry)
+	return nil
+}
+
+func (repo *memoryJournalRepository) All(ctx context.Context) ([]JournalEntry, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return append([]JournalEntry(nil), repo.entries...), nil
+}
+
+func (repo *memoryJournalRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for i := range repo.entries {
+		if repo.entries[i].ID == id {
+			repo.entries = append(repo.entries[:i], repo.entries[i+1:]...)
+			return nil
+		}
+	}
+
+	return errNotFound
+}
+
+func (repo *memoryStatsRepository) AddDownloads(ctx context.Context, ids []primitive.ObjectID, day time.Time) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	counts, ok := repo.counts[day]
+	if !ok {
+		counts = make(map[primitive.ObjectID]int64)
+		repo.counts[day] = counts
+	}
+	for _, id := range ids {
+		counts[id]++
+	}
+
+	return nil
+}
+
+func (repo *memoryStatsRepository) TopDownloads(ctx context.Context, since time.Time, count int) ([]SongDownloads, error) {
+	repo.mutex.Lock()
+	totals := make(map[primitive.ObjectID]int64)
+	for day, counts := range repo.counts {
+		if day.Before(since) {
+			continue
+		}
+		for id, n := range counts {
+			totals[id] += n
+		}
+	}
+	repo.mutex.Unlock()
+
+	downloads := make([]SongDownloads, 0, len(totals))
+	for id, n := range totals {
+		downloads = append(downloads, SongDownloads{Song: id, Count: n})
+	}
+	sort.Slice(downloads, func(i, j int) bool {
+		if downloads[i].Count != downloads[j].Count {
+			return downloads[i].Count > downloads[j].Count
+		}
+		return downloads[i].Song.Hex() < downloads[j].Song.Hex()
+	})
+
+	return downloads[:limit(count, len(downloads))], nil
+}
+
+func (repo *memoryStatsRepository) DeleteSong(ctx context.Context, id primitive.ObjectID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, counts := range repo.counts {
+		delete(counts, id)
+	}
+
+	return nil
+}
+
+func (repo *memoryDownloadEventRepository) Insert(ctx context.Context, events []DownloadEvent) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.events = append(repo.events, events...)
+	return nil
+}
+
+func (repo *memoryDownloadEventRepository) EachSince(ctx context.Context, since time.Time, fn func(event *DownloadEvent)) error {
+	repo.mutex.RLock()
+	events := append([]DownloadEvent(nil), repo.events...)
+	repo.mutex.RUnlock()
+
+	for i := range events {
+		if !events[i].Time.Before(since) {
+			fn(&events[i])
+		}
+	}
+
+	return nil
+}
+
+func (repo *memoryDownloadEventRepository) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	kept := repo.events[:0]
+	for _, event := range repo.events {
+		if !event.Time.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+
+	removed := len(repo.events) - len(kept)
+	repo.events = kept
+	return removed, nil
+}
+
+func (repo *memoryPlayEventRepository) Insert(ctx context.Context, event *PlayEvent) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.events = append(repo.events, *event)
+	return nil
+}
+
+func (repo *memoryPlayEventRepository) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	return repo.deleteWhere(func(event *PlayEvent) bool { return event.Time.Before(before) }), nil
+}
+
+func (repo *memoryPlayEventRepository) DeleteSong(ctx context.Context, id primitive.ObjectID) error {
+	repo.deleteWhere(func(event *PlayEvent) bool { return event.Song == id })
+	return nil
+}
+
+// deleteWhere - удаляет события, для которых match возвращает true, и возвращает их количество
+func (repo *memoryPlayEventRepository) deleteWhere(match func(event *PlayEvent) bool) int {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	kept := repo.events[:0]
+	for i := range repo.events {
+		if !match(&repo.events[i]) {
+			kept = append(kept, repo.events[i])
+		}
+	}
+
+	removed := len(repo.events) - len(kept)
+	repo.events = kept
+	return removed
+}
@@ -0,0 +1,849 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/STEJLS/AudioServer/XMLconfig"
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// connectToMongo - устанавливет соединение с MongoDB, применяет к ней миграции
+// и создает хранилища записей для ее коллекций.
+// Если в конфиге задана строка подключения uri, то host и port не используются.
+func connectToMongo(config XMLconfig.DataBase) (repositories, func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnectTimeout)
+	defer cancel()
+
+	uri := config.URI
+	if uri == "" {
+		uri = fmt.Sprintf("mongodb://%v:%v", config.Host, config.Port)
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err == nil {
+		// Connect не ждет сервер, поэтому недоступность БД проверяется отдельно
+		err = client.Ping(ctx, nil)
+	}
+	if err != nil {
+		i18n.Fatal("mongo.connect_error", err)
+	}
+	db := client.Database(config.Name)
+
+	// Миграции на больших коллекциях могут идти дольше тайм-аута подключения
+	err = migrateMongo(context.Background(), db)
+	if err != nil {
+		i18n.Fatal("mongo.migrate_error", err)
+	}
+
+	i18n.Info("mongo.connected")
+
+	return newMongoRepositories(db), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), dbConnectTimeout)
+		defer cancel()
+
+		err := client.Disconnect(ctx)
+		if err != nil {
+			i18n.Error("mongo.disconnect_error", err)
+		}
+	}
+}
+
+// newMongoRepositories - хранилища записей в коллекциях базы данных MongoDB
+func newMongoRepositories(db *mongo.Database) repositories {
+	return repositories{
+		songs:          newMongoSongRepository(db.Collection("Songs")),
+		playlists:      newMongoPlaylistRepository(db.Collection("Playlists")),
+		waveforms:      newMongoWaveformRepository(db.Collection("Waveforms")),
+		fingerprints:   newMongoFingerprintRepository(db.Collection("Fingerprints")),
+		blobs:          newMongoBlobRepository(db.Collection("Blobs")),
+		journal:        newMongoJournalRepository(db.Collection("Journal")),
+		stats:          newMongoStatsRepository(db.Collection("DownloadStats")),
+		downloadEvents: newMongoDownloadEventRepository(db.Collection("DownloadEvents")),
+		playEvents:     newMongoPlayEventRepository(db.Collection("PlayEvents")),
+	}
+}
+
+// mongoSongRepository - реализация SongRepository для коллекции MongoDB
+type mongoSongRepository struct {
+	coll *mongo.Collection
+}
+
+// mongoPlaylistRepository - реализация PlaylistRepository для коллекции MongoDB
+type mongoPlaylistRepository struct {
+	coll *mongo.Collection
+}
+
+// mongoWaveformRepository - реализация WaveformRepository для коллекции MongoDB
+type mongoWaveformRepository struct {
+	coll *mongo.Collection
+}
+
+// mongoFingerprintRepository - реализация FingerprintRepository для коллекции MongoDB
+type mongoFingerprintRepository struct {
+	coll *mongo.Collection
+}
+
+// mongoBlobRepository - реализация BlobRepository для коллекции MongoDB
+type mongoBlobRepository struct {
+	coll *mongo.Collection
+}
+
+// mongoJournalRepository - реализация JournalRepository для коллекции MongoDB
+type mongoJournalRepository struct {
+	coll *mongo.Collection
+}
+
+// mongoStatsRepository - реализация StatsRepository для коллекции MongoDB
+type mongoStatsRepository struct {
+	coll *mongo.Collection
+}
+
+// mongoDownloadEventRepository - реализация DownloadEventRepository для коллекции MongoDB
+type mongoDownloadEventRepository struct {
+	coll *mongo.Collection
+}
+
+// mongoPlayEventRepository - реализация PlayEventRepository для коллекции MongoDB
+type mongoPlayEventRepository struct {
+	coll *mongo.Collection
+}
+
+// newMongoSongRepository - конструктор для типа mongoSongRepository
+func newMongoSongRepository(coll *mongo.Collection) *mongoSongRepository {
+	return &mongoSongRepository{coll: coll}
+}
+
+// newMongoPlaylistRepository - конструктор для типа mongoPlaylistRepository
+func newMongoPlaylistRepository(coll *mongo.Collection) *mongoPlaylistRepository {
+	return &mongoPlaylistRepository{coll: coll}
+}
+
+// newMongoWaveformRepository - конструктор для типа mongoWaveformRepository
+func newMongoWaveformRepository(coll *mongo.Collection) *mongoWaveformRepository {
+	return &mongoWaveformRepository{coll: coll}
+}
+
+// newMongoFingerprintRepository - конструктор для типа mongoFingerprintRepository
+func newMongoFingerprintRepository(coll *mongo.Collection) *mongoFingerprintRepository {
+	return &mongoFingerprintRepository{coll: coll}
+}
+
+// newMongoBlobRepository - конструктор для типа mongoBlobRepository
+func newMongoBlobRepository(coll *mongo.Collection) *mongoBlobRepository {
+	return &mongoBlobRepository{coll: coll}
+}
+
+// newMongoJournalRepository - конструктор для типа mongoJournalRepository
+func newMongoJournalRepository(coll *mongo.Collection) *mongoJournalRepository {
+	return &mongoJournalRepository{coll: coll}
+}
+
+// newMongoStatsRepository - конструктор для типа mongoStatsRepository
+func newMongoStatsRepository(coll *mongo.Collection) *mongoStatsRepository {
+	return &mongoStatsRepository{coll: coll}
+}
+
+// newMongoDownloadEventRepository - конструктор для типа mongoDownloadEventRepository
+func newMongoDownloadEventRepository(coll *mongo.Collection) *mongoDownloadEventRepository {
+	return &mongoDownloadEventRepository{coll: coll}
+}
+
+// newMongoPlayEventRepository - конструктор для типа mongoPlayEventRepository
+func newMongoPlayEventRepository(coll *mongo.Collection) *mongoPlayEventRepository {
+	return &mongoPlayEventRepository{coll: coll}
+}
+
+// findAll - выполняет запрос к коллекции и декодирует все найденные документы в result
+func findAll(ctx context.Context, coll *mongo.Collection, filter interface{}, result interface{}, opts ...*options.FindOptions) error {
+	cursor, err := coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return err
+	}
+
+	return cursor.All(ctx, result)
+}
+
+// mongoError - переводит ошибки драйвера MongoDB в ошибки репозиториев
+func mongoError(err error) error {
+	if err == mongo.ErrNoDocuments {
+		return errNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return errDuplicate
+	}
+
+	return err
+}
+
+// updateError - ошибка обновления одной записи, errNotFound, если запись не нашлась
+func updateError(result *mongo.UpdateResult, err error) error {
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+// deleteError - ошибка удаления одной записи, errNotFound, если запись не нашлась
+func deleteError(result *mongo.DeleteResult, err error) error {
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+// sortedBy - параметры запроса с сортировкой по убыванию поля и ограничением количества,
+// count = 0 - без ограничения
+func sortedBy(field string, count int) *options.FindOptions {
+	return options.Find().SetSort(bson.D{{Key: field, Value: -1}}).SetLimit(int64(count))
+}
+
+// mongoSongSortFields - поля документов песен для полей сортировки songSortFields
+var mongoSongSortFields = map[string]string{
+	sortUploadDate: "UploadDate",
+	sortDownloads:  "CountOfDownload",
+	sortPlays:      "CountOfPlays",
+	sortTitle:      "Title",
+	sortArtist:     "Artist",
+	sortBitrate:    "Bitrate",
+	sortDuration:   "Duration",
+}
+
+// mongoPlaylistSortFields - поля документов плейлистов для полей сортировки playlistSortFields
+var mongoPlaylistSortFields = map[string]string{
+	sortCreated: "_id",
+	sortName:    "Name",
+}
+
+// pageOptions - параметры запроса страницы: сортировка по полю и по ID в том же направлении
+// и ограничение количества
+func pageOptions(field string, descending bool, count int) *options.FindOptions {
+	order := 1
+	if descending {
+		order = -1
+	}
+
+	sort := bson.D{{Key: field, Value: order}}
+	if field != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: order})
+	}
+
+	return options.Find().SetSort(sort).SetLimit(int64(count))
+}
+
+// afterCursor - дополняет условие запроса так, чтобы выбирались только записи после позиции курсора
+func afterCursor(query bson.M, field string, descending bool, after *pageCursor) bson.M {
+	if after == nil {
+		return query
+	}
+
+	operator := "$gt"
+	if descending {
+		operator = "$lt"
+	}
+
+	if field == "_id" {
+		query["_id"] = bson.M{operator: after.ID}
+		return query
+	}
+
+	query["$or"] = []bson.M{
+		bson.M{field: bson.M{operator: after.Value}},
+		bson.M{field: after.Value, "_id": bson.M{operator: after.ID}},
+	}
+	return query
+}
+
+// songFilter - условие запроса песен по фильтрам запроса страницы
+func songFilter(query *SongQuery) bson.M {
+	filter := bson.M{}
+	if query.Genre != "" {
+		filter["Genre"] = query.Genre
+	}
+	if query.Artist != "" {
+		filter["Artist"] = query.Artist
+	}
+	if condition := rangeCondition(query.MinBitrate, query.MaxBitrate); condition != nil {
+		filter["Bitrate"] = condition
+	}
+	if condition := rangeCondition(query.MinDuration, query.MaxDuration); condition != nil {
+		filter["Duration"] = condition
+	}
+
+	uploadDate := bson.M{}
+	if !query.UploadedFrom.IsZero() {
+		uploadDate["$gte"] = query.UploadedFrom
+	}
+	if !query.UploadedTo.IsZero() {
+		uploadDate["$lt"] = query.UploadedTo
+	}
+	if len(uploadDate) != 0 {
+		filter["UploadDate"] = uploadDate
+	}
+
+	return filter
+}
+
+// rangeCondition - условие, что значение от min до max включительно, 0 - без ограничения.
+// Без ограничений возвращает nil.
+func rangeCondition(min, max int) bson.M {
+	condition := bson.M{}
+	if min != 0 {
+		condition["$gte"] = min
+	}
+	if max != 0 {
+		condition["$lte"] = max
+	}
+	if len(condition) == 0 {
+		return nil
+	}
+
+	return condition
+}
+
+// searchPattern - регулярное выражение, совпадающее с любым из слов
+func searchPattern(words []string) primitive.Regex {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+
+	return primitive.Regex{Pattern: strings.Join(quoted, "|"), Options: "i"}
+}
+
+// notDeleted - дополняет условие запроса так, чтобы записи из корзины не попадали в выдачу
+func notDeleted(query bson.M) bson.M {
+	query["DeletedAt"] = bson.M{"$exists": false}
+	return query
+}
+
+// deletedAfter - условие запроса записи из корзины, удаленной позже указанного времени
+func deletedAfter(query bson.M, after time.Time) bson.M {
+	query["DeletedAt"] = bson.M{"$gt": after}
+	return query
+}
+
+func (repo *mongoSongRepository) Insert(ctx context.Context, song *SongInfo) error {
+	_, err := repo.coll.InsertOne(ctx, song)
+	return mongoError(err)
+}
+
+func (repo *mongoSongRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*SongInfo, error) {
+	var song SongInfo
+	err := repo.coll.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&song)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &song, nil
+}
+
+func (repo *mongoSongRepository) FindByIDIncludingTrash(ctx context.Context, id primitive.ObjectID) (*SongInfo, error) {
+	var song SongInfo
+	err := repo.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&song)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &song, nil
+}
+
+func (repo *mongoSongRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, notDeleted(bson.M{"_id": bson.M{"$in": ids}}), &songs)
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) FindDuplicate(ctx context.Context, song *SongInfo) (primitive.ObjectID, error) {
+	return repo.findID(ctx, bson.M{"Title": song.Title,
+		"Artist":   song.Artist,
+		"Genre":    song.Genre,
+		"Bitrate":  song.Bitrate,
+		"Duration": song.Duration,
+		"Size":     song.Size,
+	})
+}
+
+func (repo *mongoSongRepository) FindByPayloadHash(ctx context.Context, hash string) (primitive.ObjectID, error) {
+	return repo.findID(ctx, bson.M{"PayloadHash": hash})
+}
+
+// findID - ID первой песни, подходящей под условие, или пустой ID
+func (repo *mongoSongRepository) findID(ctx context.Context, query bson.M) (primitive.ObjectID, error) {
+	var result SongInfo
+	err := repo.coll.FindOne(ctx, query, options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, nil
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return result.ID, nil
+}
+
+func (repo *mongoSongRepository) List(ctx context.Context, query *SongQuery) ([]SongInfo, error) {
+	field := mongoSongSortFields[query.Sort]
+
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, afterCursor(notDeleted(songFilter(query)), field, query.Descending, query.After),
+		&songs, pageOptions(field, query.Descending, query.Limit))
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) Search(ctx context.Context, words []string) ([]SongInfo, error) {
+	pattern := searchPattern(words)
+
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, notDeleted(bson.M{"$or": []bson.M{
+		bson.M{"Artist": pattern},
+		bson.M{"Genre": pattern},
+		bson.M{"Title": pattern},
+	}}), &songs)
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) Update(ctx context.Context, song *SongInfo) error {
+	return updateError(repo.coll.ReplaceOne(ctx, bson.M{"_id": song.ID}, song))
+}
+
+func (repo *mongoSongRepository) IncrementDownloads(ctx context.Context, ids []primitive.ObjectID) error {
+	_, err := repo.coll.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$inc": bson.M{"CountOfDownload": 1}})
+	return mongoError(err)
+}
+
+func (repo *mongoSongRepository) IncrementPlays(ctx context.Context, id primitive.ObjectID) error {
+	return updateError(repo.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"CountOfPlays": 1}}))
+}
+
+func (repo *mongoSongRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteError(repo.coll.DeleteOne(ctx, bson.M{"_id": id}))
+}
+
+func (repo *mongoSongRepository) Trash(ctx context.Context, id primitive.ObjectID, positions []PlaylistPosition) error {
+	return updateError(repo.coll.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), bson.M{"$set": bson.M{
+		"DeletedAt":   time.Now(),
+		"TrashedFrom": positions,
+	}}))
+}
+
+func (repo *mongoSongRepository) Restore(ctx context.Context, id primitive.ObjectID, after time.Time) (*SongInfo, error) {
+	var song SongInfo
+	err := repo.coll.FindOneAndUpdate(ctx, deletedAfter(bson.M{"_id": id}, after),
+		bson.M{"$unset": bson.M{"DeletedAt": "", "TrashedFrom": ""}}).Decode(&song)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &song, nil
+}
+
+func (repo *mongoSongRepository) Trashed(ctx context.Context, after time.Time) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, deletedAfter(bson.M{}, after), &songs, sortedBy("DeletedAt", 0))
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) Expired(ctx context.Context, before time.Time) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, bson.M{"DeletedAt": bson.M{"$lte": before}}, &songs)
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) All(ctx context.Context) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, bson.M{}, &songs)
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) WithoutBlob(ctx context.Context) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, bson.M{"Blob": bson.M{"$exists": false}}, &songs)
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) SetBlob(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return updateError(repo.coll.UpdateByID(ctx, id, bson.M{"$set": bson.M{"Blob": hash}}))
+}
+
+func (repo *mongoSongRepository) CountByBlob(ctx context.Context, hash string) (int, error) {
+	count, err := repo.coll.CountDocuments(ctx, bson.M{"Blob": hash})
+	return int(count), mongoError(err)
+}
+
+func (repo *mongoSongRepository) WithoutPayloadHash(ctx context.Context) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, bson.M{
+		"PayloadHash": bson.M{"$exists": false},
+		"DuplicateOf": bson.M{"$exists": false},
+	}, &songs)
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) SetPayloadHash(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return updateError(repo.coll.UpdateByID(ctx, id, bson.M{"$set": bson.M{"PayloadHash": hash}}))
+}
+
+func (repo *mongoSongRepository) SetDuplicateOf(ctx context.Context, id primitive.ObjectID, original primitive.ObjectID) error {
+	return updateError(repo.coll.UpdateByID(ctx, id, bson.M{"$set": bson.M{"DuplicateOf": original}}))
+}
+
+func (repo *mongoSongRepository) DuplicateGroups(ctx context.Context) ([]duplicateGroup, error) {
+	cursor, err := repo.coll.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"DuplicateOf": bson.M{"$exists": true}}},
+		{"$group": bson.M{"_id": "$DuplicateOf", "Duplicates": bson.M{"$push": "$_id"}}},
+		{"$sort": bson.M{"_id": 1}},
+	})
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var groups []duplicateGroup
+	err = cursor.All(ctx, &groups)
+	return groups, mongoError(err)
+}
+
+func (repo *mongoSongRepository) NotAlbumAnalyzed(ctx context.Context) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, bson.M{"IsAlbumAnalyzed": bson.M{"$ne": true}}, &songs)
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) FindByAlbum(ctx context.Context, album, albumArtist string) ([]SongInfo, error) {
+	var songs []SongInfo
+	err := findAll(ctx, repo.coll, bson.M{"Album": album, "$or": []bson.M{
+		bson.M{"AlbumArtist": albumArtist},
+		bson.M{"AlbumArtist": "", "Artist": albumArtist},
+	}}, &songs)
+	return songs, mongoError(err)
+}
+
+func (repo *mongoSongRepository) UpdateLoudness(ctx context.Context, song *SongInfo) error {
+	return updateError(repo.coll.UpdateByID(ctx, song.ID, bson.M{"$set": bson.M{
+		"Loudness":        song.Loudness,
+		"TruePeak":        song.TruePeak,
+		"TrackGain":       song.TrackGain,
+		"TrackPeak":       song.TrackPeak,
+		"IsAnalyzed":      song.IsAnalyzed,
+		"AlbumGain":       song.AlbumGain,
+		"AlbumPeak":       song.AlbumPeak,
+		"IsAlbumAnalyzed": song.IsAlbumAnalyzed,
+	}}))
+}
+
+func (repo *mongoSongRepository) UpdateMetadata(ctx context.Context, song *SongInfo) error {
+	return updateError(repo.coll.UpdateOne(ctx, notDeleted(bson.M{"_id": song.ID}), bson.M{"$set": bson.M{
+		"Title":       song.Title,
+		"Artist":      song.Artist,
+		"Genre":       song.Genre,
+		"Album":       song.Album,
+		"AlbumArtist": song.AlbumArtist,
+	}}))
+}
+
+func (repo *mongoPlaylistRepository) Insert(ctx context.Context, playList *PlayList) error {
+	_, err := repo.coll.InsertOne(ctx, playList)
+	return mongoError(err)
+}
+
+func (repo *mongoPlaylistRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*PlayList, error) {
+	var playList PlayList
+	err := repo.coll.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&playList)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &playList, nil
+}
+
+func (repo *mongoPlaylistRepository) FindBySong(ctx context.Context, songID primitive.ObjectID) ([]PlayList, error) {
+	var playLists []PlayList
+	err := findAll(ctx, repo.coll, bson.M{"IDs": songID.Hex()}, &playLists)
+	return playLists, mongoError(err)
+}
+
+func (repo *mongoPlaylistRepository) List(ctx context.Context, query *PlaylistQuery) ([]PlayList, error) {
+	field := mongoPlaylistSortFields[query.Sort]
+
+	var playLists []PlayList
+	err := findAll(ctx, repo.coll, afterCursor(notDeleted(bson.M{}), field, query.Descending, query.After),
+		&playLists, pageOptions(field, query.Descending, query.Limit))
+	return playLists, mongoError(err)
+}
+
+func (repo *mongoPlaylistRepository) Search(ctx context.Context, words []string) ([]PlayList, error) {
+	var playLists []PlayList
+	err := findAll(ctx, repo.coll, notDeleted(bson.M{"Name": searchPattern(words)}), &playLists)
+	return playLists, mongoError(err)
+}
+
+func (repo *mongoPlaylistRepository) Update(ctx context.Context, playList *PlayList) error {
+	return updateError(repo.coll.ReplaceOne(ctx, bson.M{"_id": playList.ID}, playList))
+}
+
+func (repo *mongoPlaylistRepository) AddSong(ctx context.Context, id primitive.ObjectID, songID primitive.ObjectID, index int) error {
+	return updateError(repo.coll.UpdateByID(ctx, id, bson.M{"$push": bson.M{"IDs": bson.M{
+		"$each":     []string{songID.Hex()},
+		"$position": index,
+	}}}))
+}
+
+func (repo *mongoPlaylistRepository) RemoveSong(ctx context.Context, songID primitive.ObjectID) error {
+	_, err := repo.coll.UpdateMany(ctx, bson.M{"IDs": songID.Hex()}, bson.M{"$pull": bson.M{"IDs": songID.Hex()}})
+	return mongoError(err)
+}
+
+func (repo *mongoPlaylistRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteError(repo.coll.DeleteOne(ctx, bson.M{"_id": id}))
+}
+
+func (repo *mongoPlaylistRepository) Trash(ctx context.Context, id primitive.ObjectID) error {
+	return updateError(repo.coll.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), bson.M{"$set": bson.M{"DeletedAt": time.Now()}}))
+}
+
+func (repo *mongoPlaylistRepository) Restore(ctx context.Context, id primitive.ObjectID, after time.Time) error {
+	return updateError(repo.coll.UpdateOne(ctx, deletedAfter(bson.M{"_id": id}, after), bson.M{"$unset": bson.M{"DeletedAt": ""}}))
+}
+
+func (repo *mongoPlaylistRepository) Trashed(ctx context.Context, after time.Time) ([]PlayList, error) {
+	var playLists []PlayList
+	err := findAll(ctx, repo.coll, deletedAfter(bson.M{}, after), &playLists, sortedBy("DeletedAt", 0))
+	return playLists, mongoError(err)
+}
+
+func (repo *mongoPlaylistRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	result, err := repo.coll.DeleteMany(ctx, bson.M{"DeletedAt": bson.M{"$lte": before}})
+	if err != nil {
+		return 0, mongoError(err)
+	}
+
+	return int(result.DeletedCount), nil
+}
+
+func (repo *mongoWaveformRepository) Insert(ctx context.Context, waveform *Waveform) error {
+	_, err := repo.coll.InsertOne(ctx, waveform)
+	return mongoError(err)
+}
+
+func (repo *mongoWaveformRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*Waveform, error) {
+	var waveform Waveform
+	err := repo.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&waveform)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return &waveform, nil
+}
+
+func (repo *mongoWaveformRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteError(repo.coll.DeleteOne(ctx, bson.M{"_id": id}))
+}
+
+func (repo *mongoFingerprintRepository) Insert(ctx context.Context, fingerprint *Fingerprint) error {
+	_, err := repo.coll.InsertOne(ctx, fingerprint)
+	return mongoError(err)
+}
+
+func (repo *mongoFingerprintRepository) EachByDuration(ctx context.Context, min, max int, fn func(fingerprint *Fingerprint)) error {
+	cursor, err := repo.coll.Find(ctx, bson.M{"Duration": bson.M{"$gte": min, "$lte": max}})
+	if err != nil {
+		return mongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var fingerprint Fingerprint
+		err = cursor.Decode(&fingerprint)
+		if err != nil {
+			return err
+		}
+		fn(&fingerprint)
+	}
+
+	return cursor.Err()
+}
+
+func (repo *mongoFingerprintRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteError(repo.coll.DeleteOne(ctx, bson.M{"_id": id}))
+}
+
+func (repo *mongoBlobRepository) Acquire(ctx context.Context, hash string, size int64) (bool, error) {
+	result, err := repo.coll.UpdateByID(ctx, hash, bson.M{
+		"$inc":         bson.M{"RefCount": 1},
+		"$setOnInsert": bson.M{"Size": size},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, mongoError(err)
+	}
+
+	return result.UpsertedID != nil, nil
+}
+
+func (repo *mongoBlobRepository) Release(ctx context.Context, hash string) (bool, error) {
+	err := updateError(repo.coll.UpdateByID(ctx, hash, bson.M{"$inc": bson.M{"RefCount": -1}}))
+	if err != nil {
+		return false, err
+	}
+
+	// Запись удаляется, только если за это время на блоб не появилось новых ссылок
+	err = repo.coll.FindOneAndDelete(ctx, bson.M{"_id": hash, "RefCount": bson.M{"$lte": 0}}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (repo *mongoBlobRepository) All(ctx context.Context) ([]Blob, error) {
+	var blobs []Blob
+	err := findAll(ctx, repo.coll, bson.M{}, &blobs)
+	return blobs, mongoError(err)
+}
+
+func (repo *mongoBlobRepository) SetRefCount(ctx context.Context, hash string, count int, size int64) error {
+	_, err := repo.coll.UpdateByID(ctx, hash, bson.M{
+		"$set":         bson.M{"RefCount": count},
+		"$setOnInsert": bson.M{"Size": size},
+	}, options.Update().SetUpsert(true))
+	return mongoError(err)
+}
+
+func (repo *mongoBlobRepository) Delete(ctx context.Context, hash string) error {
+	return deleteError(repo.coll.DeleteOne(ctx, bson.M{"_id": hash}))
+}
+
+func (repo *mongoJournalRepository) Insert(ctx context.Context, entry *JournalEntry) error {
+	_, err := repo.coll.InsertOne(ctx, entry)
+	return mongoError(err)
+}
+
+func (repo *mongoJournalRepository) All(ctx context.Context) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	err := findAll(ctx, repo.coll, bson.M{}, &entries, options.Find().SetSort(bson.M{"_id": 1}))
+	return entries, mongoError(err)
+}
+
+func (repo *mongoJournalRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteError(repo.coll.DeleteOne(ctx, bson.M{"_id": id}))
+}
+
+func (repo *mongoStatsRepository) AddDownloads(ctx context.Context, ids []primitive.ObjectID, day time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, len(ids))
+	for i, id := range ids {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"Song": id, "Day": day}).
+			SetUpdate(bson.M{"$inc": bson.M{"Count": 1}}).
+			SetUpsert(true)
+	}
+
+	_, err := repo.coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return mongoError(err)
+}
+
+func (repo *mongoStatsRepository) TopDownloads(ctx context.Context, since time.Time, count int) ([]SongDownloads, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"Day": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$Song", "Count": bson.M{"$sum": "$Count"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "Count", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	if count > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: count}})
+	}
+
+	cursor, err := repo.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var downloads []SongDownloads
+	err = cursor.All(ctx, &downloads)
+	return downloads, mongoError(err)
+}
+
+func (repo *mongoStatsRepository) DeleteSong(ctx context.Context, id primitive.ObjectID) error {
+	_, err := repo.coll.DeleteMany(ctx, bson.M{"Song": id})
+	return mongoError(err)
+}
+
+func (repo *mongoDownloadEventRepository) Insert(ctx context.Context, events []DownloadEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(events))
+	for i := range events {
+		documents[i] = &events[i]
+	}
+
+	_, err := repo.coll.InsertMany(ctx, documents)
+	return mongoError(err)
+}
+
+func (repo *mongoDownloadEventRepository) EachSince(ctx context.Context, since time.Time, fn func(event *DownloadEvent)) error {
+	cursor, err := repo.coll.Find(ctx, bson.M{"Time": bson.M{"$gte": since}})
+	if err != nil {
+		return mongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var event DownloadEvent
+		err = cursor.Decode(&event)
+		if err != nil {
+			return err
+		}
+		fn(&event)
+	}
+
+	return cursor.Err()
+}
+
+func (repo *mongoDownloadEventRepository) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	result, err := repo.coll.DeleteMany(ctx, bson.M{"Time": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, mongoError(err)
+	}
+
+	return int(result.DeletedCount), nil
+}
+
+func (repo *mongoPlayEventRepository) Insert(ctx context.Context, event *PlayEvent) error {
+	_, err := repo.coll.InsertOne(ctx, event)
+	return mongoError(err)
+}
+
+func (repo *mongoPlayEventRepository) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	result, err := repo.coll.DeleteMany(ctx, bson.M{"Time": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, mongoError(err)
+	}
+
+	return int(result.DeletedCount), nil
+}
+
+func (repo *mongoPlayEventRepository) DeleteSong(ctx context.Context, id primitive.ObjectID) error {
+	_, err := repo.coll.DeleteMany(ctx, bson.M{"Song": id})
+	return mongoError(err)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoMigration - изменение схемы MongoDB: создание индексов или преобразование документов.
+// В MongoDB нет транзакций для изменения схемы, поэтому миграция должна быть идемпотентной:
+// если сервер остановится до записи о ней, то при следующем запуске она выполнится еще раз.
+type mongoMigration struct {
+	Description string
+	Apply       func(ctx context.Context, db *mongo.Database) error
+}
+
+// migrationRecord - запись о примененной миграции в коллекции _migrations
+type migrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"Description"`
+	AppliedAt   time.Time `bson:"AppliedAt"`
+}
+
+// mongoMigrations - миграции MongoDB по версиям: версия миграции - это ее номер в срезе, начиная с 1.
+// Примененные миграции не изменяются, новые добавляются в конец.
+var mongoMigrations = []mongoMigration{
+	{"индексы песен, плейлистов и отпечатков", createIndexes},
+	{"значения по умолчанию для полей, добавленных в SongInfo", fillSongDefaults},
+	{"индексы посуточной статистики загрузок", createStatsIndexes},
+	{"индекс событий загрузок", createDownloadEventsIndex},
+	{"количество прослушиваний песен и индексы событий прослушиваний", createPlays},
+	{"индексы для постраничной отдачи списков", createPageIndexes},
+	{"неуникальный индекс метаданных песен", relaxMetadataIndex},
+}
+
+// migrateMongo - применяет миграции, которых еще нет в коллекции _migrations
+func migrateMongo(ctx context.Context, db *mongo.Database) error {
+	migrations := db.Collection("_migrations")
+
+	var last migrationRecord
+	err := migrations.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"_id": -1})).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	for version := last.Version + 1; version <= len(mongoMigrations); version++ {
+		migration := mongoMigrations[version-1]
+		err = migration.Apply(ctx, db)
+		if err != nil {
+			return fmt.Errorf("миграция %v (%v): %v", version, migration.Description, err)
+		}
+
+		_, err = migrations.InsertOne(ctx, migrationRecord{
+			Version:     version,
+			Description: migration.Description,
+			AppliedAt:   time.Now().UTC(),
+		})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			// Запись о миграции уже есть, если ее параллельно применил другой экземпляр сервера
+			return err
+		}
+
+		i18n.Info("mongo.migration_applied", version, migration.Description)
+	}
+
+	return nil
+}
+
+// createIndexes - создает индексы для сортировок и фильтров запросов к коллекциям.
+// Уже существующие индексы с теми же ключами и параметрами не изменяются.
+func createIndexes(ctx context.Context, db *mongo.Database) error {
+	// Песни без хэша (добавленные до его появления) в индекс не попадают
+	_, err := db.Collection("Songs").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "PayloadHash", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "CountOfDownload", Value: -1}}},
+		{Keys: bson.D{{Key: "UploadDate", Value: -1}}},
+		{Keys: bson.D{{Key: "DeletedAt", Value: 1}}},
+		{Keys: bson.D{{Key: "Blob", Value: 1}}},
+		{Keys: bson.D{{Key: "DuplicateOf", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "Album", Value: 1}, {Key: "AlbumArtist", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// По этим полям CheckExistMetaInDB ищет дубликаты. Уникальный индекс не дает
+	// добавить дубликат и параллельным запросам, которые оба прошли проверку.
+	_, err = db.Collection("Songs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "Title", Value: 1},
+			{Key: "Artist", Value: 1},
+			{Key: "Genre", Value: 1},
+			{Key: "Bitrate", Value: 1},
+			{Key: "Duration", Value: 1},
+			{Key: "Size", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetName("Metadata"),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("в коллекции Songs есть песни с одинаковыми метаданными, удалите лишние: %v", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("Playlists").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "IDs", Value: 1}}},
+		{Keys: bson.D{{Key: "DeletedAt", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("Fingerprints").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "Duration", Value: 1}},
+	})
+	return err
+}
+
+// metadataIndex - индекс полей, по которым CheckExistMetaInDB ищет дубликаты песен без отпечатка.
+// Индекс не уникален: у разных по отпечатку песен метаданные могут совпадать, а точные копии
+// отсекает уникальный индекс PayloadHash.
+var metadataIndex = mongo.IndexModel{
+	Keys: bson.D{
+		{Key: "Title", Value: 1},
+		{Key: "Artist", Value: 1},
+		{Key: "Genre", Value: 1},
+		{Key: "Bitrate", Value: 1},
+		{Key: "Duration", Value: 1},
+		{Key: "Size", Value: 1},
+	},
+	Options: options.Index().SetName("Metadata"),
+}
+
+// relaxMetadataIndex - заменяет уникальный индекс метаданных, созданный миграцией createIndexes,
+// на неуникальный metadataIndex
+func relaxMetadataIndex(ctx context.Context, db *mongo.Database) error {
+	indexes := db.Collection("Songs").Indexes()
+	_, err := indexes.DropOne(ctx, "Metadata")
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && (commandErr.Name == "IndexNotFound" || commandErr.Name == "NamespaceNotFound") {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = indexes.CreateOne(ctx, metadataIndex)
+	return err
+}
+
+// fillSongDefaults - записывает значения по умолчанию в поля, которых нет у песен,
+// добавленных до их появления в SongInfo. Иначе такие песни не находятся запросами
+// на равенство, например поиском песен альбома по пустому AlbumArtist.
+func fillSongDefaults(ctx context.Context, db *mongo.Database) error {
+	defaults := bson.D{
+		{Key: "Album", Value: ""},
+		{Key: "AlbumArtist", Value: ""},
+		{Key: "Loudness", Value: 0.0},
+		{Key: "TruePeak", Value: 0.0},
+		{Key: "TrackGain", Value: 0.0},
+		{Key: "TrackPeak", Value: 0.0},
+		{Key: "IsAnalyzed", Value: false},
+		{Key: "AlbumGain", Value: 0.0},
+		{Key: "AlbumPeak", Value: 0.0},
+		{Key: "IsAlbumAnalyzed", Value: false},
+	}
+
+	songs := db.Collection("Songs")
+	for _, field := range defaults {
+		_, err := songs.UpdateMany(ctx,
+			bson.M{field.Key: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{field.Key: field.Value}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createStatsIndexes - создает индексы коллекции посуточных счетчиков загрузок.
+// Счетчик песни за сутки один, поэтому параллельные загрузки увеличивают один документ.
+func createStatsIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("DownloadStats").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "Song", Value: 1}, {Key: "Day", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "Day", Value: 1}}},
+	})
+	return err
+}
+
+// createDownloadEventsIndex - создает индекс по времени событий загрузок:
+// по нему выбираются события окон чартов и удаляются устаревшие
+func createDownloadEventsIndex(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("DownloadEvents").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "Time", Value: 1}},
+	})
+	return err
+}
+
+// createPlays - записывает нулевое количество прослушиваний песням, добавленным до его появления,
+// и создает индексы для сортировки по прослушиваниям и удаления событий прослушиваний
+func createPlays(ctx context.Context, db *mongo.Database) error {
+	songs := db.Collection("Songs")
+	_, err := songs.UpdateMany(ctx,
+		bson.M{"CountOfPlays": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"CountOfPlays": int64(0)}})
+	if err != nil {
+		return err
+	}
+
+	_, err = songs.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "CountOfPlays", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("PlayEvents").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "Time", Value: 1}}},
+		{Keys: bson.D{{Key: "Song", Value: 1}}},
+	})
+	return err
+}
+
+// createPageIndexes - создает индексы для постраничной отдачи списков: по каждому полю сортировки
+// вместе с ID (записи с равными значениями поля упорядочиваются по ID) и по полям фильтров
+func createPageIndexes(ctx context.Context, db *mongo.Database) error {
+	var songIndexes []mongo.IndexModel
+	for _, field := range []string{"UploadDate", "CountOfDownload", "CountOfPlays", "Title", "Artist", "Bitrate", "Duration"} {
+		songIndexes = append(songIndexes, mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}, {Key: "_id", Value: 1}}})
+	}
+	songIndexes = append(songIndexes, mongo.IndexModel{Keys: bson.D{{Key: "Genre", Value: 1}}})
+
+	_, err := db.Collection("Songs").Indexes().CreateMany(ctx, songIndexes)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("Playlists").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "Name", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	return err
+}
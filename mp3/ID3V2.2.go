@@ -20,6 +20,12 @@ func getV22Tags(readSeeker io.ReadSeeker, file *MP3meta) {
 		case "TCO":
 			readTextFrame(&file.Genre, header.size, readSeeker)
 			break
+		case "TAL":
+			readTextFrame(&file.Album, header.size, readSeeker)
+			break
+		case "TP2":
+			readTextFrame(&file.AlbumArtist, header.size, readSeeker)
+			break
 		default:
 			//Пропускаем ненужные фреймы
 			readSeeker.Seek(int64(header.size), os.SEEK_CUR)
@@ -21,6 +21,12 @@ func getV23_24Tags(readSeeker io.ReadSeeker, file *MP3meta, version byte) {
 		case "TCON":
 			readTextFrame(&file.Genre, header.size, readSeeker)
 			break
+		case "TALB":
+			readTextFrame(&file.Album, header.size, readSeeker)
+			break
+		case "TPE2":
+			readTextFrame(&file.AlbumArtist, header.size, readSeeker)
+			break
 		default:
 			//Пропускаем ненужные фреймы
 			readSeeker.Seek(int64(header.size), os.SEEK_CUR)
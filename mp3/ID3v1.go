@@ -5,11 +5,11 @@ package mp3
 import (
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/STEJLS/AudioServer/i18n"
 	"golang.org/x/text/encoding/charmap"
 )
 
@@ -45,22 +45,22 @@ func (t *id3v1Tag) convertToUnicode() {
 	var err error
 	t.title, err = decoder.String(t.title)
 	if err != nil {
-		log.Println(err.Error())
+		i18n.Error("mp3.id3v1_decode_error", err)
 	}
 
 	t.artist, err = decoder.String(t.artist)
 	if err != nil {
-		log.Println(err.Error())
+		i18n.Error("mp3.id3v1_decode_error", err)
 	}
 
 	t.album, err = decoder.String(t.album)
 	if err != nil {
-		log.Println(err.Error())
+		i18n.Error("mp3.id3v1_decode_error", err)
 	}
 
 	t.comment, err = decoder.String(t.comment)
 	if err != nil {
-		log.Println(err.Error())
+		i18n.Error("mp3.id3v1_decode_error", err)
 	}
 }
 
@@ -76,15 +76,15 @@ func getID3v1Tags(readSeeker io.ReadSeeker, file *MP3meta) {
 
 	//Проверяем действительно ли это ID3v1
 	if n != id3v1Tagsize {
-		log.Printf("Считано %v, а размер заголовка idv3v1 %v\n", n, id3v1Tagsize)
+		i18n.Info("mp3.id3v1_short_tag", n, id3v1Tagsize)
 		return
 	}
 	if err != nil {
-		log.Println("Чтение ID3v1 тэга: " + err.Error())
+		i18n.Error("mp3.id3v1_read_error", err)
 		return
 	}
 	if string(data[:3]) != "TAG" {
-		log.Println("Это не ID3v1: найдено '" + string(data[:3]) + "' , а должно быть TAG")
+		i18n.Info("mp3.id3v1_not_found", string(data[:3]))
 		return
 	}
 
@@ -94,7 +94,7 @@ func getID3v1Tags(readSeeker io.ReadSeeker, file *MP3meta) {
 	if data[96] > 48 && data[96] < 57 {
 		year, err = strconv.Atoi(string(data[93:97]))
 		if err != nil {
-			log.Println(err.Error())
+			i18n.Error("mp3.id3v1_year_error", err)
 		}
 	} else {
 		year = 0
@@ -134,5 +134,6 @@ func getID3v1Tags(readSeeker io.ReadSeeker, file *MP3meta) {
 	file.Title = t.title
 	file.Artist = t.artist
 	file.Genre = t.genre
+	file.Album = t.album
 	file.idv3v1tag = true
 }
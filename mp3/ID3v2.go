@@ -2,10 +2,10 @@ package mp3
 
 import (
 	"io"
-	"log"
 	"os"
 	"strings"
 
+	"github.com/STEJLS/AudioServer/i18n"
 	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/encoding/unicode"
 )
@@ -44,10 +44,10 @@ func getID3v2Tags(readSeeker io.ReadSeeker, file *MP3meta) {
 	n, err := readSeeker.Read(data)
 
 	if err != nil {
-		log.Println(err.Error())
+		i18n.Error("mp3.id3v2_read_error", err)
 	}
 	if n != idv3v2HeaderSize {
-		log.Printf("Считано %v, а размер заголовка idv3v2 %v\n", n, idv3v2HeaderSize)
+		i18n.Error("mp3.id3v2_short_header", n, idv3v2HeaderSize)
 		return
 	}
 
@@ -77,7 +77,7 @@ func getID3v2Tags(readSeeker io.ReadSeeker, file *MP3meta) {
 
 		_, err := readSeeker.Seek(int64(file.idv3v2size), os.SEEK_SET)
 		if err != nil {
-			log.Println("При попытке переместиться конец текущего ID3V2 заголовока: " + err.Error())
+			i18n.Error("mp3.id3v2_seek_end_error", err)
 		}
 
 		offset := searchOffsetForNextID3v2Header(readSeeker, header.Size)
@@ -87,7 +87,7 @@ func getID3v2Tags(readSeeker io.ReadSeeker, file *MP3meta) {
 
 		_, err = readSeeker.Seek(int64(file.idv3v2size+offset), os.SEEK_SET)
 		if err != nil {
-			log.Println("При попытке переместиться на следующий ID3V2 заголовок: " + err.Error())
+			i18n.Error("mp3.id3v2_seek_next_error", err)
 		}
 
 		readSeeker.Read(data)
@@ -100,11 +100,11 @@ func searchOffsetForNextID3v2Header(readSeeker io.ReadSeeker, distance int32) in
 
 	n, err := readSeeker.Read(data)
 	if err != nil {
-		log.Println("При поиске следующего заголовка ID3v2: " + err.Error())
+		i18n.Error("mp3.id3v2_search_error", err)
 		return -1
 	}
 	if n != int(distance) {
-		log.Printf("Ищем второй и тд ID3v2 тэг. Считано %v, а размер заголовка idv3v2 %v\n", n, idv3v2HeaderSize)
+		i18n.Error("mp3.id3v2_next_short_header", n, idv3v2HeaderSize)
 		return -1
 	}
 
@@ -175,7 +175,7 @@ func readTextFrame(destination *string, size int32, readSeeker io.ReadSeeker) {
 	var err error
 	_, err = readSeeker.Read(data)
 	if err != nil {
-		log.Println(err)
+		i18n.Error("mp3.text_frame_read_error", err)
 	}
 
 	var s string
@@ -198,7 +198,7 @@ func readTextFrame(destination *string, size int32, readSeeker io.ReadSeeker) {
 	}
 
 	if err != nil {
-		log.Println(err)
+		i18n.Error("mp3.text_frame_decode_error", err)
 	}
 
 	s = string(data)
@@ -0,0 +1,137 @@
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+var errUnsupportedTag = errors.New("Запись в ID3v2 тэг данной версии не поддерживается")
+
+// WriteTags - записывает в файл fileName пользовательские текстовые фреймы TXXX
+// (описание - ключ tags, текст - значение), заменяя фреймы TXXX с теми же описаниями.
+// Поддерживаются тэги ID3v2.3 и ID3v2.4 без расширенного заголовка и unsynchronisation,
+// если тэга нет, то создается тэг ID3v2.3. Файл перезаписывается атомарно через временный файл.
+func WriteTags(fileName string, tags map[string]string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	version := byte(3)
+	var frames []byte
+	audio := data
+
+	if isID3V2header(data) {
+		if len(data) < idv3v2HeaderSize {
+			return errUnsupportedTag
+		}
+		header := parseID3v2Header(data)
+		if header.Version != 3 && header.Version != 4 || data[5] != 0 {
+			return errUnsupportedTag
+		}
+
+		end := idv3v2HeaderSize + int(header.Size)
+		if end > len(data) {
+			return errUnsupportedTag
+		}
+		version = header.Version
+		frames = filterTXXXFrames(data[idv3v2HeaderSize:end], version, tags)
+		audio = data[end:]
+	}
+
+	descriptions := make([]string, 0, len(tags))
+	for description := range tags {
+		descriptions = append(descriptions, description)
+	}
+	sort.Strings(descriptions)
+	for _, description := range descriptions {
+		frames = append(frames, makeTXXXFrame(description, tags[description], version)...)
+	}
+
+	result := new(bytes.Buffer)
+	result.WriteString("ID3")
+	result.Write([]byte{version, 0, 0})
+	result.Write(encodeSyncsafe(len(frames)))
+	result.Write(frames)
+	result.Write(audio)
+
+	tmpName := fileName + ".tmp"
+	err = ioutil.WriteFile(tmpName, result.Bytes(), 0666)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, fileName)
+}
+
+// filterTXXXFrames - возвращает фреймы тэга без фреймов TXXX, описания которых есть в tags.
+// Отступ (нулевые байты в конце тэга) отбрасывается.
+func filterTXXXFrames(data []byte, version byte, tags map[string]string) []byte {
+	var result []byte
+	pointer := 0
+	for pointer+frameV23V24HeaderSize <= len(data) && data[pointer] != 0 {
+		var size int
+		if version == 4 {
+			size = int(calculateTagSize(data[pointer+4 : pointer+8]))
+		} else {
+			size = int(convertByteToInt(data[pointer+4 : pointer+8]))
+		}
+
+		end := pointer + frameV23V24HeaderSize + size
+		if size < 0 || end > len(data) {
+			break
+		}
+
+		frame := data[pointer:end]
+		pointer = end
+
+		if string(frame[:4]) == "TXXX" {
+			if _, ok := tags[txxxDescription(frame[frameV23V24HeaderSize:])]; ok {
+				continue
+			}
+		}
+		result = append(result, frame...)
+	}
+
+	return result
+}
+
+// txxxDescription - возвращает описание фрейма TXXX, записанного в ISO-8859-1 или UTF-8.
+// Для описаний в UTF-16 возвращается пустая строка.
+func txxxDescription(body []byte) string {
+	if len(body) == 0 || body[0] != 0 && body[0] != 3 {
+		return ""
+	}
+
+	end := bytes.IndexByte(body[1:], 0)
+	if end == -1 {
+		return string(body[1:])
+	}
+
+	return string(body[1 : end+1])
+}
+
+// makeTXXXFrame - создает фрейм TXXX в кодировке ISO-8859-1
+func makeTXXXFrame(description, value string, version byte) []byte {
+	body := append([]byte{0}, description...)
+	body = append(body, 0)
+	body = append(body, value...)
+
+	frame := []byte("TXXX")
+	if version == 4 {
+		frame = append(frame, encodeSyncsafe(len(body))...)
+	} else {
+		frame = append(frame, byte(len(body)>>24), byte(len(body)>>16), byte(len(body)>>8), byte(len(body)))
+	}
+	frame = append(frame, 0, 0) // флаги
+
+	return append(frame, body...)
+}
+
+// encodeSyncsafe - кодирует размер в 4 байта по 7 бит, как в заголовке ID3v2
+func encodeSyncsafe(size int) []byte {
+	return []byte{byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+}
@@ -0,0 +1,66 @@
+package mp3
+
+// bitReader - читает из среза байт последовательности бит (старший бит первым),
+// как того требует формат MPEG аудио.
+type bitReader struct {
+	data []byte // данные
+	pos  int    // текущая позиция в битах
+}
+
+// newBitReader - конструктор для типа bitReader
+func newBitReader(data []byte) *bitReader {
+	return &bitReader{data: data}
+}
+
+// readBit - читает один бит. За концом данных возвращает нули.
+func (br *bitReader) readBit() uint32 {
+	idx, shift := br.pos>>3, 7-uint(br.pos&7)
+	br.pos++
+	if idx >= len(br.data) {
+		return 0
+	}
+
+	return uint32(br.data[idx]>>shift) & 1
+}
+
+// readBits - читает n (не больше 32) бит и возвращает их как беззнаковое число
+func (br *bitReader) readBits(n int) uint32 {
+	var result uint32
+	for n > 0 {
+		idx := br.pos >> 3
+		if idx >= len(br.data) {
+			result <<= uint(n)
+			br.pos += n
+			return result
+		}
+
+		offset := br.pos & 7
+		available := 8 - offset
+		take := available
+		if take > n {
+			take = n
+		}
+
+		chunk := uint32(br.data[idx]>>uint(available-take)) & (1<<uint(take) - 1)
+		result = result<<uint(take) | chunk
+		br.pos += take
+		n -= take
+	}
+
+	return result
+}
+
+// skip - пропускает n бит
+func (br *bitReader) skip(n int) {
+	br.pos += n
+}
+
+// bitPos - возвращает текущую позицию в битах
+func (br *bitReader) bitPos() int {
+	return br.pos
+}
+
+// seekBit - устанавливает текущую позицию в битах
+func (br *bitReader) seekBit(pos int) {
+	br.pos = pos
+}
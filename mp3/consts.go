@@ -113,3 +113,76 @@ var (
 		layer1:        4,
 	}
 )
+
+// scaleFactorBand - границы полос масштабных коэффициентов Layer III
+// для длинных (576 линий) и коротких (192 линии на окно) блоков
+type scaleFactorBand struct {
+	long  [23]int
+	short [14]int
+}
+
+var (
+	// scaleFactorBands - границы полос масштабных коэффициентов в зависимости от частоты дискретизации
+	// (ISO/IEC 11172-3, таблица B.8 и ISO/IEC 13818-3, таблица B.2)
+	scaleFactorBands = map[int]scaleFactorBand{
+		44100: {
+			long:  [23]int{0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 52, 62, 74, 90, 110, 134, 162, 196, 238, 288, 342, 418, 576},
+			short: [14]int{0, 4, 8, 12, 16, 22, 30, 40, 52, 66, 84, 106, 136, 192},
+		},
+		48000: {
+			long:  [23]int{0, 4, 8, 12, 16, 20, 24, 30, 36, 42, 50, 60, 72, 88, 106, 128, 156, 190, 230, 276, 330, 384, 576},
+			short: [14]int{0, 4, 8, 12, 16, 22, 28, 38, 50, 64, 80, 100, 126, 192},
+		},
+		32000: {
+			long:  [23]int{0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 54, 66, 82, 102, 126, 156, 194, 240, 296, 364, 448, 550, 576},
+			short: [14]int{0, 4, 8, 12, 16, 22, 30, 42, 58, 78, 104, 138, 180, 192},
+		},
+		22050: {
+			long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+			short: [14]int{0, 4, 8, 12, 18, 24, 32, 42, 56, 74, 100, 132, 174, 192},
+		},
+		24000: {
+			long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 114, 136, 162, 194, 232, 278, 332, 394, 464, 540, 576},
+			short: [14]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 136, 180, 192},
+		},
+		16000: {
+			long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+			short: [14]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+		},
+		11025: {
+			long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+			short: [14]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+		},
+		12000: {
+			long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+			short: [14]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+		},
+		8000: {
+			long:  [23]int{0, 12, 24, 36, 48, 60, 72, 88, 108, 132, 160, 192, 232, 280, 336, 400, 476, 566, 568, 570, 572, 574, 576},
+			short: [14]int{0, 8, 16, 24, 36, 52, 72, 96, 124, 160, 162, 164, 166, 192},
+		},
+	}
+
+	// scaleFactorLengths - длины масштабных коэффициентов slen1 и slen2 для MPEG1 по scalefac_compress
+	scaleFactorLengths = [16][2]int{
+		{0, 0}, {0, 1}, {0, 2}, {0, 3}, {3, 0}, {1, 1}, {1, 2}, {1, 3},
+		{2, 1}, {2, 2}, {2, 3}, {3, 1}, {3, 2}, {3, 3}, {4, 2}, {4, 3},
+	}
+
+	// preTab - таблица предыскажения для длинных блоков (используется при preflag)
+	preTab = [22]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 3, 3, 3, 2, 0}
+
+	// scaleFactorPartitions - количество масштабных коэффициентов в каждой из 4 групп для MPEG2 (LSF)
+	// по номеру таблицы и типу блока (длинный, короткий, смешанный), ISO/IEC 13818-3, таблица B.1
+	scaleFactorPartitions = [6][3][4]int{
+		{{6, 5, 5, 5}, {9, 9, 9, 9}, {6, 9, 9, 9}},
+		{{6, 5, 7, 3}, {9, 9, 12, 6}, {6, 9, 12, 6}},
+		{{11, 10, 0, 0}, {18, 18, 0, 0}, {15, 18, 0, 0}},
+		{{7, 7, 7, 0}, {12, 12, 12, 0}, {6, 15, 12, 0}},
+		{{6, 6, 6, 3}, {12, 9, 9, 6}, {6, 12, 9, 6}},
+		{{8, 8, 5, 0}, {15, 12, 9, 0}, {6, 18, 9, 0}},
+	}
+
+	// aliasCoefficients - коэффициенты ci для устранения наложения спектров
+	aliasCoefficients = [8]float64{-0.6, -0.535, -0.33, -0.185, -0.095, -0.041, -0.0142, -0.0037}
+)
@@ -0,0 +1,202 @@
+package mp3
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Типы изображений в ID3v2
+const (
+	pictureTypeFrontCover = 3
+)
+
+// Cover - возвращает обложку из фреймов APIC (PIC для ID3v2.2) первого тэга ID3v2
+// и ее MIME тип. Предпочитается передняя обложка, иначе берется первое изображение.
+// Если изображений нет, то возвращается nil.
+func Cover(readSeeker io.ReadSeeker) ([]byte, string) {
+	_, err := readSeeker.Seek(id3v2HeaderPosition, os.SEEK_SET)
+	if err != nil {
+		return nil, ""
+	}
+
+	data := make([]byte, idv3v2HeaderSize)
+	_, err = io.ReadFull(readSeeker, data)
+	if err != nil || !isID3V2header(data) {
+		return nil, ""
+	}
+
+	header := parseID3v2Header(data)
+	if header.Version < 2 || header.Version > 4 {
+		return nil, ""
+	}
+
+	tag := make([]byte, header.Size)
+	_, err = io.ReadFull(readSeeker, tag)
+	if err != nil {
+		return nil, ""
+	}
+
+	if header.Unsynchronisation {
+		tag = removeUnsynchronisation(tag)
+	}
+
+	if header.ExtendedHeader && header.Version > 2 && len(tag) >= 4 {
+		size := int(convertByteToInt(tag[:4])) + 4 // в ID3v2.3 размер не включает само поле размера
+		if header.Version == 4 {
+			size = int(calculateTagSize(tag[:4]))
+		}
+		if size > len(tag) {
+			return nil, ""
+		}
+		tag = tag[size:]
+	}
+
+	var cover []byte
+	var mimeType string
+	for len(tag) > 0 {
+		name, body, rest, ok := nextFrame(tag, header.Version)
+		if !ok {
+			break
+		}
+		tag = rest
+
+		var pictureType byte
+		var picture []byte
+		var pictureMIME string
+		switch name {
+		case "APIC":
+			pictureType, picture, pictureMIME = parseAPIC(body)
+		case "PIC":
+			pictureType, picture, pictureMIME = parsePIC(body)
+		default:
+			continue
+		}
+
+		if picture == nil {
+			continue
+		}
+		if pictureType == pictureTypeFrontCover {
+			return picture, pictureMIME
+		}
+		if cover == nil {
+			cover, mimeType = picture, pictureMIME
+		}
+	}
+
+	return cover, mimeType
+}
+
+// nextFrame - отделяет от данных тэга очередной фрейм. Возвращает его имя, данные
+// и оставшиеся фреймы. Сжатые и зашифрованные фреймы возвращаются с пустым именем.
+func nextFrame(tag []byte, version byte) (name string, body, rest []byte, ok bool) {
+	headerSize := frameV23V24HeaderSize
+	if version == 2 {
+		headerSize = frameV22HeaderSize
+	}
+	if len(tag) < headerSize || tag[0] == 0 { // дальше только padding
+		return "", nil, nil, false
+	}
+
+	var size int
+	switch version {
+	case 2:
+		name = string(tag[:3])
+		size = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+	case 3:
+		name = string(tag[:4])
+		size = int(convertByteToInt(tag[4:8]))
+	case 4:
+		name = string(tag[:4])
+		size = int(calculateTagSize(tag[4:8]))
+	}
+
+	if size < 0 || headerSize+size > len(tag) {
+		return "", nil, nil, false
+	}
+	body = tag[headerSize : headerSize+size]
+	rest = tag[headerSize+size:]
+
+	switch {
+	case version == 3 && tag[9]&0xC0 != 0, version == 4 && tag[9]&0x0C != 0:
+		// Сжатые и зашифрованные фреймы не поддерживаются
+		return "", nil, rest, true
+	case version == 4:
+		if tag[9]&0x01 != 0 && len(body) >= 4 { // индикатор длины данных
+			body = body[4:]
+		}
+		if tag[9]&0x02 != 0 {
+			body = removeUnsynchronisation(body)
+		}
+	}
+
+	return name, body, rest, true
+}
+
+// parseAPIC - разбирает фрейм APIC: кодировка, MIME тип, тип изображения, описание, данные
+func parseAPIC(body []byte) (byte, []byte, string) {
+	if len(body) < 2 {
+		return 0, nil, ""
+	}
+
+	end := bytes.IndexByte(body[1:], 0)
+	if end < 0 || end+2 >= len(body) {
+		return 0, nil, ""
+	}
+	mimeType := string(body[1 : end+1])
+	if mimeType == "" || mimeType == "image/jpg" {
+		mimeType = "image/jpeg"
+	}
+
+	pictureType := body[end+2]
+	data := skipDescription(body[end+3:], body[0])
+	if len(data) == 0 {
+		return 0, nil, ""
+	}
+
+	return pictureType, data, mimeType
+}
+
+// parsePIC - разбирает фрейм PIC ID3v2.2: кодировка, формат (3 символа), тип изображения, описание, данные
+func parsePIC(body []byte) (byte, []byte, string) {
+	if len(body) < 6 {
+		return 0, nil, ""
+	}
+
+	mimeType := "image/jpeg"
+	if string(bytes.ToUpper(body[1:4])) == "PNG" {
+		mimeType = "image/png"
+	}
+
+	data := skipDescription(body[5:], body[0])
+	if len(data) == 0 {
+		return 0, nil, ""
+	}
+
+	return body[4], data, mimeType
+}
+
+// skipDescription - пропускает строку описания, заканчивающуюся нулем
+// (двумя нулевыми байтами для кодировок UTF-16)
+func skipDescription(data []byte, encoding byte) []byte {
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				return data[i+2:]
+			}
+		}
+		return nil
+	}
+
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return nil
+	}
+
+	return data[end+1:]
+}
+
+// removeUnsynchronisation - убирает нулевые байты, вставленные после 0xFF при unsynchronisation
+func removeUnsynchronisation(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+}
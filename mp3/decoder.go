@@ -0,0 +1,117 @@
+package mp3
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+)
+
+// Decoder - декодер MPEG аудио (MPEG1, MPEG2 и MPEG2.5, Layer I, II и III) в PCM.
+// Параметры потока (версия, слой, частота дискретизации и количество каналов)
+// берутся из первого фрейма, фреймы с другими параметрами считаются мусором и пропускаются.
+type Decoder struct {
+	reader     *bufio.Reader
+	first      frameHeader        // заголовок первого фрейма
+	synth      [2]synthesisFilter // синтезирующие фильтры по каналам
+	layer3     layer3Decoder
+	sampleRate int
+	channels   int
+}
+
+// ErrNoFrames - в потоке не найдено ни одного фрейма MPEG аудио
+var ErrNoFrames = errors.New("Фреймы MPEG аудио не найдены")
+
+// NewDecoder - конструктор для типа Decoder. Пропускает ID3v2 тэги
+// и находит первый фрейм, указатель чтения должен быть в начале файла.
+func NewDecoder(readSeeker io.ReadSeeker) (*Decoder, error) {
+	var meta MP3meta
+	getID3v2Tags(readSeeker, &meta)
+
+	_, err := readSeeker.Seek(int64(meta.idv3v2size), os.SEEK_SET)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := &Decoder{reader: bufio.NewReader(readSeeker)}
+	header, err := decoder.nextHeader(nil)
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrNoFrames
+		}
+		return nil, err
+	}
+
+	decoder.first = *header
+	decoder.sampleRate = header.SampleRate
+	decoder.channels = header.channels()
+
+	return decoder, nil
+}
+
+// SampleRate - возвращает частоту дискретизации в герцах
+func (d *Decoder) SampleRate() int {
+	return d.sampleRate
+}
+
+// Channels - возвращает количество каналов
+func (d *Decoder) Channels() int {
+	return d.channels
+}
+
+// ReadFrame - декодирует следующий фрейм и возвращает его отсчеты по каналам
+// в диапазоне [-1, 1]. В конце потока возвращает io.EOF.
+func (d *Decoder) ReadFrame() ([][]float64, error) {
+	header, err := d.nextHeader(&d.first)
+	if err != nil {
+		return nil, err
+	}
+	d.reader.Discard(4)
+
+	body := make([]byte, header.Size-4)
+	_, err = io.ReadFull(d.reader, body)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	out := make([][]float64, d.channels)
+	for ch := range out {
+		out[ch] = make([]float64, header.Samples)
+	}
+
+	switch header.layer {
+	case layer1:
+		decodeLayer1(header, body, d.synth[:], out)
+	case layer2:
+		decodeLayer2(header, body, d.synth[:], out)
+	case layer3:
+		// Если в резервуаре бит недостаточно данных (начало потока), фрейм отдается тишиной
+		err = d.layer3.decodeFrame(header, body, d.synth[:], out)
+		if err != nil && err != errMainData {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// nextHeader - находит заголовок следующего фрейма, не продвигая указатель чтения за него.
+// Если передан образец, то пропускаются заголовки с отличающимися от него параметрами.
+func (d *Decoder) nextHeader(sample *frameHeader) (*frameHeader, error) {
+	header := new(frameHeader)
+	for {
+		data, err := d.reader.Peek(4)
+		if err != nil {
+			return nil, io.EOF
+		}
+
+		if header.Parse(data) == nil && header.decodable() && (sample == nil || sample.matches(header)) {
+			return header, nil
+		}
+
+		d.reader.Discard(1)
+	}
+}
@@ -0,0 +1,150 @@
+package mp3
+
+import "errors"
+
+// huffmanTree - дерево декодирования Хаффмана. Узел n хранит потомков
+// nodes[2n] (бит 0) и nodes[2n+1] (бит 1): положительное значение - номер
+// следующего узла, отрицательное - лист со значением -(value+1).
+type huffmanTree struct {
+	nodes []int32
+}
+
+// huffmanTable - таблица Хаффмана для пар значений big_values
+type huffmanTable struct {
+	size    int          // значения x и y лежат в диапазоне [0, size)
+	linbits int          // количество дополнительных бит для значения 15
+	tree    *huffmanTree // дерево декодирования, nil для пустой таблицы
+}
+
+var errHuffmanCode = errors.New("Некорректный код Хаффмана")
+
+var (
+	// bigValuesTables - таблицы 0-31, выбираемые полем table_select
+	bigValuesTables [32]huffmanTable
+	// count1Tables - таблицы A и B для четверок значений
+	count1Tables [2]*huffmanTree
+)
+
+func init() {
+	codes := [...][]huffmanCode{
+		1: huffmanCodes1, 2: huffmanCodes2, 3: huffmanCodes3, 5: huffmanCodes5,
+		6: huffmanCodes6, 7: huffmanCodes7, 8: huffmanCodes8, 9: huffmanCodes9,
+		10: huffmanCodes10, 11: huffmanCodes11, 12: huffmanCodes12, 13: huffmanCodes13,
+		15: huffmanCodes15, 16: huffmanCodes16, 24: huffmanCodes24,
+	}
+	sizes := [...]int{1: 2, 2: 3, 3: 3, 5: 4, 6: 4, 7: 6, 8: 6, 9: 6, 10: 8, 11: 8, 12: 8, 13: 16, 15: 16, 16: 16, 24: 16}
+	for i, c := range codes {
+		if c != nil {
+			bigValuesTables[i] = huffmanTable{size: sizes[i], tree: buildHuffmanTree(c)}
+		}
+	}
+
+	// Таблицы 16-23 и 24-31 отличаются только количеством linbits
+	linbits := [16]int{1, 2, 3, 4, 6, 8, 10, 13, 4, 5, 6, 7, 8, 9, 11, 13}
+	for i := 0; i < 16; i++ {
+		base := bigValuesTables[16]
+		if i >= 8 {
+			base = bigValuesTables[24]
+		}
+		base.linbits = linbits[i]
+		bigValuesTables[16+i] = base
+	}
+
+	count1Tables[0] = buildHuffmanTree(huffmanCodesA)
+	codesB := make([]huffmanCode, 16)
+	for v := range codesB {
+		codesB[v] = huffmanCode{4, uint32(15 - v)}
+	}
+	count1Tables[1] = buildHuffmanTree(codesB)
+}
+
+// buildHuffmanTree - строит дерево декодирования по списку кодовых слов,
+// значением листа является индекс кодового слова в списке
+func buildHuffmanTree(codes []huffmanCode) *huffmanTree {
+	tree := &huffmanTree{nodes: make([]int32, 2, 4*len(codes))}
+	for value, c := range codes {
+		node := 0
+		for i := int(c.length) - 1; i >= 0; i-- {
+			slot := 2*node + int(c.code>>uint(i)&1)
+			if i == 0 {
+				tree.nodes[slot] = -int32(value + 1)
+				break
+			}
+			if tree.nodes[slot] == 0 {
+				tree.nodes[slot] = int32(len(tree.nodes) / 2)
+				tree.nodes = append(tree.nodes, 0, 0)
+			}
+			node = int(tree.nodes[slot])
+		}
+	}
+
+	return tree
+}
+
+// decode - читает один код Хаффмана и возвращает закодированное значение
+func (tree *huffmanTree) decode(br *bitReader) (int, error) {
+	node := 0
+	for i := 0; i < 32; i++ {
+		next := tree.nodes[2*node+int(br.readBit())]
+		if next < 0 {
+			return int(-next - 1), nil
+		}
+		if next == 0 {
+			return 0, errHuffmanCode
+		}
+		node = int(next)
+	}
+
+	return 0, errHuffmanCode
+}
+
+// decodePair - читает пару значений x, y с учетом linbits и знаковых бит
+func (table *huffmanTable) decodePair(br *bitReader) (int, int, error) {
+	if table.tree == nil {
+		return 0, 0, nil
+	}
+
+	value, err := table.tree.decode(br)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	x, y := value/table.size, value%table.size
+	x = table.readValue(br, x)
+	y = table.readValue(br, y)
+
+	return x, y, nil
+}
+
+// readValue - дочитывает linbits и знак одного значения пары
+func (table *huffmanTable) readValue(br *bitReader, v int) int {
+	if table.linbits != 0 && v == 15 {
+		v += int(br.readBits(table.linbits))
+	}
+	if v != 0 && br.readBit() == 1 {
+		v = -v
+	}
+
+	return v
+}
+
+// decodeQuad - читает четверку значений v, w, x, y из области count1
+func decodeQuad(tree *huffmanTree, br *bitReader) ([4]int, error) {
+	var quad [4]int
+
+	value, err := tree.decode(br)
+	if err != nil {
+		return quad, err
+	}
+
+	for i := 0; i < 4; i++ {
+		if value>>uint(3-i)&1 == 1 {
+			quad[i] = 1
+			if br.readBit() == 1 {
+				quad[i] = -1
+			}
+		}
+	}
+
+	return quad, nil
+}
@@ -0,0 +1,290 @@
+package mp3
+
+import "math"
+
+// quantClass - класс квантования отсчетов подполосы Layer II (ISO/IEC 11172-3, таблица B.4)
+type quantClass struct {
+	levels  int  // количество уровней квантования
+	grouped bool // три отсчета упакованы в одно кодовое слово
+	bits    int  // длина кодового слова в битах
+}
+
+// allocationTable - таблица распределения бит Layer II
+type allocationTable struct {
+	sblimit int            // количество используемых подполос
+	nbal    []int          // длина поля распределения бит для каждой подполосы
+	classes [][]quantClass // классы квантования для каждой подполосы по значению поля
+}
+
+var (
+	// scaleFactorValues - значения масштабных коэффициентов Layer I и II: 2^(1 - i/3)
+	scaleFactorValues [64]float64
+
+	// layer2Tables - таблицы распределения бит B.2a-B.2d из ISO/IEC 11172-3
+	// и таблица B.1 из ISO/IEC 13818-3 для MPEG2
+	layer2Tables [5]allocationTable
+)
+
+func init() {
+	for i := range scaleFactorValues {
+		scaleFactorValues[i] = math.Pow(2, 1-float64(i)/3)
+	}
+
+	rowA := newQuantClasses(3, 7, 15, 31, 63, 127, 255, 511, 1023, 2047, 4095, 8191, 16383, 32767, 65535)
+	rowB := newQuantClasses(3, 5, 7, 9, 15, 31, 63, 127, 255, 511, 1023, 2047, 4095, 8191, 65535)
+	rowC := newQuantClasses(3, 5, 7, 9, 15, 31, 65535)
+	rowD := newQuantClasses(3, 5, 65535)
+	rowE := newQuantClasses(3, 5, 9, 15, 31, 63, 127, 255, 511, 1023, 2047, 4095, 8191, 16383, 32767)
+	rowF := newQuantClasses(3, 5, 9, 15, 31, 63, 127)
+	rowG := newQuantClasses(3, 5, 7, 9, 15, 31, 63, 127, 255, 511, 1023, 2047, 4095, 8191, 16383)
+	rowH := newQuantClasses(3, 5, 9)
+
+	layer2Tables[0] = newAllocationTable(27, []int{3, 8, 12, 4}, [][]quantClass{rowA, rowB, rowC, rowD})
+	layer2Tables[1] = newAllocationTable(30, []int{3, 8, 12, 7}, [][]quantClass{rowA, rowB, rowC, rowD})
+	layer2Tables[2] = newAllocationTable(8, []int{2, 6}, [][]quantClass{rowE, rowF})
+	layer2Tables[3] = newAllocationTable(12, []int{2, 10}, [][]quantClass{rowE, rowF})
+	layer2Tables[4] = newAllocationTable(30, []int{4, 7, 19}, [][]quantClass{rowG, rowF, rowH})
+}
+
+// newQuantClasses - строит строку таблицы распределения бит по количеству уровней,
+// нулевое значение поля означает, что подполоса не передается
+func newQuantClasses(levels ...int) []quantClass {
+	row := make([]quantClass, 1, len(levels)+1)
+	for _, l := range levels {
+		class := quantClass{levels: l}
+		switch l {
+		case 3:
+			class.grouped, class.bits = true, 5
+		case 5:
+			class.grouped, class.bits = true, 7
+		case 9:
+			class.grouped, class.bits = true, 10
+		default:
+			class.bits = int(math.Log2(float64(l + 1)))
+		}
+		row = append(row, class)
+	}
+
+	return row
+}
+
+// newAllocationTable - собирает таблицу из групп подряд идущих подполос с одинаковыми строками
+func newAllocationTable(sblimit int, counts []int, rows [][]quantClass) allocationTable {
+	table := allocationTable{sblimit: sblimit}
+	for i, count := range counts {
+		nbal := int(math.Log2(float64(len(rows[i]))))
+		for j := 0; j < count; j++ {
+			table.nbal = append(table.nbal, nbal)
+			table.classes = append(table.classes, rows[i])
+		}
+	}
+
+	return table
+}
+
+// dequantize - переводит кодовое слово с заданным количеством уровней в значение от -1 до 1
+func dequantize(code, levels int) float64 {
+	return float64(2*code-levels+1) / float64(levels)
+}
+
+// stereoBound - первая подполоса, начиная с которой отсчеты в режиме joint stereo общие для двух каналов
+func stereoBound(header *frameHeader, sblimit int) int {
+	bound := sblimit
+	if header.channelMode == jointStereo {
+		bound = 4 * (int(header.modeExtension) + 1)
+	}
+	if bound > sblimit {
+		bound = sblimit
+	}
+
+	return bound
+}
+
+// decodeLayer1 - декодирует фрейм Layer I (384 отсчета на канал)
+func decodeLayer1(header *frameHeader, body []byte, synth []synthesisFilter, out [][]float64) {
+	nch := header.channels()
+	if header.Protection {
+		body = body[2:]
+	}
+	br := newBitReader(body)
+	bound := stereoBound(header, 32)
+
+	var allocation [2][32]int
+	for sb := 0; sb < 32; sb++ {
+		for ch := 0; ch < nch; ch++ {
+			if sb < bound || ch == 0 {
+				allocation[ch][sb] = int(br.readBits(4))
+			} else {
+				allocation[ch][sb] = allocation[0][sb]
+			}
+		}
+	}
+
+	var scale [2][32]float64
+	for sb := 0; sb < 32; sb++ {
+		for ch := 0; ch < nch; ch++ {
+			if allocation[ch][sb] != 0 {
+				scale[ch][sb] = scaleFactorValues[br.readBits(6)]
+			}
+		}
+	}
+
+	var subbands [2][32]float64
+	for s := 0; s < 12; s++ {
+		for sb := 0; sb < 32; sb++ {
+			for ch := 0; ch < nch; ch++ {
+				alloc := allocation[ch][sb]
+				if alloc == 0 || alloc == 15 {
+					subbands[ch][sb] = 0
+					continue
+				}
+
+				if sb < bound || ch == 0 {
+					bits := alloc + 1
+					subbands[ch][sb] = dequantize(int(br.readBits(bits)), 1<<uint(bits)-1)
+				} else {
+					subbands[ch][sb] = subbands[0][sb]
+				}
+			}
+		}
+
+		for ch := 0; ch < nch; ch++ {
+			var scaled [32]float64
+			for sb := range scaled {
+				scaled[sb] = subbands[ch][sb] * scale[ch][sb]
+			}
+			synth[ch].synthesize(scaled[:], out[ch][s*32:s*32+32])
+		}
+	}
+}
+
+// layer2Table - выбирает таблицу распределения бит Layer II по битрейту на канал и частоте дискретизации
+func layer2Table(header *frameHeader) *allocationTable {
+	if header.isLSF() {
+		return &layer2Tables[4]
+	}
+
+	bitratePerChannel := header.Bitrate / header.channels()
+	switch {
+	case bitratePerChannel <= 48000 && header.SampleRate == 32000:
+		return &layer2Tables[3]
+	case bitratePerChannel <= 48000:
+		return &layer2Tables[2]
+	case bitratePerChannel <= 80000 || header.SampleRate == 48000:
+		return &layer2Tables[0]
+	default:
+		return &layer2Tables[1]
+	}
+}
+
+// decodeLayer2 - декодирует фрейм Layer II (1152 отсчета на канал)
+func decodeLayer2(header *frameHeader, body []byte, synth []synthesisFilter, out [][]float64) {
+	nch := header.channels()
+	if header.Protection {
+		body = body[2:]
+	}
+	br := newBitReader(body)
+	table := layer2Table(header)
+	bound := stereoBound(header, table.sblimit)
+
+	var allocation [2][32]*quantClass
+	for sb := 0; sb < table.sblimit; sb++ {
+		for ch := 0; ch < nch; ch++ {
+			if sb < bound || ch == 0 {
+				index := br.readBits(table.nbal[sb])
+				if index != 0 {
+					allocation[ch][sb] = &table.classes[sb][index]
+				}
+			} else {
+				allocation[ch][sb] = allocation[0][sb]
+			}
+		}
+	}
+
+	var scfsi [2][32]int
+	for sb := 0; sb < table.sblimit; sb++ {
+		for ch := 0; ch < nch; ch++ {
+			if allocation[ch][sb] != nil {
+				scfsi[ch][sb] = int(br.readBits(2))
+			}
+		}
+	}
+
+	var scale [2][32][3]float64
+	for sb := 0; sb < table.sblimit; sb++ {
+		for ch := 0; ch < nch; ch++ {
+			if allocation[ch][sb] == nil {
+				continue
+			}
+
+			s := &scale[ch][sb]
+			switch scfsi[ch][sb] {
+			case 0:
+				s[0] = scaleFactorValues[br.readBits(6)]
+				s[1] = scaleFactorValues[br.readBits(6)]
+				s[2] = scaleFactorValues[br.readBits(6)]
+			case 1:
+				s[0] = scaleFactorValues[br.readBits(6)]
+				s[1] = s[0]
+				s[2] = scaleFactorValues[br.readBits(6)]
+			case 2:
+				s[0] = scaleFactorValues[br.readBits(6)]
+				s[1], s[2] = s[0], s[0]
+			case 3:
+				s[0] = scaleFactorValues[br.readBits(6)]
+				s[1] = scaleFactorValues[br.readBits(6)]
+				s[2] = s[1]
+			}
+		}
+	}
+
+	var samples [2][32][3]float64
+	for gr := 0; gr < 12; gr++ {
+		part := gr / 4
+		for sb := 0; sb < table.sblimit; sb++ {
+			// values - деквантованные отсчеты без учета масштабного коэффициента,
+			// выше границы joint stereo они общие для обоих каналов
+			var values [3]float64
+			for ch := 0; ch < nch; ch++ {
+				class := allocation[ch][sb]
+				if class == nil {
+					samples[ch][sb] = [3]float64{}
+					continue
+				}
+
+				if sb < bound || ch == 0 {
+					var codes [3]int
+					if class.grouped {
+						c := int(br.readBits(class.bits))
+						for i := 0; i < 3; i++ {
+							codes[i] = c % class.levels
+							c /= class.levels
+						}
+					} else {
+						for i := 0; i < 3; i++ {
+							codes[i] = int(br.readBits(class.bits))
+						}
+					}
+					for i := 0; i < 3; i++ {
+						values[i] = dequantize(codes[i], class.levels)
+					}
+				}
+
+				for i := 0; i < 3; i++ {
+					samples[ch][sb][i] = values[i] * scale[ch][sb][part]
+				}
+			}
+		}
+
+		for i := 0; i < 3; i++ {
+			for ch := 0; ch < nch; ch++ {
+				var subbands [32]float64
+				for sb := 0; sb < table.sblimit; sb++ {
+					subbands[sb] = samples[ch][sb][i]
+				}
+				offset := (gr*3 + i) * 32
+				synth[ch].synthesize(subbands[:], out[ch][offset:offset+32])
+			}
+		}
+	}
+}
@@ -0,0 +1,725 @@
+package mp3
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	granuleSamples   = 576  // количество частотных линий в грануле
+	maxReservoirSize = 4096 // сколько байт основных данных хранится для следующих фреймов
+)
+
+// granuleInfo - побочная информация одной гранулы одного канала
+type granuleInfo struct {
+	part23Length     int    // длина масштабных коэффициентов и кодов Хаффмана в битах
+	bigValues        int    // количество пар значений в области big_values
+	globalGain       int    // глобальный шаг квантования
+	scalefacCompress int    // определяет длины масштабных коэффициентов
+	windowSwitching  bool   // флаг переключения окон
+	blockType        int    // тип блока (0 - обычный, 1 - начальный, 2 - короткий, 3 - конечный)
+	mixedBlock       bool   // смешанный блок (две нижние подполосы - длинные окна)
+	tableSelect      [3]int // таблицы Хаффмана для трех областей big_values
+	subblockGain     [3]int // смещение усиления для каждого короткого окна
+	region0Count     int    // количество полос в первой области
+	region1Count     int    // количество полос во второй области
+	preflag          bool   // флаг предыскажения
+	scalefacScale    int    // шаг масштабных коэффициентов
+	count1Table      int    // таблица для области четверок (0 - A, 1 - B)
+}
+
+// sideInfo - побочная информация фрейма Layer III
+type sideInfo struct {
+	mainDataBegin int               // смещение начала основных данных назад в резервуаре
+	scfsi         [2][4]bool        // признаки повторного использования масштабных коэффициентов
+	granules      [2][2]granuleInfo // по гранулам и каналам
+}
+
+// channelScaleFactors - масштабные коэффициенты канала
+type channelScaleFactors struct {
+	long  [22]int    // для длинных блоков
+	short [13][3]int // для коротких блоков по окнам
+	// длины коэффициентов в битах, нужны для интенсивного стерео MPEG2
+	longLength  [22]int
+	shortLength [13]int
+}
+
+// band - полоса частотных линий гранулы, к которой применяется один масштабный коэффициент
+type band struct {
+	start  int // первая линия
+	width  int // ширина
+	sfb    int // номер полосы масштабных коэффициентов
+	window int // номер короткого окна, -1 для длинного блока
+}
+
+// layer3Decoder - состояние декодера Layer III, сохраняемое между фреймами
+type layer3Decoder struct {
+	reservoir []byte                 // резервуар бит: основные данные прошлых фреймов
+	overlap   [2][32][18]float64     // вторая половина результатов IMDCT для наложения
+	scale     [2]channelScaleFactors // масштабные коэффициенты по каналам
+}
+
+var errMainData = errors.New("Недостаточно данных в резервуаре бит")
+
+var (
+	// powTable - значения |x|^(4/3) для деквантования
+	powTable [8207]float64
+	// imdctLong, imdctShort - косинусы для IMDCT длинных (36 точек) и коротких (12 точек) блоков
+	imdctLong  [36][18]float64
+	imdctShort [12][6]float64
+	// imdctWindows - окна для каждого типа блока
+	imdctWindows [4][36]float64
+	// aliasCs, aliasCa - коэффициенты бабочек устранения наложения
+	aliasCs, aliasCa [8]float64
+	// intensityRatios - коэффициенты левого и правого каналов интенсивного стерео MPEG1
+	intensityRatios [7][2]float64
+)
+
+func init() {
+	for i := range powTable {
+		powTable[i] = math.Pow(float64(i), 4.0/3.0)
+	}
+
+	for i := 0; i < 36; i++ {
+		for k := 0; k < 18; k++ {
+			imdctLong[i][k] = math.Cos(math.Pi / 72 * float64((2*i+1+18)*(2*k+1)))
+		}
+	}
+	for i := 0; i < 12; i++ {
+		for k := 0; k < 6; k++ {
+			imdctShort[i][k] = math.Cos(math.Pi / 24 * float64((2*i+1+6)*(2*k+1)))
+		}
+	}
+
+	for i := 0; i < 36; i++ {
+		imdctWindows[0][i] = math.Sin(math.Pi / 36 * (float64(i) + 0.5))
+	}
+	for i := 0; i < 18; i++ {
+		imdctWindows[1][i] = imdctWindows[0][i]
+		imdctWindows[3][i+18] = imdctWindows[0][i+18]
+	}
+	for i := 18; i < 24; i++ {
+		imdctWindows[1][i] = 1
+		imdctWindows[3][i-6] = 1
+	}
+	for i := 24; i < 30; i++ {
+		imdctWindows[1][i] = math.Sin(math.Pi / 12 * (float64(i-18) + 0.5))
+		imdctWindows[3][i-18] = math.Sin(math.Pi / 12 * (float64(i-24) + 0.5))
+	}
+	for i := 0; i < 12; i++ {
+		imdctWindows[2][i] = math.Sin(math.Pi / 12 * (float64(i) + 0.5))
+	}
+
+	for i, c := range aliasCoefficients {
+		sq := math.Sqrt(1 + c*c)
+		aliasCs[i] = 1 / sq
+		aliasCa[i] = c / sq
+	}
+
+	for i := 0; i < 6; i++ {
+		ratio := math.Tan(float64(i) * math.Pi / 12)
+		intensityRatios[i] = [2]float64{ratio / (1 + ratio), 1 / (1 + ratio)}
+	}
+	intensityRatios[6] = [2]float64{1, 0}
+}
+
+// decodeFrame - декодирует фрейм Layer III. body - данные фрейма после заголовка,
+// out - отсчеты по каналам, в которые записывается результат
+func (d *layer3Decoder) decodeFrame(header *frameHeader, body []byte, synth []synthesisFilter, out [][]float64) error {
+	nch := header.channels()
+	if header.Protection {
+		body = body[2:]
+	}
+
+	br := newBitReader(body)
+	side := readSideInfo(br, header)
+	sideSize := br.bitPos() / 8
+	if sideSize > len(body) {
+		return errMainData
+	}
+
+	mainData := body[sideSize:]
+	if side.mainDataBegin > len(d.reservoir) {
+		d.appendReservoir(mainData)
+		return errMainData
+	}
+
+	data := make([]byte, 0, side.mainDataBegin+len(mainData))
+	data = append(data, d.reservoir[len(d.reservoir)-side.mainDataBegin:]...)
+	data = append(data, mainData...)
+	d.appendReservoir(mainData)
+
+	br = newBitReader(data)
+	granules := 2
+	if header.isLSF() {
+		granules = 1
+	}
+
+	var values [2][granuleSamples]int
+	var xr [2][granuleSamples]float64
+	var nonzero [2]int
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < nch; ch++ {
+			gi := &side.granules[gr][ch]
+			part2Start := br.bitPos()
+			if header.isLSF() {
+				d.readScaleFactorsLSF(br, header, gi, ch)
+			} else {
+				d.readScaleFactors(br, gi, &side.scfsi[ch], ch, gr)
+			}
+
+			bands := granuleBands(header, gi)
+			nonzero[ch] = readHuffmanData(br, gi, bands, part2Start+gi.part23Length, &values[ch])
+			br.seekBit(part2Start + gi.part23Length)
+
+			requantize(gi, bands, &d.scale[ch], &values[ch], &xr[ch])
+		}
+
+		if nch == 2 && header.channelMode == jointStereo {
+			d.processStereo(header, &side.granules[gr][1], &xr, nonzero[1])
+		}
+
+		for ch := 0; ch < nch; ch++ {
+			gi := &side.granules[gr][ch]
+			reorder(header, gi, &xr[ch])
+			antialias(gi, &xr[ch])
+			d.hybridSynthesis(gi, ch, &xr[ch])
+
+			var subbands [32]float64
+			for ss := 0; ss < 18; ss++ {
+				for sb := 0; sb < 32; sb++ {
+					subbands[sb] = xr[ch][sb*18+ss]
+					if sb&1 == 1 && ss&1 == 1 {
+						subbands[sb] = -subbands[sb]
+					}
+				}
+				offset := gr*granuleSamples + ss*32
+				synth[ch].synthesize(subbands[:], out[ch][offset:offset+32])
+			}
+		}
+	}
+
+	return nil
+}
+
+// appendReservoir - добавляет основные данные фрейма в резервуар бит
+func (d *layer3Decoder) appendReservoir(data []byte) {
+	d.reservoir = append(d.reservoir, data...)
+	if len(d.reservoir) > maxReservoirSize {
+		d.reservoir = append(d.reservoir[:0], d.reservoir[len(d.reservoir)-maxReservoirSize:]...)
+	}
+}
+
+// readSideInfo - читает побочную информацию фрейма
+func readSideInfo(br *bitReader, header *frameHeader) *sideInfo {
+	side := new(sideInfo)
+	nch := header.channels()
+	lsf := header.isLSF()
+
+	if lsf {
+		side.mainDataBegin = int(br.readBits(8))
+		br.skip(nch)
+	} else {
+		side.mainDataBegin = int(br.readBits(9))
+		if nch == 1 {
+			br.skip(5)
+		} else {
+			br.skip(3)
+		}
+		for ch := 0; ch < nch; ch++ {
+			for band := 0; band < 4; band++ {
+				side.scfsi[ch][band] = br.readBit() == 1
+			}
+		}
+	}
+
+	granules := 2
+	if lsf {
+		granules = 1
+	}
+
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < nch; ch++ {
+			gi := &side.granules[gr][ch]
+			gi.part23Length = int(br.readBits(12))
+			gi.bigValues = int(br.readBits(9))
+			if gi.bigValues > granuleSamples/2 {
+				gi.bigValues = granuleSamples / 2
+			}
+			gi.globalGain = int(br.readBits(8))
+			if lsf {
+				gi.scalefacCompress = int(br.readBits(9))
+			} else {
+				gi.scalefacCompress = int(br.readBits(4))
+			}
+
+			gi.windowSwitching = br.readBit() == 1
+			if gi.windowSwitching {
+				gi.blockType = int(br.readBits(2))
+				gi.mixedBlock = br.readBit() == 1
+				for i := 0; i < 2; i++ {
+					gi.tableSelect[i] = int(br.readBits(5))
+				}
+				for i := 0; i < 3; i++ {
+					gi.subblockGain[i] = int(br.readBits(3))
+				}
+				gi.region0Count = 7
+				if gi.blockType == 2 && !gi.mixedBlock {
+					gi.region0Count = 8
+				}
+				gi.region1Count = 36
+			} else {
+				for i := 0; i < 3; i++ {
+					gi.tableSelect[i] = int(br.readBits(5))
+				}
+				gi.region0Count = int(br.readBits(4))
+				gi.region1Count = int(br.readBits(3))
+			}
+
+			if !lsf {
+				gi.preflag = br.readBit() == 1
+			}
+			gi.scalefacScale = int(br.readBit())
+			gi.count1Table = int(br.readBit())
+		}
+	}
+
+	return side
+}
+
+// isShort - возвращает true, если гранула содержит короткие окна
+func (gi *granuleInfo) isShort() bool {
+	return gi.windowSwitching && gi.blockType == 2
+}
+
+// granuleBands - разбивает гранулу на полосы масштабных коэффициентов в порядке
+// следования данных: для коротких блоков каждая полоса повторяется для трех окон
+func granuleBands(header *frameHeader, gi *granuleInfo) []band {
+	sfb := scaleFactorBands[header.SampleRate]
+	bands := make([]band, 0, 39)
+
+	if !gi.isShort() {
+		for i := 0; i < 22; i++ {
+			bands = append(bands, band{sfb.long[i], sfb.long[i+1] - sfb.long[i], i, -1})
+		}
+		return bands
+	}
+
+	firstShort := 0
+	if gi.mixedBlock {
+		for i := 0; sfb.long[i] < 36; i++ {
+			bands = append(bands, band{sfb.long[i], sfb.long[i+1] - sfb.long[i], i, -1})
+		}
+		for sfb.short[firstShort]*3 < 36 {
+			firstShort++
+		}
+	}
+
+	for i := firstShort; i < 13; i++ {
+		width := sfb.short[i+1] - sfb.short[i]
+		for win := 0; win < 3; win++ {
+			bands = append(bands, band{sfb.short[i]*3 + win*width, width, i, win})
+		}
+	}
+
+	return bands
+}
+
+// readScaleFactors - читает масштабные коэффициенты MPEG1
+func (d *layer3Decoder) readScaleFactors(br *bitReader, gi *granuleInfo, scfsi *[4]bool, ch, gr int) {
+	sf := &d.scale[ch]
+	slen := scaleFactorLengths[gi.scalefacCompress]
+
+	if gi.isShort() {
+		first := 0
+		if gi.mixedBlock {
+			for i := 0; i < 8; i++ {
+				sf.long[i] = int(br.readBits(slen[0]))
+			}
+			first = 3
+		}
+		for i := first; i < 12; i++ {
+			length := slen[0]
+			if i >= 6 {
+				length = slen[1]
+			}
+			for win := 0; win < 3; win++ {
+				sf.short[i][win] = int(br.readBits(length))
+			}
+		}
+		sf.short[12] = [3]int{}
+		return
+	}
+
+	groups := [5]int{0, 6, 11, 16, 21}
+	for group := 0; group < 4; group++ {
+		if gr == 1 && scfsi[group] {
+			continue
+		}
+
+		length := slen[0]
+		if group >= 2 {
+			length = slen[1]
+		}
+		for i := groups[group]; i < groups[group+1]; i++ {
+			sf.long[i] = int(br.readBits(length))
+		}
+	}
+	sf.long[21] = 0
+}
+
+// readScaleFactorsLSF - читает масштабные коэффициенты MPEG2 (ISO/IEC 13818-3, 2.4.3.2)
+func (d *layer3Decoder) readScaleFactorsLSF(br *bitReader, header *frameHeader, gi *granuleInfo, ch int) {
+	sf := &d.scale[ch]
+	var slen [4]int
+	var table int
+
+	compress := gi.scalefacCompress
+	if ch == 1 && header.IntensityStereo {
+		compress >>= 1
+		switch {
+		case compress < 180:
+			slen = [4]int{compress / 36, compress % 36 / 6, compress % 6, 0}
+			table = 3
+		case compress < 244:
+			compress -= 180
+			slen = [4]int{compress % 64 >> 4, compress % 16 >> 2, compress % 4, 0}
+			table = 4
+		default:
+			compress -= 244
+			slen = [4]int{compress / 3, compress % 3, 0, 0}
+			table = 5
+		}
+	} else {
+		switch {
+		case compress < 400:
+			slen = [4]int{(compress >> 4) / 5, (compress >> 4) % 5, compress % 16 >> 2, compress % 4}
+			table = 0
+		case compress < 500:
+			compress -= 400
+			slen = [4]int{(compress >> 2) / 5, (compress >> 2) % 5, compress % 4, 0}
+			table = 1
+		default:
+			compress -= 500
+			slen = [4]int{compress / 3, compress % 3, 0, 0}
+			table = 2
+			gi.preflag = true
+		}
+	}
+
+	blockIndex := 0
+	if gi.isShort() {
+		blockIndex = 1
+		if gi.mixedBlock {
+			blockIndex = 2
+		}
+	}
+
+	var values, lengths [39]int
+	n := 0
+	for part, count := range scaleFactorPartitions[table][blockIndex] {
+		for i := 0; i < count; i++ {
+			values[n] = int(br.readBits(slen[part]))
+			lengths[n] = slen[part]
+			n++
+		}
+	}
+
+	if !gi.isShort() {
+		for i := 0; i < 21; i++ {
+			sf.long[i], sf.longLength[i] = values[i], lengths[i]
+		}
+		sf.long[21], sf.longLength[21] = 0, 0
+		return
+	}
+
+	n, first := 0, 0
+	if gi.mixedBlock {
+		for i := 0; i < 6; i++ {
+			sf.long[i], sf.longLength[i] = values[n], lengths[n]
+			n++
+		}
+		first = 3
+	}
+	for i := first; i < 12; i++ {
+		for win := 0; win < 3; win++ {
+			sf.short[i][win] = values[n]
+			n++
+		}
+		sf.shortLength[i] = lengths[n-1]
+	}
+	sf.short[12], sf.shortLength[12] = [3]int{}, 0
+}
+
+// readHuffmanData - декодирует коды Хаффмана гранулы в values, end - позиция конца данных гранулы в битах.
+// Возвращает количество декодированных линий (все линии дальше нулевые).
+func readHuffmanData(br *bitReader, gi *granuleInfo, bands []band, end int, values *[granuleSamples]int) int {
+	*values = [granuleSamples]int{}
+
+	region1Start, region2Start := granuleSamples, granuleSamples
+	sum, count := 0, 0
+	for _, b := range bands {
+		sum += b.width
+		count++
+		if count == gi.region0Count+1 {
+			region1Start = sum
+		}
+		if count == gi.region0Count+gi.region1Count+2 {
+			region2Start = sum
+		}
+	}
+
+	bigValuesEnd := gi.bigValues * 2
+	i := 0
+	for ; i < bigValuesEnd; i += 2 {
+		table := &bigValuesTables[gi.tableSelect[2]]
+		if i < region1Start {
+			table = &bigValuesTables[gi.tableSelect[0]]
+		} else if i < region2Start {
+			table = &bigValuesTables[gi.tableSelect[1]]
+		}
+
+		x, y, err := table.decodePair(br)
+		if err != nil {
+			return i
+		}
+		values[i], values[i+1] = x, y
+	}
+
+	tree := count1Tables[gi.count1Table]
+	for i+4 <= granuleSamples && br.bitPos() < end {
+		quad, err := decodeQuad(tree, br)
+		if err != nil {
+			break
+		}
+		if br.bitPos() > end {
+			break
+		}
+		copy(values[i:i+4], quad[:])
+		i += 4
+	}
+
+	return i
+}
+
+// requantize - деквантует значения гранулы с учетом масштабных коэффициентов
+func requantize(gi *granuleInfo, bands []band, sf *channelScaleFactors, values *[granuleSamples]int, xr *[granuleSamples]float64) {
+	multiplier := 0.5 * float64(1+gi.scalefacScale)
+	global := 0.25 * float64(gi.globalGain-210)
+
+	for _, b := range bands {
+		var exponent float64
+		if b.window < 0 {
+			pre := 0
+			if gi.preflag {
+				pre = preTab[b.sfb]
+			}
+			exponent = global - multiplier*float64(sf.long[b.sfb]+pre)
+		} else {
+			exponent = global - 2*float64(gi.subblockGain[b.window]) - multiplier*float64(sf.short[b.sfb][b.window])
+		}
+
+		scale := math.Exp2(exponent)
+		for i := b.start; i < b.start+b.width; i++ {
+			v := values[i]
+			switch {
+			case v > 0:
+				xr[i] = powTable[v] * scale
+			case v < 0:
+				xr[i] = -powTable[-v] * scale
+			default:
+				xr[i] = 0
+			}
+		}
+	}
+}
+
+// processStereo - обработка совмещенного стерео (MS и интенсивное стерео).
+// right - побочная информация правого канала, rightNonzero - граница ненулевых линий правого канала
+func (d *layer3Decoder) processStereo(header *frameHeader, right *granuleInfo, xr *[2][granuleSamples]float64, rightNonzero int) {
+	// intensity - для каждой линии признак того, что она обработана интенсивным стерео
+	var intensity [granuleSamples]bool
+
+	if header.IntensityStereo {
+		bands := granuleBands(header, right)
+		sf := &d.scale[1]
+
+		// Для каждого окна (длинные блоки - окно -1) ищем последнюю полосу с ненулевыми значениями
+		// правого канала: интенсивное стерео применяется только выше нее
+		lastBand := map[int]int{-1: -1, 0: -1, 1: -1, 2: -1}
+		for i, b := range bands {
+			for j := b.start; j < b.start+b.width && j < rightNonzero; j++ {
+				if xr[1][j] != 0 {
+					lastBand[b.window] = i
+					break
+				}
+			}
+		}
+		if right.mixedBlock {
+			// если ненулевые линии есть в коротких окнах, длинная часть не обрабатывается
+			for win := 0; win < 3; win++ {
+				if lastBand[win] >= 0 {
+					lastBand[-1] = len(bands)
+				}
+			}
+		}
+
+		for i, b := range bands {
+			if i <= lastBand[b.window] {
+				continue
+			}
+
+			pos, length := d.intensityPosition(b, sf)
+			var kl, kr float64
+			if header.isLSF() {
+				if pos == 1<<uint(length)-1 {
+					continue
+				}
+				kl, kr = lsfIntensityRatios(pos, right.scalefacCompress)
+			} else {
+				if pos >= 7 {
+					continue
+				}
+				kl, kr = intensityRatios[pos][0], intensityRatios[pos][1]
+			}
+
+			for j := b.start; j < b.start+b.width; j++ {
+				v := xr[0][j]
+				xr[0][j], xr[1][j] = v*kl, v*kr
+				intensity[j] = true
+			}
+		}
+	}
+
+	if header.MSStereo {
+		for i := 0; i < granuleSamples; i++ {
+			if intensity[i] {
+				continue
+			}
+			m, s := xr[0][i], xr[1][i]
+			xr[0][i], xr[1][i] = (m+s)*math.Sqrt2/2, (m-s)*math.Sqrt2/2
+		}
+	}
+}
+
+// intensityPosition - возвращает позицию интенсивного стерео для полосы и длину ее кода в битах.
+// Для последней полосы коэффициент не передается, используется значение предыдущей.
+func (d *layer3Decoder) intensityPosition(b band, sf *channelScaleFactors) (int, int) {
+	if b.window < 0 {
+		sfb := b.sfb
+		if sfb == 21 {
+			sfb = 20
+		}
+		return sf.long[sfb], sf.longLength[sfb]
+	}
+
+	sfb := b.sfb
+	if sfb == 12 {
+		sfb = 11
+	}
+	return sf.short[sfb][b.window], sf.shortLength[sfb]
+}
+
+// lsfIntensityRatios - коэффициенты левого и правого каналов интенсивного стерео MPEG2
+func lsfIntensityRatios(pos, scalefacCompress int) (float64, float64) {
+	if pos == 0 {
+		return 1, 1
+	}
+
+	base := math.Pow(2, -0.25)
+	if scalefacCompress&1 == 1 {
+		base = math.Sqrt2 / 2
+	}
+
+	if pos&1 == 1 {
+		return math.Pow(base, float64(pos+1)/2), 1
+	}
+	return 1, math.Pow(base, float64(pos)/2)
+}
+
+// reorder - переставляет линии коротких блоков из порядка "по окнам" в порядок "по частотам",
+// который ожидает IMDCT
+func reorder(header *frameHeader, gi *granuleInfo, xr *[granuleSamples]float64) {
+	if !gi.isShort() {
+		return
+	}
+
+	sfb := scaleFactorBands[header.SampleRate]
+	var tmp [granuleSamples]float64
+	first := 0
+	if gi.mixedBlock {
+		for sfb.short[first]*3 < 36 {
+			first++
+		}
+	}
+
+	for i := first; i < 13; i++ {
+		start := sfb.short[i] * 3
+		width := sfb.short[i+1] - sfb.short[i]
+		for win := 0; win < 3; win++ {
+			for j := 0; j < width; j++ {
+				tmp[start+3*j+win] = xr[start+win*width+j]
+			}
+		}
+	}
+
+	start := sfb.short[first] * 3
+	copy(xr[start:], tmp[start:])
+}
+
+// antialias - устранение наложения спектров между соседними подполосами
+func antialias(gi *granuleInfo, xr *[granuleSamples]float64) {
+	limit := 32
+	if gi.isShort() {
+		if !gi.mixedBlock {
+			return
+		}
+		limit = 2
+	}
+
+	for sb := 1; sb < limit; sb++ {
+		for i := 0; i < 8; i++ {
+			up, down := xr[18*sb-1-i], xr[18*sb+i]
+			xr[18*sb-1-i] = up*aliasCs[i] - down*aliasCa[i]
+			xr[18*sb+i] = down*aliasCs[i] + up*aliasCa[i]
+		}
+	}
+}
+
+// hybridSynthesis - обратное MDCT с оконной функцией и наложением половин соседних гранул
+func (d *layer3Decoder) hybridSynthesis(gi *granuleInfo, ch int, xr *[granuleSamples]float64) {
+	for sb := 0; sb < 32; sb++ {
+		blockType := gi.blockType
+		if !gi.windowSwitching || (gi.mixedBlock && sb < 2) {
+			blockType = 0
+		}
+
+		in := xr[sb*18 : sb*18+18]
+		var raw [36]float64
+		if blockType == 2 {
+			for win := 0; win < 3; win++ {
+				for i := 0; i < 12; i++ {
+					var sum float64
+					for k := 0; k < 6; k++ {
+						sum += in[win+3*k] * imdctShort[i][k]
+					}
+					raw[6+6*win+i] += sum * imdctWindows[2][i]
+				}
+			}
+		} else {
+			for i := 0; i < 36; i++ {
+				var sum float64
+				for k := 0; k < 18; k++ {
+					sum += in[k] * imdctLong[i][k]
+				}
+				raw[i] = sum * imdctWindows[blockType][i]
+			}
+		}
+
+		overlap := &d.overlap[ch][sb]
+		for i := 0; i < 18; i++ {
+			in[i] = raw[i] + overlap[i]
+			overlap[i] = raw[i+18]
+		}
+	}
+}
@@ -5,21 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"os"
 	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
 )
 
 type MP3meta struct {
-	Title      string // название песни
-	Artist     string // исполнитель
-	Genre      string // жанр
-	Bitrate    int    // килобит в секунду
-	Duration   int    // продолжительность песни в секундах
-	idv3v1tag  bool   // есть ли idv3v1tag(размер 128 байт с конца)
-	idv3v2tag  bool   // есть ли idv3v2tag
-	idv3v2size int    // размер idv3v1tag
+	Title       string // название песни
+	Artist      string // исполнитель
+	Genre       string // жанр
+	Album       string // альбом
+	AlbumArtist string // исполнитель альбома
+	Bitrate     int    // килобит в секунду
+	Duration    int    // продолжительность песни в секундах
+	idv3v1tag   bool   // есть ли idv3v1tag(размер 128 байт с конца)
+	idv3v2tag   bool   // есть ли idv3v2tag
+	idv3v2size  int    // размер idv3v1tag
 }
 
 //GetTitle - возвращает название песни(Возвращет пустую строку если название песни неизвестно)
@@ -37,6 +40,16 @@ func (mp3meta MP3meta) GetGenre() string {
 	return mp3meta.Genre
 }
 
+//GetAlbum - возвращает название альбома(Возвращет пустую строку если название альбома неизвестно)
+func (mp3meta MP3meta) GetAlbum() string {
+	return mp3meta.Album
+}
+
+//GetAlbumArtist - возвращает исполнителя альбома(Возвращет пустую строку если он неизвестен)
+func (mp3meta MP3meta) GetAlbumArtist() string {
+	return mp3meta.AlbumArtist
+}
+
 //GetBitrate - возвращает битрейт в билобайтах в секунду
 func (mp3meta MP3meta) GetBitrate() int {
 	return mp3meta.Bitrate
@@ -66,7 +79,7 @@ func ParseMetadata(readSeeker io.ReadSeeker) *MP3meta {
 	//Работаем с фреймами mp3(цель вычислить длину песни и битрейт)
 	err := getDurationAndBitRate(readSeeker, file)
 	if err != nil {
-		log.Println(err.Error())
+		i18n.Error("mp3.frames_error", err)
 		return nil
 	}
 
@@ -81,7 +94,7 @@ func getDurationAndBitRate(readSeeker io.ReadSeeker, file *MP3meta) error {
 	_, err := readSeeker.Seek(int64(file.idv3v2size), os.SEEK_SET)
 
 	if err != nil {
-		log.Println("При попытке переместиться на конец ID3V2 тэга: " + err.Error())
+		i18n.Error("mp3.seek_tag_end_error", err)
 	}
 
 	offset := searchOffsetForFirstMP3FrameHeader(readSeeker, 10000) //цифра придуманная...
@@ -92,7 +105,7 @@ func getDurationAndBitRate(readSeeker io.ReadSeeker, file *MP3meta) error {
 
 	_, err = readSeeker.Seek(int64(file.idv3v2size+offset), os.SEEK_SET)
 	if err != nil {
-		log.Println("При попытке переместиться на начало MP3 заголовка фрейма: " + err.Error())
+		i18n.Error("mp3.seek_frame_error", err)
 	}
 	//считываем заголовок первого фрейма---------------------------------     1
 	data := make([]byte, 4)
@@ -125,12 +138,12 @@ func getDurationAndBitRate(readSeeker io.ReadSeeker, file *MP3meta) error {
 		duration += mp3header.Duration
 		_, err := readSeeker.Seek(mp3header.Size-4, os.SEEK_CUR)
 		if err != nil {
-			log.Println("При переходе на следующий фрейм MP3" + err.Error())
+			i18n.Error("mp3.next_frame_error", err)
 			break
 		}
 		_, err = readSeeker.Read(data)
 		if err != nil {
-			log.Println("При чтении следующего заголовка фрейма MP3" + err.Error())
+			i18n.Error("mp3.next_header_error", err)
 			break
 		}
 		count++
@@ -149,11 +162,11 @@ func searchOffsetForFirstMP3FrameHeader(readSeeker io.ReadSeeker, distance int)
 	n, err := readSeeker.Read(data)
 
 	if n < 4 {
-		log.Println("Кол-во считаных байт меньше чем размер заголовка.")
+		i18n.Error("mp3.short_frame_header")
 		return -1
 	}
 	if err != nil {
-		log.Println(err.Error())
+		i18n.Error("mp3.frame_search_error", err)
 	}
 
 	for i := 0; i < n-1; i++ {
@@ -19,6 +19,7 @@ type (
 		Pad             bool
 		Private         bool
 		channelMode     channelMode
+		modeExtension   byte
 		IntensityStereo bool
 		MSStereo        bool
 		CopyRight       bool
@@ -70,6 +71,9 @@ func (this *frameHeader) Parse(data []byte) error {
 	this.Pad = ((data[2] >> 1) & 0x01) == 0x01
 	this.Private = (data[2] & 0x01) == 0x01
 	this.channelMode = channelMode(data[3]>>6) & 0x03
+	this.modeExtension = (data[3] >> 4) & 0x03
+	this.IntensityStereo = this.channelMode == jointStereo && this.modeExtension&0x01 == 0x01
+	this.MSStereo = this.channelMode == jointStereo && this.modeExtension&0x02 == 0x02
 	this.CopyRight = (data[3]>>3)&0x01 == 0x01
 	this.Original = (data[3]>>2)&0x01 == 0x01
 	this.emphasis = emphasis(data[3] & 0x03)
@@ -81,6 +85,32 @@ func (this *frameHeader) Parse(data []byte) error {
 	return nil
 }
 
+// channels - возвращает количество каналов в фрейме
+func (this *frameHeader) channels() int {
+	if this.channelMode == singleChannel {
+		return 1
+	}
+	return 2
+}
+
+// isLSF - возвращает true для MPEG2 и MPEG2.5 (Low Sampling Frequency)
+func (this *frameHeader) isLSF() bool {
+	return this.version != mPEG1
+}
+
+// decodable - проверяет, что заголовок описывает фрейм, который можно декодировать
+func (this *frameHeader) decodable() bool {
+	return this.layer != layerReserved && this.SampleRate != 0
+}
+
+// matches - проверяет, что фрейм принадлежит тому же потоку
+func (this *frameHeader) matches(other *frameHeader) bool {
+	return this.version == other.version &&
+		this.layer == other.layer &&
+		this.SampleRate == other.SampleRate &&
+		this.channels() == other.channels()
+}
+
 func (this *frameHeader) samples() int {
 	return samplesPerFrame[this.version][this.layer]
 }
@@ -0,0 +1,36 @@
+package mp3
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// AudioOffset - возвращает смещение первого фрейма MPEG аудио от начала файла.
+// ID3v2 тэги и мусор между ними и первым фреймом пропускаются.
+func AudioOffset(readSeeker io.ReadSeeker) (int64, error) {
+	var meta MP3meta
+	getID3v2Tags(readSeeker, &meta)
+
+	offset := int64(meta.idv3v2size)
+	_, err := readSeeker.Seek(offset, os.SEEK_SET)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(readSeeker)
+	header := new(frameHeader)
+	for {
+		data, err := reader.Peek(4)
+		if err != nil {
+			return 0, ErrNoFrames
+		}
+
+		if header.Parse(data) == nil && header.decodable() {
+			return offset, nil
+		}
+
+		reader.Discard(1)
+		offset++
+	}
+}
@@ -0,0 +1,2640 @@
+package main
+
+import "net/http"
+
+// openAPISpec - отдает описание API в формате OpenAPI 3. Описание ведется вручную вместе
+// с обработчиками: при изменении параметров или ответов обработчика его нужно обновить.
+func openAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(openAPIDocument))
+}
+
+// openAPIDocument - описание API первой и второй версии, отдается по адресу /openapi.json
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "AudioServer API",
+    "version": "2.0.0",
+    "description": "Songs and playlists storage. Version 1 endpoints take form or query parameters and accept any HTTP method; lists of IDs are passed as a JSON array in a string parameter (ids=[\"...\",\"...\"]). Version 2 endpoints live under /api/v2/ and follow REST conventions. Errors are returned as an Error object (or plain text, see errorFormat). Messages are localized by the lang parameter or the Accept-Language header."
+  },
+  "tags": [
+    {
+      "name": "songs"
+    },
+    {
+      "name": "playlists"
+    },
+    {
+      "name": "stats"
+    },
+    {
+      "name": "maintenance"
+    },
+    {
+      "name": "v2"
+    }
+  ],
+  "paths": {
+    "/addSong": {
+      "post": {
+        "operationId": "addSong",
+        "summary": "Upload a song",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "the song is added",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "no file in the form",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "409": {
+            "description": "the song is already in the system (details.id), Location points to it",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "413": {
+            "description": "the file is too large",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "415": {
+            "description": "unsupported format",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "multipart/form-data": {
+              "schema": {
+                "$ref": "#/components/schemas/SongUpload"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/getSong": {
+      "get": {
+        "operationId": "getSong",
+        "summary": "Download or stream a song file",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/id"
+          },
+          {
+            "name": "isDownload",
+            "in": "query",
+            "required": false,
+            "description": "true - the delivered file counts as a download",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "true",
+                "false"
+              ]
+            }
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "song file, range requests are supported",
+            "content": {
+              "application/octet-stream": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "206": {
+            "description": "part of the file",
+            "content": {
+              "application/octet-stream": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/getWaveform": {
+      "get": {
+        "operationId": "getWaveform",
+        "summary": "Waveform peaks of a song",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/id"
+          },
+          {
+            "name": "points",
+            "in": "query",
+            "required": false,
+            "description": "number of points, absent - stored resolution",
+            "schema": {
+              "type": "integer",
+              "minimum": 1
+            }
+          },
+          {
+            "name": "format",
+            "in": "query",
+            "required": false,
+            "description": "response format",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "json",
+                "binary"
+              ],
+              "default": "json"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "waveform",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Waveform"
+                }
+              },
+              "application/octet-stream": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/getSongsInZip": {
+      "get": {
+        "operationId": "getSongsInZip",
+        "summary": "Download songs as a zip archive",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/ids"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "zip archive",
+            "content": {
+              "application/zip": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/getMetadataOfSongsbyIDs": {
+      "get": {
+        "operationId": "getMetadataOfSongsbyIDs",
+        "summary": "Songs by IDs",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/ids"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "songs in no particular order; songs in the trash are skipped",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/SongInfo"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/getMetadataOfNewSongs": {
+      "get": {
+        "operationId": "getMetadataOfNewSongs",
+        "summary": "Page of songs, newest first by default",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/songSort"
+          },
+          {
+            "$ref": "#/components/parameters/limit"
+          },
+          {
+            "$ref": "#/components/parameters/cursor"
+          },
+          {
+            "$ref": "#/components/parameters/order"
+          },
+          {
+            "$ref": "#/components/parameters/genre"
+          },
+          {
+            "$ref": "#/components/parameters/artist"
+          },
+          {
+            "$ref": "#/components/parameters/minBitrate"
+          },
+          {
+            "$ref": "#/components/parameters/maxBitrate"
+          },
+          {
+            "$ref": "#/components/parameters/minDuration"
+          },
+          {
+            "$ref": "#/components/parameters/maxDuration"
+          },
+          {
+            "$ref": "#/components/parameters/uploadedFrom"
+          },
+          {
+            "$ref": "#/components/parameters/uploadedTo"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "page of songs",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SongPage"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/getMetadataOfPopularSongs": {
+      "get": {
+        "operationId": "getMetadataOfPopularSongs",
+        "summary": "Page of popular songs",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "name": "sort",
+            "in": "query",
+            "required": false,
+            "description": "popularity measure",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "downloads",
+                "plays"
+              ],
+              "default": "downloads"
+            }
+          },
+          {
+            "name": "days",
+            "in": "query",
+            "required": false,
+            "description": "popularity by downloads over the last days (including today); not combined with plays, filters or order=asc",
+            "schema": {
+              "type": "integer",
+              "minimum": 1
+            }
+          },
+          {
+            "$ref": "#/components/parameters/limit"
+          },
+          {
+            "$ref": "#/components/parameters/cursor"
+          },
+          {
+            "$ref": "#/components/parameters/order"
+          },
+          {
+            "$ref": "#/components/parameters/genre"
+          },
+          {
+            "$ref": "#/components/parameters/artist"
+          },
+          {
+            "$ref": "#/components/parameters/minBitrate"
+          },
+          {
+            "$ref": "#/components/parameters/maxBitrate"
+          },
+          {
+            "$ref": "#/components/parameters/minDuration"
+          },
+          {
+            "$ref": "#/components/parameters/maxDuration"
+          },
+          {
+            "$ref": "#/components/parameters/uploadedFrom"
+          },
+          {
+            "$ref": "#/components/parameters/uploadedTo"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "page of songs",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SongPage"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/searchSongs": {
+      "get": {
+        "operationId": "searchSongs",
+        "summary": "Search songs by artist, title or genre",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/searchString"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "found songs",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/SongInfo"
+                  },
+                  "nullable": true
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/addPlaylist": {
+      "post": {
+        "operationId": "addPlaylist",
+        "summary": "Create a playlist",
+        "tags": [
+          "playlists"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "ID of the created playlist",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ObjectID"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "name": {
+                    "type": "string"
+                  },
+                  "ids": {
+                    "type": "string",
+                    "description": "JSON array of song IDs"
+                  }
+                },
+                "required": [
+                  "name",
+                  "ids"
+                ]
+              }
+            }
+          }
+        }
+      }
+    },
+    "/getPlaylists": {
+      "get": {
+        "operationId": "getPlaylists",
+        "summary": "Page of playlists, newest first by default",
+        "tags": [
+          "playlists"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/playlistSort"
+          },
+          {
+            "$ref": "#/components/parameters/limit"
+          },
+          {
+            "$ref": "#/components/parameters/cursor"
+          },
+          {
+            "$ref": "#/components/parameters/order"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "page of playlists",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PlaylistPage"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/searchPlaylists": {
+      "get": {
+        "operationId": "searchPlaylists",
+        "summary": "Search playlists by name",
+        "tags": [
+          "playlists"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/searchString"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "found playlists",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/PlayList"
+                  },
+                  "nullable": true
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/getPlaylistInZip": {
+      "get": {
+        "operationId": "getPlaylistInZip",
+        "summary": "Download a playlist as a zip archive with an m3u8 list",
+        "tags": [
+          "playlists"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/id"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "zip archive",
+            "content": {
+              "application/zip": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/analyzePlaylist": {
+      "post": {
+        "operationId": "analyzePlaylist",
+        "summary": "Queue a playlist for album ReplayGain analysis",
+        "tags": [
+          "playlists"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "queued",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "503": {
+            "description": "the queue is full",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "id": {
+                    "$ref": "#/components/schemas/ObjectID"
+                  }
+                },
+                "required": [
+                  "id"
+                ]
+              }
+            }
+          }
+        }
+      }
+    },
+    "/deleteSong": {
+      "post": {
+        "operationId": "deleteSong",
+        "summary": "Move a song to the trash",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "done",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "405": {
+            "description": "only POST is allowed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "id": {
+                    "$ref": "#/components/schemas/ObjectID"
+                  }
+                },
+                "required": [
+                  "id"
+                ]
+              }
+            }
+          }
+        }
+      }
+    },
+    "/restoreSong": {
+      "post": {
+        "operationId": "restoreSong",
+        "summary": "Restore a song from the trash",
+        "tags": [
+          "songs"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "done",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "405": {
+            "description": "only POST is allowed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "id": {
+                    "$ref": "#/components/schemas/ObjectID"
+                  }
+                },
+                "required": [
+                  "id"
+                ]
+              }
+            }
+          }
+        }
+      }
+    },
+    "/deletePlaylist": {
+      "post": {
+        "operationId": "deletePlaylist",
+        "summary": "Move a playlist to the trash",
+        "tags": [
+          "playlists"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "done",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "405": {
+            "description": "only POST is allowed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "id": {
+                    "$ref": "#/components/schemas/ObjectID"
+                  }
+                },
+                "required": [
+                  "id"
+                ]
+              }
+            }
+          }
+        }
+      }
+    },
+    "/restorePlaylist": {
+      "post": {
+        "operationId": "restorePlaylist",
+        "summary": "Restore a playlist from the trash",
+        "tags": [
+          "playlists"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "done",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "405": {
+            "description": "only POST is allowed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "id": {
+                    "$ref": "#/components/schemas/ObjectID"
+                  }
+                },
+                "required": [
+                  "id"
+                ]
+              }
+            }
+          }
+        }
+      }
+    },
+    "/getTrash": {
+      "get": {
+        "operationId": "getTrash",
+        "summary": "Songs and playlists in the trash that can be restored",
+        "tags": [
+          "maintenance"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "trash contents",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Trash"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/getDuplicateGroups": {
+      "get": {
+        "operationId": "getDuplicateGroups",
+        "summary": "Songs with exact audio copies",
+        "tags": [
+          "maintenance"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "groups",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/DuplicateGroup"
+                  }
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/getScrubReport": {
+      "get": {
+        "operationId": "getScrubReport",
+        "summary": "Report of the last storage check",
+        "tags": [
+          "maintenance"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "report",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ScrubReport"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          }
+        }
+      }
+    },
+    "/getCharts": {
+      "get": {
+        "operationId": "getCharts",
+        "summary": "Precomputed download chart",
+        "tags": [
+          "stats"
+        ],
+        "parameters": [
+          {
+            "name": "window",
+            "in": "query",
+            "required": false,
+            "description": "time window",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "24h",
+                "7d",
+                "30d",
+                "trending"
+              ],
+              "default": "7d"
+            }
+          },
+          {
+            "name": "by",
+            "in": "query",
+            "required": false,
+            "description": "grouping",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "song",
+                "artist",
+                "genre"
+              ],
+              "default": "song"
+            }
+          },
+          {
+            "name": "genre",
+            "in": "query",
+            "required": false,
+            "description": "only songs of this genre",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/limit"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "chart",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Chart"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "description": "charts are not computed yet",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/reportPlay": {
+      "post": {
+        "operationId": "reportPlay",
+        "summary": "Report a play of a song",
+        "tags": [
+          "stats"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "the play is saved; it is counted from 30 seconds or when completed",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "405": {
+            "description": "only POST is allowed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "id": {
+                    "$ref": "#/components/schemas/ObjectID"
+                  },
+                  "client": {
+                    "type": "string",
+                    "description": "client identifier"
+                  },
+                  "position": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "description": "seconds played"
+                  },
+                  "completed": {
+                    "type": "boolean"
+                  }
+                },
+                "required": [
+                  "id",
+                  "client",
+                  "position"
+                ]
+              }
+            }
+          }
+        }
+      }
+    },
+    "/api/v2/songs": {
+      "get": {
+        "operationId": "listSongs",
+        "summary": "Page of songs, newest first by default",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/songSort"
+          },
+          {
+            "$ref": "#/components/parameters/limit"
+          },
+          {
+            "$ref": "#/components/parameters/cursor"
+          },
+          {
+            "$ref": "#/components/parameters/order"
+          },
+          {
+            "$ref": "#/components/parameters/genre"
+          },
+          {
+            "$ref": "#/components/parameters/artist"
+          },
+          {
+            "$ref": "#/components/parameters/minBitrate"
+          },
+          {
+            "$ref": "#/components/parameters/maxBitrate"
+          },
+          {
+            "$ref": "#/components/parameters/minDuration"
+          },
+          {
+            "$ref": "#/components/parameters/maxDuration"
+          },
+          {
+            "$ref": "#/components/parameters/uploadedFrom"
+          },
+          {
+            "$ref": "#/components/parameters/uploadedTo"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "page of songs",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SongPage"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "post": {
+        "operationId": "createSong",
+        "summary": "Upload a song",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "201": {
+            "description": "the song is added, Location points to it",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SongInfo"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "409": {
+            "description": "the song is already in the system, Location points to it",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "413": {
+            "description": "the file is too large",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "415": {
+            "description": "not multipart/form-data or unsupported format",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "multipart/form-data": {
+              "schema": {
+                "$ref": "#/components/schemas/SongUpload"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/api/v2/songs/{id}": {
+      "get": {
+        "operationId": "getSongV2",
+        "summary": "Song metadata",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/pathID"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "song",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SongInfo"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "patch": {
+        "operationId": "patchSong",
+        "summary": "Change song metadata (file tags are not changed)",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/pathID"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "changed song",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SongInfo"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "415": {
+            "description": "not application/json",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/SongPatch"
+              }
+            }
+          }
+        }
+      },
+      "delete": {
+        "operationId": "deleteSongV2",
+        "summary": "Move a song to the trash",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/pathID"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "moved"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/api/v2/songs/{id}/stream": {
+      "get": {
+        "operationId": "streamSong",
+        "summary": "Song file",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/pathID"
+          },
+          {
+            "name": "download",
+            "in": "query",
+            "required": false,
+            "description": "true - the delivered file counts as a download",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "true",
+                "false"
+              ]
+            }
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "song file, range requests are supported",
+            "content": {
+              "application/octet-stream": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "206": {
+            "description": "part of the file",
+            "content": {
+              "application/octet-stream": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/api/v2/playlists": {
+      "get": {
+        "operationId": "listPlaylists",
+        "summary": "Page of playlists, newest first by default",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/playlistSort"
+          },
+          {
+            "$ref": "#/components/parameters/limit"
+          },
+          {
+            "$ref": "#/components/parameters/cursor"
+          },
+          {
+            "$ref": "#/components/parameters/order"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "page of playlists",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PlaylistPage"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "post": {
+        "operationId": "createPlaylist",
+        "summary": "Create a playlist",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "201": {
+            "description": "the playlist is created, Location points to it",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PlayList"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "415": {
+            "description": "not application/json",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/PlaylistBody"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/api/v2/playlists/{id}": {
+      "get": {
+        "operationId": "getPlaylist",
+        "summary": "Playlist",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/pathID"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "playlist",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PlayList"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "patch": {
+        "operationId": "patchPlaylist",
+        "summary": "Rename a playlist or replace its songs",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/pathID"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "changed playlist",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PlayList"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "415": {
+            "description": "not application/json",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/PlaylistBody"
+              }
+            }
+          }
+        }
+      },
+      "delete": {
+        "operationId": "deletePlaylistV2",
+        "summary": "Move a playlist to the trash",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/pathID"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "moved"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/api/v2/playlists/{id}/zip": {
+      "get": {
+        "operationId": "playlistZip",
+        "summary": "Download a playlist as a zip archive with an m3u8 list",
+        "tags": [
+          "v2"
+        ],
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/pathID"
+          },
+          {
+            "$ref": "#/components/parameters/lang"
+          },
+          {
+            "$ref": "#/components/parameters/errorFormat"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "zip archive",
+            "content": {
+              "application/zip": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "getOpenAPI",
+        "summary": "This document",
+        "tags": [
+          "maintenance"
+        ],
+        "responses": {
+          "200": {
+            "description": "OpenAPI 3 document",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "ObjectID": {
+        "type": "string",
+        "pattern": "^[0-9a-f]{24}$",
+        "description": "MongoDB ObjectId in hex",
+        "example": "5a1f3c2e9d1b2c0012345678"
+      },
+      "SongInfo": {
+        "type": "object",
+        "required": [
+          "id",
+          "FileName",
+          "Title",
+          "Artist",
+          "Genre",
+          "Album",
+          "AlbumArtist",
+          "Bitrate",
+          "Duration",
+          "CountOfDownload",
+          "CountOfPlays",
+          "Size",
+          "UploadDate"
+        ],
+        "properties": {
+          "id": {
+            "$ref": "#/components/schemas/ObjectID"
+          },
+          "FileName": {
+            "type": "string",
+            "description": "original file name"
+          },
+          "Title": {
+            "type": "string"
+          },
+          "Artist": {
+            "type": "string"
+          },
+          "Genre": {
+            "type": "string"
+          },
+          "Album": {
+            "type": "string"
+          },
+          "AlbumArtist": {
+            "type": "string"
+          },
+          "Bitrate": {
+            "type": "integer",
+            "description": "kilobits per second"
+          },
+          "Duration": {
+            "type": "integer",
+            "description": "seconds"
+          },
+          "CountOfDownload": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "CountOfPlays": {
+            "type": "integer",
+            "format": "int64",
+            "description": "counted plays, see /reportPlay"
+          },
+          "Size": {
+            "type": "integer",
+            "description": "bytes"
+          },
+          "UploadDate": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Loudness": {
+            "type": "number",
+            "description": "EBU R128 integrated loudness, LUFS"
+          },
+          "TruePeak": {
+            "type": "number",
+            "description": "dBTP"
+          },
+          "TrackGain": {
+            "type": "number",
+            "description": "ReplayGain, dB"
+          },
+          "TrackPeak": {
+            "type": "number"
+          },
+          "IsAnalyzed": {
+            "type": "boolean"
+          },
+          "AlbumGain": {
+            "type": "number",
+            "description": "album or playlist ReplayGain, dB"
+          },
+          "AlbumPeak": {
+            "type": "number"
+          },
+          "IsAlbumAnalyzed": {
+            "type": "boolean"
+          },
+          "PayloadHash": {
+            "type": "string",
+            "description": "SHA-256 of audio data without tags"
+          },
+          "DuplicateOf": {
+            "$ref": "#/components/schemas/ObjectID"
+          },
+          "Blob": {
+            "type": "string",
+            "description": "SHA-256 of the stored file"
+          },
+          "DeletedAt": {
+            "type": "string",
+            "format": "date-time",
+            "description": "set for songs in the trash"
+          }
+        }
+      },
+      "PlayList": {
+        "type": "object",
+        "required": [
+          "id",
+          "Name",
+          "IDs"
+        ],
+        "properties": {
+          "id": {
+            "$ref": "#/components/schemas/ObjectID"
+          },
+          "Name": {
+            "type": "string"
+          },
+          "IDs": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ObjectID"
+            },
+            "description": "song IDs in playlist order"
+          },
+          "DeletedAt": {
+            "type": "string",
+            "format": "date-time",
+            "description": "set for playlists in the trash"
+          }
+        }
+      },
+      "SongPage": {
+        "type": "object",
+        "required": [
+          "items",
+          "nextCursor"
+        ],
+        "properties": {
+          "items": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/SongInfo"
+            }
+          },
+          "nextCursor": {
+            "type": "string",
+            "description": "cursor of the next page, empty on the last page"
+          }
+        }
+      },
+      "PlaylistPage": {
+        "type": "object",
+        "required": [
+          "items",
+          "nextCursor"
+        ],
+        "properties": {
+          "items": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/PlayList"
+            }
+          },
+          "nextCursor": {
+            "type": "string",
+            "description": "cursor of the next page, empty on the last page"
+          }
+        }
+      },
+      "Error": {
+        "type": "object",
+        "required": [
+          "code",
+          "message",
+          "requestId"
+        ],
+        "properties": {
+          "code": {
+            "type": "string",
+            "description": "stable error code",
+            "enum": [
+              "internal_error",
+              "missing_parameter",
+              "missing_fields",
+              "invalid_parameter",
+              "invalid_id",
+              "invalid_ids",
+              "invalid_json",
+              "method_not_allowed",
+              "unsupported_media_type",
+              "resource_not_found",
+              "song_not_found",
+              "songs_not_found",
+              "song_file_not_found",
+              "song_not_in_trash",
+              "playlist_not_found",
+              "playlist_not_in_trash",
+              "waveform_not_found",
+              "no_upload_file",
+              "file_too_large",
+              "unsupported_format",
+              "duplicate_song",
+              "queue_full",
+              "scrub_report_not_ready",
+              "charts_not_ready"
+            ]
+          },
+          "message": {
+            "type": "string",
+            "description": "localized message for the user"
+          },
+          "details": {
+            "type": "object",
+            "additionalProperties": true,
+            "properties": {
+              "reason": {
+                "type": "string",
+                "description": "stable code that refines the error code",
+                "enum": [
+                  "days_require_sort_downloads",
+                  "days_incompatible_with_filters",
+                  "cursor_mismatch"
+                ]
+              }
+            }
+          },
+          "requestId": {
+            "type": "string",
+            "description": "also returned in the X-Request-ID header"
+          }
+        }
+      },
+      "Trash": {
+        "type": "object",
+        "properties": {
+          "Songs": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/SongInfo"
+            }
+          },
+          "Playlists": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/PlayList"
+            }
+          },
+          "Retention": {
+            "type": "integer",
+            "description": "days"
+          }
+        }
+      },
+      "Chart": {
+        "type": "object",
+        "properties": {
+          "Window": {
+            "type": "string"
+          },
+          "By": {
+            "type": "string"
+          },
+          "Genre": {
+            "type": "string"
+          },
+          "Computed": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Entries": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "Position": {
+                  "type": "integer"
+                },
+                "Name": {
+                  "type": "string"
+                },
+                "Song": {
+                  "$ref": "#/components/schemas/SongInfo"
+                },
+                "Downloads": {
+                  "type": "integer",
+                  "format": "int64"
+                },
+                "Score": {
+                  "type": "number"
+                }
+              }
+            }
+          }
+        }
+      },
+      "DuplicateGroup": {
+        "type": "object",
+        "properties": {
+          "Original": {
+            "$ref": "#/components/schemas/ObjectID"
+          },
+          "Duplicates": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ObjectID"
+            }
+          }
+        }
+      },
+      "Waveform": {
+        "type": "object",
+        "description": "audiowaveform JSON format",
+        "properties": {
+          "version": {
+            "type": "integer"
+          },
+          "channels": {
+            "type": "integer"
+          },
+          "sample_rate": {
+            "type": "integer"
+          },
+          "samples_per_pixel": {
+            "type": "integer"
+          },
+          "bits": {
+            "type": "integer"
+          },
+          "length": {
+            "type": "integer"
+          },
+          "data": {
+            "type": "array",
+            "items": {
+              "type": "integer"
+            }
+          }
+        }
+      },
+      "ScrubReport": {
+        "type": "object",
+        "properties": {
+          "Started": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Finished": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "Mode": {
+            "type": "string"
+          },
+          "CheckedSongs": {
+            "type": "integer"
+          },
+          "CheckedFiles": {
+            "type": "integer"
+          },
+          "MissingFiles": {
+            "type": "array",
+            "items": {
+              "type": "object"
+            }
+          },
+          "OrphanFiles": {
+            "type": "array",
+            "items": {
+              "type": "object"
+            }
+          },
+          "SizeMismatches": {
+            "type": "array",
+            "items": {
+              "type": "object"
+            }
+          },
+          "RefCountMismatches": {
+            "type": "array",
+            "items": {
+              "type": "object"
+            }
+          },
+          "Errors": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "SongPatch": {
+        "type": "object",
+        "additionalProperties": false,
+        "description": "absent fields are not changed",
+        "properties": {
+          "Title": {
+            "type": "string"
+          },
+          "Artist": {
+            "type": "string"
+          },
+          "Genre": {
+            "type": "string"
+          },
+          "Album": {
+            "type": "string"
+          },
+          "AlbumArtist": {
+            "type": "string"
+          }
+        }
+      },
+      "PlaylistBody": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "Name": {
+            "type": "string"
+          },
+          "IDs": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ObjectID"
+            }
+          }
+        }
+      },
+      "SongUpload": {
+        "type": "object",
+        "required": [
+          "file"
+        ],
+        "properties": {
+          "file": {
+            "type": "string",
+            "format": "binary",
+            "description": "mp3, flac or wav"
+          }
+        }
+      }
+    },
+    "parameters": {
+      "lang": {
+        "name": "lang",
+        "in": "query",
+        "required": false,
+        "description": "language of messages, overrides Accept-Language",
+        "schema": {
+          "type": "string",
+          "enum": [
+            "ru",
+            "en"
+          ]
+        }
+      },
+      "errorFormat": {
+        "name": "errorFormat",
+        "in": "query",
+        "required": false,
+        "description": "error response format, also set by the X-Error-Format header",
+        "schema": {
+          "type": "string",
+          "enum": [
+            "json",
+            "text"
+          ]
+        }
+      },
+      "id": {
+        "name": "id",
+        "in": "query",
+        "required": true,
+        "description": "ID of the resource",
+        "schema": {
+          "$ref": "#/components/schemas/ObjectID"
+        }
+      },
+      "pathID": {
+        "name": "id",
+        "in": "path",
+        "required": true,
+        "description": "ID of the resource",
+        "schema": {
+          "$ref": "#/components/schemas/ObjectID"
+        }
+      },
+      "ids": {
+        "name": "ids",
+        "in": "query",
+        "required": true,
+        "description": "JSON array of IDs, for example [\"5a1f3c2e9d1b2c0012345678\"]",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "limit": {
+        "name": "limit",
+        "in": "query",
+        "required": false,
+        "description": "page size (count is accepted too); 0 or absent - default size, values above the maximum are reduced to it",
+        "schema": {
+          "type": "integer",
+          "minimum": 0
+        }
+      },
+      "cursor": {
+        "name": "cursor",
+        "in": "query",
+        "required": false,
+        "description": "nextCursor of the previous page; must be used with the same sort and order",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "order": {
+        "name": "order",
+        "in": "query",
+        "required": false,
+        "description": "sort direction, the default depends on the sort field",
+        "schema": {
+          "type": "string",
+          "enum": [
+            "asc",
+            "desc"
+          ]
+        }
+      },
+      "songSort": {
+        "name": "sort",
+        "in": "query",
+        "required": false,
+        "description": "sort field",
+        "schema": {
+          "type": "string",
+          "enum": [
+            "uploadDate",
+            "downloads",
+            "plays",
+            "title",
+            "artist",
+            "bitrate",
+            "duration"
+          ],
+          "default": "uploadDate"
+        }
+      },
+      "playlistSort": {
+        "name": "sort",
+        "in": "query",
+        "required": false,
+        "description": "sort field",
+        "schema": {
+          "type": "string",
+          "enum": [
+            "created",
+            "name"
+          ],
+          "default": "created"
+        }
+      },
+      "genre": {
+        "name": "genre",
+        "in": "query",
+        "required": false,
+        "description": "exact genre",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "artist": {
+        "name": "artist",
+        "in": "query",
+        "required": false,
+        "description": "exact artist",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "minBitrate": {
+        "name": "minBitrate",
+        "in": "query",
+        "required": false,
+        "description": "minimum bitrate, kbit/s",
+        "schema": {
+          "type": "integer",
+          "minimum": 0
+        }
+      },
+      "maxBitrate": {
+        "name": "maxBitrate",
+        "in": "query",
+        "required": false,
+        "description": "maximum bitrate, kbit/s",
+        "schema": {
+          "type": "integer",
+          "minimum": 0
+        }
+      },
+      "minDuration": {
+        "name": "minDuration",
+        "in": "query",
+        "required": false,
+        "description": "minimum duration, seconds",
+        "schema": {
+          "type": "integer",
+          "minimum": 0
+        }
+      },
+      "maxDuration": {
+        "name": "maxDuration",
+        "in": "query",
+        "required": false,
+        "description": "maximum duration, seconds",
+        "schema": {
+          "type": "integer",
+          "minimum": 0
+        }
+      },
+      "uploadedFrom": {
+        "name": "uploadedFrom",
+        "in": "query",
+        "required": false,
+        "description": "uploaded at or after, RFC 3339 or YYYY-MM-DD",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "uploadedTo": {
+        "name": "uploadedTo",
+        "in": "query",
+        "required": false,
+        "description": "uploaded before, RFC 3339 or YYYY-MM-DD",
+        "schema": {
+          "type": "string"
+        }
+      },
+      "searchString": {
+        "name": "searchString",
+        "in": "query",
+        "required": false,
+        "description": "words to search for; an empty string returns null",
+        "schema": {
+          "type": "string"
+        }
+      }
+    },
+    "responses": {
+      "BadRequest": {
+        "description": "invalid request",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "NotFound": {
+        "description": "resource not found",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "InternalError": {
+        "description": "server error",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
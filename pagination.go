@@ -0,0 +1,569 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Постраничная отдача списков песен и плейлистов. Следующая страница начинается после последней
+// записи предыдущей, а не через смещение, поэтому добавление и удаление записей между запросами
+// не приводит к пропускам и повторам. Позиция передается клиенту непрозрачным курсором.
+
+// Поля сортировки песен. По каждому полю вместе с ID есть индекс в MongoDB и SQLite.
+const (
+	sortUploadDate = "uploadDate"
+	sortDownloads  = "downloads"
+	sortPlays      = "plays"
+	sortTitle      = "title"
+	sortArtist     = "artist"
+	sortBitrate    = "bitrate"
+	sortDuration   = "duration"
+)
+
+// Поля сортировки плейлистов
+const (
+	sortCreated = "created" // по ID: ObjectId начинается со времени создания
+	sortName    = "name"
+)
+
+// songSortFields - поля сортировки песен и порядок по умолчанию: true - по убыванию
+var songSortFields = map[string]bool{
+	sortUploadDate: true,
+	sortDownloads:  true,
+	sortPlays:      true,
+	sortTitle:      false,
+	sortArtist:     false,
+	sortBitrate:    true,
+	sortDuration:   false,
+}
+
+// popularSortFields - поля сортировки популярных песен
+var popularSortFields = map[string]bool{
+	sortDownloads: true,
+	sortPlays:     true,
+}
+
+// playlistSortFields - поля сортировки плейлистов и порядок по умолчанию: true - по убыванию
+var playlistSortFields = map[string]bool{
+	sortCreated: true,
+	sortName:    false,
+}
+
+// errInvalidCursor - курсор поврежден или получен для другой сортировки
+var errInvalidCursor = errors.New("invalid cursor")
+
+// SongQuery - условия выборки страницы песен. Нулевые значения фильтров не ограничивают выборку.
+type SongQuery struct {
+	Sort         string      // поле сортировки из songSortFields
+	Descending   bool        // сортировка по убыванию
+	Genre        string      // жанр
+	Artist       string      // исполнитель
+	MinBitrate   int         // минимальный битрейт в килобитах в секунду
+	MaxBitrate   int         // максимальный битрейт
+	MinDuration  int         // минимальная продолжительность в секундах
+	MaxDuration  int         // максимальная продолжительность
+	UploadedFrom time.Time   // загружена не раньше
+	UploadedTo   time.Time   // загружена раньше
+	After        *pageCursor // страница начинается после этой позиции, nil - первая страница
+	Limit        int         // сколько песен вернуть, 0 - без ограничения
+}
+
+// PlaylistQuery - условия выборки страницы плейлистов
+type PlaylistQuery struct {
+	Sort       string      // поле сортировки из playlistSortFields
+	Descending bool        // сортировка по убыванию
+	After      *pageCursor // страница начинается после этой позиции, nil - первая страница
+	Limit      int         // сколько плейлистов вернуть, 0 - без ограничения
+}
+
+// pageCursor - позиция последней записи отданной страницы. При равных значениях поля сортировки
+// записи упорядочиваются по ID в том же направлении, поэтому позиция однозначна.
+// Популярные за последние дни песни считаются по статистике, а не по полю песни,
+// поэтому для них в курсоре хранится смещение.
+type pageCursor struct {
+	Sort       string             `json:"s"`
+	Descending bool               `json:"d,omitempty"`
+	Value      interface{}        `json:"v,omitempty"` // значение поля сортировки
+	ID         primitive.ObjectID `json:"id"`
+	Days       int                `json:"w,omitempty"` // за сколько дней считается популярность
+	Offset     int                `json:"o,omitempty"` // сколько песен, популярных за Days дней, уже отдано
+}
+
+// encodeCursor - курсор в виде строки для клиента
+func encodeCursor(cursor *pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor - курсор из строки, полученной от клиента. Значение поля сортировки
+// приводится к тому типу, в котором оно хранится в SongInfo или PlayList.
+func decodeCursor(s string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var cursor pageCursor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&cursor)
+	if err != nil || cursor.Offset < 0 || cursor.Days < 0 {
+		return nil, errInvalidCursor
+	}
+
+	if cursor.Days != 0 {
+		cursor.Value = nil
+		return &cursor, nil
+	}
+
+	switch cursor.Sort {
+	case sortUploadDate:
+		s, _ := cursor.Value.(string)
+		cursor.Value, err = time.Parse(time.RFC3339Nano, s)
+	case sortDownloads, sortPlays:
+		number, _ := cursor.Value.(json.Number)
+		cursor.Value, err = number.Int64()
+	case sortBitrate, sortDuration:
+		number, _ := cursor.Value.(json.Number)
+		var value int64
+		value, err = number.Int64()
+		cursor.Value = int(value)
+	case sortTitle, sortArtist, sortName:
+		_, ok := cursor.Value.(string)
+		if !ok {
+			err = errInvalidCursor
+		}
+	case sortCreated:
+		cursor.Value = nil
+	default:
+		err = errInvalidCursor
+	}
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// songSortValue - значение поля сортировки песни
+func songSortValue(song *SongInfo, field string) interface{} {
+	switch field {
+	case sortUploadDate:
+		return song.UploadDate
+	case sortDownloads:
+		return song.CountOfDownload
+	case sortPlays:
+		return song.CountOfPlays
+	case sortTitle:
+		return song.Title
+	case sortArtist:
+		return song.Artist
+	case sortBitrate:
+		return song.Bitrate
+	case sortDuration:
+		return song.Duration
+	}
+
+	return nil
+}
+
+// playlistSortValue - значение поля сортировки плейлиста. Для sortCreated значения нет:
+// плейлисты сортируются только по ID.
+func playlistSortValue(playList *PlayList, field string) interface{} {
+	if field == sortName {
+		return playList.Name
+	}
+
+	return nil
+}
+
+// compareSortValues - сравнивает значения поля сортировки одного типа: -1, 0 или 1
+func compareSortValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		b := b.(time.Time)
+		if a.Before(b) {
+			return -1
+		}
+		if a.After(b) {
+			return 1
+		}
+	case int64:
+		b := b.(int64)
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+	case int:
+		b := b.(int)
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+
+	return 0
+}
+
+// comparePosition - сравнивает позиции записей (значение поля сортировки, ID) с учетом направления
+// сортировки: отрицательное значение - первая запись идет раньше
+func comparePosition(valueA interface{}, idA primitive.ObjectID, valueB interface{}, idB primitive.ObjectID, descending bool) int {
+	result := compareSortValues(valueA, valueB)
+	if result == 0 {
+		result = bytes.Compare(idA[:], idB[:])
+	}
+	if descending {
+		result = -result
+	}
+
+	return result
+}
+
+// matches - подходит ли песня под фильтры запроса
+func (query *SongQuery) matches(song *SongInfo) bool {
+	return (query.Genre == "" || song.Genre == query.Genre) &&
+		(query.Artist == "" || song.Artist == query.Artist) &&
+		(query.MinBitrate == 0 || song.Bitrate >= query.MinBitrate) &&
+		(query.MaxBitrate == 0 || song.Bitrate <= query.MaxBitrate) &&
+		(query.MinDuration == 0 || song.Duration >= query.MinDuration) &&
+		(query.MaxDuration == 0 || song.Duration <= query.MaxDuration) &&
+		(query.UploadedFrom.IsZero() || !song.UploadDate.Before(query.UploadedFrom)) &&
+		(query.UploadedTo.IsZero() || song.UploadDate.Before(query.UploadedTo))
+}
+
+// filtered - задан ли хотя бы один фильтр
+func (query *SongQuery) filtered() bool {
+	return query.Genre != "" || query.Artist != "" || query.MinBitrate != 0 || query.MaxBitrate != 0 ||
+		query.MinDuration != 0 || query.MaxDuration != 0 || !query.UploadedFrom.IsZero() || !query.UploadedTo.IsZero()
+}
+
+// page - песни, подходящие под запрос, в порядке сортировки. Используется хранилищами,
+// которые не умеют сортировать сами.
+func (query *SongQuery) page(songs []SongInfo) []SongInfo {
+	var result []SongInfo
+	for i := range songs {
+		if !query.matches(&songs[i]) {
+			continue
+		}
+		if query.After != nil && comparePosition(songSortValue(&songs[i], query.Sort), songs[i].ID,
+			query.After.Value, query.After.ID, query.Descending) <= 0 {
+			continue
+		}
+		result = append(result, songs[i])
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return comparePosition(songSortValue(&result[i], query.Sort), result[i].ID,
+			songSortValue(&result[j], query.Sort), result[j].ID, query.Descending) < 0
+	})
+	return result[:limit(query.Limit, len(result))]
+}
+
+// page - плейлисты после позиции запроса в порядке сортировки. Используется хранилищами,
+// которые не умеют сортировать сами.
+func (query *PlaylistQuery) page(playLists []PlayList) []PlayList {
+	var result []PlayList
+	for i := range playLists {
+		if query.After != nil && comparePosition(playlistSortValue(&playLists[i], query.Sort), playLists[i].ID,
+			query.After.Value, query.After.ID, query.Descending) <= 0 {
+			continue
+		}
+		result = append(result, playLists[i])
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return comparePosition(playlistSortValue(&result[i], query.Sort), result[i].ID,
+			playlistSortValue(&result[j], query.Sort), result[j].ID, query.Descending) < 0
+	})
+	return result[:limit(query.Limit, len(result))]
+}
+
+// songPage - страница списка песен
+type songPage struct {
+	Items      []SongInfo `json:"items"`
+	NextCursor string     `json:"nextCursor"` // курсор следующей страницы, пустой на последней странице
+}
+
+// playlistPage - страница списка плейлистов
+type playlistPage struct {
+	Items      []PlayList `json:"items"`
+	NextCursor string     `json:"nextCursor"` // курсор следующей страницы, пустой на последней странице
+}
+
+// listSongs - страница песен по запросу. Песен запрашивается на одну больше размера страницы:
+// лишняя песня показывает, что есть следующая страница.
+func listSongs(ctx context.Context, songs SongRepository, query *SongQuery) (*songPage, error) {
+	limit := query.Limit
+	query.Limit++
+	result, err := songs.List(ctx, query)
+	query.Limit = limit
+	if err != nil {
+		return nil, err
+	}
+
+	page := &songPage{Items: []SongInfo{}}
+	if len(result) > limit {
+		result = result[:limit]
+		last := &result[limit-1]
+		page.NextCursor = encodeCursor(&pageCursor{
+			Sort:       query.Sort,
+			Descending: query.Descending,
+			Value:      songSortValue(last, query.Sort),
+			ID:         last.ID,
+		})
+	}
+	page.Items = append(page.Items, result...)
+
+	return page, nil
+}
+
+// listPlaylists - страница плейлистов по запросу, см. listSongs
+func listPlaylists(ctx context.Context, playlists PlaylistRepository, query *PlaylistQuery) (*playlistPage, error) {
+	limit := query.Limit
+	query.Limit++
+	result, err := playlists.List(ctx, query)
+	query.Limit = limit
+	if err != nil {
+		return nil, err
+	}
+
+	page := &playlistPage{Items: []PlayList{}}
+	if len(result) > limit {
+		result = result[:limit]
+		last := &result[limit-1]
+		page.NextCursor = encodeCursor(&pageCursor{
+			Sort:       query.Sort,
+			Descending: query.Descending,
+			Value:      playlistSortValue(last, query.Sort),
+			ID:         last.ID,
+		})
+	}
+	page.Items = append(page.Items, result...)
+
+	return page, nil
+}
+
+// parseSongQuery - условия выборки страницы песен из параметров запроса: sort, order (asc или desc),
+// limit, cursor и фильтры genre, artist, minBitrate, maxBitrate, minDuration, maxDuration,
+// uploadedFrom и uploadedTo (RFC 3339 или ГГГГ-ММ-ДД). Курсор должен быть получен для той же
+// сортировки и того же days (см. popularSince). Если параметр некорректен, то отвечает ошибкой.
+func parseSongQuery(w http.ResponseWriter, r *http.Request, sortFields map[string]bool, defaultSort string, days int) (*SongQuery, bool) {
+	query := &SongQuery{
+		Genre:  r.FormValue("genre"),
+		Artist: r.FormValue("artist"),
+	}
+
+	var ok bool
+	query.Sort, query.Descending, ok = parseSort(w, r, sortFields, defaultSort)
+	if !ok {
+		return nil, false
+	}
+
+	query.Limit, ok = parsePageLimit(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{
+		{"minBitrate", &query.MinBitrate},
+		{"maxBitrate", &query.MaxBitrate},
+		{"minDuration", &query.MinDuration},
+		{"maxDuration", &query.MaxDuration},
+	} {
+		if !parseIntParameter(w, r, param.name, param.value) {
+			return nil, false
+		}
+	}
+
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"uploadedFrom", &query.UploadedFrom},
+		{"uploadedTo", &query.UploadedTo},
+	} {
+		if !parseTimeParameter(w, r, param.name, param.value) {
+			return nil, false
+		}
+	}
+
+	query.After, ok = parseCursor(w, r, query.Sort, query.Descending, days)
+	if !ok {
+		return nil, false
+	}
+
+	return query, true
+}
+
+// parsePlaylistQuery - условия выборки страницы плейлистов из параметров sort, order, limit и cursor
+func parsePlaylistQuery(w http.ResponseWriter, r *http.Request) (*PlaylistQuery, bool) {
+	query := &PlaylistQuery{}
+
+	var ok bool
+	query.Sort, query.Descending, ok = parseSort(w, r, playlistSortFields, sortCreated)
+	if !ok {
+		return nil, false
+	}
+
+	query.Limit, ok = parsePageLimit(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	query.After, ok = parseCursor(w, r, query.Sort, query.Descending, 0)
+	if !ok {
+		return nil, false
+	}
+
+	return query, true
+}
+
+// parseSort - поле сортировки из параметра sort и направление из параметра order
+func parseSort(w http.ResponseWriter, r *http.Request, sortFields map[string]bool, defaultSort string) (string, bool, bool) {
+	field := r.FormValue("sort")
+	if field == "" {
+		field = defaultSort
+	}
+
+	descending, ok := sortFields[field]
+	if !ok {
+		allowed := make([]string, 0, len(sortFields))
+		for name := range sortFields {
+			allowed = append(allowed, name)
+		}
+		sort.Strings(allowed)
+
+		invalidPageParameter(w, r, "sort", field, errorDetails{"allowed": allowed})
+		return "", false, false
+	}
+
+	switch order := r.FormValue("order"); order {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		invalidPageParameter(w, r, "order", order, errorDetails{"allowed": []string{"asc", "desc"}})
+		return "", false, false
+	}
+
+	return field, descending, true
+}
+
+// parsePageLimit - размер страницы из параметра limit (или count, как раньше).
+// Без параметра или при 0 - defaultPageSize, больше maxPageSize не бывает.
+func parsePageLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	name := "limit"
+	strLimit := r.FormValue(name)
+	if strLimit == "" {
+		name = "count"
+		strLimit = r.FormValue(name)
+	}
+	if strLimit == "" {
+		return defaultPageSize, true
+	}
+
+	limit, err := strconv.Atoi(strLimit)
+	if err != nil || limit < 0 {
+		invalidPageParameter(w, r, name, strLimit, nil)
+		return 0, false
+	}
+
+	if limit == 0 {
+		return defaultPageSize, true
+	}
+	if limit > maxPageSize {
+		return maxPageSize, true
+	}
+
+	return limit, true
+}
+
+// parseIntParameter - неотрицательное число из параметра name, без параметра value не изменяется
+func parseIntParameter(w http.ResponseWriter, r *http.Request, name string, value *int) bool {
+	str := r.FormValue(name)
+	if str == "" {
+		return true
+	}
+
+	number, err := strconv.Atoi(str)
+	if err != nil || number < 0 {
+		invalidPageParameter(w, r, name, str, nil)
+		return false
+	}
+
+	*value = number
+	return true
+}
+
+// parseTimeParameter - время из параметра name в формате RFC 3339 или дата ГГГГ-ММ-ДД (начало суток в UTC),
+// без параметра value не изменяется
+func parseTimeParameter(w http.ResponseWriter, r *http.Request, name string, value *time.Time) bool {
+	str := r.FormValue(name)
+	if str == "" {
+		return true
+	}
+
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", str)
+	}
+	if err != nil {
+		invalidPageParameter(w, r, name, str, nil)
+		return false
+	}
+
+	*value = t.UTC()
+	return true
+}
+
+// parseCursor - курсор из параметра cursor, nil - первая страница
+func parseCursor(w http.ResponseWriter, r *http.Request, field string, descending bool, days int) (*pageCursor, bool) {
+	str := r.FormValue("cursor")
+	if str == "" {
+		return nil, true
+	}
+
+	cursor, err := decodeCursor(str)
+	if err == nil && (cursor.Sort != field || cursor.Descending != descending || cursor.Days != days) {
+		err = errInvalidCursor
+	}
+	if err != nil {
+		invalidPageParameter(w, r, "cursor", str, errorDetails{"reason": reasonCursorMismatch})
+		return nil, false
+	}
+
+	return cursor, true
+}
+
+// invalidPageParameter - отвечает ошибкой о некорректном параметре выборки страницы
+func invalidPageParameter(w http.ResponseWriter, r *http.Request, name, value string, details errorDetails) {
+	i18n.Info("page.invalid_parameter", name, value)
+	if details == nil {
+		details = errorDetails{}
+	}
+	details["parameter"] = name
+
+	writeError(w, r, http.StatusBadRequest, codeInvalidParameter, details)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/STEJLS/AudioServer/flac"
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/mp3"
+	"github.com/STEJLS/AudioServer/storage"
+	"github.com/STEJLS/AudioServer/wav"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Размеры тэгов, которые могут находиться в конце файла после аудиоданных
+const (
+	id3v1TagSize     = 128
+	apeTagFooterSize = 32
+)
+
+// duplicateGroup - песня и ее точные копии (совпадают аудиоданные, но не тэги)
+type duplicateGroup struct {
+	Original   primitive.ObjectID   `json:"Original" bson:"_id"`
+	Duplicates []primitive.ObjectID `json:"Duplicates" bson:"Duplicates"`
+}
+
+// payloadHash - вычисляет SHA-256 аудиоданных песни без тэгов ID3v1, ID3v2, APE
+// и блоков метаданных flac, поэтому у файлов, отличающихся только тэгами, он совпадает.
+// Ошибки только логируются (возвращается пустая строка).
+func payloadHash(readSeeker io.ReadSeeker, ext string) string {
+	start, end, err := audioPayload(readSeeker, ext)
+	if err != nil {
+		i18n.Error("payload_hash.error", err)
+		return ""
+	}
+
+	_, err = readSeeker.Seek(start, os.SEEK_SET)
+	if err != nil {
+		i18n.Error("payload_hash.seek_error", err)
+		return ""
+	}
+
+	hash := sha256.New()
+	_, err = io.CopyN(hash, readSeeker, end-start)
+	if err != nil {
+		i18n.Error("payload_hash.read_error", err)
+		return ""
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// audioPayload - находит границы аудиоданных в файле с расширением ext
+func audioPayload(readSeeker io.ReadSeeker, ext string) (start, end int64, err error) {
+	fileSize, err := readSeeker.Seek(0, os.SEEK_END)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch strings.ToLower(ext) {
+	case ".mp3":
+		start, err = mp3.AudioOffset(readSeeker)
+		if err != nil {
+			return 0, 0, err
+		}
+		end, err = trailingTagsOffset(readSeeker, start, fileSize)
+	case ".flac":
+		start, err = flac.AudioOffset(readSeeker)
+		if err != nil {
+			return 0, 0, err
+		}
+		end, err = trailingTagsOffset(readSeeker, start, fileSize)
+	case ".wav":
+		var size int64
+		start, size, err = wav.AudioData(readSeeker)
+		end = start + size
+		if end > fileSize {
+			end = fileSize
+		}
+	default:
+		err = errUnsupportedFormat
+	}
+
+	return start, end, err
+}
+
+// trailingTagsOffset - отбрасывает тэги ID3v1 и APE в конце аудиоданных [start, end)
+// и возвращает новый конец аудиоданных
+func trailingTagsOffset(readSeeker io.ReadSeeker, start, end int64) (int64, error) {
+	buf := make([]byte, apeTagFooterSize)
+	for {
+		if end-start >= id3v1TagSize {
+			_, err := readSeeker.Seek(end-id3v1TagSize, os.SEEK_SET)
+			if err != nil {
+				return 0, err
+			}
+			_, err = io.ReadFull(readSeeker, buf[:3])
+			if err != nil {
+				return 0, err
+			}
+			if string(buf[:3]) == "TAG" {
+				end -= id3v1TagSize
+				continue
+			}
+		}
+
+		if end-start >= apeTagFooterSize {
+			_, err := readSeeker.Seek(end-apeTagFooterSize, os.SEEK_SET)
+			if err != nil {
+				return 0, err
+			}
+			_, err = io.ReadFull(readSeeker, buf)
+			if err != nil {
+				return 0, err
+			}
+			if string(buf[:8]) == "APETAGEX" {
+				// Размер включает элементы и футер, заголовок учитывается отдельно по флагу
+				size := int64(binary.LittleEndian.Uint32(buf[12:16]))
+				if binary.LittleEndian.Uint32(buf[20:24])&(1<<31) != 0 {
+					size += apeTagFooterSize
+				}
+				if size >= apeTagFooterSize && size <= end-start {
+					end -= size
+					continue
+				}
+			}
+		}
+
+		return end, nil
+	}
+}
+
+// findDuplicateByPayloadHash - ищет в БД песню с такими же аудиоданными.
+// Возвращает ID найденной песни или пустой ID.
+func findDuplicateByPayloadHash(ctx context.Context, songs SongRepository, hash string) (primitive.ObjectID, error) {
+	if hash == "" {
+		return primitive.NilObjectID, nil
+	}
+
+	id, err := songs.FindByPayloadHash(ctx, hash)
+	if err != nil {
+		i18n.Error("payload_hash.db_error", err)
+		return primitive.NilObjectID, err
+	}
+
+	return id, nil
+}
+
+// hashCatalogue - вычисляет хэши аудиоданных песен, добавленных до их появления.
+// Если хэш уже занят другой песней, то песня помечается как ее копия.
+func hashCatalogue(songs SongRepository, files storage.Storage) {
+	ctx := context.Background()
+
+	withoutHash, err := songs.WithoutPayloadHash(ctx)
+	if err != nil {
+		i18n.Error("payload_hash.find_error", err)
+		return
+	}
+
+	if len(withoutHash) == 0 {
+		return
+	}
+	i18n.Info("payload_hash.start", len(withoutHash))
+
+	for _, song := range withoutHash {
+		hash := hashStoredSong(files, &song)
+		if hash == "" {
+			continue
+		}
+
+		err = songs.SetPayloadHash(ctx, song.ID, hash)
+		if err != errDuplicate {
+			if err != nil {
+				i18n.Error("payload_hash.save_error", err)
+			}
+			continue
+		}
+
+		original, err := findDuplicateByPayloadHash(ctx, songs, hash)
+		if err != nil || original.IsZero() {
+			continue
+		}
+
+		i18n.Info("payload_hash.duplicate", song.ID.Hex(), original.Hex())
+		err = songs.SetDuplicateOf(ctx, song.ID, original)
+		if err != nil {
+			i18n.Error("db.update_error", err)
+		}
+	}
+
+	i18n.Info("payload_hash.done")
+}
+
+// hashStoredSong - вычисляет хэш аудиоданных файла песни из хранилища files
+func hashStoredSong(files storage.Storage, song *SongInfo) string {
+	file, err := storage.Open(files, songFileName(song))
+	if err != nil {
+		i18n.Error("storage.open_error", err)
+		return ""
+	}
+	defer file.Close()
+
+	return payloadHash(file, filepath.Ext(song.FileName))
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/STEJLS/AudioServer/flac"
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/mp3"
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// playlistJSON - описание плейлиста, которое кладется в архив
+type playlistJSON struct {
+	ID    primitive.ObjectID `json:"id"`
+	Name  string             `json:"Name"`
+	Songs []playlistSongJSON `json:"Songs"`
+}
+
+// playlistSongJSON - песня плейлиста в архиве: номер, путь к файлу в архиве и метаданные
+type playlistSongJSON struct {
+	Index int    `json:"Index"`
+	Path  string `json:"Path"`
+	*SongInfo
+}
+
+// playlistTrack - песня плейлиста, файл которой есть в хранилище
+type playlistTrack struct {
+	song *SongInfo
+	size int64
+	name string // имя файла в архиве
+}
+
+// fileNameReplacer - заменяет символы, недопустимые в именах файлов
+var fileNameReplacer = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_",
+)
+
+// servePlaylistInZIP - отдает плейлист в zip архиве. Песни лежат в папке плейлиста в порядке PlayList.IDs
+// под именами по шаблону playlistNameTemplate, рядом с ними плейлист .m3u8, описание playlist.json
+// и обложка первой песни, у которой она есть.
+func (h *handlers) servePlaylistInZIP(playList *PlayList, w http.ResponseWriter, r *http.Request) {
+	ids := makeSliceSliceObjectIDs(playList.IDs)
+
+	songs, err := h.songs.FindByIDs(r.Context(), ids)
+	if err != nil {
+		i18n.Error("playlist_zip.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	tracks := h.playlistTracks(ids, songs)
+	if len(tracks) == 0 {
+		i18n.Error("playlist_zip.no_files")
+		writeError(w, r, http.StatusBadRequest, codeSongsNotFound, nil)
+		return
+	}
+
+	folder := sanitizeFileName(playList.Name)
+	if folder == "" {
+		folder = serviceName + playList.ID.Hex()
+	}
+
+	var entries []zipEntry
+	for i := range tracks {
+		name := songFileName(tracks[i].song)
+		entries = append(entries, zipEntry{
+			name:     folder + "/" + tracks[i].name,
+			size:     tracks[i].size,
+			modified: tracks[i].song.UploadDate,
+			song:     tracks[i].song.ID,
+			open: func() (io.ReadCloser, error) {
+				return h.files.Get(name)
+			},
+		})
+	}
+
+	entries = append(entries, memoryZipEntry(folder+"/"+folder+".m3u8", makeM3U8(playList, tracks)))
+
+	data, err := json.MarshalIndent(makePlaylistJSON(playList, tracks), "", "  ")
+	if err != nil {
+		i18n.Error("playlist_zip.marshal_error", err)
+	} else {
+		entries = append(entries, memoryZipEntry(folder+"/playlist.json", data))
+	}
+
+	cover, mimeType := h.findCover(tracks)
+	if cover != nil {
+		ext := "jpg"
+		if mimeType == "image/png" {
+			ext = "png"
+		}
+		entries = append(entries, memoryZipEntry(folder+"/cover."+ext, cover))
+	}
+
+	h.serveZIP(entries, folder+".zip", w, r)
+}
+
+// playlistTracks - расставляет песни в порядке ids и дает им имена по шаблону.
+// Песни, которых нет в БД или в хранилище, пропускаются.
+func (h *handlers) playlistTracks(ids []primitive.ObjectID, songs []SongInfo) []playlistTrack {
+	byID := make(map[primitive.ObjectID]*SongInfo, len(songs))
+	for i := range songs {
+		byID[songs[i].ID] = &songs[i]
+	}
+
+	var tracks []playlistTrack
+	for _, id := range ids {
+		song, ok := byID[id]
+		if !ok {
+			i18n.Info("playlist_zip.song_not_found", id.Hex())
+			continue
+		}
+
+		info, err := h.files.Stat(songFileName(song))
+		if err != nil {
+			i18n.Error("zip.stat_error", id.Hex(), err)
+			continue
+		}
+
+		tracks = append(tracks, playlistTrack{song: song, size: info.Size})
+	}
+
+	width := len(strconv.Itoa(len(tracks)))
+	if width < 2 {
+		width = 2
+	}
+
+	names := make([]string, len(tracks))
+	for i := range tracks {
+		names[i] = trackFileName(playlistNameTemplate, i+1, width, tracks[i].song)
+	}
+	for i, name := range uniqueNames(names) {
+		tracks[i].name = name
+	}
+
+	return tracks
+}
+
+// trackFileName - имя файла песни по шаблону. Поддерживаются поля {index}, {artist},
+// {title}, {album}, {genre} и {ext}. Номер дополняется нулями до ширины width.
+// Очищается все имя целиком, поэтому "/" или ".." в самом шаблоне не выводят
+// файл за пределы папки плейлиста.
+func trackFileName(template string, index, width int, song *SongInfo) string {
+	number := fmt.Sprintf("%0*d", width, index)
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(song.FileName)), ".")
+	title := song.Title
+	if title == "" {
+		title = strings.TrimSuffix(song.FileName, filepath.Ext(song.FileName))
+	}
+
+	name := sanitizeFileName(strings.NewReplacer(
+		"{index}", number,
+		"{artist}", sanitizeFileName(song.Artist),
+		"{title}", sanitizeFileName(title),
+		"{album}", sanitizeFileName(song.Album),
+		"{genre}", sanitizeFileName(song.Genre),
+		"{ext}", ext,
+	).Replace(template))
+	if name == "" {
+		name = number + "." + ext
+	}
+
+	return name
+}
+
+// sanitizeFileName - делает строку пригодной для имени файла
+func sanitizeFileName(name string) string {
+	return strings.Trim(fileNameReplacer.Replace(stripControl(name)), " .")
+}
+
+// stripControl - удаляет управляющие символы, в том числе переводы строк
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// makeM3U8 - плейлист в формате M3U8 с путями относительно папки плейлиста.
+// Из названий удаляются переводы строк, иначе они добавили бы в плейлист свои строки.
+func makeM3U8(playList *PlayList, tracks []playlistTrack) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#PLAYLIST:" + stripControl(playList.Name) + "\n")
+	for _, track := range tracks {
+		fmt.Fprintf(buf, "#EXTINF:%v,%v - %v\n", track.song.Duration,
+			stripControl(track.song.Artist), stripControl(track.song.Title))
+		buf.WriteString(track.name + "\n")
+	}
+
+	return buf.Bytes()
+}
+
+// makePlaylistJSON - описание плейлиста и его песен для playlist.json
+func makePlaylistJSON(playList *PlayList, tracks []playlistTrack) *playlistJSON {
+	result := &playlistJSON{ID: playList.ID, Name: playList.Name}
+	for i, track := range tracks {
+		result.Songs = append(result.Songs, playlistSongJSON{Index: i + 1, Path: track.name, SongInfo: track.song})
+	}
+
+	return result
+}
+
+// findCover - возвращает встроенную обложку первой песни, у которой она есть
+func (h *handlers) findCover(tracks []playlistTrack) ([]byte, string) {
+	for _, track := range tracks {
+		ext := strings.ToLower(filepath.Ext(track.song.FileName))
+		if ext != ".mp3" && ext != ".flac" {
+			continue
+		}
+
+		file, err := storage.Open(h.files, songFileName(track.song))
+		if err != nil {
+			i18n.Error("storage.open_error", err)
+			continue
+		}
+
+		var cover []byte
+		var mimeType string
+		if ext == ".mp3" {
+			cover, mimeType = mp3.Cover(file)
+		} else {
+			cover, mimeType = flac.Cover(file)
+		}
+		file.Close()
+
+		if cover != nil {
+			return cover, mimeType
+		}
+	}
+
+	return nil, ""
+}
+
+// memoryZipEntry - файл архива с данными из памяти
+func memoryZipEntry(name string, data []byte) zipEntry {
+	return zipEntry{
+		name:     name,
+		size:     int64(len(data)),
+		modified: time.Now(),
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrackFileName(t *testing.T) {
+	song := &SongInfo{FileName: "song.MP3", Artist: "AC/DC", Title: "Back\nIn Black", Album: ".."}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{defaultPlaylistNameTemplate, "07 - AC_DC - BackIn Black.mp3"},
+		{"../{artist}/{title}.{ext}", "_AC_DC_BackIn Black.mp3"},
+		{"/{album}/{index}.{ext}", "__07.mp3"},
+		{"{album}", "07.mp3"},
+	}
+	for _, test := range tests {
+		got := trackFileName(test.template, 7, 2, song)
+		if got != test.want {
+			t.Errorf("trackFileName(%q) = %q, want %q", test.template, got, test.want)
+		}
+		if strings.ContainsAny(got, "/\\\n") || strings.HasPrefix(got, ".") {
+			t.Errorf("trackFileName(%q) = %q leaves the playlist folder", test.template, got)
+		}
+	}
+}
+
+func TestMakeM3U8StripsNewlines(t *testing.T) {
+	playList := &PlayList{Name: "Mix\n#EXTINF:1,evil\nhttp://evil/x.mp3"}
+	tracks := []playlistTrack{{
+		song: &SongInfo{Duration: 10, Artist: "A\r\nhttp://evil/", Title: "T"},
+		name: "01 - A - T.mp3",
+	}}
+
+	lines := strings.Split(strings.TrimSuffix(string(makeM3U8(playList, tracks)), "\n"), "\n")
+	want := []string{
+		"#EXTM3U",
+		"#PLAYLIST:Mix#EXTINF:1,evilhttp://evil/x.mp3",
+		"#EXTINF:10,Ahttp://evil/ - T",
+		"01 - A - T.mp3",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("makeM3U8 =\n%v\nwant\n%v", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
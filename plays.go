@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// playsJob - события прослушиваний песен. Клиент сообщает о каждом прослушивании один раз,
+// когда оно закончилось (песня дослушана, переключена или плеер остановлен). Засчитанные
+// прослушивания сразу прибавляются к CountOfPlays песни, а сами события хранятся
+// в течение срока хранения и затем удаляются.
+type playsJob struct {
+	playEvents PlayEventRepository
+	interval   time.Duration
+	retention  time.Duration // сколько хранятся события прослушиваний
+}
+
+// newPlaysJob - конструктор для типа playsJob, interval - период удаления устаревших событий в минутах,
+// retention - срок хранения событий в днях
+func newPlaysJob(playEvents PlayEventRepository, interval, retention int) *playsJob {
+	if interval == 0 {
+		interval = defaultPlayPurgeInterval
+	}
+	if retention == 0 {
+		retention = defaultPlayRetention
+	}
+
+	return &playsJob{
+		playEvents: playEvents,
+		interval:   time.Duration(interval) * time.Minute,
+		retention:  time.Duration(retention) * 24 * time.Hour,
+	}
+}
+
+// run - запускает бесконечный цикл удаления устаревших событий, вызывается в отдельной горутине
+func (job *playsJob) run() {
+	i18n.Info("plays_job.started", job.interval, job.retention)
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		job.purge()
+		<-ticker.C
+	}
+}
+
+// purge - удаляет события прослушиваний старше срока хранения
+func (job *playsJob) purge() {
+	removed, err := job.playEvents.DeleteBefore(context.Background(), time.Now().Add(-job.retention))
+	if err != nil {
+		i18n.Error("plays_job.purge_error", err)
+		return
+	}
+
+	if removed != 0 {
+		i18n.Info("plays_job.purged", removed)
+	}
+}
+
+// playCounts - засчитывается ли прослушивание: песня дослушана до конца
+// или прослушана хотя бы playCountPosition секунд
+func playCounts(position int, completed bool) bool {
+	return completed || position >= playCountPosition
+}
+
+// reportPlay - сохраняет событие прослушивания песни и, если оно засчитывается,
+// увеличивает количество прослушиваний песни. Возвращает errNotFound, если песни нет
+// или она в корзине.
+func reportPlay(ctx context.Context, songs SongRepository, playEvents PlayEventRepository, songID primitive.ObjectID, client string, position int, completed bool) (*PlayEvent, error) {
+	_, err := songs.FindByID(ctx, songID)
+	if err != nil {
+		return nil, err
+	}
+
+	event := PlayEvent{
+		ID:        primitive.NewObjectID(),
+		Song:      songID,
+		Client:    client,
+		Position:  position,
+		Completed: completed,
+		Counted:   playCounts(position, completed),
+		Time:      time.Now().UTC(),
+	}
+
+	err = playEvents.Insert(ctx, &event)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.Counted {
+		err = songs.IncrementPlays(ctx, songID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &event, nil
+}
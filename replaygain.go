@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/STEJLS/AudioServer/analysis"
+	"github.com/STEJLS/AudioServer/flac"
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/mp3"
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// replayGainJob - фоновая задача расчета ReplayGain. Периодически находит песни
+// без рассчитанного ReplayGain альбома, группирует их по альбому и исполнителю альбома
+// и пересчитывает всю группу. Плейлисты рассчитываются по запросу как отдельные альбомы.
+type replayGainJob struct {
+	songs     SongRepository
+	playlists PlaylistRepository
+	store     *blobStore
+	interval  time.Duration
+	writeTags bool                    // записывать ли результаты в тэги хранимых файлов
+	queue     chan primitive.ObjectID // плейлисты, ожидающие расчета
+}
+
+// albumKey - ключ группировки песен в альбом
+type albumKey struct {
+	album  string
+	artist string
+}
+
+// newReplayGainJob - конструктор для типа replayGainJob, interval - период запуска в минутах
+func newReplayGainJob(store *blobStore, interval int, writeTags bool) *replayGainJob {
+	if interval == 0 {
+		interval = defaultReplayGainInterval
+	}
+
+	return &replayGainJob{
+		songs:     store.songs,
+		playlists: store.playlists,
+		store:     store,
+		interval:  time.Duration(interval) * time.Minute,
+		writeTags: writeTags,
+		queue:     make(chan primitive.ObjectID, replayGainQueueSize),
+	}
+}
+
+// run - запускает бесконечный цикл обработки, вызывается в отдельной горутине
+func (job *replayGainJob) run() {
+	i18n.Info("replaygain.started", job.interval)
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	job.analyzeAlbums()
+	for {
+		select {
+		case <-ticker.C:
+			job.analyzeAlbums()
+		case id := <-job.queue:
+			job.analyzePlaylist(id)
+		}
+	}
+}
+
+// enqueuePlaylist - ставит плейлист в очередь на расчет.
+// Возвращает false, если очередь переполнена.
+func (job *replayGainJob) enqueuePlaylist(id primitive.ObjectID) bool {
+	select {
+	case job.queue <- id:
+		return true
+	default:
+		return false
+	}
+}
+
+// analyzeAlbums - рассчитывает ReplayGain для альбомов, в которых есть нерассчитанные песни.
+// Песни без альбома считаются альбомом из одного трека.
+func (job *replayGainJob) analyzeAlbums() {
+	ctx := context.Background()
+
+	songs, err := job.songs.NotAlbumAnalyzed(ctx)
+	if err != nil {
+		i18n.Error("replaygain.find_error", err)
+		return
+	}
+
+	if len(songs) == 0 {
+		return
+	}
+	i18n.Info("replaygain.start", len(songs))
+
+	done := make(map[albumKey]bool)
+	for _, song := range songs {
+		if song.Album == "" {
+			job.analyzeGroup([]SongInfo{song})
+			continue
+		}
+
+		key := albumKey{album: song.Album, artist: albumArtist(&song)}
+		if done[key] {
+			continue
+		}
+		done[key] = true
+
+		album, err := job.songs.FindByAlbum(ctx, key.album, key.artist)
+		if err != nil {
+			i18n.Error("replaygain.album_error", err)
+			continue
+		}
+
+		job.analyzeGroup(album)
+	}
+
+	i18n.Info("replaygain.done")
+}
+
+// analyzePlaylist - рассчитывает ReplayGain для песен плейлиста, считая его альбомом
+func (job *replayGainJob) analyzePlaylist(id primitive.ObjectID) {
+	ctx := context.Background()
+
+	playList, err := job.playlists.FindByID(ctx, id)
+	if err != nil {
+		i18n.Error("replaygain.playlist_error", err)
+		return
+	}
+
+	songs, err := job.songs.FindByIDs(ctx, makeSliceSliceObjectIDs(playList.IDs))
+	if err != nil {
+		i18n.Error("replaygain.playlist_songs_error", err)
+		return
+	}
+
+	job.analyzeGroup(songs)
+	i18n.Info("replaygain.playlist_done", id.Hex())
+}
+
+// analyzeGroup - декодирует песни группы, рассчитывает громкость треков и группы в целом
+// и сохраняет результаты в БД. Песни, которые не удалось декодировать, в расчет не входят.
+func (job *replayGainJob) analyzeGroup(songs []SongInfo) {
+	ctx := context.Background()
+
+	var analyzed []*SongInfo
+	var tracks []*analysis.Loudness
+	for i := range songs {
+		loudness, err := loadLoudness(job.store.files, &songs[i])
+		if err != nil {
+			i18n.Error("replaygain.analyze_error", songs[i].ID.Hex(), err)
+			// Повторный анализ ничего не изменит, поэтому песня помечается как рассчитанная
+			songs[i].IsAlbumAnalyzed = true
+			err = job.songs.UpdateLoudness(ctx, &songs[i])
+			if err != nil {
+				i18n.Error("db.update_error", err)
+			}
+			continue
+		}
+
+		setTrackLoudness(&songs[i], loudness)
+		analyzed = append(analyzed, &songs[i])
+		tracks = append(tracks, loudness)
+	}
+
+	if len(tracks) == 0 {
+		return
+	}
+
+	album := analysis.AnalyzeAlbum(tracks)
+	for _, song := range analyzed {
+		song.AlbumGain = album.AlbumGain
+		song.AlbumPeak = album.AlbumPeak
+		song.IsAlbumAnalyzed = true
+
+		err := job.songs.UpdateLoudness(ctx, song)
+		if err != nil {
+			i18n.Error("replaygain.save_error", err)
+			continue
+		}
+
+		if job.writeTags {
+			job.writeReplayGainTags(song)
+		}
+	}
+}
+
+// loadLoudness - декодирует хранимый файл песни и анализирует его громкость
+func loadLoudness(files storage.Storage, song *SongInfo) (*analysis.Loudness, error) {
+	file, err := storage.Open(files, songFileName(song))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	source, err := newAudioSource(file, filepath.Ext(song.FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	return analysis.AnalyzeLoudness(source)
+}
+
+// writeReplayGainTags - записывает ReplayGain в тэги хранимого файла песни.
+// Поле Size не меняется: оно описывает загруженный файл и используется при поиске дубликатов.
+func (job *replayGainJob) writeReplayGainTags(song *SongInfo) {
+	tags := map[string]string{
+		"REPLAYGAIN_TRACK_GAIN": fmt.Sprintf("%.2f dB", song.TrackGain),
+		"REPLAYGAIN_TRACK_PEAK": fmt.Sprintf("%.6f", song.TrackPeak),
+		"REPLAYGAIN_ALBUM_GAIN": fmt.Sprintf("%.2f dB", song.AlbumGain),
+		"REPLAYGAIN_ALBUM_PEAK": fmt.Sprintf("%.6f", song.AlbumPeak),
+	}
+
+	var writeTags func(fileName string, tags map[string]string) error
+	switch strings.ToLower(filepath.Ext(song.FileName)) {
+	case ".mp3":
+		writeTags = mp3.WriteTags
+	case ".flac":
+		writeTags = flac.WriteTags
+	default:
+		i18n.Info("replaygain.tags_unsupported", song.FileName)
+		return
+	}
+
+	err := job.store.rewriteStoredFile(song, func(fileName string) error {
+		return writeTags(fileName, tags)
+	})
+	if err != nil {
+		i18n.Error("replaygain.tags_error", song.ID.Hex(), err)
+	}
+}
+
+// albumArtist - исполнитель альбома, если он не указан, то используется исполнитель песни
+func albumArtist(song *SongInfo) string {
+	if song.AlbumArtist != "" {
+		return song.AlbumArtist
+	}
+
+	return song.Artist
+}
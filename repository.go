@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ошибки репозиториев, не зависящие от хранилища
+var (
+	errNotFound  = errors.New("not found")
+	errDuplicate = errors.New("duplicate key")
+)
+
+// SongRepository - хранилище записей о песнях. Методы поиска не возвращают песни из корзины,
+// если не сказано иначе. Если запись не найдена, то возвращается errNotFound.
+// Отмена контекста прерывает запрос к хранилищу.
+type SongRepository interface {
+	// Insert - добавляет песню. Если песня с таким же PayloadHash уже есть, то возвращает errDuplicate.
+	Insert(ctx context.Context, song *SongInfo) error
+	// FindByID - находит песню по ID
+	FindByID(ctx context.Context, id primitive.ObjectID) (*SongInfo, error)
+	// FindByIDIncludingTrash - находит песню по ID, в том числе в корзине
+	FindByIDIncludingTrash(ctx context.Context, id primitive.ObjectID) (*SongInfo, error)
+	// FindByIDs - находит песни по списку ID, порядок результата не определен
+	FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]SongInfo, error)
+	// FindDuplicate - ищет песню с такими же метаданными (в том числе в корзине), возвращает ее ID или пустой ID
+	FindDuplicate(ctx context.Context, song *SongInfo) (primitive.ObjectID, error)
+	// FindByPayloadHash - ищет песню с такими же аудиоданными (в том числе в корзине), возвращает ее ID или пустой ID
+	FindByPayloadHash(ctx context.Context, hash string) (primitive.ObjectID, error)
+	// List - страница песен, подходящих под фильтры запроса, в порядке сортировки запроса
+	// (при равных значениях поля сортировки - по ID в том же направлении)
+	List(ctx context.Context, query *SongQuery) ([]SongInfo, error)
+	// Search - песни, у которых исполнитель, название или жанр содержит хотя бы одно из слов (без учета регистра)
+	Search(ctx context.Context, words []string) ([]SongInfo, error)
+	// Update - заменяет запись о песне
+	Update(ctx context.Context, song *SongInfo) error
+	// IncrementDownloads - увеличивает количество загрузок песен на единицу
+	IncrementDownloads(ctx context.Context, ids []primitive.ObjectID) error
+	// IncrementPlays - увеличивает количество прослушиваний песни на единицу
+	IncrementPlays(ctx context.Context, id primitive.ObjectID) error
+	// Delete - окончательно удаляет запись о песне
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// Trash - перемещает песню в корзину, positions - ее позиции в плейлистах
+	Trash(ctx context.Context, id primitive.ObjectID, positions []PlaylistPosition) error
+	// Restore - возвращает из корзины песню, удаленную позже deletedAfter.
+	// Возвращает песню с позициями в плейлистах до удаления.
+	Restore(ctx context.Context, id primitive.ObjectID, deletedAfter time.Time) (*SongInfo, error)
+	// Trashed - песни в корзине, удаленные позже deletedAfter, начиная с последних
+	Trashed(ctx context.Context, deletedAfter time.Time) ([]SongInfo, error)
+	// Expired - песни в корзине, удаленные не позже deletedBefore
+	Expired(ctx context.Context, deletedBefore time.Time) ([]SongInfo, error)
+	// All - все песни, в том числе в корзине
+	All(ctx context.Context) ([]SongInfo, error)
+	// WithoutBlob - песни (в том числе в корзине), файлы которых хранятся под ID песни
+	WithoutBlob(ctx context.Context) ([]SongInfo, error)
+	// SetBlob - записывает хэш файла песни
+	SetBlob(ctx context.Context, id primitive.ObjectID, hash string) error
+	// CountByBlob - количество песен (в том числе в корзине), ссылающихся на блоб
+	CountByBlob(ctx context.Context, hash string) (int, error)
+	// WithoutPayloadHash - песни без хэша аудиоданных, не помеченные как копии
+	WithoutPayloadHash(ctx context.Context) ([]SongInfo, error)
+	// SetPayloadHash - записывает хэш аудиоданных. Если хэш занят другой песней, то возвращает errDuplicate.
+	SetPayloadHash(ctx context.Context, id primitive.ObjectID, hash string) error
+	// SetDuplicateOf - помечает песню как точную копию песни original
+	SetDuplicateOf(ctx context.Context, id primitive.ObjectID, original primitive.ObjectID) error
+	// DuplicateGroups - песни, у которых есть точные копии, вместе с копиями, по возрастанию ID
+	DuplicateGroups(ctx context.Context) ([]duplicateGroup, error)
+	// NotAlbumAnalyzed - песни (в том числе в корзине) без рассчитанного ReplayGain альбома
+	NotAlbumAnalyzed(ctx context.Context) ([]SongInfo, error)
+	// FindByAlbum - песни альбома (в том числе в корзине). Если исполнитель альбома у песни
+	// не указан, то сравнивается исполнитель песни.
+	FindByAlbum(ctx context.Context, album, albumArtist string) ([]SongInfo, error)
+	// UpdateLoudness - записывает громкость и ReplayGain песни
+	UpdateLoudness(ctx context.Context, song *SongInfo) error
+	// UpdateMetadata - записывает название, исполнителя, жанр, альбом и исполнителя альбома песни
+	UpdateMetadata(ctx context.Context, song *SongInfo) error
+}
+
+// PlaylistRepository - хранилище плейлистов. Методы поиска не возвращают плейлисты из корзины,
+// если не сказано иначе. Если запись не найдена, то возвращается errNotFound.
+// Отмена контекста прерывает запрос к хранилищу.
+type PlaylistRepository interface {
+	// Insert - добавляет плейлист
+	Insert(ctx context.Context, playList *PlayList) error
+	// FindByID - находит плейлист по ID
+	FindByID(ctx context.Context, id primitive.ObjectID) (*PlayList, error)
+	// FindBySong - плейлисты (в том числе в корзине), в которых есть песня
+	FindBySong(ctx context.Context, songID primitive.ObjectID) ([]PlayList, error)
+	// List - страница плейлистов в порядке сортировки запроса
+	// (при равных значениях поля сортировки - по ID в том же направлении)
+	List(ctx context.Context, query *PlaylistQuery) ([]PlayList, error)
+	// Search - плейлисты, название которых содержит хотя бы одно из слов (без учета регистра)
+	Search(ctx context.Context, words []string) ([]PlayList, error)
+	// Update - заменяет плейлист
+	Update(ctx context.Context, playList *PlayList) error
+	// AddSong - вставляет песню в плейлист (в том числе в корзине) на позицию index
+	AddSong(ctx context.Context, id primitive.ObjectID, songID primitive.ObjectID, index int) error
+	// RemoveSong - убирает песню из всех плейлистов (в том числе из корзины)
+	RemoveSong(ctx context.Context, songID primitive.ObjectID) error
+	// Delete - окончательно удаляет плейлист
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// Trash - перемещает плейлист в корзину
+	Trash(ctx context.Context, id primitive.ObjectID) error
+	// Restore - возвращает из корзины плейлист, удаленный позже deletedAfter
+	Restore(ctx context.Context, id primitive.ObjectID, deletedAfter time.Time) error
+	// Trashed - плейлисты в корзине, удаленные позже deletedAfter, начиная с последних
+	Trashed(ctx context.Context, deletedAfter time.Time) ([]PlayList, error)
+	// DeleteExpired - окончательно удаляет плейлисты, удаленные в корзину не позже deletedBefore.
+	// Возвращает количество удаленных плейлистов.
+	DeleteExpired(ctx context.Context, deletedBefore time.Time) (int, error)
+}
+
+// WaveformRepository - хранилище форм волны песен
+type WaveformRepository interface {
+	// Insert - добавляет форму волны
+	Insert(ctx context.Context, waveform *Waveform) error
+	// FindByID - находит форму волны по ID песни
+	FindByID(ctx context.Context, id primitive.ObjectID) (*Waveform, error)
+	// Delete - удаляет форму волны песни
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// FingerprintRepository - хранилище акустических отпечатков песен
+type FingerprintRepository interface {
+	// Insert - добавляет отпечаток
+	Insert(ctx context.Context, fingerprint *Fingerprint) error
+	// EachByDuration - вызывает fn для каждого отпечатка песни продолжительностью от min до max секунд.
+	// Отпечатки читаются по одному, поэтому все кандидаты не загружаются в память разом.
+	EachByDuration(ctx context.Context, min, max int, fn func(fingerprint *Fingerprint)) error
+	// Delete - удаляет отпечаток песни
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// BlobRepository - хранилище записей о блобах со счетчиками ссылок
+type BlobRepository interface {
+	// Acquire - добавляет ссылку на блоб, создавая запись о нем при необходимости.
+	// Возвращает true, если записи о блобе еще не было.
+	Acquire(ctx context.Context, hash string, size int64) (bool, error)
+	// Release - убирает ссылку на блоб и удаляет запись о нем, если ссылок не осталось.
+	// Возвращает true, если запись удалена.
+	Release(ctx context.Context, hash string) (bool, error)
+	// All - все блобы
+	All(ctx context.Context) ([]Blob, error)
+	// SetRefCount - записывает количество ссылок на блоб, создавая запись о нем при необходимости
+	SetRefCount(ctx context.Context, hash string, count int, size int64) error
+	// Delete - удаляет запись о блобе
+	Delete(ctx context.Context, hash string) error
+}
+
+// JournalRepository - журнал незавершенных изменений песен и их файлов в хранилище
+type JournalRepository interface {
+	// Insert - добавляет запись журнала перед началом изменения
+	Insert(ctx context.Context, entry *JournalEntry) error
+	// All - все записи журнала, начиная с самых старых
+	All(ctx context.Context) ([]JournalEntry, error)
+	// Delete - удаляет запись журнала после завершения изменения
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// StatsRepository - посуточные счетчики загрузок песен. Сутки задаются временем их начала в UTC.
+type StatsRepository interface {
+	// AddDownloads - увеличивает на единицу счетчики загрузок песен за сутки day
+	AddDownloads(ctx context.Context, ids []primitive.ObjectID, day time.Time) error
+	// TopDownloads - песни с наибольшим количеством загрузок начиная с суток since,
+	// по убыванию количества, count = 0 - без ограничения
+	TopDownloads(ctx context.Context, since time.Time, count int) ([]SongDownloads, error)
+	// DeleteSong - удаляет счетчики загрузок песни
+	DeleteSong(ctx context.Context, id primitive.ObjectID) error
+}
+
+// DownloadEventRepository - события загрузок песен
+type DownloadEventRepository interface {
+	// Insert - добавляет события
+	Insert(ctx context.Context, events []DownloadEvent) error
+	// EachSince - вызывает fn для каждого события не раньше since. События читаются
+	// по одному, поэтому все события окна не загружаются в память разом.
+	EachSince(ctx context.Context, since time.Time, fn func(event *DownloadEvent)) error
+	// DeleteBefore - удаляет события раньше before. Возвращает количество удаленных событий.
+	DeleteBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+// PlayEventRepository - события прослушиваний песен
+type PlayEventRepository interface {
+	// Insert - добавляет событие
+	Insert(ctx context.Context, event *PlayEvent) error
+	// DeleteBefore - удаляет события раньше before. Возвращает количество удаленных событий.
+	DeleteBefore(ctx context.Context, before time.Time) (int, error)
+	// DeleteSong - удаляет все события песни
+	DeleteSong(ctx context.Context, id primitive.ObjectID) error
+}
+
+// repositories - хранилища записей, с которыми работают обработчики запросов.
+// В работе это реализации для выбранной в конфиге БД, в тестах - реализации в памяти и SQLite.
+type repositories struct {
+	songs          SongRepository
+	playlists      PlaylistRepository
+	waveforms      WaveformRepository
+	fingerprints   FingerprintRepository
+	blobs          BlobRepository
+	journal        JournalRepository
+	stats          StatsRepository
+	downloadEvents DownloadEventRepository
+	playEvents     PlayEventRepository
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Режимы работы проверки хранилища
+const (
+	scrubModeDryRun     = "dryrun"     // только отчет
+	scrubModeQuarantine = "quarantine" // осиротевшие файлы переносятся в карантин
+	scrubModeRepair     = "repair"     // еще исправляются записи БД
+)
+
+// scrubJob - фоновая проверка целостности хранилища. Сверяет записи о песнях
+// с файлами хранилища и в зависимости от режима исправляет найденные расхождения.
+type scrubJob struct {
+	store    *blobStore
+	interval time.Duration
+	mode     string
+
+	mutex  sync.Mutex
+	report *scrubReport // отчет последней проверки
+}
+
+// scrubReport - отчет проверки хранилища
+type scrubReport struct {
+	Started            time.Time           `json:"Started"`
+	Finished           time.Time           `json:"Finished"`
+	Mode               string              `json:"Mode"`
+	CheckedSongs       int                 `json:"CheckedSongs"`
+	CheckedFiles       int                 `json:"CheckedFiles"`
+	MissingFiles       []scrubMissingFile  `json:"MissingFiles"`
+	OrphanFiles        []scrubOrphanFile   `json:"OrphanFiles"`
+	SizeMismatches     []scrubSizeMismatch `json:"SizeMismatches"`
+	RefCountMismatches []scrubRefCount     `json:"RefCountMismatches"`
+	Errors             []string            `json:"Errors"`
+}
+
+// scrubMissingFile - песня, файла которой нет в хранилище
+type scrubMissingFile struct {
+	SongID   primitive.ObjectID `json:"SongID"`
+	File     string             `json:"File"`
+	Repaired bool               `json:"Repaired"` // удалена ли запись о песне
+}
+
+// scrubOrphanFile - файл хранилища, на который не ссылается ни одна песня
+type scrubOrphanFile struct {
+	File     string    `json:"File"`
+	Size     int64     `json:"Size"`
+	ModTime  time.Time `json:"ModTime"`
+	IsTemp   bool      `json:"IsTemp"`   // временный файл незавершенной записи
+	Repaired bool      `json:"Repaired"` // перенесен ли файл в карантин (временный - удален)
+}
+
+// scrubSizeMismatch - файл, размер которого не совпадает с записью в БД.
+// Такие файлы не исправляются автоматически, так как правильного содержимого нет.
+type scrubSizeMismatch struct {
+	SongID   primitive.ObjectID `json:"SongID"`
+	File     string             `json:"File"`
+	Expected int64              `json:"Expected"`
+	Actual   int64              `json:"Actual"`
+}
+
+// scrubRefCount - блоб, счетчик ссылок которого не совпадает с количеством песен
+type scrubRefCount struct {
+	Blob     string `json:"Blob"`
+	Expected int    `json:"Expected"`
+	Actual   int    `json:"Actual"`
+	Repaired bool   `json:"Repaired"`
+}
+
+// newScrubJob - конструктор для типа scrubJob, interval - период запуска в минутах
+func newScrubJob(store *blobStore, interval int, mode string) *scrubJob {
+	if interval == 0 {
+		interval = defaultScrubInterval
+	}
+	if mode == "" {
+		mode = scrubModeDryRun
+	}
+
+	return &scrubJob{
+		store:    store,
+		interval: time.Duration(interval) * time.Minute,
+		mode:     mode,
+	}
+}
+
+// run - запускает бесконечный цикл проверок, вызывается в отдельной горутине
+func (job *scrubJob) run() {
+	i18n.Info("scrub.started", job.interval, job.mode)
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		job.scrub()
+		<-ticker.C
+	}
+}
+
+// lastReport - отчет последней завершенной проверки, nil если проверок еще не было
+func (job *scrubJob) lastReport() *scrubReport {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	return job.report
+}
+
+// scrub - выполняет одну проверку хранилища и сохраняет отчет
+func (job *scrubJob) scrub() {
+	i18n.Info("scrub.start")
+	report := &scrubReport{Started: time.Now(), Mode: job.mode}
+
+	// Загрузка сохраняет файл блоба раньше, чем запись о песне, поэтому только что загруженный
+	// файл может оказаться в списке без ссылающейся на него песни. От переноса таких файлов
+	// в карантин защищает только scrubGracePeriod, см. checkOrphans.
+	files, err := job.store.files.List("")
+	if err != nil {
+		i18n.Error("scrub.list_error", err)
+		return
+	}
+
+	ctx := context.Background()
+
+	songs, err := job.store.songs.All(ctx)
+	if err != nil {
+		i18n.Error("scrub.songs_error", err)
+		return
+	}
+
+	blobs, err := job.store.blobs.All(ctx)
+	if err != nil {
+		i18n.Error("scrub.blobs_error", err)
+		return
+	}
+
+	job.checkSongs(report, songs, files, blobs)
+	job.checkOrphans(report, songs, files, blobs)
+	job.checkRefCounts(report, songs, blobs)
+
+	report.Finished = time.Now()
+	job.mutex.Lock()
+	job.report = report
+	job.mutex.Unlock()
+
+	i18n.Info("scrub.done",
+		len(report.MissingFiles), len(report.OrphanFiles), len(report.SizeMismatches), len(report.RefCountMismatches))
+}
+
+// checkSongs - находит песни без файлов и файлы, размер которых не совпадает с записью.
+// Размер файла блоба сверяется с записью о блобе, так как запись ReplayGain в тэги
+// меняет файл, но не SongInfo.Size. Размер файла песни, еще не переведенной на блобы, сверяется с SongInfo.Size.
+func (job *scrubJob) checkSongs(report *scrubReport, songs []SongInfo, files []storage.FileInfo, blobs []Blob) {
+	sizes := make(map[string]int64, len(files))
+	for _, file := range files {
+		sizes[file.Name] = file.Size
+	}
+
+	blobSizes := make(map[string]int64, len(blobs))
+	for _, blob := range blobs {
+		blobSizes[blob.Hash] = blob.Size
+	}
+
+	for i := range songs {
+		song := &songs[i]
+		name := songFileName(song)
+		report.CheckedSongs++
+
+		size, ok := sizes[name]
+		if !ok {
+			// Файл мог быть перезаписан после получения списка файлов
+			info, err := job.store.files.Stat(name)
+			if err == storage.ErrNotExist {
+				job.missingFile(report, song, name)
+				continue
+			}
+			if err != nil {
+				report.Errors = append(report.Errors, "Stat "+name+": "+err.Error())
+				continue
+			}
+			size = info.Size
+		}
+
+		expected := int64(song.Size)
+		if song.Blob != "" {
+			expected, ok = blobSizes[song.Blob]
+			if !ok {
+				continue // расхождение попадет в отчет о счетчиках ссылок
+			}
+		}
+
+		if size != expected {
+			report.SizeMismatches = append(report.SizeMismatches, scrubSizeMismatch{
+				SongID:   song.ID,
+				File:     name,
+				Expected: expected,
+				Actual:   size,
+			})
+		}
+	}
+}
+
+// missingFile - записывает в отчет песню без файла, а в режиме repair удаляет запись о ней
+func (job *scrubJob) missingFile(report *scrubReport, song *SongInfo, name string) {
+	missing := scrubMissingFile{SongID: song.ID, File: name}
+
+	// Песня могла быть добавлена после получения списка файлов
+	if job.mode == scrubModeRepair && time.Since(song.UploadDate) > scrubGracePeriod {
+		err := job.store.removeSongRecords(song)
+		if err != nil {
+			report.Errors = append(report.Errors, "Remove song "+song.ID.Hex()+": "+err.Error())
+		} else {
+			missing.Repaired = true
+		}
+	}
+
+	report.MissingFiles = append(report.MissingFiles, missing)
+}
+
+// checkOrphans - находит файлы хранилища, на которые не ссылается ни одна песня,
+// и оставшиеся временные файлы. Файлы моложе scrubGracePeriod не трогаются: они могут
+// принадлежать записи, которая еще не завершилась.
+// Файлы блобов, записи о которых еще есть в БД, не переносятся в карантин: новая загрузка
+// такого же содержимого не сохранила бы файл и сослалась бы на отсутствующий блоб.
+// Такие записи удаляет checkRefCounts в режиме repair, и файл переносится следующей проверкой.
+func (job *scrubJob) checkOrphans(report *scrubReport, songs []SongInfo, files []storage.FileInfo, blobs []Blob) {
+	referenced := make(map[string]bool, len(songs))
+	for i := range songs {
+		referenced[songFileName(&songs[i])] = true
+	}
+
+	recorded := make(map[string]bool, len(blobs))
+	for _, blob := range blobs {
+		recorded[blobName(blob.Hash)] = true
+	}
+
+	for _, file := range files {
+		if strings.HasPrefix(file.Name, quarantinePrefix) {
+			continue
+		}
+		report.CheckedFiles++
+
+		if referenced[file.Name] || time.Since(file.ModTime) < scrubGracePeriod {
+			continue
+		}
+
+		orphan := scrubOrphanFile{File: file.Name, Size: file.Size, ModTime: file.ModTime}
+		if job.mode != scrubModeDryRun && !recorded[file.Name] {
+			err := storage.Move(job.store.files, file.Name, quarantinePrefix+file.Name)
+			if err != nil {
+				report.Errors = append(report.Errors, "Quarantine "+file.Name+": "+err.Error())
+			} else {
+				orphan.Repaired = true
+			}
+		}
+		report.OrphanFiles = append(report.OrphanFiles, orphan)
+	}
+
+	temps, err := storage.ListTemp(job.store.files)
+	if err != nil {
+		report.Errors = append(report.Errors, "ListTemp: "+err.Error())
+		return
+	}
+
+	for _, file := range temps {
+		if time.Since(file.ModTime) < scrubGracePeriod {
+			continue
+		}
+
+		// Частично записанный файл бесполезен, поэтому он не переносится в карантин, а удаляется
+		orphan := scrubOrphanFile{File: file.Name, Size: file.Size, ModTime: file.ModTime, IsTemp: true}
+		if job.mode != scrubModeDryRun {
+			err = job.store.files.Delete(file.Name)
+			if err != nil {
+				report.Errors = append(report.Errors, "Delete "+file.Name+": "+err.Error())
+			} else {
+				orphan.Repaired = true
+			}
+		}
+		report.OrphanFiles = append(report.OrphanFiles, orphan)
+	}
+}
+
+// checkRefCounts - сверяет счетчики ссылок блобов с количеством ссылающихся на них песен.
+// В режиме repair счетчики исправляются, а записи о блобах без ссылок удаляются
+// (их файлы будут перенесены в карантин следующей проверкой).
+func (job *scrubJob) checkRefCounts(report *scrubReport, songs []SongInfo, blobs []Blob) {
+	counts := make(map[string]int)
+	for i := range songs {
+		if songs[i].Blob != "" {
+			counts[songs[i].Blob]++
+		}
+	}
+
+	for _, blob := range blobs {
+		if blob.RefCount == counts[blob.Hash] {
+			delete(counts, blob.Hash)
+			continue
+		}
+
+		mismatch := scrubRefCount{Blob: blob.Hash, Expected: counts[blob.Hash], Actual: blob.RefCount}
+		delete(counts, blob.Hash)
+		if job.mode == scrubModeRepair {
+			mismatch.Repaired = job.repairRefCount(report, blob.Hash)
+		}
+		report.RefCountMismatches = append(report.RefCountMismatches, mismatch)
+	}
+
+	// Блобы, на которые ссылаются песни, но записей о которых нет
+	for hash, count := range counts {
+		mismatch := scrubRefCount{Blob: hash, Expected: count}
+		if job.mode == scrubModeRepair {
+			mismatch.Repaired = job.repairRefCount(report, hash)
+		}
+		report.RefCountMismatches = append(report.RefCountMismatches, mismatch)
+	}
+}
+
+// repairRefCount - пересчитывает ссылки на блоб по БД и исправляет счетчик.
+// Запись о блобе без ссылок удаляется.
+func (job *scrubJob) repairRefCount(report *scrubReport, hash string) bool {
+	ctx := context.Background()
+	count, err := job.store.songs.CountByBlob(ctx, hash)
+	if err == nil && count == 0 {
+		err = job.store.blobs.Delete(ctx, hash)
+		if err == errNotFound {
+			err = nil
+		}
+	} else if err == nil {
+		var size int64
+		info, statErr := job.store.files.Stat(blobName(hash))
+		if statErr == nil {
+			size = info.Size
+		}
+		err = job.store.blobs.SetRefCount(ctx, hash, count, size)
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, "Repair blob "+hash+": "+err.Error())
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/STEJLS/AudioServer/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestScrubJob - проверка хранилища с записями в памяти и файлами в каталоге directory
+func newTestScrubJob(t *testing.T, mode string) (job *scrubJob, directory string) {
+	t.Helper()
+	directory = t.TempDir()
+	files, err := storage.NewLocal(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newScrubJob(newBlobStore(files, newMemoryRepositories()), 0, mode), directory
+}
+
+// putTestFile - записывает файл в хранилище и состаривает его на age
+func putTestFile(t *testing.T, job *scrubJob, directory, name string, data []byte, age time.Duration) {
+	t.Helper()
+	err := job.store.files.Put(name, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Now().Add(-age)
+	err = os.Chtimes(filepath.Join(directory, filepath.FromSlash(name)), modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fileExists - есть ли файл в хранилище
+func fileExists(t *testing.T, files storage.Storage, name string) bool {
+	t.Helper()
+	_, err := files.Stat(name)
+	if err == storage.ErrNotExist {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return true
+}
+
+func TestScrubDryRunReportsOrphan(t *testing.T) {
+	job, directory := newTestScrubJob(t, scrubModeDryRun)
+	putTestFile(t, job, directory, "orphan", []byte("orphan"), 2*scrubGracePeriod)
+
+	job.scrub()
+	report := job.lastReport()
+	if len(report.OrphanFiles) != 1 || report.OrphanFiles[0].File != "orphan" || report.OrphanFiles[0].Repaired {
+		t.Fatalf("orphan files = %+v", report.OrphanFiles)
+	}
+	if !fileExists(t, job.store.files, "orphan") || fileExists(t, job.store.files, quarantinePrefix+"orphan") {
+		t.Error("dry run moved the orphan file")
+	}
+}
+
+func TestScrubQuarantineMovesOrphan(t *testing.T) {
+	job, directory := newTestScrubJob(t, scrubModeQuarantine)
+	putTestFile(t, job, directory, "orphan", []byte("orphan"), 2*scrubGracePeriod)
+
+	job.scrub()
+	report := job.lastReport()
+	if len(report.OrphanFiles) != 1 || !report.OrphanFiles[0].Repaired || len(report.Errors) != 0 {
+		t.Fatalf("orphan files = %+v, errors = %v", report.OrphanFiles, report.Errors)
+	}
+	if fileExists(t, job.store.files, "orphan") || !fileExists(t, job.store.files, quarantinePrefix+"orphan") {
+		t.Error("orphan file is not in quarantine")
+	}
+
+	// Файлы карантина не проверяются повторно
+	job.scrub()
+	if report = job.lastReport(); len(report.OrphanFiles) != 0 {
+		t.Errorf("orphan files after quarantine = %+v", report.OrphanFiles)
+	}
+}
+
+func TestScrubRepairRefCounts(t *testing.T) {
+	ctx := context.Background()
+	job, directory := newTestScrubJob(t, scrubModeRepair)
+	repos := job.store.repositories
+
+	data := []byte("song content")
+	hash, size, _ := hashContent(bytes.NewReader(data))
+	putTestFile(t, job, directory, blobName(hash), data, 2*scrubGracePeriod)
+	song := &SongInfo{ID: primitive.NewObjectID(), FileName: "song.mp3", Size: len(data), Blob: hash}
+	if err := repos.songs.Insert(ctx, song); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.blobs.SetRefCount(ctx, hash, 3, size); err != nil {
+		t.Fatal(err)
+	}
+
+	// Запись о блобе, на который не ссылается ни одна песня
+	unused, _, _ := hashContent(bytes.NewReader([]byte("unused")))
+	if err := repos.blobs.SetRefCount(ctx, unused, 1, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	job.scrub()
+	report := job.lastReport()
+	if len(report.RefCountMismatches) != 2 || len(report.Errors) != 0 {
+		t.Fatalf("ref count mismatches = %+v, errors = %v", report.RefCountMismatches, report.Errors)
+	}
+	for _, mismatch := range report.RefCountMismatches {
+		if !mismatch.Repaired {
+			t.Errorf("mismatch is not repaired: %+v", mismatch)
+		}
+	}
+
+	blobs, err := repos.blobs.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || blobs[0].Hash != hash || blobs[0].RefCount != 1 || blobs[0].Size != size {
+		t.Errorf("blobs after repair = %+v", blobs)
+	}
+
+	job.scrub()
+	if report = job.lastReport(); len(report.RefCountMismatches) != 0 || len(report.MissingFiles) != 0 {
+		t.Errorf("report after repair = %+v", report)
+	}
+}
+
+func TestScrubGracePeriod(t *testing.T) {
+	job, directory := newTestScrubJob(t, scrubModeQuarantine)
+	putTestFile(t, job, directory, "fresh", []byte("fresh"), 0)
+	putTestFile(t, job, directory, "recent", []byte("recent"), scrubGracePeriod-time.Minute)
+
+	job.scrub()
+	report := job.lastReport()
+	if len(report.OrphanFiles) != 0 || report.CheckedFiles != 2 {
+		t.Errorf("checked files = %v, orphan files = %+v", report.CheckedFiles, report.OrphanFiles)
+	}
+	for _, name := range []string{"fresh", "recent"} {
+		if !fileExists(t, job.store.files, name) {
+			t.Errorf("file %v is moved", name)
+		}
+	}
+}
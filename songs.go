@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/STEJLS/AudioServer/flac"
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/mp3"
+	"github.com/STEJLS/AudioServer/storage"
+	"github.com/STEJLS/AudioServer/wav"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Общая часть обработчиков песен и плейлистов первой и второй версии API.
+// Функции возвращают ошибки, а код ответа по ним выбирает writeSongError,
+// поэтому обе версии отвечают на одни и те же ошибки одинаково.
+
+// errInvalidIDs - среди ID песен есть некорректные
+var errInvalidIDs = errors.New("Получены некорректные ID")
+
+// duplicateSongError - загружаемая песня уже есть в системе
+type duplicateSongError struct {
+	id primitive.ObjectID // ID песни, с которой совпала загружаемая
+}
+
+func (err *duplicateSongError) Error() string {
+	return "Данный файл уже есть в системе, id: " + err.id.Hex()
+}
+
+// writeSongError - отвечает клиенту ошибкой, которую вернули функции этого файла.
+// Для дубликата песни в заголовке Location передается адрес уже добавленной песни.
+func writeSongError(w http.ResponseWriter, r *http.Request, err error) {
+	var duplicate *duplicateSongError
+	switch {
+	case err == errNoUploadFile:
+		writeError(w, r, http.StatusBadRequest, codeNoUploadFile, errorDetails{"field": formFileName})
+	case err == errUploadTooLarge:
+		writeError(w, r, http.StatusRequestEntityTooLarge, codeFileTooLarge, errorDetails{"maxSize": maxUploadSize})
+	case err == errUnsupportedFormat:
+		writeError(w, r, http.StatusUnsupportedMediaType, codeUnsupportedFormat, nil)
+	case errors.As(err, &duplicate):
+		w.Header().Set("Location", apiV2Prefix+"songs/"+duplicate.id.Hex())
+		writeError(w, r, http.StatusConflict, codeDuplicateSong, errorDetails{"id": duplicate.id.Hex()})
+	case err == errDuplicate:
+		writeError(w, r, http.StatusConflict, codeDuplicateSong, nil)
+	case err == errInvalidIDs:
+		writeError(w, r, http.StatusBadRequest, codeInvalidIDs, nil)
+	default:
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+	}
+}
+
+// findSong - находит песню вне корзины, в случае ошибки отвечает клиенту
+func (h *handlers) findSong(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) (*SongInfo, bool) {
+	song, err := h.songs.FindByID(r.Context(), id)
+	if err == errNotFound {
+		i18n.Info("song.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codeSongNotFound, nil)
+		return nil, false
+	}
+	if err != nil {
+		i18n.Error("db.find_record", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return nil, false
+	}
+
+	return song, true
+}
+
+// findPlaylist - находит плейлист вне корзины, в случае ошибки отвечает клиенту
+func (h *handlers) findPlaylist(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) (*PlayList, bool) {
+	playList, err := h.playlists.FindByID(r.Context(), id)
+	if err == errNotFound {
+		i18n.Info("playlist.not_found", id.Hex())
+		writeError(w, r, http.StatusNotFound, codePlaylistNotFound, nil)
+		return nil, false
+	}
+	if err != nil {
+		i18n.Error("db.find_record", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return nil, false
+	}
+
+	return playList, true
+}
+
+// uploadSong - принимает загружаемую песню из multipart тела запроса. Парсит метаданные о песне,
+// заносит их в базу данных и сохраняет в хранилище (имя под которым хранится песня
+// в хранилище – это хэш его содержимого, см. blobName). Файл не буферизуется в памяти:
+// он сразу записывается во временный файл хранилища.
+// Возвращает errNoUploadFile, errUploadTooLarge, errUnsupportedFormat,
+// *duplicateSongError или errDuplicate, если песня уже есть в системе.
+func (h *handlers) uploadSong(w http.ResponseWriter, r *http.Request) (*SongInfo, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	fd, err := receiveUpload(h.files, r)
+	if err != nil {
+		switch err {
+		case errNoUploadFile:
+			i18n.Error("upload.no_file", formFileName, err)
+		case errUploadTooLarge:
+			i18n.Info("upload.too_large", maxUploadSize)
+		}
+		return nil, err
+	}
+	defer fd.Discard()
+	i18n.Info("upload.received", fd.name)
+
+	extension := filepath.Ext(fd.name)
+	var metaData IMetadata
+
+	switch strings.ToLower(extension) {
+	case ".mp3":
+		metaData = mp3.ParseMetadata(fd)
+	case ".flac":
+		metaData = flac.ParseMetadata(fd)
+	case ".wav":
+		metaData = wav.ParseMetadata(fd)
+	}
+
+	if metaData == nil || reflect.ValueOf(metaData).IsNil() {
+		i18n.Info("upload.unsupported_format", extension)
+		return nil, errUnsupportedFormat
+	}
+	i18n.Info("upload.metadata_parsed")
+
+	id := primitive.NewObjectID()
+	infoToDB := NewSongInfo(id, fd.name, int(fd.size), metaData)
+	infoToDB.Blob = fd.hash
+
+	NormalizeMetadata(infoToDB, extension)
+
+	infoToDB.PayloadHash = payloadHash(fd, extension)
+	duplicateID, err := findDuplicateByPayloadHash(r.Context(), h.songs, infoToDB.PayloadHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !duplicateID.IsZero() {
+		i18n.Info("upload.duplicate_payload", duplicateID.Hex())
+		return nil, &duplicateSongError{id: duplicateID}
+	}
+
+	waveform, fingerprint := analyzeAudio(fd, extension, infoToDB)
+
+	// Если аудио не удалось декодировать, дубликаты ищутся по метаданным
+	if fingerprint != nil {
+		duplicateID, err = findDuplicateByFingerprint(r.Context(), h.fingerprints, fingerprint)
+	} else {
+		duplicateID, err = CheckExistMetaInDB(r.Context(), h.songs, infoToDB)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !duplicateID.IsZero() {
+		i18n.Info("upload.duplicate_song", duplicateID.Hex())
+		return nil, &duplicateSongError{id: duplicateID}
+	}
+
+	// Загрузка записывается в журнал: если сервер остановится, не добавив запись о песне,
+	// то сохраненный файл будет удален при следующем запуске
+	journalID, err := h.store.beginJournal(journalUpload, id, fd.hash, "")
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.store.storeUploadedBlob(r.Context(), fd)
+	if err != nil {
+		i18n.Error("upload.store_error", err)
+		h.store.finishJournal(journalID, err)
+		return nil, err
+	}
+
+	// Добавление записи о песне завершает загрузку
+	err = h.songs.Insert(r.Context(), infoToDB)
+	if err != nil {
+		h.store.finishJournal(journalID, h.store.releaseBlob(fd.hash))
+	}
+	if err == errDuplicate {
+		// Такой же файл был добавлен параллельным запросом
+		i18n.Info("upload.duplicate_hash", err)
+		return nil, err
+	}
+	if err != nil {
+		i18n.Error("db.insert_record", err)
+		return nil, err
+	}
+	h.store.finishJournal(journalID, nil)
+	i18n.Info("upload.stored")
+
+	// Песня уже сохранена, поэтому связанные с ней записи сохраняются и после отмены запроса
+	if waveform != nil {
+		err = h.waveforms.Insert(context.Background(), waveform)
+		if err != nil {
+			i18n.Error("upload.waveform_error", err)
+		}
+	}
+
+	if fingerprint != nil {
+		err = h.fingerprints.Insert(context.Background(), fingerprint)
+		if err != nil {
+			i18n.Error("upload.fingerprint_error", err)
+		}
+	}
+
+	return infoToDB, nil
+}
+
+// streamSong - отдает файл песни с поддержкой запросов диапазонов.
+// Если download = true и файл дошел до клиента целиком, то засчитывается загрузка.
+func (h *handlers) streamSong(song *SongInfo, download bool, w http.ResponseWriter, r *http.Request) {
+	info, err := h.files.Stat(songFileName(song))
+	if err != nil {
+		if err == storage.ErrNotExist {
+			i18n.Error("song.file_not_found", song.ID.Hex())
+			writeError(w, r, http.StatusNotFound, codeSongFileNotFound, nil)
+			return
+		}
+
+		i18n.Error("storage.stat_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+
+	file, err := storage.Open(h.files, songFileName(song))
+	if err != nil {
+		i18n.Error("storage.open_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Add("Content-Disposition", "filename=\""+song.FileName+"\"")
+	writer := &deliveryWriter{ResponseWriter: w}
+	http.ServeContent(writer, r, song.FileName, info.ModTime, file)
+
+	// Загрузка считается, только если файл дошел до клиента
+	if download && writer.delivered(info.Size) {
+		h.countDownloads([]primitive.ObjectID{song.ID})
+	}
+}
+
+// createPlaylist - добавляет плейлист из песен ids. Возвращает errInvalidIDs,
+// если среди ids есть строки, которые не являются ID.
+func (h *handlers) createPlaylist(ctx context.Context, name string, ids []string) (*PlayList, error) {
+	if len(makeSliceSliceObjectIDs(ids)) != len(ids) {
+		return nil, errInvalidIDs
+	}
+
+	playList := PlayList{
+		ID:   primitive.NewObjectID(),
+		Name: name,
+		IDs:  ids,
+	}
+
+	err := h.playlists.Insert(ctx, &playList)
+	if err != nil {
+		i18n.Error("db.insert_record", err)
+		return nil, err
+	}
+
+	return &playList, nil
+}
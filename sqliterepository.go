@@ -0,0 +1,1178 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteSongRepository - реализация SongRepository для таблицы songs базы SQLite
+type sqliteSongRepository struct {
+	db *sql.DB
+}
+
+// sqlitePlaylistRepository - реализация PlaylistRepository для таблицы playlists базы SQLite
+type sqlitePlaylistRepository struct {
+	db *sql.DB
+}
+
+// sqliteWaveformRepository - реализация WaveformRepository для таблицы waveforms базы SQLite
+type sqliteWaveformRepository struct {
+	db *sql.DB
+}
+
+// sqliteFingerprintRepository - реализация FingerprintRepository для таблицы fingerprints базы SQLite
+type sqliteFingerprintRepository struct {
+	db *sql.DB
+}
+
+// sqliteBlobRepository - реализация BlobRepository для таблицы blobs базы SQLite
+type sqliteBlobRepository struct {
+	db *sql.DB
+}
+
+// sqliteJournalRepository - реализация JournalRepository для таблицы journal базы SQLite
+type sqliteJournalRepository struct {
+	db *sql.DB
+}
+
+// sqliteStatsRepository - реализация StatsRepository для таблицы download_stats базы SQLite
+type sqliteStatsRepository struct {
+	db *sql.DB
+}
+
+// sqliteDownloadEventRepository - реализация DownloadEventRepository для таблицы download_events базы SQLite
+type sqliteDownloadEventRepository struct {
+	db *sql.DB
+}
+
+// newSQLiteSongRepository - конструктор для типа sqliteSongRepository
+func newSQLiteSongRepository(db *sql.DB) *sqliteSongRepository {
+	return &sqliteSongRepository{db: db}
+}
+
+// newSQLitePlaylistRepository - конструктор для типа sqlitePlaylistRepository
+func newSQLitePlaylistRepository(db *sql.DB) *sqlitePlaylistRepository {
+	return &sqlitePlaylistRepository{db: db}
+}
+
+// newSQLiteWaveformRepository - конструктор для типа sqliteWaveformRepository
+func newSQLiteWaveformRepository(db *sql.DB) *sqliteWaveformRepository {
+	return &sqliteWaveformRepository{db: db}
+}
+
+// newSQLiteFingerprintRepository - конструктор для типа sqliteFingerprintRepository
+func newSQLiteFingerprintRepository(db *sql.DB) *sqliteFingerprintRepository {
+	return &sqliteFingerprintRepository{db: db}
+}
+
+// newSQLiteBlobRepository - конструктор для типа sqliteBlobRepository
+func newSQLiteBlobRepository(db *sql.DB) *sqliteBlobRepository {
+	return &sqliteBlobRepository{db: db}
+}
+
+// newSQLiteJournalRepository - конструктор для типа sqliteJournalRepository
+func newSQLiteJournalRepository(db *sql.DB) *sqliteJournalRepository {
+	return &sqliteJournalRepository{db: db}
+}
+
+// newSQLiteStatsRepository - конструктор для типа sqliteStatsRepository
+func newSQLiteStatsRepository(db *sql.DB) *sqliteStatsRepository {
+	return &sqliteStatsRepository{db: db}
+}
+
+// sqlitePlayEventRepository - реализация PlayEventRepository для таблицы play_events базы SQLite
+type sqlitePlayEventRepository struct {
+	db *sql.DB
+}
+
+// newSQLiteDownloadEventRepository - конструктор для типа sqliteDownloadEventRepository
+func newSQLiteDownloadEventRepository(db *sql.DB) *sqliteDownloadEventRepository {
+	return &sqliteDownloadEventRepository{db: db}
+}
+
+// newSQLitePlayEventRepository - конструктор для типа sqlitePlayEventRepository
+func newSQLitePlayEventRepository(db *sql.DB) *sqlitePlayEventRepository {
+	return &sqlitePlayEventRepository{db: db}
+}
+
+// songFields - столбцы таблицы songs кроме id, в порядке аргументов songArgs
+var songFields = []string{
+	"file_name", "title", "artist", "genre", "album", "album_artist", "bitrate", "duration",
+	"count_of_download", "size", "upload_date", "loudness", "true_peak", "track_gain", "track_peak",
+	"is_analyzed", "album_gain", "album_peak", "is_album_analyzed", "payload_hash", "duplicate_of",
+	"blob", "deleted_at", "trashed_from", "count_of_plays",
+}
+
+// songColumns - столбцы, из которых читается песня в scanSong
+var songColumns = "id, " + strings.Join(songFields, ", ")
+
+// playlistColumns - столбцы, из которых читается плейлист в scanPlaylist
+const playlistColumns = "id, name, ids, deleted_at"
+
+// rowScanner - общий интерфейс sql.Row и sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// sqliteError - переводит ошибки SQLite в ошибки репозиториев
+func sqliteError(err error) error {
+	if err == sql.ErrNoRows {
+		return errNotFound
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return errDuplicate
+		}
+	}
+
+	return err
+}
+
+// changeError - ошибка изменения одной записи, errNotFound, если запись не нашлась
+func changeError(result sql.Result, err error) error {
+	if err != nil {
+		return sqliteError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+// placeholders - список из n параметров запроса: ?, ?, ?
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// idArgs - ID в виде аргументов запроса
+func idArgs(ids []primitive.ObjectID) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id.Hex()
+	}
+
+	return args
+}
+
+// sqliteLimit - ограничение количества записей для LIMIT, count = 0 - без ограничения
+func sqliteLimit(count int) int {
+	if count <= 0 {
+		return -1
+	}
+
+	return count
+}
+
+// sqliteSongSortColumns - столбцы таблицы songs для полей сортировки songSortFields
+var sqliteSongSortColumns = map[string]string{
+	sortUploadDate: "upload_date",
+	sortDownloads:  "count_of_download",
+	sortPlays:      "count_of_plays",
+	sortTitle:      "title",
+	sortArtist:     "artist",
+	sortBitrate:    "bitrate",
+	sortDuration:   "duration",
+}
+
+// sqlitePlaylistSortColumns - столбцы таблицы playlists для полей сортировки playlistSortFields.
+// ObjectId начинается со времени создания, поэтому порядок ID - это порядок создания.
+var sqlitePlaylistSortColumns = map[string]string{
+	sortCreated: "id",
+	sortName:    "name",
+}
+
+// sqlitePageOrder - сортировка страницы по столбцу и по ID в том же направлении с ограничением количества
+func sqlitePageOrder(column string, descending bool) string {
+	order := " ASC"
+	if descending {
+		order = " DESC"
+	}
+
+	if column == "id" {
+		return " ORDER BY id" + order + " LIMIT ?"
+	}
+
+	return " ORDER BY " + column + order + ", id" + order + " LIMIT ?"
+}
+
+// sqliteAfterCursor - условие, что запись идет после позиции курсора
+func sqliteAfterCursor(column string, descending bool, after *pageCursor) (string, []interface{}) {
+	operator := " > ?"
+	if descending {
+		operator = " < ?"
+	}
+
+	if column == "id" {
+		return "id" + operator, []interface{}{after.ID.Hex()}
+	}
+
+	value := after.Value
+	if t, ok := value.(time.Time); ok {
+		value = t.UnixNano()
+	}
+
+	return "(" + column + operator + " OR (" + column + " = ? AND id" + operator + "))",
+		[]interface{}{value, value, after.ID.Hex()}
+}
+
+// nullString - пустая строка хранится как NULL, так же как поле с omitempty в MongoDB
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+// nullTime - время в наносекундах Unix или NULL
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return t.UnixNano()
+}
+
+// timeFromNull - время из наносекунд Unix, NULL - это nil
+func timeFromNull(value sql.NullInt64) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+
+	t := time.Unix(0, value.Int64).UTC()
+	return &t
+}
+
+// ftsQuery - запрос FTS5, совпадающий с записями, в которых есть хотя бы одно из слов.
+// Токенизатор trigram находит только подстроки от трех символов, для более коротких слов
+// возвращается false и поиск идет через containsCondition.
+func ftsQuery(words []string) (string, bool) {
+	phrases := make([]string, len(words))
+	for i, word := range words {
+		if utf8.RuneCountInString(word) < 3 {
+			return "", false
+		}
+		phrases[i] = `"` + strings.Replace(word, `"`, `""`, -1) + `"`
+	}
+
+	return strings.Join(phrases, " OR "), true
+}
+
+// containsCondition - условие, что хотя бы один из столбцов содержит хотя бы одно из слов
+// без учета регистра. LIKE в SQLite не учитывает регистр только латинских букв, поэтому
+// строки сравниваются после fold_case (см. sqliteschema.go), как и в поиске MongoDB.
+func containsCondition(columns []string, words []string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, word := range words {
+		for _, column := range columns {
+			conditions = append(conditions, "instr(fold_case("+column+"), ?) > 0")
+			args = append(args, strings.ToLower(word))
+		}
+	}
+
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// songArgs - значения столбцов songFields для песни
+func songArgs(song *SongInfo) ([]interface{}, error) {
+	var duplicateOf interface{}
+	if song.DuplicateOf != nil {
+		duplicateOf = song.DuplicateOf.Hex()
+	}
+
+	var trashedFrom interface{}
+	if len(song.TrashedFrom) != 0 {
+		data, err := json.Marshal(song.TrashedFrom)
+		if err != nil {
+			return nil, err
+		}
+		trashedFrom = string(data)
+	}
+
+	return []interface{}{
+		song.FileName, song.Title, song.Artist, song.Genre, song.Album, song.AlbumArtist, song.Bitrate, song.Duration,
+		song.CountOfDownload, song.Size, song.UploadDate.UnixNano(), song.Loudness, song.TruePeak, song.TrackGain, song.TrackPeak,
+		song.IsAnalyzed, song.AlbumGain, song.AlbumPeak, song.IsAlbumAnalyzed, nullString(song.PayloadHash), duplicateOf,
+		nullString(song.Blob), nullTime(song.DeletedAt), trashedFrom, song.CountOfPlays,
+	}, nil
+}
+
+// scanSong - читает песню из строки результата со столбцами songColumns
+func scanSong(row rowScanner) (SongInfo, error) {
+	var song SongInfo
+	var id string
+	var uploadDate int64
+	var payloadHash, duplicateOf, blob, trashedFrom sql.NullString
+	var deletedAt sql.NullInt64
+	err := row.Scan(&id,
+		&song.FileName, &song.Title, &song.Artist, &song.Genre, &song.Album, &song.AlbumArtist, &song.Bitrate, &song.Duration,
+		&song.CountOfDownload, &song.Size, &uploadDate, &song.Loudness, &song.TruePeak, &song.TrackGain, &song.TrackPeak,
+		&song.IsAnalyzed, &song.AlbumGain, &song.AlbumPeak, &song.IsAlbumAnalyzed, &payloadHash, &duplicateOf,
+		&blob, &deletedAt, &trashedFrom, &song.CountOfPlays)
+	if err != nil {
+		return song, err
+	}
+
+	song.ID, err = primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return song, err
+	}
+	if duplicateOf.Valid {
+		original, err := primitive.ObjectIDFromHex(duplicateOf.String)
+		if err != nil {
+			return song, err
+		}
+		song.DuplicateOf = &original
+	}
+	if trashedFrom.Valid {
+		err = json.Unmarshal([]byte(trashedFrom.String), &song.TrashedFrom)
+		if err != nil {
+			return song, err
+		}
+	}
+	song.UploadDate = time.Unix(0, uploadDate).UTC()
+	song.PayloadHash = payloadHash.String
+	song.Blob = blob.String
+	song.DeletedAt = timeFromNull(deletedAt)
+
+	return song, nil
+}
+
+// querySongs - песни, выбранные запросом с условием where (вместе с сортировкой и ограничением)
+func (repo *sqliteSongRepository) querySongs(ctx context.Context, where string, args ...interface{}) ([]SongInfo, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT "+songColumns+" FROM songs "+where, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	var songs []SongInfo
+	for rows.Next() {
+		song, err := scanSong(rows)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+
+	return songs, rows.Err()
+}
+
+// findSong - первая песня, выбранная запросом с условием where
+func (repo *sqliteSongRepository) findSong(ctx context.Context, where string, args ...interface{}) (*SongInfo, error) {
+	song, err := scanSong(repo.db.QueryRowContext(ctx, "SELECT "+songColumns+" FROM songs "+where, args...))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return &song, nil
+}
+
+// findID - ID первой песни, подходящей под условие, или пустой ID
+func (repo *sqliteSongRepository) findID(ctx context.Context, where string, args ...interface{}) (primitive.ObjectID, error) {
+	var id string
+	err := repo.db.QueryRowContext(ctx, "SELECT id FROM songs WHERE "+where+" LIMIT 1", args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return primitive.NilObjectID, nil
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return primitive.ObjectIDFromHex(id)
+}
+
+func (repo *sqliteSongRepository) Insert(ctx context.Context, song *SongInfo) error {
+	args, err := songArgs(song)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.db.ExecContext(ctx, "INSERT INTO songs ("+songColumns+") VALUES ("+placeholders(len(songFields)+1)+")",
+		append([]interface{}{song.ID.Hex()}, args...)...)
+	return sqliteError(err)
+}
+
+func (repo *sqliteSongRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*SongInfo, error) {
+	return repo.findSong(ctx, "WHERE id = ? AND deleted_at IS NULL", id.Hex())
+}
+
+func (repo *sqliteSongRepository) FindByIDIncludingTrash(ctx context.Context, id primitive.ObjectID) (*SongInfo, error) {
+	return repo.findSong(ctx, "WHERE id = ?", id.Hex())
+}
+
+func (repo *sqliteSongRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]SongInfo, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	return repo.querySongs(ctx, "WHERE deleted_at IS NULL AND id IN ("+placeholders(len(ids))+") ORDER BY seq", idArgs(ids)...)
+}
+
+func (repo *sqliteSongRepository) FindDuplicate(ctx context.Context, song *SongInfo) (primitive.ObjectID, error) {
+	return repo.findID(ctx, "title = ? AND artist = ? AND genre = ? AND bitrate = ? AND duration = ? AND size = ?",
+		song.Title, song.Artist, song.Genre, song.Bitrate, song.Duration, song.Size)
+}
+
+func (repo *sqliteSongRepository) FindByPayloadHash(ctx context.Context, hash string) (primitive.ObjectID, error) {
+	return repo.findID(ctx, "payload_hash = ?", hash)
+}
+
+func (repo *sqliteSongRepository) List(ctx context.Context, query *SongQuery) ([]SongInfo, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, value)
+	}
+
+	if query.Genre != "" {
+		addCondition("genre = ?", query.Genre)
+	}
+	if query.Artist != "" {
+		addCondition("artist = ?", query.Artist)
+	}
+	if query.MinBitrate != 0 {
+		addCondition("bitrate >= ?", query.MinBitrate)
+	}
+	if query.MaxBitrate != 0 {
+		addCondition("bitrate <= ?", query.MaxBitrate)
+	}
+	if query.MinDuration != 0 {
+		addCondition("duration >= ?", query.MinDuration)
+	}
+	if query.MaxDuration != 0 {
+		addCondition("duration <= ?", query.MaxDuration)
+	}
+	if !query.UploadedFrom.IsZero() {
+		addCondition("upload_date >= ?", query.UploadedFrom.UnixNano())
+	}
+	if !query.UploadedTo.IsZero() {
+		addCondition("upload_date < ?", query.UploadedTo.UnixNano())
+	}
+
+	column := sqliteSongSortColumns[query.Sort]
+	if query.After != nil {
+		condition, cursorArgs := sqliteAfterCursor(column, query.Descending, query.After)
+		conditions = append(conditions, condition)
+		args = append(args, cursorArgs...)
+	}
+
+	return repo.querySongs(ctx, "WHERE "+strings.Join(conditions, " AND ")+sqlitePageOrder(column, query.Descending),
+		append(args, sqliteLimit(query.Limit))...)
+}
+
+func (repo *sqliteSongRepository) Search(ctx context.Context, words []string) ([]SongInfo, error) {
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	if query, ok := ftsQuery(words); ok {
+		return repo.querySongs(ctx, "WHERE deleted_at IS NULL AND seq IN (SELECT rowid FROM songs_fts WHERE songs_fts MATCH ?) ORDER BY seq", query)
+	}
+
+	condition, args := containsCondition([]string{"artist", "genre", "title"}, words)
+	return repo.querySongs(ctx, "WHERE deleted_at IS NULL AND "+condition+" ORDER BY seq", args...)
+}
+
+func (repo *sqliteSongRepository) Update(ctx context.Context, song *SongInfo) error {
+	args, err := songArgs(song)
+	if err != nil {
+		return err
+	}
+
+	return changeError(repo.db.ExecContext(ctx, "UPDATE songs SET "+strings.Join(songFields, " = ?, ")+" = ? WHERE id = ?",
+		append(args, song.ID.Hex())...))
+}
+
+func (repo *sqliteSongRepository) IncrementDownloads(ctx context.Context, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := repo.db.ExecContext(ctx, "UPDATE songs SET count_of_download = count_of_download + 1 WHERE id IN ("+placeholders(len(ids))+")",
+		idArgs(ids)...)
+	return sqliteError(err)
+}
+
+func (repo *sqliteSongRepository) IncrementPlays(ctx context.Context, id primitive.ObjectID) error {
+	return changeError(repo.db.ExecContext(ctx, "UPDATE songs SET count_of_plays = count_of_plays + 1 WHERE id = ?", id.Hex()))
+}
+
+func (repo *sqliteSongRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return changeError(repo.db.ExecContext(ctx, "DELETE FROM songs WHERE id = ?", id.Hex()))
+}
+
+func (repo *sqliteSongRepository) Trash(ctx context.Context, id primitive.ObjectID, positions []PlaylistPosition) error {
+	var trashedFrom interface{}
+	if len(positions) != 0 {
+		data, err := json.Marshal(positions)
+		if err != nil {
+			return err
+		}
+		trashedFrom = string(data)
+	}
+
+	return changeError(repo.db.ExecContext(ctx, "UPDATE songs SET deleted_at = ?, trashed_from = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now().UnixNano(), trashedFrom, id.Hex()))
+}
+
+func (repo *sqliteSongRepository) Restore(ctx context.Context, id primitive.ObjectID, after time.Time) (*SongInfo, error) {
+	var song SongInfo
+	err := withTx(ctx, repo.db, func(tx *sql.Tx) error {
+		var err error
+		song, err = scanSong(tx.QueryRowContext(ctx, "SELECT "+songColumns+" FROM songs WHERE id = ? AND deleted_at > ?",
+			id.Hex(), after.UnixNano()))
+		if err != nil {
+			return sqliteError(err)
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE songs SET deleted_at = NULL, trashed_from = NULL WHERE id = ?", id.Hex())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &song, nil
+}
+
+func (repo *sqliteSongRepository) Trashed(ctx context.Context, after time.Time) ([]SongInfo, error) {
+	return repo.querySongs(ctx, "WHERE deleted_at > ? ORDER BY deleted_at DESC", after.UnixNano())
+}
+
+func (repo *sqliteSongRepository) Expired(ctx context.Context, before time.Time) ([]SongInfo, error) {
+	return repo.querySongs(ctx, "WHERE deleted_at <= ? ORDER BY seq", before.UnixNano())
+}
+
+func (repo *sqliteSongRepository) All(ctx context.Context) ([]SongInfo, error) {
+	return repo.querySongs(ctx, "ORDER BY seq")
+}
+
+func (repo *sqliteSongRepository) WithoutBlob(ctx context.Context) ([]SongInfo, error) {
+	return repo.querySongs(ctx, "WHERE blob IS NULL ORDER BY seq")
+}
+
+func (repo *sqliteSongRepository) SetBlob(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return changeError(repo.db.ExecContext(ctx, "UPDATE songs SET blob = ? WHERE id = ?", nullString(hash), id.Hex()))
+}
+
+func (repo *sqliteSongRepository) CountByBlob(ctx context.Context, hash string) (int, error) {
+	var count int
+	err := repo.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM songs WHERE blob = ?", hash).Scan(&count)
+	return count, sqliteError(err)
+}
+
+func (repo *sqliteSongRepository) WithoutPayloadHash(ctx context.Context) ([]SongInfo, error) {
+	return repo.querySongs(ctx, "WHERE payload_hash IS NULL AND duplicate_of IS NULL ORDER BY seq")
+}
+
+func (repo *sqliteSongRepository) SetPayloadHash(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return changeError(repo.db.ExecContext(ctx, "UPDATE songs SET payload_hash = ? WHERE id = ?", nullString(hash), id.Hex()))
+}
+
+func (repo *sqliteSongRepository) SetDuplicateOf(ctx context.Context, id primitive.ObjectID, original primitive.ObjectID) error {
+	return changeError(repo.db.ExecContext(ctx, "UPDATE songs SET duplicate_of = ? WHERE id = ?", original.Hex(), id.Hex()))
+}
+
+func (repo *sqliteSongRepository) DuplicateGroups(ctx context.Context) ([]duplicateGroup, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT duplicate_of, id FROM songs WHERE duplicate_of IS NOT NULL ORDER BY duplicate_of, seq")
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	var groups []duplicateGroup
+	for rows.Next() {
+		var originalHex, idHex string
+		err = rows.Scan(&originalHex, &idHex)
+		if err != nil {
+			return nil, err
+		}
+
+		original, err := primitive.ObjectIDFromHex(originalHex)
+		if err != nil {
+			return nil, err
+		}
+		id, err := primitive.ObjectIDFromHex(idHex)
+		if err != nil {
+			return nil, err
+		}
+
+		// Строки отсортированы по оригиналу, поэтому копии одной песни идут подряд
+		if len(groups) == 0 || groups[len(groups)-1].Original != original {
+			groups = append(groups, duplicateGroup{Original: original})
+		}
+		last := &groups[len(groups)-1]
+		last.Duplicates = append(last.Duplicates, id)
+	}
+
+	return groups, rows.Err()
+}
+
+func (repo *sqliteSongRepository) NotAlbumAnalyzed(ctx context.Context) ([]SongInfo, error) {
+	return repo.querySongs(ctx, "WHERE is_album_analyzed = 0 ORDER BY seq")
+}
+
+func (repo *sqliteSongRepository) FindByAlbum(ctx context.Context, album, albumArtist string) ([]SongInfo, error) {
+	return repo.querySongs(ctx, "WHERE album = ? AND (album_artist = ? OR album_artist = '' AND artist = ?) ORDER BY seq",
+		album, albumArtist, albumArtist)
+}
+
+func (repo *sqliteSongRepository) UpdateLoudness(ctx context.Context, song *SongInfo) error {
+	return changeError(repo.db.ExecContext(ctx, `UPDATE songs SET loudness = ?, true_peak = ?, track_gain = ?, track_peak = ?,
+		is_analyzed = ?, album_gain = ?, album_peak = ?, is_album_analyzed = ? WHERE id = ?`,
+		song.Loudness, song.TruePeak, song.TrackGain, song.TrackPeak,
+		song.IsAnalyzed, song.AlbumGain, song.AlbumPeak, song.IsAlbumAnalyzed, song.ID.Hex()))
+}
+
+func (repo *sqliteSongRepository) UpdateMetadata(ctx context.Context, song *SongInfo) error {
+	return changeError(repo.db.ExecContext(ctx, `UPDATE songs SET title = ?, artist = ?, genre = ?, album = ?, album_artist = ?
+		WHERE id = ? AND deleted_at IS NULL`,
+		song.Title, song.Artist, song.Genre, song.Album, song.AlbumArtist, song.ID.Hex()))
+}
+
+// scanPlaylist - читает плейлист из строки результата со столбцами playlistColumns
+func scanPlaylist(row rowScanner) (PlayList, error) {
+	var playList PlayList
+	var id, ids string
+	var deletedAt sql.NullInt64
+	err := row.Scan(&id, &playList.Name, &ids, &deletedAt)
+	if err != nil {
+		return playList, err
+	}
+
+	playList.ID, err = primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return playList, err
+	}
+	err = json.Unmarshal([]byte(ids), &playList.IDs)
+	if err != nil {
+		return playList, err
+	}
+	playList.DeletedAt = timeFromNull(deletedAt)
+
+	return playList, nil
+}
+
+// queryPlaylists - плейлисты, выбранные запросом с условием where (вместе с сортировкой и ограничением)
+func (repo *sqlitePlaylistRepository) queryPlaylists(ctx context.Context, where string, args ...interface{}) ([]PlayList, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT "+playlistColumns+" FROM playlists "+where, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	var playLists []PlayList
+	for rows.Next() {
+		playList, err := scanPlaylist(rows)
+		if err != nil {
+			return nil, err
+		}
+		playLists = append(playLists, playList)
+	}
+
+	return playLists, rows.Err()
+}
+
+// songIDsJSON - список ID песен плейлиста в том виде, в котором он хранится
+func songIDsJSON(ids []string) (string, error) {
+	data, err := json.Marshal(ids)
+	return string(data), err
+}
+
+// containsSong - условие, что в плейлисте есть песня
+const containsSong = "EXISTS (SELECT 1 FROM json_each(playlists.ids) WHERE json_each.value = ?)"
+
+func (repo *sqlitePlaylistRepository) Insert(ctx context.Context, playList *PlayList) error {
+	ids, err := songIDsJSON(playList.IDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.db.ExecContext(ctx, "INSERT INTO playlists ("+playlistColumns+") VALUES (?, ?, ?, ?)",
+		playList.ID.Hex(), playList.Name, ids, nullTime(playList.DeletedAt))
+	return sqliteError(err)
+}
+
+func (repo *sqlitePlaylistRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*PlayList, error) {
+	playList, err := scanPlaylist(repo.db.QueryRowContext(ctx,
+		"SELECT "+playlistColumns+" FROM playlists WHERE id = ? AND deleted_at IS NULL", id.Hex()))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return &playList, nil
+}
+
+func (repo *sqlitePlaylistRepository) FindBySong(ctx context.Context, songID primitive.ObjectID) ([]PlayList, error) {
+	return repo.queryPlaylists(ctx, "WHERE "+containsSong+" ORDER BY seq", songID.Hex())
+}
+
+func (repo *sqlitePlaylistRepository) List(ctx context.Context, query *PlaylistQuery) ([]PlayList, error) {
+	where := "WHERE deleted_at IS NULL"
+	var args []interface{}
+
+	column := sqlitePlaylistSortColumns[query.Sort]
+	if query.After != nil {
+		var condition string
+		condition, args = sqliteAfterCursor(column, query.Descending, query.After)
+		where += " AND " + condition
+	}
+
+	return repo.queryPlaylists(ctx, where+sqlitePageOrder(column, query.Descending), append(args, sqliteLimit(query.Limit))...)
+}
+
+func (repo *sqlitePlaylistRepository) Search(ctx context.Context, words []string) ([]PlayList, error) {
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	if query, ok := ftsQuery(words); ok {
+		return repo.queryPlaylists(ctx, "WHERE deleted_at IS NULL AND seq IN (SELECT rowid FROM playlists_fts WHERE playlists_fts MATCH ?) ORDER BY seq", query)
+	}
+
+	condition, args := containsCondition([]string{"name"}, words)
+	return repo.queryPlaylists(ctx, "WHERE deleted_at IS NULL AND "+condition+" ORDER BY seq", args...)
+}
+
+func (repo *sqlitePlaylistRepository) Update(ctx context.Context, playList *PlayList) error {
+	ids, err := songIDsJSON(playList.IDs)
+	if err != nil {
+		return err
+	}
+
+	return changeError(repo.db.ExecContext(ctx, "UPDATE playlists SET name = ?, ids = ?, deleted_at = ? WHERE id = ?",
+		playList.Name, ids, nullTime(playList.DeletedAt), playList.ID.Hex()))
+}
+
+func (repo *sqlitePlaylistRepository) AddSong(ctx context.Context, id primitive.ObjectID, songID primitive.ObjectID, index int) error {
+	return withTx(ctx, repo.db, func(tx *sql.Tx) error {
+		var data string
+		err := tx.QueryRowContext(ctx, "SELECT ids FROM playlists WHERE id = ?", id.Hex()).Scan(&data)
+		if err != nil {
+			return sqliteError(err)
+		}
+
+		var ids []string
+		err = json.Unmarshal([]byte(data), &ids)
+		if err != nil {
+			return err
+		}
+
+		// Как и $position в MongoDB, индекс за концом списка добавляет песню в конец
+		if index > len(ids) {
+			index = len(ids)
+		}
+		ids = append(ids[:index], append([]string{songID.Hex()}, ids[index:]...)...)
+
+		data, err = songIDsJSON(ids)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE playlists SET ids = ? WHERE id = ?", data, id.Hex())
+		return err
+	})
+}
+
+func (repo *sqlitePlaylistRepository) RemoveSong(ctx context.Context, songID primitive.ObjectID) error {
+	return withTx(ctx, repo.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT id, ids FROM playlists WHERE "+containsSong, songID.Hex())
+		if err != nil {
+			return err
+		}
+
+		changed := make(map[string][]string)
+		for rows.Next() {
+			var id, data string
+			err = rows.Scan(&id, &data)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			var ids []string
+			err = json.Unmarshal([]byte(data), &ids)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			kept := make([]string, 0, len(ids))
+			for _, stored := range ids {
+				if stored != songID.Hex() {
+					kept = append(kept, stored)
+				}
+			}
+			changed[id] = kept
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for id, ids := range changed {
+			data, err := songIDsJSON(ids)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, "UPDATE playlists SET ids = ? WHERE id = ?", data, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *sqlitePlaylistRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return changeError(repo.db.ExecContext(ctx, "DELETE FROM playlists WHERE id = ?", id.Hex()))
+}
+
+func (repo *sqlitePlaylistRepository) Trash(ctx context.Context, id primitive.ObjectID) error {
+	return changeError(repo.db.ExecContext(ctx, "UPDATE playlists SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now().UnixNano(), id.Hex()))
+}
+
+func (repo *sqlitePlaylistRepository) Restore(ctx context.Context, id primitive.ObjectID, after time.Time) error {
+	return changeError(repo.db.ExecContext(ctx, "UPDATE playlists SET deleted_at = NULL WHERE id = ? AND deleted_at > ?",
+		id.Hex(), after.UnixNano()))
+}
+
+func (repo *sqlitePlaylistRepository) Trashed(ctx context.Context, after time.Time) ([]PlayList, error) {
+	return repo.queryPlaylists(ctx, "WHERE deleted_at > ? ORDER BY deleted_at DESC", after.UnixNano())
+}
+
+func (repo *sqlitePlaylistRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM playlists WHERE deleted_at <= ?", before.UnixNano())
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+
+	removed, err := result.RowsAffected()
+	return int(removed), err
+}
+
+func (repo *sqliteWaveformRepository) Insert(ctx context.Context, waveform *Waveform) error {
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO waveforms (id, sample_rate, samples_per_pixel, data) VALUES (?, ?, ?, ?)",
+		waveform.ID.Hex(), waveform.SampleRate, waveform.SamplesPerPixel, waveform.Data)
+	return sqliteError(err)
+}
+
+func (repo *sqliteWaveformRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*Waveform, error) {
+	waveform := Waveform{ID: id}
+	err := repo.db.QueryRowContext(ctx, "SELECT sample_rate, samples_per_pixel, data FROM waveforms WHERE id = ?", id.Hex()).
+		Scan(&waveform.SampleRate, &waveform.SamplesPerPixel, &waveform.Data)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return &waveform, nil
+}
+
+func (repo *sqliteWaveformRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return changeError(repo.db.ExecContext(ctx, "DELETE FROM waveforms WHERE id = ?", id.Hex()))
+}
+
+func (repo *sqliteFingerprintRepository) Insert(ctx context.Context, fingerprint *Fingerprint) error {
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO fingerprints (id, duration, data) VALUES (?, ?, ?)",
+		fingerprint.ID.Hex(), fingerprint.Duration, fingerprint.Data)
+	return sqliteError(err)
+}
+
+func (repo *sqliteFingerprintRepository) EachByDuration(ctx context.Context, min, max int, fn func(fingerprint *Fingerprint)) error {
+	rows, err := repo.db.QueryContext(ctx, "SELECT id, duration, data FROM fingerprints WHERE duration BETWEEN ? AND ?", min, max)
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fingerprint Fingerprint
+		var id string
+		err = rows.Scan(&id, &fingerprint.Duration, &fingerprint.Data)
+		if err != nil {
+			return err
+		}
+
+		fingerprint.ID, err = primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return err
+		}
+		fn(&fingerprint)
+	}
+
+	return rows.Err()
+}
+
+func (repo *sqliteFingerprintRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return changeError(repo.db.ExecContext(ctx, "DELETE FROM fingerprints WHERE id = ?", id.Hex()))
+}
+
+func (repo *sqliteBlobRepository) Acquire(ctx context.Context, hash string, size int64) (bool, error) {
+	created := false
+	err := withTx(ctx, repo.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "UPDATE blobs SET ref_count = ref_count + 1 WHERE hash = ?", hash)
+		if err != nil {
+			return err
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil || updated != 0 {
+			return err
+		}
+
+		created = true
+		_, err = tx.ExecContext(ctx, "INSERT INTO blobs (hash, size, ref_count) VALUES (?, ?, 1)", hash, size)
+		return err
+	})
+
+	return created, sqliteError(err)
+}
+
+func (repo *sqliteBlobRepository) Release(ctx context.Context, hash string) (bool, error) {
+	removed := false
+	err := withTx(ctx, repo.db, func(tx *sql.Tx) error {
+		err := changeError(tx.ExecContext(ctx, "UPDATE blobs SET ref_count = ref_count - 1 WHERE hash = ?", hash))
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM blobs WHERE hash = ? AND ref_count <= 0", hash)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		removed = deleted != 0
+		return err
+	})
+
+	return removed, err
+}
+
+func (repo *sqliteBlobRepository) All(ctx context.Context) ([]Blob, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT hash, size, ref_count FROM blobs")
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	var blobs []Blob
+	for rows.Next() {
+		var blob Blob
+		err = rows.Scan(&blob.Hash, &blob.Size, &blob.RefCount)
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, blob)
+	}
+
+	return blobs, rows.Err()
+}
+
+func (repo *sqliteBlobRepository) SetRefCount(ctx context.Context, hash string, count int, size int64) error {
+	_, err := repo.db.ExecContext(ctx, `INSERT INTO blobs (hash, size, ref_count) VALUES (?, ?, ?)
+		ON CONFLICT (hash) DO UPDATE SET ref_count = excluded.ref_count`, hash, size, count)
+	return sqliteError(err)
+}
+
+func (repo *sqliteBlobRepository) Delete(ctx context.Context, hash string) error {
+	return changeError(repo.db.ExecContext(ctx, "DELETE FROM blobs WHERE hash = ?", hash))
+}
+
+func (repo *sqliteJournalRepository) Insert(ctx context.Context, entry *JournalEntry) error {
+	_, err := repo.db.ExecContext(ctx, `INSERT INTO journal (id, operation, song, blob, old_blob, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, entry.ID.Hex(), entry.Operation, entry.Song.Hex(), entry.Blob, entry.OldBlob,
+		entry.CreatedAt.UnixNano())
+	return sqliteError(err)
+}
+
+func (repo *sqliteJournalRepository) All(ctx context.Context) ([]JournalEntry, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT id, operation, song, blob, old_blob, created_at FROM journal ORDER BY seq")
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	var entries []JournalEntry
+	for rows.Next() {
+		var entry JournalEntry
+		var id, song string
+		var createdAt int64
+		err = rows.Scan(&id, &entry.Operation, &song, &entry.Blob, &entry.OldBlob, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.ID, err = primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, err
+		}
+		entry.Song, err = primitive.ObjectIDFromHex(song)
+		if err != nil {
+			return nil, err
+		}
+		entry.CreatedAt = time.Unix(0, createdAt).UTC()
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (repo *sqliteJournalRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return changeError(repo.db.ExecContext(ctx, "DELETE FROM journal WHERE id = ?", id.Hex()))
+}
+
+func (repo *sqliteStatsRepository) AddDownloads(ctx context.Context, ids []primitive.ObjectID, day time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return withTx(ctx, repo.db, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			_, err := tx.ExecContext(ctx, `INSERT INTO download_stats (song, day, count) VALUES (?, ?, 1)
+				ON CONFLICT (song, day) DO UPDATE SET count = count + 1`, id.Hex(), day.UnixNano())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *sqliteStatsRepository) TopDownloads(ctx context.Context, since time.Time, count int) ([]SongDownloads, error) {
+	rows, err := repo.db.QueryContext(ctx, `SELECT song, SUM(count) AS total FROM download_stats WHERE day >= ?
+		GROUP BY song ORDER BY total DESC, song LIMIT ?`, since.UnixNano(), sqliteLimit(count))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+
+	var downloads []SongDownloads
+	for rows.Next() {
+		var song string
+		var total int64
+		err = rows.Scan(&song, &total)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := primitive.ObjectIDFromHex(song)
+		if err != nil {
+			return nil, err
+		}
+		downloads = append(downloads, SongDownloads{Song: id, Count: total})
+	}
+
+	return downloads, rows.Err()
+}
+
+func (repo *sqliteStatsRepository) DeleteSong(ctx context.Context, id primitive.ObjectID) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM download_stats WHERE song = ?", id.Hex())
+	return sqliteError(err)
+}
+
+func (repo *sqliteDownloadEventRepository) Insert(ctx context.Context, events []DownloadEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	return withTx(ctx, repo.db, func(tx *sql.Tx) error {
+		for i := range events {
+			_, err := tx.ExecContext(ctx, "INSERT INTO download_events (id, song, time) VALUES (?, ?, ?)",
+				events[i].ID.Hex(), events[i].Song.Hex(), events[i].Time.UnixNano())
+			if err != nil {
+				return sqliteError(err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *sqliteDownloadEventRepository) EachSince(ctx context.Context, since time.Time, fn func(event *DownloadEvent)) error {
+	rows, err := repo.db.QueryContext(ctx, "SELECT id, song, time FROM download_events WHERE time >= ?", since.UnixNano())
+	if err != nil {
+		return sqliteError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event DownloadEvent
+		var id, song string
+		var eventTime int64
+		err = rows.Scan(&id, &song, &eventTime)
+		if err != nil {
+			return err
+		}
+
+		event.ID, err = primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return err
+		}
+		event.Song, err = primitive.ObjectIDFromHex(song)
+		if err != nil {
+			return err
+		}
+		event.Time = time.Unix(0, eventTime).UTC()
+		fn(&event)
+	}
+
+	return rows.Err()
+}
+
+func (repo *sqliteDownloadEventRepository) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM download_events WHERE time < ?", before.UnixNano())
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+
+	removed, err := result.RowsAffected()
+	return int(removed), err
+}
+
+func (repo *sqlitePlayEventRepository) Insert(ctx context.Context, event *PlayEvent) error {
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT INTO play_events (id, song, client, position, completed, counted, time) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		event.ID.Hex(), event.Song.Hex(), event.Client, event.Position, event.Completed, event.Counted, event.Time.UnixNano())
+	return sqliteError(err)
+}
+
+func (repo *sqlitePlayEventRepository) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM play_events WHERE time < ?", before.UnixNano())
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+
+	removed, err := result.RowsAffected()
+	return int(removed), err
+}
+
+func (repo *sqlitePlayEventRepository) DeleteSong(ctx context.Context, id primitive.ObjectID) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM play_events WHERE song = ?", id.Hex())
+	return sqliteError(err)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"modernc.org/sqlite" // драйвер SQLite на чистом Go, собирается без cgo
+)
+
+func init() {
+	// fold_case - строка в нижнем регистре для поиска без учета регистра. Встроенные
+	// lower() и LIKE меняют регистр только латинских букв.
+	sqlite.MustRegisterDeterministicScalarFunction("fold_case", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch value := args[0].(type) {
+		case string:
+			return strings.ToLower(value), nil
+		case []byte:
+			return strings.ToLower(string(value)), nil
+		}
+		return args[0], nil
+	})
+}
+
+// sqliteMigrations - изменения схемы SQLite по версиям: версия миграции - это ее номер в срезе, начиная с 1.
+// Примененные миграции не изменяются, новые добавляются в конец.
+var sqliteMigrations = []string{
+	// 1 - песни, плейлисты, формы волны, отпечатки и блобы.
+	// Поиск идет через FTS5 с токенизатором trigram: он находит подстроки без учета регистра, как регулярные
+	// выражения в MongoDB. Времена хранятся в наносекундах Unix, ID - в шестнадцатеричном виде.
+	`CREATE TABLE songs (
+		seq               INTEGER PRIMARY KEY AUTOINCREMENT,
+		id                TEXT NOT NULL UNIQUE,
+		file_name         TEXT NOT NULL,
+		title             TEXT NOT NULL,
+		artist            TEXT NOT NULL,
+		genre             TEXT NOT NULL,
+		album             TEXT NOT NULL,
+		album_artist      TEXT NOT NULL,
+		bitrate           INTEGER NOT NULL,
+		duration          INTEGER NOT NULL,
+		count_of_download INTEGER NOT NULL,
+		size              INTEGER NOT NULL,
+		upload_date       INTEGER NOT NULL,
+		loudness          REAL NOT NULL,
+		true_peak         REAL NOT NULL,
+		track_gain        REAL NOT NULL,
+		track_peak        REAL NOT NULL,
+		is_analyzed       INTEGER NOT NULL,
+		album_gain        REAL NOT NULL,
+		album_peak        REAL NOT NULL,
+		is_album_analyzed INTEGER NOT NULL,
+		payload_hash      TEXT UNIQUE,
+		duplicate_of      TEXT,
+		blob              TEXT,
+		deleted_at        INTEGER,
+		trashed_from      TEXT
+	);
+	CREATE INDEX songs_upload_date ON songs (upload_date);
+	CREATE INDEX songs_count_of_download ON songs (count_of_download);
+	CREATE INDEX songs_metadata ON songs (title, artist, genre, bitrate, duration, size);
+	CREATE INDEX songs_album ON songs (album);
+	CREATE INDEX songs_blob ON songs (blob);
+	CREATE INDEX songs_duplicate_of ON songs (duplicate_of);
+	CREATE INDEX songs_deleted_at ON songs (deleted_at);
+
+	CREATE VIRTUAL TABLE songs_fts USING fts5(artist, title, genre, content = 'songs', content_rowid = 'seq', tokenize = 'trigram');
+	CREATE TRIGGER songs_fts_insert AFTER INSERT ON songs BEGIN
+		INSERT INTO songs_fts (rowid, artist, title, genre) VALUES (new.seq, new.artist, new.title, new.genre);
+	END;
+	CREATE TRIGGER songs_fts_delete AFTER DELETE ON songs BEGIN
+		INSERT INTO songs_fts (songs_fts, rowid, artist, title, genre) VALUES ('delete', old.seq, old.artist, old.title, old.genre);
+	END;
+	CREATE TRIGGER songs_fts_update AFTER UPDATE OF artist, title, genre ON songs BEGIN
+		INSERT INTO songs_fts (songs_fts, rowid, artist, title, genre) VALUES ('delete', old.seq, old.artist, old.title, old.genre);
+		INSERT INTO songs_fts (rowid, artist, title, genre) VALUES (new.seq, new.artist, new.title, new.genre);
+	END;
+
+	CREATE TABLE playlists (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT NOT NULL UNIQUE,
+		name       TEXT NOT NULL,
+		ids        TEXT NOT NULL,
+		deleted_at INTEGER
+	);
+	CREATE INDEX playlists_deleted_at ON playlists (deleted_at);
+
+	CREATE VIRTUAL TABLE playlists_fts USING fts5(name, content = 'playlists', content_rowid = 'seq', tokenize = 'trigram');
+	CREATE TRIGGER playlists_fts_insert AFTER INSERT ON playlists BEGIN
+		INSERT INTO playlists_fts (rowid, name) VALUES (new.seq, new.name);
+	END;
+	CREATE TRIGGER playlists_fts_delete AFTER DELETE ON playlists BEGIN
+		INSERT INTO playlists_fts (playlists_fts, rowid, name) VALUES ('delete', old.seq, old.name);
+	END;
+	CREATE TRIGGER playlists_fts_update AFTER UPDATE OF name ON playlists BEGIN
+		INSERT INTO playlists_fts (playlists_fts, rowid, name) VALUES ('delete', old.seq, old.name);
+		INSERT INTO playlists_fts (rowid, name) VALUES (new.seq, new.name);
+	END;
+
+	CREATE TABLE waveforms (
+		id                TEXT PRIMARY KEY,
+		sample_rate       INTEGER NOT NULL,
+		samples_per_pixel INTEGER NOT NULL,
+		data              BLOB NOT NULL
+	);
+
+	CREATE TABLE fingerprints (
+		id       TEXT PRIMARY KEY,
+		duration INTEGER NOT NULL,
+		data     BLOB NOT NULL
+	);
+	CREATE INDEX fingerprints_duration ON fingerprints (duration);
+
+	CREATE TABLE blobs (
+		hash      TEXT PRIMARY KEY,
+		size      INTEGER NOT NULL,
+		ref_count INTEGER NOT NULL
+	);`,
+
+	// 2 - по метаданным ищутся дубликаты, поэтому они уникальны, как и в MongoDB
+	`DROP INDEX songs_metadata;
+	CREATE UNIQUE INDEX songs_metadata ON songs (title, artist, genre, bitrate, duration, size);`,
+
+	// 3 - журнал незавершенных изменений песен и их файлов
+	`CREATE TABLE journal (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT NOT NULL UNIQUE,
+		operation  TEXT NOT NULL,
+		song       TEXT NOT NULL,
+		blob       TEXT NOT NULL,
+		old_blob   TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);`,
+
+	// 4 - посуточные счетчики загрузок песен
+	`CREATE TABLE download_stats (
+		song  TEXT NOT NULL,
+		day   INTEGER NOT NULL,
+		count INTEGER NOT NULL,
+		PRIMARY KEY (song, day)
+	);
+	CREATE INDEX download_stats_day ON download_stats (day);`,
+
+	// 5 - события загрузок песен для чартов
+	`CREATE TABLE download_events (
+		seq  INTEGER PRIMARY KEY AUTOINCREMENT,
+		id   TEXT NOT NULL UNIQUE,
+		song TEXT NOT NULL,
+		time INTEGER NOT NULL
+	);
+	CREATE INDEX download_events_time ON download_events (time);`,
+
+	// 6 - количество прослушиваний песен и события прослушиваний
+	`ALTER TABLE songs ADD COLUMN count_of_plays INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX songs_count_of_plays ON songs (count_of_plays);
+	CREATE TABLE play_events (
+		id        TEXT PRIMARY KEY,
+		song      TEXT NOT NULL,
+		client    TEXT NOT NULL,
+		position  INTEGER NOT NULL,
+		completed INTEGER NOT NULL,
+		counted   INTEGER NOT NULL,
+		time      INTEGER NOT NULL
+	);
+	CREATE INDEX play_events_time ON play_events (time);
+	CREATE INDEX play_events_song ON play_events (song);`,
+
+	// 7 - индексы для постраничной отдачи списков: сортировка по полю и ID и фильтры.
+	// Индексы по полю и ID заменяют индексы только по полю.
+	`DROP INDEX songs_upload_date;
+	DROP INDEX songs_count_of_download;
+	DROP INDEX songs_count_of_plays;
+	CREATE INDEX songs_upload_date ON songs (upload_date, id);
+	CREATE INDEX songs_count_of_download ON songs (count_of_download, id);
+	CREATE INDEX songs_count_of_plays ON songs (count_of_plays, id);
+	CREATE INDEX songs_title ON songs (title, id);
+	CREATE INDEX songs_artist ON songs (artist, id);
+	CREATE INDEX songs_bitrate ON songs (bitrate, id);
+	CREATE INDEX songs_duration ON songs (duration, id);
+	CREATE INDEX songs_genre ON songs (genre);
+	CREATE INDEX playlists_name ON playlists (name, id);`,
+
+	// 8 - метаданные снова не уникальны: у разных по отпечатку песен они могут совпадать,
+	// а точные копии отсекает уникальный payload_hash
+	`DROP INDEX songs_metadata;
+	CREATE INDEX songs_metadata ON songs (title, artist, genre, bitrate, duration, size);`,
+}
+
+// connectToSQLite - открывает базу данных SQLite, применяет к ней миграции
+// и создает хранилища записей для ее таблиц
+func connectToSQLite(path string) (repositories, func()) {
+	db, err := openSQLite(path)
+	if err != nil {
+		i18n.Fatal("sqlite.open_error", err)
+	}
+
+	err = migrateSQLite(db)
+	if err != nil {
+		i18n.Fatal("sqlite.migrate_error", err)
+	}
+
+	i18n.Info("sqlite.opened", path)
+
+	return newSQLiteRepositories(db), func() {
+		err := db.Close()
+		if err != nil {
+			i18n.Error("sqlite.close_error", err)
+		}
+	}
+}
+
+// openSQLite - открывает базу данных SQLite по пути path или в памяти, если path = ":memory:"
+func openSQLite(path string) (*sql.DB, error) {
+	// Транзакции сразу берут блокировку на запись, иначе параллельные транзакции,
+	// начавшиеся с чтения, не смогут продолжить запись и завершатся ошибкой
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate")
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if path == ":memory:" {
+		// Каждое соединение с ":memory:" открывает свою отдельную базу
+		db.SetMaxOpenConns(1)
+	}
+
+	return db, nil
+}
+
+// newSQLiteRepositories - хранилища записей в таблицах базы данных SQLite
+func newSQLiteRepositories(db *sql.DB) repositories {
+	return repositories{
+		songs:          newSQLiteSongRepository(db),
+		playlists:      newSQLitePlaylistRepository(db),
+		waveforms:      newSQLiteWaveformRepository(db),
+		fingerprints:   newSQLiteFingerprintRepository(db),
+		blobs:          newSQLiteBlobRepository(db),
+		journal:        newSQLiteJournalRepository(db),
+		stats:          newSQLiteStatsRepository(db),
+		downloadEvents: newSQLiteDownloadEventRepository(db),
+		playEvents:     newSQLitePlayEventRepository(db),
+	}
+}
+
+// migrateSQLite - применяет миграции, которых еще нет в таблице _migrations.
+// Каждая миграция выполняется в своей транзакции вместе с записью о ней.
+func migrateSQLite(db *sql.DB) error {
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS _migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM _migrations").Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for version < len(sqliteMigrations) {
+		version++
+		err = withTx(ctx, db, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, sqliteMigrations[version-1])
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, "INSERT INTO _migrations (version, applied_at) VALUES (?, ?)",
+				version, time.Now().UnixNano())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		i18n.Info("sqlite.migration_applied", version)
+	}
+
+	return nil
+}
+
+// withTx - выполняет fn в транзакции. Если fn возвращает ошибку, то транзакция откатывается.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestSQLite - база SQLite в памяти с примененными миграциями
+func newTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := openSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = migrateSQLite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	db := newTestSQLite(t)
+
+	// Повторный запуск не применяет миграции заново
+	err := migrateSQLite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count, version int
+	err = db.QueryRow("SELECT COUNT(*), MAX(version) FROM _migrations").Scan(&count, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(sqliteMigrations) || version != len(sqliteMigrations) {
+		t.Errorf("_migrations: %d rows, version %d, want %d", count, version, len(sqliteMigrations))
+	}
+
+	// Миграция 8 делает индекс метаданных неуникальным
+	var unique int
+	err = db.QueryRow(`SELECT "unique" FROM pragma_index_list('songs') WHERE name = 'songs_metadata'`).Scan(&unique)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unique != 0 {
+		t.Error("songs_metadata is unique")
+	}
+
+	songs := newSQLiteSongRepository(db)
+	for _, hash := range []string{"first", "second"} {
+		err = songs.Insert(context.Background(), &SongInfo{ID: primitive.NewObjectID(), Title: "Same", PayloadHash: hash})
+		if err != nil {
+			t.Errorf("Insert song with the same metadata: %v", err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local - драйвер хранилища в каталоге локальной файловой системы
+type Local struct {
+	directory string
+}
+
+// NewLocal - конструктор для типа Local, создает каталог, если его нет
+func NewLocal(directory string) (*Local, error) {
+	err := os.MkdirAll(directory, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Local{directory: directory}, nil
+}
+
+// path - путь к файлу хранилища в файловой системе
+func (l *Local) path(name string) string {
+	return filepath.Join(l.directory, filepath.FromSlash(name))
+}
+
+// Put - записывает данные во временный файл и переименовывает его,
+// поэтому читатели никогда не видят файл записанным частично
+func (l *Local) Put(name string, r io.Reader, size int64) error {
+	path := l.path(name)
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(file, r, size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return nil
+}
+
+// createTemp - создает скрытый временный файл загрузки в каталоге хранилища
+func (l *Local) createTemp() (*os.File, error) {
+	return ioutil.TempFile(l.directory, ".upload-*")
+}
+
+// commit - переименовывает временный файл загрузки в файл хранилища
+func (l *Local) commit(tempName, name string) error {
+	path := l.path(name)
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempName, path)
+}
+
+// move - переименовывает файл хранилища
+func (l *Local) move(from, to string) error {
+	_, err := l.Stat(from)
+	if err != nil {
+		return err
+	}
+
+	return l.commit(l.path(from), to)
+}
+
+// Get - открывает файл на чтение
+func (l *Local) Get(name string) (io.ReadCloser, error) {
+	return l.Open(name)
+}
+
+// Open - открывает файл с произвольным доступом
+func (l *Local) Open(name string) (io.ReadSeekCloser, error) {
+	file, err := os.Open(l.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// NewRangeReader - открывает на чтение часть файла
+func (l *Local) NewRangeReader(name string, offset, length int64) (io.ReadCloser, error) {
+	file, err := l.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = file.Seek(offset, os.SEEK_SET)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if length < 0 {
+		return file, nil
+	}
+
+	return &limitedReadCloser{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
+// Stat - возвращает сведения о файле
+func (l *Local) Stat(name string) (*FileInfo, error) {
+	info, err := os.Stat(l.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete - удаляет файл
+func (l *Local) Delete(name string) error {
+	err := os.Remove(l.path(name))
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+
+	return err
+}
+
+// List - обходит каталог хранилища. Скрытые файлы (в том числе временные файлы Put) пропускаются.
+func (l *Local) List(prefix string) ([]FileInfo, error) {
+	var files []FileInfo
+	err := filepath.Walk(l.directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		name, err := filepath.Rel(l.directory, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+
+		if strings.HasPrefix(name, prefix) {
+			files = append(files, FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()})
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// listTemp - находит временные файлы Put и загрузок, они хранятся рядом с обычными файлами
+func (l *Local) listTemp() ([]FileInfo, error) {
+	var files []FileInfo
+	err := filepath.Walk(l.directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !(strings.HasPrefix(info.Name(), ".put-") || strings.HasPrefix(info.Name(), ".upload-")) {
+			return nil
+		}
+
+		name, err := filepath.Rel(l.directory, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, FileInfo{Name: filepath.ToSlash(name), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+
+	return files, err
+}
+
+// limitedReadCloser - ограниченное чтение с закрытием исходного файла
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// maxSkip - на сколько байт вперед можно переместиться дочитыванием открытого потока,
+// а не открытием нового
+const maxSkip = 64 * 1024
+
+var errNegativeOffset = errors.New("Отрицательная позиция в файле")
+
+// rangeFile - файл с произвольным доступом поверх NewRangeReader. Поток открывается
+// лениво с текущей позиции и переоткрывается только при перемещении назад или далеко вперед.
+type rangeFile struct {
+	storage Storage
+	name    string
+	size    int64
+	offset  int64         // текущая позиция
+	reader  io.ReadCloser // открытый поток
+	readPos int64         // позиция, с которой читает reader
+}
+
+// Read - читает данные с текущей позиции
+func (f *rangeFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	if f.reader != nil && (f.offset < f.readPos || f.offset-f.readPos > maxSkip) {
+		f.reader.Close()
+		f.reader = nil
+	}
+
+	if f.reader == nil {
+		reader, err := f.storage.NewRangeReader(f.name, f.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		f.reader, f.readPos = reader, f.offset
+	}
+
+	if f.offset > f.readPos {
+		n, err := io.CopyN(io.Discard, f.reader, f.offset-f.readPos)
+		f.readPos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.reader.Read(p)
+	f.offset += int64(n)
+	f.readPos += int64(n)
+	if err == io.EOF && f.offset < f.size {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// Seek - перемещает текущую позицию, поток при этом не переоткрывается
+func (f *rangeFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_CUR:
+		offset += f.offset
+	case os.SEEK_END:
+		offset += f.size
+	}
+
+	if offset < 0 {
+		return f.offset, errNegativeOffset
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// Close - закрывает открытый поток
+func (f *rangeFile) Close() error {
+	if f.reader == nil {
+		return nil
+	}
+
+	err := f.reader.Close()
+	f.reader = nil
+	return err
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3 - драйвер для S3-совместимых хранилищ (Amazon S3, MinIO и т.п.).
+// Используются адреса в стиле path: endpoint/bucket/name.
+type S3 struct {
+	endpoint *url.URL
+	bucket   string
+	signer   signer
+	client   *http.Client
+}
+
+// s3Error - ответ S3 с описанием ошибки
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// listResult - ответ S3 на запрос ListObjectsV2
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// NewS3 - конструктор для типа S3, endpoint - адрес сервера вида http://host:port
+func NewS3(endpoint, bucket, region, accessKey, secretKey string) (*S3, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("Некорректный адрес хранилища S3: %q", endpoint)
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3{
+		endpoint: u,
+		bucket:   bucket,
+		signer:   signer{region: region, accessKey: accessKey, secretKey: secretKey},
+		client:   &http.Client{},
+	}, nil
+}
+
+// do - подписывает и выполняет запрос к объекту name (или к корзине, если name пустое).
+// Ответы с кодом не из 2xx превращаются в ошибки, 404 - в ErrNotExist.
+func (s *S3) do(method, name string, query url.Values, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+	if name != "" {
+		u.Path += "/" + name
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	s.signer.sign(req, "", time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return nil, ErrNotExist
+	}
+
+	var e s3Error
+	data, _ := ioutil.ReadAll(resp.Body)
+	if xml.Unmarshal(data, &e) != nil || e.Code == "" {
+		return nil, fmt.Errorf("Хранилище S3 вернуло %v", resp.Status)
+	}
+
+	return nil, fmt.Errorf("Хранилище S3 вернуло %v: %v: %v", resp.Status, e.Code, e.Message)
+}
+
+// Put - загружает объект одним запросом PUT
+func (s *S3) Put(name string, r io.Reader, size int64) error {
+	resp, err := s.do(http.MethodPut, name, nil, io.LimitReader(r, size), size, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// Get - открывает объект на чтение
+func (s *S3) Get(name string) (io.ReadCloser, error) {
+	return s.NewRangeReader(name, 0, -1)
+}
+
+// NewRangeReader - открывает на чтение часть объекта с помощью заголовка Range
+func (s *S3) NewRangeReader(name string, offset, length int64) (io.ReadCloser, error) {
+	header := make(http.Header)
+	switch {
+	case length == 0:
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	case length > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%v-%v", offset, offset+length-1))
+	case offset > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%v-", offset))
+	}
+
+	resp, err := s.do(http.MethodGet, name, nil, nil, 0, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// Stat - возвращает сведения об объекте по запросу HEAD
+func (s *S3) Stat(name string) (*FileInfo, error) {
+	resp, err := s.do(http.MethodHead, name, nil, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	info := &FileInfo{Name: name, Size: resp.ContentLength}
+	info.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return info, nil
+}
+
+// Delete - удаляет объект. S3 не сообщает об отсутствии объекта, поэтому сначала выполняется Stat.
+func (s *S3) Delete(name string) error {
+	_, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodDelete, name, nil, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// List - перечисляет объекты корзины запросами ListObjectsV2 (постранично)
+func (s *S3) List(prefix string) ([]FileInfo, error) {
+	var files []FileInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			files = append(files, FileInfo{Name: object.Key, Size: object.Size, ModTime: object.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
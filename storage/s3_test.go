@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// s3Stub - минимальный S3-совместимый сервер в памяти (одна корзина, адреса в стиле path).
+// Поддерживает PUT, GET с Range, HEAD, DELETE и постраничный ListObjectsV2.
+type s3Stub struct {
+	bucket   string
+	pageSize int // сколько объектов отдается в одном ответе ListObjectsV2
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	times   map[string]time.Time
+}
+
+func newS3Stub(t *testing.T, bucket string) (*s3Stub, *S3) {
+	stub := &s3Stub{bucket: bucket, pageSize: 2, objects: map[string][]byte{}, times: map[string]time.Time{}}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3(server.URL, bucket, "", "minio", "minio123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stub, s
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, signAlgorithm+" Credential=minio/") || r.Header.Get("X-Amz-Date") == "" {
+		s.error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path != s.bucket && !strings.HasPrefix(path, s.bucket+"/") {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(path, s.bucket), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key == "" {
+		if r.Method != http.MethodGet || r.URL.Query().Get("list-type") != "2" {
+			s.error(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		s.list(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			s.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.objects[key] = data
+		s.times[key] = time.Now().UTC().Truncate(time.Second)
+	case http.MethodGet, http.MethodHead:
+		data, ok := s.objects[key]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		http.ServeContent(w, r, key, s.times[key], bytes.NewReader(data))
+	case http.MethodDelete:
+		delete(s.objects, key)
+		delete(s.times, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list - ответ ListObjectsV2, маркер продолжения - это номер первого объекта следующей страницы
+func (s *s3Stub) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+	end := start + s.pageSize
+	if end > len(keys) {
+		end = len(keys)
+	}
+
+	var result listResult
+	for _, key := range keys[start:end] {
+		result.Contents = append(result.Contents, struct {
+			Key          string    `xml:"Key"`
+			Size         int64     `xml:"Size"`
+			LastModified time.Time `xml:"LastModified"`
+		}{key, int64(len(s.objects[key])), s.times[key]})
+	}
+	if end < len(keys) {
+		result.IsTruncated = true
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"ListBucketResult"`
+		listResult
+	}{listResult: result})
+}
+
+func (s *s3Stub) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		s3Error
+	}{s3Error: s3Error{Code: code, Message: http.StatusText(status)}})
+}
+
+func put(t *testing.T, s Storage, name, data string) {
+	t.Helper()
+	err := s.Put(name, strings.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Put(%q): %v", name, err)
+	}
+}
+
+// reader - возвращает функцию, читающую результат Get или NewRangeReader целиком
+func reader(t *testing.T) func(io.ReadCloser, error) string {
+	return func(r io.ReadCloser, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
+
+func TestS3PutGet(t *testing.T) {
+	stub, s := newS3Stub(t, "music")
+	read := reader(t)
+
+	put(t, s, "ab/abcdef.mp3", "0123456789")
+	put(t, s, "empty.mp3", "")
+	if got := string(stub.objects["ab/abcdef.mp3"]); got != "0123456789" {
+		t.Fatalf("stored %q", got)
+	}
+
+	if got := read(s.Get("ab/abcdef.mp3")); got != "0123456789" {
+		t.Errorf("Get = %q", got)
+	}
+	if got := read(s.Get("empty.mp3")); got != "" {
+		t.Errorf("Get(empty) = %q", got)
+	}
+	if got := read(s.NewRangeReader("ab/abcdef.mp3", 2, 3)); got != "234" {
+		t.Errorf("NewRangeReader(2, 3) = %q", got)
+	}
+	if got := read(s.NewRangeReader("ab/abcdef.mp3", 7, -1)); got != "789" {
+		t.Errorf("NewRangeReader(7, -1) = %q", got)
+	}
+
+	info, err := s.Stat("ab/abcdef.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 10 || info.ModTime.IsZero() {
+		t.Errorf("Stat = %+v", info)
+	}
+
+	file, err := Open(s, "ab/abcdef.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Seek(-4, io.SeekEnd)
+	if got := read(file, nil); got != "6789" {
+		t.Errorf("Open + Seek = %q", got)
+	}
+
+	_, err = s.Get("missing.mp3")
+	if err != ErrNotExist {
+		t.Errorf("Get(missing) error = %v, want ErrNotExist", err)
+	}
+	_, err = s.Stat("missing.mp3")
+	if err != ErrNotExist {
+		t.Errorf("Stat(missing) error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestS3Delete(t *testing.T) {
+	_, s := newS3Stub(t, "music")
+	put(t, s, "a.mp3", "data")
+
+	err := s.Delete("a.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Stat("a.mp3")
+	if err != ErrNotExist {
+		t.Errorf("Stat after Delete error = %v, want ErrNotExist", err)
+	}
+	err = s.Delete("a.mp3")
+	if err != ErrNotExist {
+		t.Errorf("second Delete error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestS3List(t *testing.T) {
+	_, s := newS3Stub(t, "music")
+	for _, name := range []string{"ab/1", "ab/2", "ab/3", "cd/4", "quarantine/ab/5"} {
+		put(t, s, name, name)
+	}
+
+	files, err := s.List("ab/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+		if f.Size != int64(len(f.Name)) {
+			t.Errorf("%v: size %v", f.Name, f.Size)
+		}
+	}
+	// три объекта не помещаются на одну страницу заглушки, List должен пройти по маркеру продолжения
+	if strings.Join(names, ",") != "ab/1,ab/2,ab/3" {
+		t.Errorf("List(ab/) = %v", names)
+	}
+
+	files, err = s.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 5 {
+		t.Errorf("List() returned %d files, want 5", len(files))
+	}
+}
+
+func TestS3Move(t *testing.T) {
+	stub, s := newS3Stub(t, "music")
+	read := reader(t)
+	put(t, s, "ab/song.mp3", "payload")
+
+	err := Move(s, "ab/song.mp3", "quarantine/ab/song.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stub.objects["ab/song.mp3"]; ok {
+		t.Error("source still exists after Move")
+	}
+	if got := read(s.Get("quarantine/ab/song.mp3")); got != "payload" {
+		t.Errorf("moved file = %q", got)
+	}
+
+	err = Move(s, "ab/song.mp3", "cd/song.mp3")
+	if err != ErrNotExist {
+		t.Errorf("Move(missing) error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestS3ErrorResponse(t *testing.T) {
+	_, s := newS3Stub(t, "music")
+	s.bucket = "other"
+
+	err := s.Put("a.mp3", strings.NewReader("x"), 1)
+	if err != ErrNotExist {
+		t.Errorf("Put to missing bucket error = %v, want ErrNotExist", err)
+	}
+
+	s.bucket = "music"
+	s.signer.accessKey = "intruder"
+	err = s.Put("a.mp3", strings.NewReader("x"), 1)
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put with wrong key error = %v, want AccessDenied", err)
+	}
+}
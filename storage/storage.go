@@ -0,0 +1,104 @@
+// Package storage - хранилище файлов песен. Поддерживаются драйверы
+// для локальной файловой системы и для S3-совместимых хранилищ.
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotExist - файла с таким именем нет в хранилище
+var ErrNotExist = errors.New("Файл не найден в хранилище")
+
+// FileInfo - сведения о хранимом файле
+type FileInfo struct {
+	Name    string    // имя файла в хранилище, части пути разделяются "/"
+	Size    int64     // размер в байтах
+	ModTime time.Time // время последнего изменения
+}
+
+// Storage - хранилище файлов. Имена файлов - это пути относительно корня хранилища
+// с разделителем "/". Если файла нет, то методы возвращают ErrNotExist.
+type Storage interface {
+	// Put - сохраняет size байт из r под именем name, существующий файл заменяется
+	Put(name string, r io.Reader, size int64) error
+	// Get - открывает файл на чтение целиком
+	Get(name string) (io.ReadCloser, error)
+	// NewRangeReader - открывает на чтение length байт файла начиная с offset,
+	// при отрицательном length читается до конца файла
+	NewRangeReader(name string, offset, length int64) (io.ReadCloser, error)
+	// Stat - возвращает сведения о файле
+	Stat(name string) (*FileInfo, error)
+	// Delete - удаляет файл
+	Delete(name string) error
+	// List - возвращает сведения о файлах, имена которых начинаются с prefix
+	List(prefix string) ([]FileInfo, error)
+}
+
+// seekOpener - хранилище, которое само умеет открывать файлы с произвольным доступом
+type seekOpener interface {
+	Open(name string) (io.ReadSeekCloser, error)
+}
+
+// Open - открывает файл хранилища с произвольным доступом, например для парсеров
+// метаданных и http.ServeContent. Если драйвер не умеет этого сам, то чтение
+// выполняется через NewRangeReader начиная с текущей позиции.
+func Open(s Storage, name string) (io.ReadSeekCloser, error) {
+	if opener, ok := s.(seekOpener); ok {
+		return opener.Open(name)
+	}
+
+	info, err := s.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rangeFile{storage: s, name: name, size: info.Size}, nil
+}
+
+// mover - хранилище, которое умеет переименовывать файлы без копирования
+type mover interface {
+	move(from, to string) error
+}
+
+// Move - переименовывает файл хранилища. Если драйвер не умеет переименовывать,
+// то файл копируется и затем удаляется.
+func Move(s Storage, from, to string) error {
+	if m, ok := s.(mover); ok {
+		return m.move(from, to)
+	}
+
+	info, err := s.Stat(from)
+	if err != nil {
+		return err
+	}
+
+	reader, err := s.Get(from)
+	if err != nil {
+		return err
+	}
+	err = s.Put(to, reader, info.Size)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	return s.Delete(from)
+}
+
+// tempLister - хранилище, в котором могут оставаться временные файлы
+// незавершенных записей, например после аварийной остановки сервера
+type tempLister interface {
+	listTemp() ([]FileInfo, error)
+}
+
+// ListTemp - возвращает временные файлы хранилища. Если драйвер не создает
+// временных файлов в хранилище, то возвращается пустой список.
+func ListTemp(s Storage) ([]FileInfo, error) {
+	if t, ok := s.(tempLister); ok {
+		return t.listTemp()
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// stager - хранилище, которое создает временные файлы загрузок рядом с хранимыми файлами
+// и умеет атомарно переименовывать их в хранимые
+type stager interface {
+	createTemp() (*os.File, error)
+	commit(tempName, name string) error
+}
+
+// Upload - временный файл загружаемой песни. Пока он не сохранен методом Commit,
+// в хранилище его не видно. Для хранилищ без поддержки временных файлов
+// он создается в системном временном каталоге и при сохранении копируется через Put.
+type Upload struct {
+	*os.File
+	storage Storage
+	done    bool // файл сохранен или удален
+}
+
+// NewUpload - создает временный файл загрузки для хранилища s
+func NewUpload(s Storage) (*Upload, error) {
+	var file *os.File
+	var err error
+	if st, ok := s.(stager); ok {
+		file, err = st.createTemp()
+	} else {
+		file, err = ioutil.TempFile("", "upload-*")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Upload{File: file, storage: s}, nil
+}
+
+// Commit - сохраняет загруженный файл в хранилище под именем name
+func (u *Upload) Commit(name string) error {
+	if st, ok := u.storage.(stager); ok {
+		err := u.File.Close()
+		if err == nil {
+			err = st.commit(u.Name(), name)
+		}
+		if err != nil {
+			return err
+		}
+
+		u.done = true
+		return nil
+	}
+
+	size, err := u.Seek(0, os.SEEK_END)
+	if err == nil {
+		_, err = u.Seek(0, os.SEEK_SET)
+	}
+	if err == nil {
+		err = u.storage.Put(name, u.File, size)
+	}
+	if err != nil {
+		return err
+	}
+
+	u.Discard()
+	return nil
+}
+
+// Discard - удаляет временный файл, если он не был сохранен. Повторные вызовы ничего не делают.
+func (u *Upload) Discard() {
+	if u.done {
+		return
+	}
+
+	u.File.Close()
+	os.Remove(u.Name())
+	u.done = true
+}
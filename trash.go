@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/STEJLS/AudioServer/i18n"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// trashJob - корзина песен и плейлистов. Удаленные записи помечаются полем DeletedAt
+// и скрываются из выдачи, а по истечении срока хранения удаляются окончательно вместе с файлами.
+type trashJob struct {
+	store     *blobStore
+	interval  time.Duration
+	retention time.Duration // сколько удаленные записи можно восстановить
+}
+
+// trashJSON - содержимое корзины для отдачи пользователю
+type trashJSON struct {
+	Songs     []SongInfo `json:"Songs"`
+	Playlists []PlayList `json:"Playlists"`
+	Retention int        `json:"Retention"` // срок хранения в днях
+}
+
+// newTrashJob - конструктор для типа trashJob, interval - период очистки в минутах,
+// retention - срок хранения в днях
+func newTrashJob(store *blobStore, interval, retention int) *trashJob {
+	if interval == 0 {
+		interval = defaultTrashPurgeInterval
+	}
+	if retention == 0 {
+		retention = defaultTrashRetention
+	}
+
+	return &trashJob{
+		store:     store,
+		interval:  time.Duration(interval) * time.Minute,
+		retention: time.Duration(retention) * 24 * time.Hour,
+	}
+}
+
+// restorableSince - записи, удаленные позже этого времени, еще можно восстановить
+func (job *trashJob) restorableSince() time.Time {
+	return time.Now().Add(-job.retention)
+}
+
+// retentionDays - срок хранения корзины в днях
+func (job *trashJob) retentionDays() int {
+	return int(job.retention / (24 * time.Hour))
+}
+
+// run - запускает бесконечный цикл очистки корзины, вызывается в отдельной горутине
+func (job *trashJob) run() {
+	i18n.Info("trash_job.started", job.interval, job.retention)
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		job.purge()
+		<-ticker.C
+	}
+}
+
+// purge - окончательно удаляет записи, срок хранения которых в корзине истек
+func (job *trashJob) purge() {
+	ctx := context.Background()
+	expired := job.restorableSince()
+
+	songs, err := job.store.songs.Expired(ctx, expired)
+	if err != nil {
+		i18n.Error("trash_job.find_error", err)
+		return
+	}
+
+	purged := 0
+	for i := range songs {
+		err = job.store.removeSongRecords(&songs[i])
+		if err != nil {
+			i18n.Error("trash_job.song_error", songs[i].ID.Hex(), err)
+			continue
+		}
+		purged++
+	}
+
+	removed, err := job.store.playlists.DeleteExpired(ctx, expired)
+	if err != nil {
+		i18n.Error("trash_job.playlists_error", err)
+		return
+	}
+
+	if purged != 0 || removed != 0 {
+		i18n.Info("trash_job.done", purged, removed)
+	}
+}
+
+// trashSong - перемещает песню в корзину и убирает ее из плейлистов.
+// Позиции песни в плейлистах запоминаются для восстановления.
+func trashSong(ctx context.Context, songs SongRepository, playlists PlaylistRepository, id primitive.ObjectID) error {
+	playLists, err := playlists.FindBySong(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var positions []PlaylistPosition
+	for _, playList := range playLists {
+		for i, songID := range playList.IDs {
+			if songID == id.Hex() {
+				positions = append(positions, PlaylistPosition{Playlist: playList.ID, Index: i})
+			}
+		}
+	}
+
+	err = songs.Trash(ctx, id, positions)
+	if err != nil {
+		return err
+	}
+
+	return playlists.RemoveSong(ctx, id)
+}
+
+// restoreTrashedSong - возвращает песню, удаленную позже deletedAfter, из корзины
+// на прежние места в плейлистах. Плейлисты, которые уже удалены окончательно, пропускаются.
+func restoreTrashedSong(ctx context.Context, songs SongRepository, playlists PlaylistRepository, id primitive.ObjectID, deletedAfter time.Time) error {
+	song, err := songs.Restore(ctx, id, deletedAfter)
+	if err != nil {
+		return err
+	}
+
+	// Если песня удалялась не до конца, то она могла остаться в плейлистах
+	err = playlists.RemoveSong(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Песни вставляются по возрастанию индексов, тогда каждая оказывается на своем прежнем месте
+	sort.Slice(song.TrashedFrom, func(i, j int) bool {
+		return song.TrashedFrom[i].Index < song.TrashedFrom[j].Index
+	})
+
+	for _, position := range song.TrashedFrom {
+		err = playlists.AddSong(ctx, position.Playlist, id, position.Index)
+		if err != nil && err != errNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
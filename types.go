@@ -3,50 +3,137 @@ package main
 import (
 	"time"
 
-	"gopkg.in/mgo.v2/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // IMetadata - интерфейс, который описывает поведение типов, которые возвращают метадынные
-// Они должны уметь отдавать назвение песни, имя испольнителя, название жанра, битрейт, продолжительность песни
+// Они должны уметь отдавать назвение песни, имя испольнителя, название жанра, альбом, исполнителя альбома,
+// битрейт, продолжительность песни
 type IMetadata interface {
 	GetTitle() string
 	GetArtist() string
 	GetGenre() string
+	GetAlbum() string
+	GetAlbumArtist() string
 	GetBitrate() int
 	GetDuration() int
 }
 
 // SongInfo - структура, описывающая информацию песни. Хранится в БД.
 type SongInfo struct {
-	ID              bson.ObjectId `json:"id" bson:"_id,omitempty"`                // ID записи в БД
-	FileName        string        `json:"FileName" bson:"FileName"`               // название песни
-	Title           string        `json:"Title" bson:"Title"`                     // название песни
-	Artist          string        `json:"Artist" bson:"Artist"`                   // исполнитель
-	Genre           string        `json:"Genre" bson:"Genre"`                     // жанр
-	Bitrate         int           `json:"Bitrate" bson:"Bitrate"`                 // килобит в секунду
-	Duration        int           `json:"Duration" bson:"Duration"`               // продолжительность песни в секундах
-	CountOfDownload int64         `json:"CountOfDownload" bson:"CountOfDownload"` // количество загрузок
-	Size            int           `json:"Size" bson:"Size"`                       // размер в байтах
-	UploadDate      time.Time     `json:"UploadDate" bson:"UploadDate"`           // дата загрузки
+	ID              primitive.ObjectID  `json:"id" bson:"_id,omitempty"`                            // ID записи в БД
+	FileName        string              `json:"FileName" bson:"FileName"`                           // название песни
+	Title           string              `json:"Title" bson:"Title"`                                 // название песни
+	Artist          string              `json:"Artist" bson:"Artist"`                               // исполнитель
+	Genre           string              `json:"Genre" bson:"Genre"`                                 // жанр
+	Album           string              `json:"Album" bson:"Album"`                                 // альбом
+	AlbumArtist     string              `json:"AlbumArtist" bson:"AlbumArtist"`                     // исполнитель альбома
+	Bitrate         int                 `json:"Bitrate" bson:"Bitrate"`                             // килобит в секунду
+	Duration        int                 `json:"Duration" bson:"Duration"`                           // продолжительность песни в секундах
+	CountOfDownload int64               `json:"CountOfDownload" bson:"CountOfDownload"`             // количество загрузок
+	CountOfPlays    int64               `json:"CountOfPlays" bson:"CountOfPlays"`                   // количество засчитанных прослушиваний
+	Size            int                 `json:"Size" bson:"Size"`                                   // размер в байтах
+	UploadDate      time.Time           `json:"UploadDate" bson:"UploadDate"`                       // дата загрузки
+	Loudness        float64             `json:"Loudness" bson:"Loudness"`                           // интегральная громкость по EBU R128 в LUFS
+	TruePeak        float64             `json:"TruePeak" bson:"TruePeak"`                           // истинный пиковый уровень в dBTP
+	TrackGain       float64             `json:"TrackGain" bson:"TrackGain"`                         // ReplayGain трека в дБ
+	TrackPeak       float64             `json:"TrackPeak" bson:"TrackPeak"`                         // пиковая амплитуда трека (1.0 - полная шкала)
+	IsAnalyzed      bool                `json:"IsAnalyzed" bson:"IsAnalyzed"`                       // проводился ли анализ громкости
+	AlbumGain       float64             `json:"AlbumGain" bson:"AlbumGain"`                         // ReplayGain альбома (или плейлиста) в дБ
+	AlbumPeak       float64             `json:"AlbumPeak" bson:"AlbumPeak"`                         // пиковая амплитуда альбома
+	IsAlbumAnalyzed bool                `json:"IsAlbumAnalyzed" bson:"IsAlbumAnalyzed"`             // рассчитан ли ReplayGain альбома
+	PayloadHash     string              `json:"PayloadHash" bson:"PayloadHash,omitempty"`           // SHA-256 аудиоданных без тэгов
+	DuplicateOf     *primitive.ObjectID `json:"DuplicateOf,omitempty" bson:"DuplicateOf,omitempty"` // ID песни с такими же аудиоданными
+	Blob            string              `json:"Blob,omitempty" bson:"Blob,omitempty"`               // SHA-256 файла, по нему файл хранится в хранилище
+	DeletedAt       *time.Time          `json:"DeletedAt,omitempty" bson:"DeletedAt,omitempty"`     // когда песня перемещена в корзину
+	TrashedFrom     []PlaylistPosition  `json:"-" bson:"TrashedFrom,omitempty"`                     // позиции в плейлистах до удаления в корзину
 }
 
 // NewSongInfo - конструктор для типа SongInfo на вход принимает id объекта БД, имя файла, размер файла и объект IMetadata
-func NewSongInfo(id bson.ObjectId, fileName string, filesize int, metaData IMetadata) *SongInfo {
-	return &SongInfo{id,
-		fileName,
-		metaData.GetTitle(),
-		metaData.GetArtist(),
-		metaData.GetGenre(),
-		metaData.GetBitrate(),
-		metaData.GetDuration(),
-		initialCountOfDownloads,
-		filesize,
-		time.Now().UTC(),
+func NewSongInfo(id primitive.ObjectID, fileName string, filesize int, metaData IMetadata) *SongInfo {
+	return &SongInfo{
+		ID:              id,
+		FileName:        fileName,
+		Title:           metaData.GetTitle(),
+		Artist:          metaData.GetArtist(),
+		Genre:           metaData.GetGenre(),
+		Album:           metaData.GetAlbum(),
+		AlbumArtist:     metaData.GetAlbumArtist(),
+		Bitrate:         metaData.GetBitrate(),
+		Duration:        metaData.GetDuration(),
+		CountOfDownload: initialCountOfDownloads,
+		Size:            filesize,
+		UploadDate:      time.Now().UTC(),
 	}
 }
 
 type PlayList struct {
-	ID   bson.ObjectId `json:"id" bson:"_id,omitempty"` // ID записи в БД
-	Name string        `json:"Name" bson:"Name"`        // название плейлиста
-	IDs  []string      `json:"IDs" bson:"IDs"`          // список id песен
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`                        // ID записи в БД
+	Name      string             `json:"Name" bson:"Name"`                               // название плейлиста
+	IDs       []string           `json:"IDs" bson:"IDs"`                                 // список id песен
+	DeletedAt *time.Time         `json:"DeletedAt,omitempty" bson:"DeletedAt,omitempty"` // когда плейлист перемещен в корзину
+}
+
+// PlaylistPosition - позиция песни в плейлисте. Запоминается при удалении песни в корзину,
+// чтобы при восстановлении вернуть песню на прежнее место.
+type PlaylistPosition struct {
+	Playlist primitive.ObjectID `bson:"Playlist"` // ID плейлиста
+	Index    int                `bson:"Index"`    // индекс песни в PlayList.IDs
+}
+
+// Waveform - пики формы волны песни для отрисовки в веб-плеере. Хранится в БД.
+type Waveform struct {
+	ID              primitive.ObjectID `bson:"_id"`             // ID песни
+	SampleRate      int                `bson:"SampleRate"`      // частота дискретизации песни
+	SamplesPerPixel int                `bson:"SamplesPerPixel"` // количество отсчетов в одном окне
+	Data            []byte             `bson:"Data"`            // пары min, max по окнам (8-битные числа со знаком)
+}
+
+// Fingerprint - акустический отпечаток песни для поиска дубликатов. Хранится в БД.
+type Fingerprint struct {
+	ID       primitive.ObjectID `bson:"_id"`      // ID песни
+	Duration int                `bson:"Duration"` // продолжительность песни в секундах, по ней отбираются кандидаты
+	Data     []byte             `bson:"Data"`     // 32-битные суботпечатки в little endian
+}
+
+// Blob - файл в хранилище, адресуемый по SHA-256 содержимого. Хранится в БД.
+type Blob struct {
+	Hash     string `bson:"_id"`      // SHA-256 содержимого
+	Size     int64  `bson:"Size"`     // размер в байтах
+	RefCount int    `bson:"RefCount"` // количество песен, ссылающихся на блоб
+}
+
+// JournalEntry - запись журнала о начатом изменении песни и ее файла в хранилище.
+// Удаляется, когда изменение завершено. Хранится в БД.
+type JournalEntry struct {
+	ID        primitive.ObjectID `bson:"_id"`       // ID записи журнала
+	Operation string             `bson:"Operation"` // upload, delete или rewrite
+	Song      primitive.ObjectID `bson:"Song"`      // ID песни
+	Blob      string             `bson:"Blob"`      // блоб, на который песня ссылается после изменения
+	OldBlob   string             `bson:"OldBlob"`   // блоб, на который песня ссылалась до изменения, пустой - файл под ID песни
+	CreatedAt time.Time          `bson:"CreatedAt"` // когда изменение начато
+}
+
+// SongDownloads - количество загрузок песни за период
+type SongDownloads struct {
+	Song  primitive.ObjectID `bson:"_id"`   // ID песни
+	Count int64              `bson:"Count"` // количество загрузок
+}
+
+// DownloadEvent - одна загрузка песни. Хранится в БД, пока попадает в окна чартов.
+type DownloadEvent struct {
+	ID   primitive.ObjectID `bson:"_id"`  // ID события
+	Song primitive.ObjectID `bson:"Song"` // ID песни
+	Time time.Time          `bson:"Time"` // время загрузки
+}
+
+// PlayEvent - прослушивание песни, о котором сообщил клиент. Хранится в БД в течение срока хранения событий.
+type PlayEvent struct {
+	ID        primitive.ObjectID `bson:"_id"`       // ID события
+	Song      primitive.ObjectID `bson:"Song"`      // ID песни
+	Client    string             `bson:"Client"`    // идентификатор клиента (приложения или устройства)
+	Position  int                `bson:"Position"`  // до какой секунды песня была прослушана
+	Completed bool               `bson:"Completed"` // дослушана ли песня до конца
+	Counted   bool               `bson:"Counted"`   // засчитано ли прослушивание в CountOfPlays песни
+	Time      time.Time          `bson:"Time"`      // время сообщения о прослушивании
 }
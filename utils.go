@@ -1,27 +1,39 @@
 package main
 
 import (
-	"archive/zip"
-	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
-	"strconv"
+	"path/filepath"
+	"sort"
 	"strings"
-
-	mgo "gopkg.in/mgo.v2"
-	"gopkg.in/mgo.v2/bson"
+	"time"
+
+	"github.com/STEJLS/AudioServer/XMLconfig"
+	"github.com/STEJLS/AudioServer/analysis"
+	"github.com/STEJLS/AudioServer/flac"
+	"github.com/STEJLS/AudioServer/i18n"
+	"github.com/STEJLS/AudioServer/mp3"
+	"github.com/STEJLS/AudioServer/storage"
+	"github.com/STEJLS/AudioServer/wav"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // InitFlags - инициализирует флаги командной строки
 func InitFlags() {
 	flag.StringVar(&logSource, "log_source", "log.txt", "Source for log file")
 	flag.StringVar(&configSource, "config_source", "config.xml", "Source for config file")
+	flag.BoolVar(&migrateStorageOnly, "migrate_storage", false, "Move song files named by id to content-addressed blobs and exit")
 	flag.Parse()
 }
 
@@ -29,7 +41,7 @@ func InitFlags() {
 func InitLogger(destination string) *os.File {
 	logfile, err := os.OpenFile(logSource, os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
-		log.Fatalln("Ошибка. Файл логов (%q) не открылся: ", logSource, err)
+		i18n.Fatal("log.open_error", logSource, err)
 	}
 
 	log.SetOutput(logfile)
@@ -38,95 +50,327 @@ func InitLogger(destination string) *os.File {
 	return logfile
 }
 
-// connectToDB - устанавливет соединение с БД и инициализирует глобальные переменные
-func connectToDB(host string, port int, DBName string) {
+// connectToDB - подключается к БД выбранного в конфиге типа и инициализирует глобальные
+// переменные хранилищ записей. Возвращает функцию, закрывающую соединение.
+func connectToDB(config XMLconfig.DataBase) (repositories, func()) {
+	switch config.Driver {
+	case "sqlite":
+		return connectToSQLite(config.Path)
+	default:
+		return connectToMongo(config)
+	}
+}
+
+// initStorage - создает хранилище файлов песен с выбранным в конфиге драйвером
+func initStorage(config XMLconfig.Storage) storage.Storage {
+	var files storage.Storage
 	var err error
-	audioDBsession, err = mgo.Dial(fmt.Sprintf("mongodb://%v:%v", host, port))
+	switch config.Driver {
+	case "s3":
+		files, err = storage.NewS3(config.Endpoint, config.Bucket, config.Region, config.AccessKey, config.SecretKey)
+	default:
+		directory := config.Directory
+		if directory == "" {
+			directory = storageDirectory
+		}
+		files, err = storage.NewLocal(directory)
+	}
 	if err != nil {
-		log.Fatalln(fmt.Sprintf("Фатал. При подключении к серверу БД(%v:%v): ", host, port) + err.Error())
+		i18n.Fatal("storage.init_error", err)
 	}
-	songsColl = audioDBsession.DB(DBName).C("Songs")
-	playListsColl = audioDBsession.DB(DBName).C("Playlists")
 
-	log.Printf("Инфо. Подключение к базе данных установлено.")
+	i18n.Info("storage.initialized", config.Driver)
+
+	return files
+}
+
+// Ошибки приема загружаемого файла
+var (
+	errNoUploadFile   = errors.New("Файл не найден в форме")
+	errUploadTooLarge = errors.New("Загружаемый файл слишком большой")
+)
+
+// uploadedFile - принятый от пользователя файл, записанный во временный файл хранилища
+type uploadedFile struct {
+	*storage.Upload
+	name string // имя файла пользователя
+	size int64  // размер в байтах
+	hash string // SHA-256 содержимого, по нему файл будет сохранен в хранилище
 }
 
-// saveFile - сохраняет копию обрабатываемой песни на диске под именем newFileName,
-// если все прошло успешно, то возвращает nil, в противном случае объект ошибки
-func saveFile(readSeeker io.ReadSeeker, newFileName string) error {
-	_, err := readSeeker.Seek(0, os.SEEK_SET)
+// receiveUpload - находит в multipart теле запроса файл из поля formFileName и потоково
+// записывает его во временный файл хранилища files, попутно вычисляя хэш содержимого.
+// Тело запроса должно быть ограничено http.MaxBytesReader.
+func receiveUpload(files storage.Storage, r *http.Request) (*uploadedFile, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errNoUploadFile
+	}
+
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err == io.EOF {
+			return nil, errNoUploadFile
+		}
+		if err != nil {
+			return nil, uploadError(err)
+		}
+
+		if part.FormName() == formFileName && part.FileName() != "" {
+			break
+		}
+		part.Close()
+	}
+	defer part.Close()
+
+	upload, err := storage.NewUpload(files)
+	if err != nil {
+		i18n.Error("upload.temp_error", err)
+		return nil, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(upload, hash), part)
+	if err != nil {
+		upload.Discard()
+		return nil, uploadError(err)
+	}
+
+	return &uploadedFile{
+		Upload: upload,
+		name:   filepath.Base(part.FileName()),
+		size:   size,
+		hash:   hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// uploadError - отличает превышение максимального размера загрузки от прочих ошибок чтения
+func uploadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errUploadTooLarge
+	}
+
+	i18n.Error("upload.read_error", err)
+	return err
+}
+
+// errUnsupportedFormat - формат файла не поддерживается декодерами
+var errUnsupportedFormat = errors.New("Данный формат не поддерживается")
+
+// newAudioSource - создает декодер для файла с расширением ext
+func newAudioSource(readSeeker io.ReadSeeker, ext string) (analysis.Source, error) {
+	switch strings.ToLower(ext) {
+	case ".mp3":
+		decoder, err := mp3.NewDecoder(readSeeker)
+		if err != nil {
+			return nil, err
+		}
+		return decoder, nil
+	case ".flac":
+		decoder, err := flac.NewDecoder(readSeeker)
+		if err != nil {
+			return nil, err
+		}
+		return decoder, nil
+	case ".wav":
+		decoder, err := wav.NewDecoder(readSeeker)
+		if err != nil {
+			return nil, err
+		}
+		return decoder, nil
+	}
+
+	return nil, errUnsupportedFormat
+}
+
+// analyzeAudio - декодирует песню, вычисляет ее громкость по EBU R128, истинный пик,
+// ReplayGain, форму волны и акустический отпечаток. Громкость записывается в song,
+// форма волны и отпечаток возвращаются. Ошибки анализа только логируются (возвращаются nil),
+// так как не должны мешать добавлению песни.
+func analyzeAudio(readSeeker io.ReadSeeker, ext string, song *SongInfo) (*Waveform, *Fingerprint) {
+	source, err := newAudioSource(readSeeker, ext)
+	if err != nil {
+		i18n.Error("analyze_audio.decoder_error", err)
+		return nil, nil
+	}
+
+	result, err := analysis.Analyze(source, waveformSamplesPerPixel)
+	if err != nil {
+		i18n.Error("analyze_audio.error", err)
+		return nil, nil
+	}
+
+	setTrackLoudness(song, result.Loudness)
+
+	i18n.Info("analyze_audio.done",
+		song.Loudness, song.TruePeak, result.Waveform.Length(), len(result.Fingerprint))
+
+	return newWaveform(song.ID, result.Waveform), newFingerprint(song.ID, song.Duration, result.Fingerprint)
+}
+
+// setTrackLoudness - переносит результаты анализа громкости трека в song
+func setTrackLoudness(song *SongInfo, loudness *analysis.Loudness) {
+	song.Loudness = loudness.Integrated
+	song.TruePeak = loudness.TruePeak
+	song.TrackGain = loudness.TrackGain
+	song.TrackPeak = loudness.TrackPeak
+	song.IsAnalyzed = true
+}
+
+// rewriteStoredFile - копирует файл песни во временный файл, изменяет его функцией edit
+// (например, записывает тэги) и сохраняет результат в хранилище как новый блоб.
+// Старый блоб освобождается, если на него больше нет ссылок. Замена записывается в журнал,
+// а песня переводится на новый блоб.
+func (store *blobStore) rewriteStoredFile(song *SongInfo, edit func(fileName string) error) error {
+	file, err := store.files.Get(songFileName(song))
+	if err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile("", "rewrite-*")
+	if err != nil {
+		file.Close()
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = io.Copy(temp, file)
+	file.Close()
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = edit(temp.Name())
+	if err != nil {
+		return err
+	}
+
+	temp, err = os.Open(temp.Name())
+	if err != nil {
+		return err
+	}
+	defer temp.Close()
+
+	hash, size, err := hashContent(temp)
 	if err != nil {
-		log.Println("Ошибка. Выход из запроса: не удалось перейти на начало копируемого файла: " + err.Error())
 		return err
 	}
-	data, err := ioutil.ReadAll(readSeeker)
+	if hash == song.Blob {
+		return nil
+	}
+
+	journalID, err := store.beginJournal(journalRewrite, song.ID, hash, song.Blob)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	_, err = store.acquireBlob(ctx, hash, size, func() error {
+		_, err := temp.Seek(0, os.SEEK_SET)
+		if err != nil {
+			return err
+		}
+
+		return store.files.Put(blobName(hash), temp, size)
+	})
 	if err != nil {
-		log.Println("Ошибка. Выход из запроса: не удалось прочитать файл пользователя: " + err.Error())
+		// Запись журнала остается, и ссылки на блоб пересчитываются при следующем запуске
+		store.finishJournal(journalID, err)
 		return err
 	}
 
-	err = ioutil.WriteFile(newFileName, data, 0666)
+	err = store.songs.SetBlob(ctx, song.ID, hash)
 	if err != nil {
-		log.Println("Ошибка. Выход из запроса: не удалось создать новый файл: " + err.Error())
+		store.finishJournal(journalID, store.releaseBlob(hash))
 		return err
 	}
 
-	log.Println("Инфо. Файл сохранен на диске")
+	// Песня уже ссылается на новый файл, поэтому ошибка освобождения старого
+	// не прерывает изменение: старый файл будет освобожден при следующем запуске
+	store.finishJournal(journalID, store.releaseSongFile(song))
+	song.Blob = hash
+
 	return nil
 }
 
-// removeFile - удаляет файл и обрабатывает возможные ошибки
-func removeFile(fileName string) {
-	err := os.Remove(fileName)
+// CheckExistMetaInDB - проверяет на существование в БД переданных метаданных
+// если такие данные есть, то возвращает ID найденной песни, иначе пустой ID
+func CheckExistMetaInDB(ctx context.Context, songs SongRepository, mataData *SongInfo) (primitive.ObjectID, error) {
+	id, err := songs.FindDuplicate(ctx, mataData)
 	if err != nil {
-		log.Println("Ошибка. При удалении файла: " + err.Error())
+		i18n.Error("upload.duplicate_find_error", err)
+		return primitive.NilObjectID, err
 	}
+
+	return id, nil
 }
 
-// CheckExistMetaInDB - проверяет на существование в БД переданных метаданных
-// если такие данные есть, то возвращает true , инача false
-func CheckExistMetaInDB(mataData *SongInfo) (bool, error) {
-	n, err := songsColl.Find(bson.M{"Title": mataData.Title,
-		"Artist":   mataData.Artist,
-		"Genre":    mataData.Genre,
-		"Bitrate":  mataData.Bitrate,
-		"Duration": mataData.Duration,
-		"Size":     mataData.Size,
-	}).Count()
+// getFormObjectID - извлекает из запроса переменную id и проверяет, что это primitive.ObjectID.
+// В случае ошибки отвечает клиенту и возвращает false.
+func getFormObjectID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	id := r.FormValue("id")
 
-	if err != nil {
-		log.Println("Ошибка.Выход из запроса: при поиске записи в БД: " + err.Error())
-		return false, err
+	if id == "" {
+		i18n.Info("request.missing_id")
+		writeError(w, r, http.StatusBadRequest, codeMissingParameter, errorDetails{"parameter": "id"})
+		return primitive.NilObjectID, false
 	}
 
-	if n == 0 {
-		return false, nil
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		i18n.Info("request.invalid_id", id)
+		writeError(w, r, http.StatusBadRequest, codeInvalidID, nil)
+		return primitive.NilObjectID, false
 	}
 
-	return true, nil
+	return objectID, true
 }
 
-// getCountOfMetadata - пытается извлечь переменную с именем count и возвращает его если оно корректно,
-// в противном случае возвращается значение по умолчанию
-func getCountOfMetadata(r *http.Request) int {
-	strCount := r.FormValue("count")
-
-	if strCount == "" {
-		log.Printf("Инфо. Количетсво запрашиваемых записей не указано, отдаю стандартное кол-во: %v", defaultCountMatadataForUpload)
-		return defaultCountMatadataForUpload
+// popularSince - страница песен с наибольшим количеством загрузок за последние days суток (включая
+// текущие), по убыванию количества загрузок. Страница начинается со смещения из курсора after.
+// Песни из корзины пропускаются, поэтому на странице может быть меньше limit песен.
+func popularSince(ctx context.Context, songs SongRepository, stats StatsRepository, days int, after *pageCursor, limit int) (*songPage, error) {
+	offset := 0
+	if after != nil {
+		offset = after.Offset
 	}
 
-	count, err := strconv.Atoi(strCount)
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	downloads, err := stats.TopDownloads(ctx, since, offset+limit+1)
 	if err != nil {
-		log.Println("Ошибка. Не получилось преобрахзовать введенное кол-во в число: " + err.Error())
-		return defaultCountMatadataForUpload
+		return nil, err
+	}
+
+	page := &songPage{Items: []SongInfo{}}
+	if len(downloads) > offset+limit {
+		downloads = downloads[:offset+limit]
+		page.NextCursor = encodeCursor(&pageCursor{Sort: sortDownloads, Descending: true, Days: days, Offset: offset + limit})
+	}
+	if len(downloads) <= offset {
+		return page, nil
 	}
+	downloads = downloads[offset:]
 
-	if count < 0 {
-		return defaultCountMatadataForUpload
+	ids := make([]primitive.ObjectID, len(downloads))
+	rank := make(map[primitive.ObjectID]int, len(downloads))
+	for i := range downloads {
+		ids[i] = downloads[i].Song
+		rank[downloads[i].Song] = i
 	}
 
-	return count
+	result, err := songs.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool { return rank[result[i].ID] < rank[result[j].ID] })
+	page.Items = append(page.Items, result...)
+	return page, nil
 }
 
 // NormalizeMetadata - проверяет пустые ли поля исполнитель и название
@@ -155,78 +399,164 @@ func tryParseTitleAndArtistFromFileName(song *SongInfo, ext string) {
 	}
 }
 
-// serveSongsInZIP - отдает на скачивание песни, упакованные в zip архив.
-func serveSongsInZIP(ids []bson.ObjectId, fileName string, w http.ResponseWriter) {
-	var result []SongInfo
-
-	err := songsColl.Find(bson.M{"_id": bson.M{"$in": ids}}).All(&result)
+// serveSongsInZIP - отдает на скачивание песни, упакованные в zip архив. Архив не собирается
+// в памяти, а записывается прямо в ответ, поэтому его размер не ограничен памятью сервера.
+func (h *handlers) serveSongsInZIP(ids []primitive.ObjectID, fileName string, w http.ResponseWriter, r *http.Request) {
+	result, err := h.songs.FindByIDs(r.Context(), ids)
 	if err != nil {
-		log.Println("Ошибка. При поиске песен в БД: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("songs_zip.db_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
 	if len(result) == 0 {
-		log.Println("Ошибка. Ни одна песня из полученного массива id не найдена в бд: " + err.Error())
-		http.Error(w, "Указанные песни не найдены", http.StatusBadRequest)
+		i18n.Error("songs_zip.songs_not_found")
+		writeError(w, r, http.StatusBadRequest, codeSongsNotFound, nil)
 		return
 	}
 
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
-
-	for _, song := range result {
-		data, err := ioutil.ReadFile(storageDirectory + song.ID.Hex())
-		if err != nil {
-			log.Printf("Ошибка. При чтении файла c диска id = %v ошибка: %v", song.ID.Hex(), err.Error())
-			continue
-		}
+	entries := h.songZipEntries(result)
+	if len(entries) == 0 {
+		i18n.Error("songs_zip.no_files")
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
+		return
+	}
 
-		fileWriter, err := zipWriter.Create(song.FileName)
-		if err != nil {
-			log.Println("Ошибка. При создании нового файла в архиве ошибка: " + err.Error())
-			continue
-		}
+	h.serveZIP(entries, fileName, w, r)
+}
 
-		_, err = fileWriter.Write(data)
-		if err != nil {
-			log.Println("Ошибка. При записи в файл архива: " + err.Error())
-			continue
-		}
+// serveZIP - потоково отдает архив из файлов entries и увеличивает количество загрузок песен,
+// попавших в архив. Если клиент отключается, то запись архива прекращается, а загрузки не считаются.
+func (h *handlers) serveZIP(entries []zipEntry, fileName string, w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Disposition", "filename=\""+fileName+"\"")
+	w.Header().Add("Content-type", "application/zip")
+	if size := zipSize(entries); size >= 0 {
+		w.Header().Add("Content-Length", fmt.Sprintf("%v", size))
 	}
-	err = zipWriter.Close()
+
+	err := writeZip(r.Context(), w, entries)
 	if err != nil {
-		log.Println("Ошибка. При закрытии архива: " + err.Error())
+		// Заголовки уже отправлены, поэтому клиенту остается только оборванный архив
+		i18n.Error("zip.incomplete", err)
+		return
 	}
+	i18n.Info("zip.sent")
 
-	w.Header().Add("Content-Disposition", "filename=\""+fileName+"\"")
-	w.Header().Add("Content-type", "application/zip")
-	w.Header().Add("Content-Length", fmt.Sprintf("%v", buf.Len()))
+	h.countDownloads(deliveredSongs(entries))
+}
 
-	_, err = w.Write(buf.Bytes())
+// countDownloads - атомарно увеличивает общие и посуточные счетчики загрузок песен ids
+// и сохраняет события загрузок для чартов.
+// Песни уже отправлены, поэтому счетчики обновляются и после отключения клиента.
+func (h *handlers) countDownloads(ids []primitive.ObjectID) {
+	ctx := context.Background()
+
+	err := h.songs.IncrementDownloads(ctx, ids)
 	if err != nil {
-		log.Println("Ошибка. При отдачи метоинформации: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("downloads.increment_error", err)
 		return
 	}
-	log.Println("Инфо. Песни в формате zip успешно отправлены")
 
-	_, err = songsColl.UpdateAll(bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$inc": bson.M{"CountOfDownload": 1}})
+	now := time.Now().UTC()
+	err = h.stats.AddDownloads(ctx, ids, now.Truncate(24*time.Hour))
 	if err != nil {
-		log.Println("Ошибка. При инкременте поля CountOfDownload: " + err.Error())
-	} else {
-		log.Println("Инфо. Успешно увеличино кол-во загрузок для скачиваемых песен")
+		i18n.Error("downloads.stats_error", err)
+		return
+	}
+
+	events := make([]DownloadEvent, len(ids))
+	for i, id := range ids {
+		events[i] = DownloadEvent{ID: primitive.NewObjectID(), Song: id, Time: now}
+	}
+	err = h.downloadEvents.Insert(ctx, events)
+	if err != nil {
+		i18n.Error("downloads.events_error", err)
+		return
+	}
+
+	i18n.Info("downloads.counted")
+}
+
+// deliveryWriter - запоминает статус ответа и количество отправленных байт,
+// чтобы после http.ServeContent узнать, получил ли клиент файл
+type deliveryWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+// WriteHeader - запоминает статус ответа
+func (dw *deliveryWriter) WriteHeader(status int) {
+	dw.status = status
+	dw.ResponseWriter.WriteHeader(status)
+}
+
+// Write - считает отправленные байты тела ответа
+func (dw *deliveryWriter) Write(p []byte) (int, error) {
+	if dw.status == 0 {
+		dw.status = http.StatusOK
+	}
+
+	n, err := dw.ResponseWriter.Write(p)
+	dw.written += int64(n)
+	return n, err
+}
+
+// delivered - дошел ли до клиента конец файла размером size: весь файл целиком
+// или, при докачке, последний диапазон файла
+func (dw *deliveryWriter) delivered(size int64) bool {
+	switch dw.status {
+	case http.StatusOK:
+		return dw.written == size
+	case http.StatusPartialContent:
+		var first, last, total int64
+		_, err := fmt.Sscanf(dw.Header().Get("Content-Range"), "bytes %d-%d/%d", &first, &last, &total)
+		return err == nil && last == size-1 && dw.written == last-first+1
 	}
+
+	return false
+}
+
+// songZipEntries - готовит файлы песен к записи в архив. Размеры файлов берутся
+// из хранилища заранее, песни без файла в хранилище пропускаются.
+func (h *handlers) songZipEntries(songs []SongInfo) []zipEntry {
+	var entries []zipEntry
+	var names []string
+	for i := range songs {
+		song := &songs[i]
+		name := songFileName(song)
+		info, err := h.files.Stat(name)
+		if err != nil {
+			i18n.Error("zip.stat_error", name, err)
+			continue
+		}
+
+		entries = append(entries, zipEntry{
+			size:     info.Size,
+			modified: song.UploadDate,
+			song:     song.ID,
+			open: func() (io.ReadCloser, error) {
+				return h.files.Get(name)
+			},
+		})
+		names = append(names, song.FileName)
+	}
+
+	for i, name := range uniqueNames(names) {
+		entries[i].name = name
+	}
+
+	return entries
 }
 
 // jsonIDsToSliceObjectIDs - принимает на вход строку, в которой записан массив строк в формате json,
 // делает анмаршаллинг json'а и проверяет каждую строку,
-// является ли она bson.ObjectId, и в случае успешнйо проверки добавляет ее к возвращаемому массиву
-func jsonIDsToSliceObjectIDs(jsonIDs string) []bson.ObjectId {
+// является ли она primitive.ObjectID, и в случае успешнйо проверки добавляет ее к возвращаемому массиву
+func jsonIDsToSliceObjectIDs(jsonIDs string) []primitive.ObjectID {
 	var ids []string
 	err := json.Unmarshal([]byte(jsonIDs), &ids)
 	if err != nil {
-		log.Println("Ошибка. При анмаршалинге json: " + err.Error())
+		i18n.Error("request.invalid_json_ids", err)
 		return nil
 	}
 
@@ -234,12 +564,12 @@ func jsonIDsToSliceObjectIDs(jsonIDs string) []bson.ObjectId {
 }
 
 // makeSliceSliceObjectIDs - принимает на вход массив строк, проверяет каждую строку,
-// является ли она bson.ObjectId, и в случае успешнйо проверки добавляет ее к возвращаемому массиву.
-func makeSliceSliceObjectIDs(ids []string) []bson.ObjectId {
-	arr := make([]bson.ObjectId, 0, len(ids))
+// является ли она primitive.ObjectID, и в случае успешнйо проверки добавляет ее к возвращаемому массиву.
+func makeSliceSliceObjectIDs(ids []string) []primitive.ObjectID {
+	arr := make([]primitive.ObjectID, 0, len(ids))
 	for _, id := range ids {
-		if bson.IsObjectIdHex(id) {
-			arr = append(arr, bson.ObjectIdHex(id))
+		if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+			arr = append(arr, objectID)
 		}
 	}
 
@@ -248,22 +578,28 @@ func makeSliceSliceObjectIDs(ids []string) []bson.ObjectId {
 
 //serveContent - принимает на вход данные, переводит их в формат json и пишет их в ResponseWriter
 func serveContent(inData interface{}, w http.ResponseWriter, r *http.Request) {
+	serveContentWithStatus(inData, http.StatusOK, w, r)
+}
+
+// serveContentWithStatus - то же, что serveContent, но с кодом ответа status
+func serveContentWithStatus(inData interface{}, status int, w http.ResponseWriter, r *http.Request) {
 	data, err := json.Marshal(inData)
 	if err != nil {
-		log.Println("Ошибка. При маршалинге в json результата: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("response.marshal_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 		return
 	}
 
 	w.Header().Add("Content-type", "application/json;")
+	w.WriteHeader(status)
 
 	_, err = w.Write(data)
 	if err != nil {
-		log.Println("Ошибка. При отдачи метоинформации: " + err.Error())
-		http.Error(w, "Неполадки на сервере, повторите попытку позже", http.StatusInternalServerError)
+		i18n.Error("response.write_error", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, nil)
 	}
 
-	log.Println("Инфо. Отдача метаданных успешно закончена")
+	i18n.Info("response.sent")
 }
 
 // advancedSearch - примает на вход массив слов, по которому будет осуществляться
@@ -0,0 +1,15 @@
+package wav
+
+const (
+	riffHeaderSize  int = 12   // Размер заголовка RIFF: "RIFF", размер, "WAVE"
+	chunkHeaderSize int = 8    // Размер заголовка блока: идентификатор и размер
+	minFormatSize   int = 16   // Минимальный размер блока "fmt "
+	framesPerRead   int = 4096 // Количество отсчетов на канал, отдаваемых декодером за раз
+)
+
+// Коды формата отсчетов в блоке "fmt "
+const (
+	formatPCM        uint16 = 1
+	formatFloat      uint16 = 3
+	formatExtensible uint16 = 0xFFFE
+)
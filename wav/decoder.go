@@ -0,0 +1,136 @@
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Decoder - декодер отсчетов PCM (8, 16, 24 и 32 бита) и IEEE float (32 и 64 бита) из wav
+type Decoder struct {
+	reader    *bufio.Reader
+	format    format
+	remaining int64 // сколько байт осталось в блоке data
+}
+
+// NewDecoder - конструктор для типа Decoder. Читает блок "fmt "
+// и устанавливает указатель на начало блока data.
+func NewDecoder(rs io.ReadSeeker) (*Decoder, error) {
+	decoder := new(Decoder)
+	var info *format
+	found := false
+	err := walkChunks(rs, func(c chunk) (bool, error) {
+		switch c.ID {
+		case "fmt ":
+			f, err := readFormat(rs, c)
+			info = f
+			return false, err
+		case "data":
+			decoder.remaining = c.Size
+			found = true
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil {
+		return nil, errNoFormat
+	}
+	if !found {
+		return nil, errNoData
+	}
+
+	switch {
+	case info.Code == formatPCM && info.BitsPerSample >= 1 && info.BitsPerSample <= 32:
+	case info.Code == formatFloat && (info.BitsPerSample == 32 || info.BitsPerSample == 64):
+	default:
+		return nil, errNotSupport
+	}
+	if info.BlockAlign < info.Channels*((info.BitsPerSample+7)/8) {
+		return nil, errBadFormat
+	}
+
+	decoder.format = *info
+	decoder.reader = bufio.NewReader(rs)
+
+	return decoder, nil
+}
+
+// SampleRate - возвращает частоту дискретизации в герцах
+func (d *Decoder) SampleRate() int {
+	return d.format.SampleRate
+}
+
+// Channels - возвращает количество каналов
+func (d *Decoder) Channels() int {
+	return d.format.Channels
+}
+
+// ReadFrame - читает очередную порцию отсчетов по каналам
+// в диапазоне [-1, 1]. В конце потока возвращает io.EOF.
+func (d *Decoder) ReadFrame() ([][]float64, error) {
+	align := int64(d.format.BlockAlign)
+	frames := d.remaining / align
+	if frames > int64(framesPerRead) {
+		frames = int64(framesPerRead)
+	}
+	if frames == 0 {
+		return nil, io.EOF
+	}
+
+	data := make([]byte, frames*align)
+	n, err := io.ReadFull(d.reader, data)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	// обрезанный файл декодируется до последнего целого кадра,
+	// а ошибки чтения из хранилища передаются вызывающему
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	frames = int64(n) / align
+	if frames == 0 {
+		return nil, io.EOF
+	}
+	d.remaining -= int64(n)
+
+	width := (d.format.BitsPerSample + 7) / 8
+	out := make([][]float64, d.format.Channels)
+	for ch := range out {
+		out[ch] = make([]float64, frames)
+		for i := range out[ch] {
+			offset := i*int(align) + ch*width
+			out[ch][i] = d.sample(data[offset : offset+width])
+		}
+	}
+
+	return out, nil
+}
+
+// sample - переводит один отсчет в значение от -1 до 1
+func (d *Decoder) sample(data []byte) float64 {
+	if d.format.Code == formatFloat {
+		if len(data) == 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(data))
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	}
+
+	// 8-битные отсчеты беззнаковые, остальные - знаковые little endian
+	if len(data) == 1 {
+		return float64(int(data[0])-128) / 128
+	}
+
+	var v int64
+	for i := len(data) - 1; i >= 0; i-- {
+		v = v<<8 | int64(data[i])
+	}
+	bits := uint(len(data) * 8)
+	v = v << (64 - bits) >> (64 - bits)
+
+	return float64(v) / float64(int64(1)<<(bits-1))
+}
@@ -0,0 +1,76 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// pcm16 - wav с одним каналом 16 бит и объявленным размером блока data
+func pcm16(dataSize uint32, samples int) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	for _, v := range []interface{}{
+		uint32(16), uint16(1), uint16(1), uint32(8000), uint32(16000), uint16(2), uint16(16),
+	} {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, dataSize)
+	buf.Write(make([]byte, samples*2))
+	return buf.Bytes()
+}
+
+// failingReader - отдает данные, а после них ошибку хранилища
+type failingReader struct {
+	*bytes.Reader
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func readAll(d *Decoder) (int, error) {
+	total := 0
+	for {
+		frame, err := d.ReadFrame()
+		if err != nil {
+			return total, err
+		}
+		total += len(frame[0])
+	}
+}
+
+func TestReadFrameTruncated(t *testing.T) {
+	decoder, err := NewDecoder(bytes.NewReader(pcm16(20000, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total, err := readAll(decoder)
+	if err != io.EOF || total != 100 {
+		t.Fatalf("got %d samples, %v; want 100, io.EOF", total, err)
+	}
+}
+
+func TestReadFrameStorageError(t *testing.T) {
+	errStorage := errors.New("connection reset")
+	decoder, err := NewDecoder(&failingReader{bytes.NewReader(pcm16(20000, 100)), errStorage})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = readAll(decoder)
+	if err != errStorage {
+		t.Fatalf("got %v, want %v", err, errStorage)
+	}
+}
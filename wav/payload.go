@@ -0,0 +1,30 @@
+package wav
+
+import (
+	"io"
+	"os"
+)
+
+// AudioData - возвращает смещение от начала файла и размер данных блока data
+func AudioData(rs io.ReadSeeker) (offset, size int64, err error) {
+	found := false
+	err = walkChunks(rs, func(c chunk) (bool, error) {
+		if c.ID != "data" {
+			return false, nil
+		}
+
+		offset, err = rs.Seek(0, os.SEEK_CUR)
+		size = c.Size
+		found = true
+		return true, err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !found {
+		return 0, 0, errNoData
+	}
+
+	return offset, size, nil
+}
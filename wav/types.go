@@ -0,0 +1,69 @@
+package wav
+
+import "fmt"
+
+type WavMeta struct {
+	Title    string // название песни
+	Artist   string // исполнитель
+	Album    string // альбом
+	Genre    string // жанр
+	Bitrate  int    // килобит в секунду
+	Duration int    // продолжительность песни в секундах
+}
+
+func (wavMeta WavMeta) String() string {
+	return fmt.Sprintf("title: '%v' \nartist: '%v' \ngenre:  '%v' \nBitrate:  '%v kbit/s' \nDuration:  '%v:%v'",
+		wavMeta.Title, wavMeta.Artist, wavMeta.Genre, wavMeta.Bitrate, wavMeta.Duration/60, wavMeta.Duration%60)
+}
+
+// GetTitle - возвращает название песни(Возвращет пустую строку если название песни неизвестно)
+func (wavMeta WavMeta) GetTitle() string {
+	return wavMeta.Title
+}
+
+// GetArtist - возвращает имя исполнителя(Возвращет пустую строку если имя исполнителя неизвестно)
+func (wavMeta WavMeta) GetArtist() string {
+	return wavMeta.Artist
+}
+
+// GetGenre - возвращает название жанра(Возвращет пустую строку если название жанра неизвестно)
+func (wavMeta WavMeta) GetGenre() string {
+	return wavMeta.Genre
+}
+
+// GetAlbum - возвращает название альбома(Возвращет пустую строку если название альбома неизвестно)
+func (wavMeta WavMeta) GetAlbum() string {
+	return wavMeta.Album
+}
+
+// GetAlbumArtist - возвращает исполнителя альбома. В блоке INFO такого поля нет,
+// поэтому всегда возвращается пустая строка
+func (wavMeta WavMeta) GetAlbumArtist() string {
+	return ""
+}
+
+// GetBitrate - возвращает битрейт в килобитах в секунду
+func (wavMeta WavMeta) GetBitrate() int {
+	return wavMeta.Bitrate
+}
+
+// GetDuration - возвращает продолжительность песни в секундах
+func (wavMeta WavMeta) GetDuration() int {
+	return wavMeta.Duration
+}
+
+// format - содержимое блока "fmt ", описывающее формат отсчетов
+type format struct {
+	Code          uint16 // код формата (PCM или IEEE float)
+	Channels      int    // количество каналов
+	SampleRate    int    // частота дискретизации в герцах
+	ByteRate      int    // байт в секунду
+	BlockAlign    int    // размер одного отсчета всех каналов в байтах
+	BitsPerSample int    // количество бит на отсчет
+}
+
+// chunk - заголовок блока RIFF
+type chunk struct {
+	ID   string // идентификатор из 4 символов
+	Size int64  // размер данных блока без заголовка и выравнивания
+}
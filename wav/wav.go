@@ -0,0 +1,178 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/STEJLS/AudioServer/i18n"
+)
+
+var (
+	errNotWave    = errors.New("Это не wav")
+	errNoFormat   = errors.New("Блок fmt не найден")
+	errNoData     = errors.New("Блок data не найден")
+	errBadFormat  = errors.New("Некорректный блок fmt")
+	errNotSupport = errors.New("Данный формат отсчетов wav не поддерживается")
+)
+
+// ParseMetadata - парсит метаданные wav.
+// Возвращает nil если это не wav файл.
+func ParseMetadata(rs io.ReadSeeker) *WavMeta {
+	meta := new(WavMeta)
+
+	var info *format
+	var dataSize int64
+	err := walkChunks(rs, func(c chunk) (bool, error) {
+		switch c.ID {
+		case "fmt ":
+			f, err := readFormat(rs, c)
+			info = f
+			return false, err
+		case "data":
+			dataSize = c.Size
+		case "LIST":
+			parseInfoList(rs, c, meta)
+		}
+		return false, nil
+	})
+	if err != nil {
+		i18n.Error("wav.parse_error", err)
+		return nil
+	}
+
+	if info == nil || info.ByteRate == 0 {
+		i18n.Error("wav.parse_error", errNoFormat)
+		return nil
+	}
+
+	meta.Duration = round(float64(dataSize) / float64(info.ByteRate))
+	meta.Bitrate = info.ByteRate * 8 / 1000
+
+	return meta
+}
+
+// walkChunks - перебирает блоки файла RIFF WAVE и для каждого вызывает handle.
+// Перед вызовом указатель установлен на начало данных блока. Если handle
+// возвращает true, то перебор останавливается, а указатель остается там, где его оставил handle.
+func walkChunks(rs io.ReadSeeker, handle func(c chunk) (bool, error)) error {
+	_, err := rs.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, riffHeaderSize)
+	_, err = io.ReadFull(rs, header)
+	if err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return errNotWave
+	}
+
+	offset := int64(riffHeaderSize)
+	buf := make([]byte, chunkHeaderSize)
+	for {
+		_, err = io.ReadFull(rs, buf)
+		if err != nil {
+			return nil // конец файла
+		}
+
+		c := chunk{ID: string(buf[0:4]), Size: int64(binary.LittleEndian.Uint32(buf[4:8]))}
+		stop, err := handle(c)
+		if err != nil || stop {
+			return err
+		}
+
+		// Данные блока выравниваются до четного размера
+		offset += int64(chunkHeaderSize) + c.Size + c.Size&1
+		_, err = rs.Seek(offset, os.SEEK_SET)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readFormat - читает и проверяет блок "fmt "
+func readFormat(r io.Reader, c chunk) (*format, error) {
+	if c.Size < int64(minFormatSize) || c.Size > 1024 {
+		return nil, errBadFormat
+	}
+
+	data := make([]byte, c.Size)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &format{
+		Code:          binary.LittleEndian.Uint16(data[0:2]),
+		Channels:      int(binary.LittleEndian.Uint16(data[2:4])),
+		SampleRate:    int(binary.LittleEndian.Uint32(data[4:8])),
+		ByteRate:      int(binary.LittleEndian.Uint32(data[8:12])),
+		BlockAlign:    int(binary.LittleEndian.Uint16(data[12:14])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(data[14:16])),
+	}
+
+	// В WAVE_FORMAT_EXTENSIBLE настоящий код формата - первые 2 байта GUID подформата
+	if f.Code == formatExtensible {
+		if len(data) < 26 {
+			return nil, errBadFormat
+		}
+		f.Code = binary.LittleEndian.Uint16(data[24:26])
+	}
+
+	if f.Channels == 0 || f.SampleRate == 0 || f.BlockAlign == 0 {
+		return nil, errBadFormat
+	}
+
+	return f, nil
+}
+
+// parseInfoList - парсит блок LIST типа INFO и извлекает Title, Artist, Album, Genre
+func parseInfoList(r io.Reader, c chunk, meta *WavMeta) {
+	if c.Size < 4 || c.Size > 1<<20 {
+		return
+	}
+
+	data := make([]byte, c.Size)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		i18n.Error("wav.list_read_error", err)
+		return
+	}
+
+	if string(data[0:4]) != "INFO" {
+		return
+	}
+
+	for pointer := 4; pointer+chunkHeaderSize <= len(data); {
+		id := string(data[pointer : pointer+4])
+		length := int(binary.LittleEndian.Uint32(data[pointer+4 : pointer+8]))
+		pointer += chunkHeaderSize
+		if length > len(data)-pointer {
+			break
+		}
+
+		value := strings.TrimRight(string(data[pointer:pointer+length]), "\u0000 ")
+		switch id {
+		case "INAM":
+			meta.Title = strings.TrimSpace(value)
+		case "IART":
+			meta.Artist = strings.TrimSpace(value)
+		case "IPRD":
+			meta.Album = strings.TrimSpace(value)
+		case "IGNR":
+			meta.Genre = strings.TrimSpace(value)
+		}
+
+		pointer += length + length&1
+	}
+}
+
+func round(f float64) int {
+	if math.Abs(f) < 0.5 {
+		return 0
+	}
+	return int(f + math.Copysign(0.5, f))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/STEJLS/AudioServer/analysis"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Версия форматов audiowaveform и флаг 8-битных данных в двоичном формате
+const (
+	waveformFormatVersion int32  = 2
+	waveformFlag8Bit      uint32 = 1
+)
+
+// waveformJSON - форма волны в JSON формате утилиты audiowaveform
+type waveformJSON struct {
+	Version         int32  `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"`
+}
+
+// newWaveform - конструктор для типа Waveform, пики хранятся как байты
+func newWaveform(id primitive.ObjectID, peaks *analysis.Waveform) *Waveform {
+	data := make([]byte, len(peaks.Data))
+	for i, v := range peaks.Data {
+		data[i] = byte(v)
+	}
+
+	return &Waveform{
+		ID:              id,
+		SampleRate:      peaks.SampleRate,
+		SamplesPerPixel: peaks.SamplesPerPixel,
+		Data:            data,
+	}
+}
+
+// peaks - переводит хранимую форму волны обратно в пики со знаком
+func (waveform *Waveform) peaks() *analysis.Waveform {
+	data := make([]int8, len(waveform.Data))
+	for i, v := range waveform.Data {
+		data[i] = int8(v)
+	}
+
+	return &analysis.Waveform{
+		SampleRate:      waveform.SampleRate,
+		SamplesPerPixel: waveform.SamplesPerPixel,
+		Data:            data,
+	}
+}
+
+// newWaveformJSON - готовит пики к отдаче в JSON формате audiowaveform (каналы объединены)
+func newWaveformJSON(peaks *analysis.Waveform) *waveformJSON {
+	return &waveformJSON{
+		Version:         waveformFormatVersion,
+		Channels:        1,
+		SampleRate:      peaks.SampleRate,
+		SamplesPerPixel: peaks.SamplesPerPixel,
+		Bits:            8,
+		Length:          peaks.Length(),
+		Data:            peaks.Data,
+	}
+}
+
+// encodeWaveformBinary - кодирует пики в двоичный формат audiowaveform версии 2
+// (все поля заголовка little endian, данные - 8-битные пары min, max)
+func encodeWaveformBinary(peaks *analysis.Waveform) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, waveformFormatVersion)
+	binary.Write(buf, binary.LittleEndian, waveformFlag8Bit)
+	binary.Write(buf, binary.LittleEndian, int32(peaks.SampleRate))
+	binary.Write(buf, binary.LittleEndian, int32(peaks.SamplesPerPixel))
+	binary.Write(buf, binary.LittleEndian, uint32(peaks.Length()))
+	binary.Write(buf, binary.LittleEndian, int32(1)) // количество каналов
+	binary.Write(buf, binary.LittleEndian, peaks.Data)
+
+	return buf.Bytes()
+}